@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"github.com/donknap/dpanel/app/application/logic"
+	logic2 "github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/crontab"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+type BackupSchedule struct {
+	controller.Abstract
+}
+
+func (self BackupSchedule) Create(http *gin.Context) {
+	type ParamsValidate struct {
+		Id                   int32                            `json:"id"`
+		Title                string                           `json:"title" binding:"required"`
+		Expression           []accessor.CronSettingExpression `json:"expression" binding:"required,min=1"`
+		ContainerNames       []string                         `json:"containerNames"`
+		ComposeName          string                           `json:"composeName"`
+		EnableImage          bool                             `json:"enableImage"`
+		EnableImageContainer bool                             `json:"enableImageContainer"`
+		EnableVolume         bool                             `json:"enableVolume"`
+		VolumeList           []string                         `json:"volumeList"`
+		Retention            accessor.BackupRetention         `json:"retention"`
+		TargetName           string                           `json:"targetName"`
+		EncryptionName       string                           `json:"encryptionName"`
+		Incremental          bool                             `json:"incremental"`
+		Verify               bool                             `json:"verify"`
+		TestRestore          bool                             `json:"testRestore"`
+		HealthCommand        string                           `json:"healthCommand"`
+		Hook                 *accessor.BackupHook             `json:"hook"`
+		Disable              bool                             `json:"disable"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if function.IsEmptyArray(params.ContainerNames) && params.ComposeName == "" {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+		return
+	}
+	if err := (logic.ContainerBackupOption{
+		TargetName:     params.TargetName,
+		EncryptionName: params.EncryptionName,
+		Incremental:    params.Incremental,
+	}).Check(); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	if params.TargetName != "" {
+		if _, err := (logic2.BackupTarget{}).Get(params.TargetName); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	if params.EncryptionName != "" {
+		if _, err := (logic2.BackupEncryption{}).Get(params.EncryptionName); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	if _, _, err := (logic.ContainerBackup{}).GetDumpProfile(params.Hook, ""); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	err := crontab.Client.CheckExpression(function.PluckArrayWalk(params.Expression, func(item accessor.CronSettingExpression) (string, bool) {
+		return item.ToString(), true
+	})...)
+	if err != nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageContainerCronExpressionInCorrect, "message", err.Error()), 500)
+		return
+	}
+
+	// 编辑时标题不能与其它任务重复
+	titleQuery := dao.BackupSchedule.Where(dao.BackupSchedule.Title.Eq(params.Title))
+	if params.Id > 0 {
+		titleQuery = titleQuery.Where(dao.BackupSchedule.ID.Neq(params.Id))
+	}
+	if _, err := titleQuery.First(); err == nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonIdAlreadyExists, "name", params.Title), 500)
+		return
+	}
+
+	var taskRow *entity.BackupSchedule
+	dockerEnvName := docker.Sdk.Name
+	if params.Id > 0 {
+		taskRow, _ = dao.BackupSchedule.Where(dao.BackupSchedule.ID.Eq(params.Id)).First()
+		if taskRow == nil {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+			return
+		}
+		crontab.Client.RemoveJob(taskRow.Setting.JobIds...)
+		// 编辑时保留任务所属的环境，不随当前切换的环境变化
+		if taskRow.Setting.DockerEnvName != "" {
+			dockerEnvName = taskRow.Setting.DockerEnvName
+		}
+	} else {
+		taskRow = &entity.BackupSchedule{
+			Setting: &accessor.BackupScheduleSettingOption{},
+		}
+	}
+	taskRow.Title = params.Title
+	taskRow.Setting = &accessor.BackupScheduleSettingOption{
+		DockerEnvName:        dockerEnvName,
+		Disable:              params.Disable,
+		Expression:           params.Expression,
+		ContainerNames:       params.ContainerNames,
+		ComposeName:          params.ComposeName,
+		EnableImage:          params.EnableImage,
+		EnableImageContainer: params.EnableImageContainer,
+		EnableVolume:         params.EnableVolume,
+		VolumeList:           params.VolumeList,
+		Retention:            params.Retention,
+		TargetName:           params.TargetName,
+		EncryptionName:       params.EncryptionName,
+		Incremental:          params.Incremental,
+		Verify:               params.Verify || params.TestRestore,
+		TestRestore:          params.TestRestore,
+		HealthCommand:        params.HealthCommand,
+		Hook:                 params.Hook,
+		JobIds:               make([]cron.EntryID, 0),
+		LastRunAt:            taskRow.Setting.LastRunAt,
+		LastError:            taskRow.Setting.LastError,
+	}
+	if err = dao.BackupSchedule.Save(taskRow); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	if !params.Disable {
+		if jobIds, err := (logic.BackupSchedule{}).AddJob(taskRow); err == nil {
+			taskRow.Setting.JobIds = jobIds
+		}
+	}
+	if err = dao.BackupSchedule.Save(taskRow); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"id": taskRow.ID,
+	})
+	return
+}
+
+func (self BackupSchedule) GetList(http *gin.Context) {
+	type ParamsValidate struct {
+		Title string `json:"title"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	query := dao.BackupSchedule.Order(dao.BackupSchedule.ID.Desc()).Where(gen.Cond(
+		datatypes.JSONQuery("setting").Equals(docker.Sdk.Name, "dockerEnvName"),
+	)...)
+	if params.Title != "" {
+		query = query.Where(dao.BackupSchedule.Title.Like("%" + params.Title + "%"))
+	}
+	list, _ := query.Find()
+	for _, item := range list {
+		item.Setting.NextRunTime = crontab.Client.GetNextRunTime(item.Setting.JobIds...)
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list": list,
+	})
+	return
+}
+
+func (self BackupSchedule) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Id           []int32 `json:"id" binding:"required"`
+		DeleteBackup bool    `json:"deleteBackup"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list, _ := dao.BackupSchedule.Where(dao.BackupSchedule.ID.In(params.Id...)).Find()
+	for _, item := range list {
+		crontab.Client.RemoveJob(item.Setting.JobIds...)
+		if params.DeleteBackup {
+			backupList, _ := dao.Backup.Where(gen.Cond(
+				datatypes.JSONQuery("setting").Equals(item.ID, "scheduleId"),
+			)...).Find()
+			_ = logic.ContainerBackup{}.Delete(backupList)
+		}
+		_, _ = dao.BackupSchedule.Delete(item)
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+func (self BackupSchedule) RunOnce(http *gin.Context) {
+	type ParamsValidate struct {
+		Id int32 `json:"id" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if _, err := dao.BackupSchedule.Where(dao.BackupSchedule.ID.Eq(params.Id)).First(); err != nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+		return
+	}
+	// 备份耗时较长，在后台执行，结果记录在计划及备份列表中
+	go func() {
+		_ = logic.BackupSchedule{}.Run(params.Id)
+	}()
+	self.JsonSuccessResponse(http)
+	return
+}
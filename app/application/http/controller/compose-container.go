@@ -1,26 +1,20 @@
 package controller
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/donknap/dpanel/app/application/logic"
-	"github.com/donknap/dpanel/common/accessor"
 	"github.com/donknap/dpanel/common/dao"
-	"github.com/donknap/dpanel/common/entity"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/docker"
-	"github.com/donknap/dpanel/common/service/docker/imports"
 	"github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/service/notice"
-	"github.com/donknap/dpanel/common/service/plugin"
 	"github.com/donknap/dpanel/common/service/storage"
 	"github.com/donknap/dpanel/common/service/ws"
 	"github.com/donknap/dpanel/common/types/define"
@@ -43,8 +37,6 @@ func (self Compose) ContainerDeploy(http *gin.Context) {
 	if !self.Validate(http, &params) {
 		return
 	}
-	var err error
-
 	composeRow, _ := logic.Compose{}.Get(params.Id)
 	if composeRow == nil {
 		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
@@ -54,154 +46,28 @@ func (self Compose) ContainerDeploy(http *gin.Context) {
 	if !function.IsEmptyArray(params.DeployServiceName) {
 		composeRow.Setting.DeployServiceName = params.DeployServiceName
 	}
-	tasker, warning, err := logic.Compose{}.GetTasker(&entity.Compose{
-		Name: composeRow.Name,
-		Setting: &accessor.ComposeSettingOption{
-			Type:          composeRow.Setting.Type,
-			Uri:           composeRow.Setting.Uri,
-			RemoteUrl:     composeRow.Setting.RemoteUrl,
-			Environment:   params.Environment,
-			DockerEnvName: composeRow.Setting.DockerEnvName,
-			RunName:       composeRow.Setting.RunName,
-		},
-	})
-	if err != nil {
-		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageComposeParseYamlIncorrect, "error", errors.Join(warning, err).Error()), 500)
-		return
-	}
-
-	// 添加禁用服务，只有部署的时候需要，避免在获取详情时拿不到全部服务
-	if !function.IsEmptyArray(composeRow.Setting.DeployServiceName) {
-		services, err := tasker.Project.GetServices()
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
-		}
-		for _, item := range services {
-			if !function.InArray(composeRow.Setting.DeployServiceName, item.Name) {
-				tasker.Project = tasker.Project.WithServicesDisabled(item.Name)
-			}
-		}
-	}
-
-	// 尝试创建 compose 挂载的目录，如果运行在容器内创建也无效
-	if params.CreatePath {
-		for _, service := range tasker.Project.Services {
-			for _, volume := range service.Volumes {
-				if filepath.IsAbs(volume.Source) {
-					if _, err = os.Stat(volume.Source); err != nil {
-						_ = os.MkdirAll(volume.Source, os.ModePerm)
-					}
-				}
-			}
-		}
-	}
 	_ = notice.Message{}.Info(".composeDeploy", "name", composeRow.Name)
 
-	// 如果是远程连接，尝试将本地的 compose 目录数据同步到端
-	if function.InArray([]string{
-		define.DockerRemoteTypeSSH,
-		define.DockerRemoteTypeTcp,
-	}, docker.Sdk.DockerEnv.RemoteType) {
-		_, err := logic.Explorer{}.Afs(docker.Sdk, logic.AfsCreateOption{
-			MountPoint: plugin.ExplorerName,
-			Init:       true,
-		})
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
-		}
-		rel, err := filepath.Rel(storage.Local{}.GetStorageLocalPath(), tasker.Project.WorkingDir)
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
-		}
-		importRootPath := path.Join("/dpanel", filepath.ToSlash(rel))
-		slog.Debug("compose container sync path", "path", importRootPath)
-
-		importFileList, err := imports.NewFileImport(importRootPath, imports.WithImportPath(tasker.Project.WorkingDir))
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
-		}
-		defer func() {
-			importFileList.Close()
-		}()
-		err = docker.Sdk.ContainerImport(docker.Sdk.Ctx, plugin.ExplorerName, "/", importFileList.Reader())
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
-		}
-	}
-
 	progress := ws.NewProgressPip(fmt.Sprintf(ws.MessageTypeCompose, params.Id))
 	defer progress.Close()
 
-	var response io.ReadCloser
-	if params.Build {
-		response, err = tasker.Build()
-	} else {
-		response, err = tasker.Deploy(params.RemoveOrphans, params.PullImage)
-	}
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-
-	go func() {
-		<-progress.Done()
-		_ = response.Close()
-	}()
-
 	progress.OnWrite = func(p string) error {
 		progress.BroadcastMessage(p)
 		return nil
 	}
 
-	_, err = io.Copy(progress, response)
-	if err != nil {
-		// copy 出错的只记录日志，不提示用户
-		slog.Warn("compose container deploy copy", "error", err)
-	}
+	runCompose, err := logic.Compose{}.Deploy(progress.Context(), composeRow, logic.ComposeDeployOption{
+		Environment:   params.Environment,
+		CreatePath:    params.CreatePath,
+		RemoveOrphans: params.RemoveOrphans,
+		PullImage:     params.PullImage,
+		Build:         params.Build,
+	}, progress)
 	if err != nil {
-		if function.ErrorHasKeyword(err, "denied: You may not login") {
-			_ = notice.Message{}.Error(".imagePullInvalidAuth")
-		} else if function.ErrorHasKeyword(err, "Mounts denied") {
-			_ = notice.Message{}.Error(".containerMountPathDenied")
-		}
-		composeRow.Setting.Message = err.Error()
-		composeRow.Setting.Status = accessor.ComposeStatusError
-	} else {
-		composeRow.Setting.Message = ""
-		composeRow.Setting.Status = ""
-	}
-	if composeRow.ID > 0 {
-		_ = dao.Compose.Save(composeRow)
-	}
-
-	// 查看当前任务下的容器 hash 值是否部署成功
-	runCompose := logic.Compose{}.LsItem(composeRow.Name)
-	if runCompose == nil || len(runCompose.ContainerList) != len(tasker.Project.Services) {
-		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageComposeDeployIncorrect), 500)
+		self.JsonResponseWithError(http, err, 500)
 		return
 	}
 
-	for _, item := range runCompose.ContainerList {
-		if item.Container.State != container.StateRunning {
-			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageComposeDeployIncorrect), 500)
-			return
-		}
-	}
-
-	// 如果当前容器配置过转发，则加入 dpanel-local 网络
-	for _, item := range runCompose.ContainerList {
-		if row, err := dao.SiteDomain.Where(dao.SiteDomain.ContainerID.In(item.Container.Names...)).First(); err == nil {
-			_ = docker.Sdk.NetworkConnect(docker.Sdk.Ctx, types.NetworkItem{
-				Name: define.DPanelProxyNetworkName,
-			}, row.ContainerID)
-		}
-	}
-
 	// 这里需要单独适配一下 php 环境的相关扩展安装
 	// 目前只有 php 需要这样处理，暂时先直接进行判断
 	if strings.HasPrefix(composeRow.Setting.Store, define.StoreTypeOnePanel) && strings.HasSuffix(composeRow.Setting.Store, "@php") {
@@ -305,6 +171,7 @@ func (self Compose) ContainerDestroy(http *gin.Context) {
 				Compose: composeRow,
 				Ctx:     http,
 			})
+			_ = os.RemoveAll(logic.ComposeGit{}.RepoPath(composeRow))
 		}
 	} else {
 		composeRow.Setting.DeployServiceName = make([]string, 0)
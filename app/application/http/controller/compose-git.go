@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/donknap/dpanel/app/application/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/crontab"
+	"github.com/donknap/dpanel/common/service/docker"
+	types2 "github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/ws"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+// GitSave 为任务绑定 git 仓库，任务不存在时按名称创建，保存后同步一次仓库
+func (self Compose) GitSave(http *gin.Context) {
+	type ParamsValidate struct {
+		Id                string `json:"id"`
+		Name              string `json:"name"`
+		Url               string `json:"url" binding:"required"`
+		Branch            string `json:"branch"`
+		Path              string `json:"path"`
+		Interval          int    `json:"interval" binding:"omitempty,min=0"`
+		AutoDeploy        bool   `json:"autoDeploy"`
+		PullImage         bool   `json:"pullImage"`
+		ResetWebhookToken bool   `json:"resetWebhookToken"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+
+	var composeRow *entity.Compose
+	if params.Id != "" {
+		composeRow, _ = logic.Compose{}.Get(params.Id)
+		if composeRow == nil {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+			return
+		}
+		if !function.InArray([]string{
+			accessor.ComposeTypeStoragePath, accessor.ComposeTypeText, accessor.ComposeTypeRemoteUrl,
+		}, composeRow.Setting.Type) {
+			self.JsonResponseWithError(http, errors.New("only tasks in the compose storage path can bind a git repository"), 500)
+			return
+		}
+	} else {
+		if params.Name == "" {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+			return
+		}
+		dockerEnvName := define.DockerDefaultClientName
+		if docker.Sdk.DockerEnv.EnableComposePath {
+			dockerEnvName = docker.Sdk.DockerEnv.Name
+		}
+		composeRow, _ = dao.Compose.Where(dao.Compose.Name.Eq(params.Name)).Where(gen.Cond(
+			datatypes.JSONQuery("setting").Equals(dockerEnvName, "dockerEnvName"),
+		)...).First()
+		if composeRow == nil {
+			createTime := time.Now().Local().Format(time.DateTime)
+			composeRow = &entity.Compose{
+				Name: params.Name,
+				Setting: &accessor.ComposeSettingOption{
+					Type:          accessor.ComposeTypeStoragePath,
+					Environment:   make([]types2.EnvItem, 0),
+					Uri:           []string{},
+					DockerEnvName: dockerEnvName,
+					CreatedAt:     createTime,
+					UpdatedAt:     createTime,
+				},
+			}
+		}
+	}
+
+	git := composeRow.Setting.Git
+	if git == nil {
+		git = &accessor.ComposeGit{}
+	}
+	if git.WebhookToken == "" || params.ResetWebhookToken {
+		randomBytes := make([]byte, 24)
+		if _, err := rand.Read(randomBytes); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+		git.WebhookToken = hex.EncodeToString(randomBytes)
+	}
+	git.Url = params.Url
+	git.Branch = params.Branch
+	git.Path = params.Path
+	git.Interval = params.Interval
+	git.AutoDeploy = params.AutoDeploy
+	git.PullImage = params.PullImage
+	if git.DockerEnvName == "" {
+		// 编辑时保留原有的环境，不随面板当前切换到的环境改变
+		git.DockerEnvName = docker.Sdk.DockerEnv.Name
+	}
+	composeRow.Setting.Git = git
+	if err := dao.Compose.Save(composeRow); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(http.Request.Context(), time.Minute*5)
+	defer cancel()
+	remoteCommit, err := logic.ComposeGit{}.Fetch(ctx, composeRow)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	git.RemoteCommit = remoteCommit
+	// 未部署过的任务先同步文件，之后可以直接部署或是编辑，目录中已有的非仓库文件会保留
+	if git.Commit == "" {
+		err = logic.ComposeGit{}.Checkout(ctx, composeRow, remoteCommit)
+	} else {
+		err = dao.Compose.Save(composeRow)
+	}
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"id":         composeRow.ID,
+		"webhookUri": function.RouterApiUri(fmt.Sprintf("/app/compose/git-webhook/%s", git.WebhookToken)),
+	})
+	return
+}
+
+// GitSync 部署仓库最新的提交，指定提交时回滚到该提交
+func (self Compose) GitSync(http *gin.Context) {
+	type ParamsValidate struct {
+		Id     string `json:"id" binding:"required"`
+		Commit string `json:"commit"`
+		Force  bool   `json:"force"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	composeRow, _ := logic.Compose{}.Get(params.Id)
+	if composeRow == nil || composeRow.Setting.Git == nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+		return
+	}
+
+	progress := ws.NewProgressPip(fmt.Sprintf(ws.MessageTypeCompose, params.Id))
+	defer progress.Close()
+
+	err := logic.ComposeGit{}.Sync(progress.Context(), composeRow, logic.ComposeGitSyncOption{
+		Commit: params.Commit,
+		Deploy: true,
+		Force:  params.Force,
+	}, progress)
+	if errors.Is(err, crontab.SkipRun) {
+		self.JsonResponseWithError(http, errors.New("compose git sync is running"), 500)
+		return
+	}
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"git":   composeRow.Setting.Git,
+		"drift": logic.ComposeGit{}.Drift(composeRow),
+	})
+	return
+}
+
+// GitStatus 获取仓库绑定信息及漂移状态，fetch 为 true 时先拉取仓库
+func (self Compose) GitStatus(http *gin.Context) {
+	type ParamsValidate struct {
+		Id    string `json:"id" binding:"required"`
+		Fetch bool   `json:"fetch"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	composeRow, _ := logic.Compose{}.Get(params.Id)
+	if composeRow == nil || composeRow.Setting.Git == nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+		return
+	}
+	if params.Fetch {
+		err := logic.ComposeGit{}.Sync(http.Request.Context(), composeRow, logic.ComposeGitSyncOption{}, io.Discard)
+		if err != nil && !errors.Is(err, crontab.SkipRun) {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"git":        composeRow.Setting.Git,
+		"drift":      logic.ComposeGit{}.Drift(composeRow),
+		"webhookUri": function.RouterApiUri(fmt.Sprintf("/app/compose/git-webhook/%s", composeRow.Setting.Git.WebhookToken)),
+	})
+	return
+}
+
+// GitDelete 解除仓库绑定，任务目录中的文件保持不变
+func (self Compose) GitDelete(http *gin.Context) {
+	type ParamsValidate struct {
+		Id string `json:"id" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	composeRow, _ := logic.Compose{}.Get(params.Id)
+	if composeRow == nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+		return
+	}
+	composeRow.Setting.Git = nil
+	if err := dao.Compose.Save(composeRow); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	_ = os.RemoveAll(logic.ComposeGit{}.RepoPath(composeRow))
+	self.JsonSuccessResponse(http)
+	return
+}
+
+// GitWebhook 仓库推送后调用，无需登录，通过地址中的令牌查找任务，后台执行同步
+func (self Compose) GitWebhook(http *gin.Context) {
+	composeRow, err := logic.ComposeGit{}.GetByWebhookToken(http.Param("token"))
+	if err != nil || composeRow == nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 404)
+		return
+	}
+	go func() {
+		err := logic.ComposeGit{}.Sync(context.Background(), composeRow, logic.ComposeGitSyncOption{
+			Deploy: composeRow.Setting.Git.AutoDeploy,
+		}, io.Discard)
+		if err != nil {
+			slog.Warn("compose git webhook", "name", composeRow.Name, "error", err)
+		}
+	}()
+	self.JsonSuccessResponse(http)
+	return
+}
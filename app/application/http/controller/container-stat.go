@@ -2,11 +2,13 @@ package controller
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/docker/docker/api/types/filters"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/docker"
 	"github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/metrics"
 	"github.com/donknap/dpanel/common/service/ws"
 	"github.com/gin-gonic/gin"
 )
@@ -55,6 +57,33 @@ func (self Container) GetStatInfo(http *gin.Context) {
 	}
 }
 
+func (self Container) GetStatHistory(http *gin.Context) {
+	type ParamsValidate struct {
+		Id    string `json:"id" binding:"required"`
+		Range string `json:"range" binding:"required,oneof=1h 24h 7d"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	containerInfo, err := docker.Sdk.Client.ContainerInspect(docker.Sdk.Ctx, params.Id)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	containerName := strings.TrimPrefix(containerInfo.Name, "/")
+	list, err := metrics.History.Query(docker.Sdk.Name, params.Range, containerName)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list":       list[containerName],
+		"resolution": metrics.HistoryRanges[params.Range].Resolution,
+	})
+	return
+}
+
 func (self Container) GetProcessInfo(http *gin.Context) {
 	type ParamsValidate struct {
 		Id string `json:"id" binding:"required"`
@@ -3,23 +3,17 @@ package controller
 import (
 	"errors"
 	"fmt"
-	"log/slog"
 	"slices"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
 	"github.com/donknap/dpanel/app/application/logic"
 	logic2 "github.com/donknap/dpanel/app/common/logic"
 	"github.com/donknap/dpanel/common/accessor"
-	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/entity"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/docker"
-	builder "github.com/donknap/dpanel/common/service/docker/container"
-	dockerTypes "github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/service/storage"
 	"github.com/donknap/dpanel/common/service/ws"
 	"github.com/donknap/dpanel/common/types/define"
@@ -27,8 +21,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
 	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
-	"gorm.io/datatypes"
-	"gorm.io/gen"
 )
 
 type ContainerUpgrade struct {
@@ -51,214 +43,22 @@ func (self ContainerUpgrade) Upgrade(http *gin.Context) {
 		self.JsonResponseWithError(http, err, 500)
 		return
 	}
-	if containerInfo.Name == "/"+facade.GetConfig().GetString("APP_NAME") {
-		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageContainerUpgradeDPanel), 500)
-		return
-	}
-	startContainer := containerInfo.State.Running
-	progressSteps := []string{define.ContainerUpgradeStepCreate}
-	if startContainer {
-		progressSteps = append(progressSteps, define.ContainerUpgradeStepStop)
-	}
-	progressSteps = append(progressSteps, define.ContainerUpgradeStepReplace)
-	if startContainer {
-		progressSteps = append(progressSteps, define.ContainerUpgradeStepStart)
-	}
 	progress := ws.NewProgressPip(fmt.Sprintf(ws.MessageTypeContainerUpgrade, containerInfo.ID))
 	defer progress.Close()
-	progressCurrent := 0
-	progress.BroadcastMessage(logic.ContainerUpgradeProgress{
-		Steps:   progressSteps,
-		Current: progressCurrent,
-		Total:   len(progressSteps),
-	})
-
-	progressCurrent++
-	progress.BroadcastMessage(logic.ContainerUpgradeProgress{
-		Steps:   progressSteps,
-		Current: progressCurrent,
-		Total:   len(progressSteps),
-	})
-
-	bakTime := time.Now().Format(define.DateYmdHis)
-
-	// 更新容器时可以更改镜像 tag
-	imageName := containerInfo.Config.Image
-	if params.ImageTag != "" {
-		imageName = params.ImageTag
-	}
-
-	imageInfo, err := docker.Sdk.Client.ImageInspect(docker.Sdk.Ctx, imageName)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	// 如果旧的容器使用的镜像和重新拉取的镜像一致则不升级
-	// 多平台下的其它平台镜像推送后，也会导致 digest 不一致
-	// 不一定就是本平台镜像有更新
-	// 这里还是选择更新对齐 digest
-	oldContainerImageId := containerInfo.Image
-	if containerInfo.Image == imageInfo.ID {
-		//self.JsonResponseWithoutError(http, gin.H{
-		//	"containerId": containerInfo.ID,
-		//})
-		//return
-	}
 
-	// 成功的创建一个新的容器后再对旧的进停止或是删除操作
-	newContainerName := fmt.Sprintf("%s-copy-%s", containerInfo.Name, bakTime)
-
-	options := []builder.Option{
-		builder.WithContainerInfo(containerInfo),
-		builder.WithContainerName(newContainerName),
-		builder.WithImage(imageName, false),
-	}
-	if containerInfo.NetworkSettings != nil {
-		for name, endpoint := range containerInfo.NetworkSettings.Networks {
-			options = append(options, builder.WithNetworkEndpoint(name, endpoint))
-		}
-	}
-	if params.EnableResetImageConfig {
-		options = append(options,
-			builder.WithEnv(function.PluckArrayWalk(imageInfo.Config.Env, func(item string) (dockerTypes.EnvItem, bool) {
-				return dockerTypes.NewEnvItemFromString(item), true
-			})...),
-			builder.WithLabels(function.PluckMapWalkArray(imageInfo.Config.Labels, func(name string, value string) (dockerTypes.ValueItem, bool) {
-				return dockerTypes.ValueItem{
-					Name:  name,
-					Value: value,
-				}, true
-			})...),
-			builder.WithWorkDir(imageInfo.Config.WorkingDir),
-			builder.WithCommand(imageInfo.Config.Cmd),
-			builder.WithEntrypoint(imageInfo.Config.Entrypoint),
-		)
-	}
-	containerBuilder, err := builder.New(options...)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-
-	out, err := containerBuilder.Execute()
-	if err != nil {
-		errRemove := docker.Sdk.Client.ContainerRemove(docker.Sdk.Ctx, newContainerName, container.RemoveOptions{})
-		self.JsonResponseWithError(http, errors.Join(err, errRemove), 500)
-		return
-	}
-
-	if containerInfo.State.Running {
-		progressCurrent++
-		progress.BroadcastMessage(logic.ContainerUpgradeProgress{
-			Steps:   progressSteps,
-			Current: progressCurrent,
-			Total:   len(progressSteps),
-		})
-		err = docker.Sdk.Client.ContainerStop(docker.Sdk.Ctx, containerInfo.Name, container.StopOptions{})
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
-		}
-		if containerInfo.HostConfig.AutoRemove {
-			// 如果是旧容器配置了自动删除，则等待容器自动被销毁
-			for {
-				time.Sleep(time.Second * 1)
-				if _, err = docker.Sdk.Client.ContainerInspect(docker.Sdk.Ctx, containerInfo.Name); err != nil {
-					break
-				}
-			}
-		}
-	}
-
-	bakContainerName := fmt.Sprintf("%s-bak-%s", containerInfo.Name, bakTime)
-	bakImageName := fmt.Sprintf("%s-bak-%s", containerInfo.Config.Image, bakTime)
-	progressCurrent++
-	progress.BroadcastMessage(logic.ContainerUpgradeProgress{
-		Steps:   progressSteps,
-		Current: progressCurrent,
-		Total:   len(progressSteps),
+	newContainerInfo, err := logic.ContainerUpgrade{}.Upgrade(containerInfo, logic.ContainerUpgradeOption{
+		ImageTag:               params.ImageTag,
+		EnableBak:              params.EnableBak,
+		EnableResetImageConfig: params.EnableResetImageConfig,
+		Progress: func(p logic.ContainerUpgradeProgress) {
+			progress.BroadcastMessage(p)
+		},
 	})
-
-	// 未备份旧容器，需要先删除，否则名称会冲突
-	if params.EnableBak {
-		if !containerInfo.HostConfig.AutoRemove {
-			// 备份旧容器
-			err = docker.Sdk.Client.ContainerRename(
-				docker.Sdk.Ctx,
-				containerInfo.Name,
-				bakContainerName,
-			)
-			if err != nil {
-				self.JsonResponseWithError(http, err, 500)
-				return
-			}
-		}
-
-		if oldContainerImageId != imageInfo.ID {
-			// 备份旧镜像
-			err = docker.Sdk.Client.ImageTag(
-				docker.Sdk.Ctx,
-				containerInfo.Image,
-				bakImageName,
-			)
-			if err != nil {
-				self.JsonResponseWithError(http, err, 500)
-				return
-			}
-		}
-	} else {
-		if !containerInfo.HostConfig.AutoRemove {
-			err = docker.Sdk.Client.ContainerRemove(docker.Sdk.Ctx, containerInfo.Name, container.RemoveOptions{})
-			if err != nil {
-				self.JsonResponseWithError(http, err, 500)
-				return
-			}
-		}
-		_, err = docker.Sdk.Client.ImageRemove(docker.Sdk.Ctx, containerInfo.Image, image.RemoveOptions{})
-		if err != nil {
-			slog.Debug("container upgrade delete image", "error", err.Error())
-		}
-	}
-
-	err = docker.Sdk.Client.ContainerRename(
-		docker.Sdk.Ctx,
-		newContainerName,
-		containerInfo.Name,
-	)
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
 	}
 
-	newContainerInfo, err := docker.Sdk.Client.ContainerInspect(docker.Sdk.Ctx, out.ID)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	// 容器升级后，将表中的数据更新为新的容器数据
-	if siteRow, _ := dao.Site.Where(gen.Cond(datatypes.JSONQuery("container_info").Equals(params.Md5, "Id"))...).First(); siteRow != nil {
-		siteRow.ContainerInfo = &accessor.SiteContainerInfoOption{
-			Id:   out.ID,
-			Info: newContainerInfo,
-		}
-		_ = dao.Site.Save(siteRow)
-	}
-
-	// 旧容器如果是停止状态，重建后保持不启动
-	if startContainer {
-		progressCurrent++
-		progress.BroadcastMessage(logic.ContainerUpgradeProgress{
-			Steps:   progressSteps,
-			Current: progressCurrent,
-			Total:   len(progressSteps),
-		})
-		err = docker.Sdk.Client.ContainerStart(docker.Sdk.Ctx, containerInfo.Name, container.StartOptions{})
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
-		}
-	}
-
 	facade.GetEvent().Publish(event.ContainerEditEvent, event.ContainerPayload{
 		InspectInfo:    &newContainerInfo,
 		OldInspectInfo: &containerInfo,
@@ -266,7 +66,7 @@ func (self ContainerUpgrade) Upgrade(http *gin.Context) {
 	})
 
 	self.JsonResponseWithoutError(http, gin.H{
-		"containerId": out.ID,
+		"containerId": newContainerInfo.ID,
 	})
 	return
 }
@@ -194,6 +194,9 @@ func (self Container) GetList(http *gin.Context) {
 	})
 
 	domainList, _ := dao.SiteDomain.Where(dao.SiteDomain.ContainerID.In(containerName...)).Find()
+	for _, item := range domainList {
+		item.Setting.ClearBasicAuthPassword()
+	}
 
 	self.JsonResponseWithoutError(http, gin.H{
 		"list":       list,
@@ -222,6 +225,9 @@ func (self Container) GetDetail(http *gin.Context) {
 		detail.Config.Labels[define.DPanelLabelContainerDPanelSelf] = "true"
 	}
 	domain, _ := dao.SiteDomain.Where(dao.SiteDomain.ContainerID.In(detail.Name)).Find()
+	for _, item := range domain {
+		item.Setting.ClearBasicAuthPassword()
+	}
 	self.JsonResponseWithoutError(http, gin.H{
 		"info":   detail,
 		"domain": domain,
@@ -431,6 +437,7 @@ func (self Container) Delete(http *gin.Context) {
 		if err != nil {
 			slog.Warn("container delete domain", "error", err)
 		}
+		_ = os.Remove(storage.Local{}.GetNginxHtpasswdFilePath(domain.ServerName))
 	}
 
 	_, err = dao.SiteDomain.Where(dao.SiteDomain.ContainerID.Eq(containerInfo.ID)).Delete()
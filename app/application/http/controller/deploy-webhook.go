@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/donknap/dpanel/app/application/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/ws"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+)
+
+const deployWebhookMaxBodySize = 1 << 20
+
+type DeployWebhook struct {
+	controller.Abstract
+}
+
+func (self DeployWebhook) Create(http *gin.Context) {
+	type ParamsValidate struct {
+		accessor.DeployWebhook
+		ResetToken bool `json:"resetToken"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list := logic.DeployWebhook{}.GetList()
+	old, _ := logic.DeployWebhook{}.Get(params.Name)
+	item := params.DeployWebhook
+	item.LastRunAt = old.LastRunAt
+	item.LastError = old.LastError
+	if item.DockerEnvName == "" {
+		// 编辑时保留原有的环境，不随面板当前切换到的环境改变
+		item.DockerEnvName = old.DockerEnvName
+	}
+	if item.DockerEnvName == "" {
+		item.DockerEnvName = docker.Sdk.DockerEnv.Name
+	}
+	if item.DockerEnvName == docker.Sdk.DockerEnv.Name && item.Type == accessor.DeployWebhookTypeContainer {
+		if _, err := docker.Sdk.Client.ContainerInspect(docker.Sdk.Ctx, item.Target); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	item.Token = old.Token
+	if item.Token == "" || params.ResetToken {
+		randomBytes := make([]byte, 24)
+		if _, err := rand.Read(randomBytes); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+		item.Token = hex.EncodeToString(randomBytes)
+	}
+	// 提交占位符时沿用旧的密钥
+	if function.IsSensitivePlaceholder(item.Secret) {
+		item.Secret = old.Secret
+	} else if item.Secret != "" {
+		if v, err := function.RSAEncode(item.Secret); err == nil {
+			item.Secret = v
+		}
+	}
+	if index, ok := function.IndexArrayWalk(list, func(i accessor.DeployWebhook) bool {
+		return i.Name == item.Name
+	}); ok {
+		list[index] = item
+	} else {
+		list = append(list, item)
+	}
+	if err := (logic.DeployWebhook{}).Save(list); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"uri": self.uri(item),
+	})
+	return
+}
+
+func (self DeployWebhook) GetList(http *gin.Context) {
+	list := logic.DeployWebhook{}.GetList()
+	result := make([]gin.H, 0, len(list))
+	for _, item := range list {
+		item.Secret = function.MaskSensitiveValue(item.Secret)
+		result = append(result, gin.H{
+			"webhook": item,
+			"uri":     self.uri(item),
+			"running": logic.DeployWebhook{}.IsRunning(item.Name),
+		})
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list": result,
+	})
+	return
+}
+
+func (self DeployWebhook) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Name []string `json:"name" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list := function.PluckArrayWalk(logic.DeployWebhook{}.GetList(), func(i accessor.DeployWebhook) (accessor.DeployWebhook, bool) {
+		return i, !function.InArray(params.Name, i.Name)
+	})
+	if err := (logic.DeployWebhook{}).Save(list); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	for _, name := range params.Name {
+		_ = os.Remove(logic.DeployWebhook{}.LogPath(name))
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+// Trigger 供 CI 调用，无需登录，校验令牌及签名后在后台执行，输出推送到 deploy:webhook:{name}
+func (self DeployWebhook) Trigger(http *gin.Context) {
+	item, err := logic.DeployWebhook{}.GetByToken(http.Param("token"))
+	if err != nil {
+		self.JsonResponseWithError(http, err, 404)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(http.Request.Body, deployWebhookMaxBodySize))
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	if err = (logic.DeployWebhook{}).Verify(item, http.Request.Header, body); err != nil {
+		self.JsonResponseWithError(http, err, 401)
+		return
+	}
+	if (logic.DeployWebhook{}).IsRunning(item.Name) {
+		self.JsonResponseWithError(http, errors.New("deploy webhook is running"), 500)
+		return
+	}
+	go func() {
+		progress := ws.NewProgressPip(fmt.Sprintf(ws.MessageTypeDeployWebhook, item.Name))
+		defer progress.Close()
+		// 前端关闭推送时不中断部署
+		err := logic.DeployWebhook{}.Run(context.Background(), item, progress)
+		if err != nil {
+			slog.Warn("deploy webhook", "name", item.Name, "error", err)
+		}
+	}()
+	self.JsonResponseWithoutError(http, gin.H{
+		"name": item.Name,
+	})
+	return
+}
+
+func (self DeployWebhook) uri(item accessor.DeployWebhook) string {
+	return function.RouterApiUri(fmt.Sprintf("/app/deploy-webhook/trigger/%s", item.Token))
+}
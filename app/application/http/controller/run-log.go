@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
 	"strconv"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/donknap/dpanel/app/application/logic"
 	"github.com/donknap/dpanel/common/service/docker"
 	"github.com/donknap/dpanel/common/service/ws"
 	"github.com/gin-gonic/gin"
@@ -93,3 +95,28 @@ func (self RunLog) Run(http *gin.Context) {
 	})
 	return
 }
+
+// DeployWebhook 获取部署地址最近一次运行的日志，运行中的输出通过 deploy:webhook:{name} 推送
+func (self RunLog) DeployWebhook(http *gin.Context) {
+	type ParamsValidate struct {
+		Name string `json:"name" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if _, err := (logic.DeployWebhook{}).Get(params.Name); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	content, err := os.ReadFile(logic.DeployWebhook{}.LogPath(params.Name))
+	if err != nil && !os.IsNotExist(err) {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"log":     string(content),
+		"running": logic.DeployWebhook{}.IsRunning(params.Name),
+	})
+	return
+}
@@ -4,18 +4,10 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
-	"crypto/ecdsa"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/donknap/dpanel/app/application/logic"
@@ -25,8 +17,7 @@ import (
 	"github.com/donknap/dpanel/common/entity"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/acme"
-	"github.com/donknap/dpanel/common/service/docker/types"
-	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/service/crontab"
 	"github.com/donknap/dpanel/common/service/ws"
 	"github.com/donknap/dpanel/common/types/define"
 	"github.com/gin-gonic/gin"
@@ -124,7 +115,7 @@ func (self SiteCert) DnsApi(http *gin.Context) {
 func (self SiteCert) Apply(http *gin.Context) {
 	type ParamsValidate struct {
 		Type        string   `json:"type"`
-		Domain      []string `json:"domain"`
+		Domain      []string `json:"domain" binding:"required"`
 		Email       string   `json:"email"`
 		CertServer  string   `json:"certServer"`
 		AutoUpgrade bool     `json:"autoUpgrade"`
@@ -139,108 +130,47 @@ func (self SiteCert) Apply(http *gin.Context) {
 		return
 	}
 
-	options := []acme.Option{
-		acme.WithDomain(params.Domain...),
-		acme.WithEmail(params.Email),
-		acme.WithCertServer(params.CertServer),
-		//acme.WithCertRootPath(storage.Local{}.GetStorageCertPath()),
-		acme.WithAutoUpgrade(params.AutoUpgrade),
-		acme.WithReloadCommand("/docker/acme-reload.sh"),
-	}
-
-	if params.Renew {
-		options = append(options, acme.WithRenew(), acme.WithForce())
-	} else {
-		options = append(options, acme.WithIssue(), acme.WithForce())
-	}
-
-	if params.Debug {
-		options = append(options, acme.WithDebug())
-	}
-
-	if params.DnsApi != "" {
-		if params.DnsApi == "nginx" {
-			options = append(options, acme.WithDnsNginx())
-		} else {
-			dnsApiList := make([]accessor.DnsApi, 0)
-			logic2.Setting{}.GetByKey(logic2.SettingGroupSetting, logic2.SettingGroupSettingDnsApi, &dnsApiList)
-			if i, ok := function.IndexArrayWalk(dnsApiList, func(i accessor.DnsApi) bool {
-				return i.ServerName == params.DnsApi
-			}); ok {
-				env := function.PluckArrayWalk(dnsApiList[i].Env, func(i types.EnvItem) (string, bool) {
-					return fmt.Sprintf("%s=%s", i.Name, i.Value), true
-				})
-				options = append(options, acme.WithDnsApi(dnsApiList[i].ServerName, env))
-			}
-		}
-	}
 	wsBuffer := ws.NewProgressPip(ws.MessageTypeDomainApply)
 	defer wsBuffer.Close()
 
-	builder, err := acme.New(wsBuffer.Context(), options...)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-
-	cmd, err := builder.Run()
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-
-	go func() {
-		<-wsBuffer.Done()
-		_ = cmd.Close()
-	}()
-
-	errMessage := function.ErrorMessage(define.ErrorMessageSiteDomainCertIssueFailed)
-	success := false
 	wsBuffer.OnWrite = func(p string) error {
 		wsBuffer.BroadcastMessage(p)
-		if strings.Contains(p, "-----END CERTIFICATE-----") {
-			success = true
-		}
-		if strings.Contains(p, "Error adding TXT record to domain") {
-			errMessage = function.ErrorMessage(define.ErrorMessageSiteDomainCertAddTxtFailed)
-		}
 		return nil
 	}
 
-	// 如果需要注册帐号，先运行
-	if params.EabKid != "" && params.EabHmacKey != "" {
-		if b, err := acme.New(wsBuffer.Context(),
-			acme.WithEmail(params.Email),
-			acme.WithCertServer(params.CertServer),
-			acme.WithEabAccount(params.EabKid, params.EabHmacKey),
-		); err == nil {
-			result, err := b.Result()
-			if err != nil {
-				self.JsonResponseWithError(http, err, 500)
-				return
-			}
-			wsBuffer.BroadcastMessage(string(result))
+	_, err := logic.SiteCert{}.Issue(wsBuffer.Context(), logic.SiteCertIssueOption{
+		Domain:     params.Domain,
+		Email:      params.Email,
+		CertServer: params.CertServer,
+		DnsApi:     params.DnsApi,
+		EabKid:     params.EabKid,
+		EabHmacKey: params.EabHmacKey,
+		AutoRenew:  params.AutoUpgrade,
+	}, wsBuffer)
+	if err != nil {
+		wsBuffer.BroadcastMessage(err.Error())
+		if errors.Is(err, acme.ErrAddTxtRecord) {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSiteDomainCertAddTxtFailed), 500)
 		} else {
-			self.JsonResponseWithError(http, err, 500)
-			return
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSiteDomainCertIssueFailed), 500)
 		}
+		return
 	}
+	self.JsonSuccessResponse(http)
+	return
+}
 
-	out, err := cmd.RunInPip()
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
+func (self SiteCert) Renew(http *gin.Context) {
+	err := logic.SiteCert{}.Renew(context.Background())
+	if errors.Is(err, crontab.SkipRun) {
+		self.JsonResponseWithError(http, errors.New("site cert renew is running"), 500)
 		return
 	}
-	_, err = io.Copy(wsBuffer, out)
 	if err != nil {
-		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonCancelOperator, "message", err.Error()), 500)
+		self.JsonResponseWithError(http, err, 500)
 		return
 	}
-	if success {
-		self.JsonSuccessResponse(http)
-	} else {
-		self.JsonResponseWithError(http, errMessage, 500)
-	}
+	self.JsonSuccessResponse(http)
 	return
 }
 
@@ -253,73 +183,12 @@ func (self SiteCert) Import(http *gin.Context) {
 	if !self.Validate(http, &params) {
 		return
 	}
-	var errInvalidCertFile = errors.New("invalid cert file")
-
-	// 遍历 PEM 数据块
-	var block *pem.Block
-	block, _ = pem.Decode([]byte(params.SslCrtContent))
-	if block == nil || block.Type != "CERTIFICATE" {
-		self.JsonResponseWithError(http, errInvalidCertFile, 500)
-		return
-	}
-	cert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	keyAlgorithm := "unknown"
-	switch cert.PublicKey.(type) {
-	case *rsa.PublicKey:
-		keyAlgorithm = "rsa-2048"
-		break
-	case *ecdsa.PublicKey:
-		keyAlgorithm = "ec-256"
-		break
-	}
-	if len(cert.DNSNames) <= 0 {
-		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSiteDomainCertHasNotDNSName), 500)
-		return
-	}
-	mainDomain := cert.DNSNames[0]
-	sanDomain := "no"
-	if len(cert.DNSNames) > 1 {
-		sanDomain = strings.Join(function.PluckArrayWalk(cert.DNSNames, func(i string) (string, bool) {
-			if i == mainDomain {
-				return "", false
-			}
-			return i, true
-		}), ",")
-	}
-	// 创建单个证书的配置 map
-	certConfig := []string{
-		fmt.Sprintf("Le_Domain='%s'", mainDomain),
-		fmt.Sprintf("Le_Alt='%s'", sanDomain),
-		fmt.Sprintf("Le_API='import'"),
-		fmt.Sprintf("Le_Keylength='%s'", keyAlgorithm),
-		fmt.Sprintf("Le_CertCreateTime='%d'", cert.NotBefore.Unix()),
-		fmt.Sprintf("Le_CertCreateTimeStr='%s'", cert.NotBefore.Format(time.RFC3339)),
-		fmt.Sprintf("Le_NextRenewTime='%d'", cert.NotAfter.Unix()),
-		fmt.Sprintf("Le_NextRenewTimeStr='%s'", cert.NotAfter.Format(time.RFC3339)),
-		fmt.Sprintf("Le_SerialNumber='%s'", cert.SerialNumber.String()),
-	}
-	confFilePath := filepath.Join(storage.Local{}.GetCertDomainPath(), fmt.Sprintf(logic.CertName, mainDomain), mainDomain+".conf")
-	err = os.MkdirAll(filepath.Dir(confFilePath), os.ModePerm)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	err = os.WriteFile(confFilePath, []byte(strings.Join(certConfig, "\n")), 0644)
+	builder, err := logic.SiteCert{}.New(context.Background())
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
 	}
-	err = os.WriteFile(filepath.Join(filepath.Dir(confFilePath), "fullchain.cer"), []byte(params.SslCrtContent), 0644)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	err = os.WriteFile(filepath.Join(filepath.Dir(confFilePath), mainDomain+".key"), []byte(params.SslKeyContent), 0644)
-	if err != nil {
+	if _, err = builder.Import(params.SslCrtContent, params.SslKeyContent); err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
 	}
@@ -328,7 +197,7 @@ func (self SiteCert) Import(http *gin.Context) {
 }
 
 func (self SiteCert) GetList(http *gin.Context) {
-	builder, err := acme.New(context.Background())
+	builder, err := logic.SiteCert{}.New(context.Background())
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
@@ -367,7 +236,7 @@ func (self SiteCert) Delete(http *gin.Context) {
 			return
 		}
 	}
-	builder, err := acme.New(context.Background())
+	builder, err := logic.SiteCert{}.New(context.Background())
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
@@ -386,15 +255,9 @@ func (self SiteCert) Delete(http *gin.Context) {
 	})
 
 	for _, cert := range deleteList {
-		if !cert.IsImport() {
-			if err = builder.Remove(cert.MainDomain); err != nil {
-				slog.Debug("site cert acme remove", "error", err)
-			}
-		}
-		if err = os.RemoveAll(cert.GetRootPath()); err != nil {
+		if err = builder.Remove(cert.MainDomain); err != nil {
 			slog.Debug("site cert delete path", "error", err)
 		}
-
 	}
 	self.JsonSuccessResponse(http)
 	return
@@ -409,7 +272,7 @@ func (self SiteCert) GetDetail(http *gin.Context) {
 		return
 	}
 	var err error
-	builder, err := acme.New(context.Background())
+	builder, err := logic.SiteCert{}.New(context.Background())
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
@@ -433,7 +296,7 @@ func (self SiteCert) Download(http *gin.Context) {
 	if !self.Validate(http, &params) {
 		return
 	}
-	builder, err := acme.New(context.Background())
+	builder, err := logic.SiteCert{}.New(context.Background())
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
@@ -468,7 +331,7 @@ func (self SiteCert) Download(http *gin.Context) {
 			return
 		}
 		zipHeader = &zip.FileHeader{
-			Name:               fmt.Sprintf(logic.KeyFileName, cert.MainDomain),
+			Name:               fmt.Sprintf(logic.KeyFileName, function.SafeFileName(cert.MainDomain)),
 			Method:             zip.Deflate,
 			UncompressedSize64: uint64(len(cert.SslKeyContent)),
 			Modified:           time.Now(),
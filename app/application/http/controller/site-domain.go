@@ -14,13 +14,12 @@ import (
 	"sync"
 	"time"
 
-	"github.com/docker/docker/api/types/network"
 	"github.com/donknap/dpanel/app/application/logic"
-	logic2 "github.com/donknap/dpanel/app/common/logic"
 	"github.com/donknap/dpanel/common/accessor"
 	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/entity"
 	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/acme"
 	"github.com/donknap/dpanel/common/service/docker"
 	"github.com/donknap/dpanel/common/service/exec/local"
 	"github.com/donknap/dpanel/common/service/storage"
@@ -92,66 +91,55 @@ func (self SiteDomain) Create(http *gin.Context) {
 			self.JsonResponseWithError(http, err, 500)
 			return
 		}
-		// 转发时必须保证当前环境有 dpanel 面板
-		// 将当前容器加入到默认 dpanel-local 网络中，并指定 Hostname 用于 Nginx 反向代理
-		dpanelInfo := logic2.Setting{}.GetDPanelInfo()
-		if dpanelInfo.ContainerInfo.ContainerJSONBase == nil {
-			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSiteDomainNotFoundDPanel), 500)
+		if params.ServerAddress, err = (logic.Site{}).JoinProxyNetwork(docker.Sdk, containerRow); err != nil {
+			self.JsonResponseWithError(http, err, 500)
 			return
 		}
+		siteDomainRow.ContainerID = containerRow.Name
+	}
 
-		if _, err = docker.Sdk.Client.NetworkInspect(docker.Sdk.Ctx, define.DPanelProxyNetworkName, network.InspectOptions{}); err != nil {
-			slog.Debug("site domain create default network", "name", define.DPanelProxyNetworkName)
-			_, err = docker.Sdk.Client.NetworkCreate(docker.Sdk.Ctx, define.DPanelProxyNetworkName, network.CreateOptions{
-				Driver: "bridge",
-				Options: map[string]string{
-					"name": define.DPanelProxyNetworkName,
-				},
-				EnableIPv6: function.Ptr(false),
-			})
-			if err != nil {
-				self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSiteDomainJoinDefaultNetworkFailed), 500)
-				return
-			}
-		}
+	var oldSetting *accessor.SiteDomainSettingOption
+	if siteDomainRow.ID > 0 {
+		oldSetting = siteDomainRow.Setting
+	}
+	if err = (logic.Site{}).NormalizeAccess(&params.SiteDomainSettingOption, oldSetting); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
 
-		dpanelLocalNetwork, err := docker.Sdk.Client.NetworkInspect(docker.Sdk.Ctx, define.DPanelProxyNetworkName, network.InspectOptions{})
-		if err != nil {
+	if params.Upstream != nil {
+		// 编辑时保留原有的环境，不随面板当前切换到的环境改变
+		params.Upstream.DockerEnvName = docker.Sdk.Name
+		if oldSetting != nil && oldSetting.Upstream != nil {
+			params.Upstream.DockerEnvName = oldSetting.Upstream.GetDockerEnvName()
+		}
+		if params.Upstream.Member, err = (logic.SiteUpstream{}).GetMember(params.Upstream); err != nil {
 			self.JsonResponseWithError(http, err, 500)
 			return
 		}
-
-		// 当面板自己没有加入默认网络时，加入并配置 hostname
-		// 假如当前转发的容器就是面板自己，则不在这里处理，统一在下面加入网络
-		if _, _, ok := function.PluckMapItemWalk(dpanelLocalNetwork.Containers, func(k string, v network.EndpointResource) bool {
-			return k == dpanelInfo.ContainerInfo.ID
-		}); !ok {
-			err = docker.Sdk.Client.NetworkConnect(docker.Sdk.Ctx, define.DPanelProxyNetworkName, dpanelInfo.ContainerInfo.ID, &network.EndpointSettings{
-				Aliases: []string{
-					fmt.Sprintf(define.DPanelNetworkHostName, strings.Trim(dpanelInfo.ContainerInfo.Name, "/")),
-				},
-			})
-			if err != nil {
-				self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSiteDomainJoinDefaultNetworkFailed, err.Error()), 500)
-				return
-			}
+		if len(params.Upstream.Member) == 0 {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSiteDomainUpstreamEmpty), 500)
+			return
 		}
+	}
 
-		// 当目标容器不在默认网络时，加入默认网络
-		params.ServerAddress = fmt.Sprintf(define.DPanelNetworkHostName, strings.Trim(containerRow.Name, "/"))
-		if _, ok := containerRow.NetworkSettings.Networks[define.DPanelProxyNetworkName]; !ok {
-			slog.Debug("site domain join default network ", "container name", containerRow.Name, "hostname", params.ServerAddress)
-			err = docker.Sdk.Client.NetworkConnect(docker.Sdk.Ctx, define.DPanelProxyNetworkName, params.ContainerId, &network.EndpointSettings{
-				Aliases: []string{
-					params.ServerAddress,
-				},
-			})
-			if err != nil {
-				self.JsonResponseWithError(http, err, 500)
-				return
-			}
+	for i, item := range params.Location {
+		if params.Location[i], err = (logic.Site{}).NormalizeLocation(item); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+		if item.ContainerId == "" {
+			continue
+		}
+		containerRow, err := docker.Sdk.Client.ContainerInspect(docker.Sdk.Ctx, item.ContainerId)
+		if err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+		if params.Location[i].ServerAddress, err = (logic.Site{}).JoinProxyNetwork(docker.Sdk, containerRow); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
 		}
-		siteDomainRow.ContainerID = containerRow.Name
 	}
 
 	params.TargetName = function.Md5(params.ServerName)
@@ -159,11 +147,12 @@ func (self SiteDomain) Create(http *gin.Context) {
 
 	if params.CertName != "" && params.EnableSSL {
 		siteDomainRow.Setting.CertName = params.CertName
-		siteDomainRow.Setting.SslCrt = filepath.Join(storage.Local{}.GetCertDomainPath(), fmt.Sprintf(logic.CertName, params.CertName), logic.CertFileName)
-		siteDomainRow.Setting.SslKey = filepath.Join(storage.Local{}.GetCertDomainPath(), fmt.Sprintf(logic.CertName, params.CertName), fmt.Sprintf(logic.KeyFileName, params.CertName))
+		certName := function.SafeFileName(params.CertName)
+		siteDomainRow.Setting.SslCrt = filepath.Join(storage.Local{}.GetCertDomainPath(), fmt.Sprintf(logic.CertName, certName), logic.CertFileName)
+		siteDomainRow.Setting.SslKey = filepath.Join(storage.Local{}.GetCertDomainPath(), fmt.Sprintf(logic.CertName, certName), fmt.Sprintf(logic.KeyFileName, certName))
 	}
 
-	err = logic.Site{}.MakeNginxConf(*siteDomainRow.Setting)
+	err = logic.Site{}.SaveNginxConf(*siteDomainRow.Setting)
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
@@ -212,8 +201,25 @@ func (self SiteDomain) GetList(http *gin.Context) {
 	}
 	list, _ := query.Find()
 
+	type siteDomainItem struct {
+		*entity.SiteDomain
+		Cert *acme.Cert `json:"cert,omitempty"`
+	}
+	certState, _ := logic.SiteCert{}.CertState()
+	result := make([]siteDomainItem, 0, len(list))
+	for _, item := range list {
+		item.Setting.ClearBasicAuthPassword()
+		row := siteDomainItem{
+			SiteDomain: item,
+		}
+		if item.Setting != nil && item.Setting.SslCrt != "" {
+			row.Cert = certState[item.Setting.SslCrt]
+		}
+		result = append(result, row)
+	}
+
 	self.JsonResponseWithoutError(http, gin.H{
-		"list": list,
+		"list": result,
 	})
 	return
 }
@@ -242,6 +248,7 @@ func (self SiteDomain) GetDetail(http *gin.Context) {
 	if extraVhost, err := os.ReadFile(storage.Local{}.GetNginxExtraSettingFilePath(vhostFileName)); err == nil {
 		domainRow.Setting.ExtraNginx = template.HTML(extraVhost)
 	}
+	domainRow.Setting.ClearBasicAuthPassword()
 	self.JsonResponseWithoutError(http, gin.H{
 		"domain": domainRow,
 		"vhost":  string(vhost),
@@ -263,6 +270,7 @@ func (self SiteDomain) Delete(http *gin.Context) {
 		if err != nil {
 			slog.Debug("container delete domain", "error", err)
 		}
+		_ = os.Remove(storage.Local{}.GetNginxHtpasswdFilePath(item.ServerName))
 	}
 	_, err := dao.SiteDomain.Where(dao.SiteDomain.ID.In(params.Id...)).Delete()
 	if err != nil {
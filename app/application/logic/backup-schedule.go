@@ -0,0 +1,206 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/crontab"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/patrickmn/go-cache"
+	"github.com/robfig/cron/v3"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+type BackupSchedule struct {
+}
+
+func (self BackupSchedule) AddJob(task *entity.BackupSchedule) (ids []cron.EntryID, err error) {
+	cronJob := crontab.New(
+		crontab.WithName(fmt.Sprintf("backup:%s", task.Title)),
+		crontab.WithRunFunc(func(ctx *crontab.RunFuncContext) {
+			ctx.Err = self.Run(task.ID)
+		}),
+	)
+	ids = make([]cron.EntryID, 0)
+	for _, exp := range task.Setting.Expression {
+		if id, err1 := crontab.Client.AddJob(exp.ToString(), cronJob); err1 == nil {
+			ids = append(ids, id)
+		} else {
+			err = errors.Join(err, err1)
+		}
+	}
+	if err != nil {
+		crontab.Client.RemoveJob(ids...)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Run 执行一次备份计划，同一计划同时只会运行一个
+func (self BackupSchedule) Run(id int32) (err error) {
+	task, err := dao.BackupSchedule.Where(dao.BackupSchedule.ID.Eq(id)).First()
+	if err != nil {
+		return function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	cacheKey := fmt.Sprintf(storage.CacheKeyBackupScheduleStatus, task.ID)
+	if err = storage.Cache.Add(cacheKey, "running", cache.NoExpiration); err != nil {
+		return crontab.SkipRun
+	}
+	defer func() {
+		storage.Cache.Delete(cacheKey)
+		if err != nil {
+			facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+				Event:   define.NotificationEventBackupFailed,
+				Subject: fmt.Sprintf("backup schedule %s failed", task.Title),
+				Content: err.Error(),
+			})
+		}
+		// 运行期间计划可能被修改，重新获取后只更新运行结果
+		if row, _ := dao.BackupSchedule.Where(dao.BackupSchedule.ID.Eq(task.ID)).First(); row != nil {
+			row.Setting.LastRunAt = function.Ptr(time.Now())
+			row.Setting.LastError = ""
+			if err != nil {
+				row.Setting.LastError = err.Error()
+			}
+			_ = dao.BackupSchedule.Save(row)
+		}
+	}()
+
+	dockerEnv, err := logic.Env{}.GetEnvByName(task.Setting.DockerEnvName)
+	if err != nil {
+		return err
+	}
+	dockerClient, err := docker.NewClientWithDockerEnv(dockerEnv)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		dockerClient.Close()
+	}()
+
+	containerNames, err := self.GetContainerNames(dockerClient, task.Setting)
+	if err != nil {
+		return err
+	}
+	if function.IsEmptyArray(containerNames) {
+		return function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	for _, name := range containerNames {
+		backupRow := ContainerBackup{}.NewRow(name, task.Title, task.ID)
+		_, createErr := ContainerBackup{}.Create(context.Background(), dockerClient, backupRow, ContainerBackupOption{
+			EnableImage:          task.Setting.EnableImage,
+			EnableImageContainer: task.Setting.EnableImageContainer,
+			EnableVolume:         task.Setting.EnableVolume,
+			VolumeList:           task.Setting.VolumeList,
+			TargetName:           task.Setting.TargetName,
+			EncryptionName:       task.Setting.EncryptionName,
+			Incremental:          task.Setting.Incremental,
+			Hook:                 task.Setting.Hook,
+		})
+		if createErr != nil {
+			slog.Warn("backup schedule create", "schedule", task.Title, "container", name, "error", createErr)
+			err = errors.Join(err, fmt.Errorf("%s: %w", name, createErr))
+		} else if task.Setting.Verify {
+			_, verifyErr := ContainerBackup{}.Verify(context.Background(), dockerClient, backupRow, ContainerBackupVerifyOption{
+				TestRestore:   task.Setting.TestRestore,
+				HealthCommand: task.Setting.HealthCommand,
+			})
+			if verifyErr != nil {
+				slog.Warn("backup schedule verify", "schedule", task.Title, "container", name, "error", verifyErr)
+				err = errors.Join(err, fmt.Errorf("%s verify: %w", name, verifyErr))
+			}
+		}
+		if pruneErr := self.Prune(task, name); pruneErr != nil {
+			slog.Warn("backup schedule prune", "schedule", task.Title, "container", name, "error", pruneErr)
+		}
+	}
+	// 清理过期快照后释放不再被引用的分块
+	if task.Setting.Incremental {
+		if repo, repoErr := (ContainerBackup{}).Repository(); repoErr == nil {
+			if _, pruneErr := repo.Prune(context.Background()); pruneErr != nil {
+				slog.Warn("backup schedule prune repository", "schedule", task.Title, "error", pruneErr)
+			}
+		}
+	}
+	return err
+}
+
+// GetContainerNames 获取计划需要备份的容器，编排项目按标签查找其下的所有容器
+func (self BackupSchedule) GetContainerNames(dockerClient *docker.Client, setting *accessor.BackupScheduleSettingOption) ([]string, error) {
+	result := make([]string, 0)
+	result = append(result, setting.ContainerNames...)
+	if setting.ComposeName != "" {
+		list, err := dockerClient.Client.ContainerList(dockerClient.Ctx, container.ListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", define.ComposeLabelProject, setting.ComposeName))),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			if len(item.Names) > 0 && !function.InArray(result, item.Names[0]) {
+				result = append(result, item.Names[0])
+			}
+		}
+	}
+	return result, nil
+}
+
+// Prune 按保留策略清理计划创建的快照
+func (self BackupSchedule) Prune(task *entity.BackupSchedule, containerName string) error {
+	list, err := dao.Backup.Where(dao.Backup.ContainerID.Eq(containerName)).Where(gen.Cond(
+		datatypes.JSONQuery("setting").Equals(task.ID, "scheduleId"),
+	)...).Order(dao.Backup.ID.Desc()).Find()
+	if err != nil {
+		return err
+	}
+	return ContainerBackup{}.Delete(self.Expired(list, task.Setting.Retention))
+}
+
+// Expired 返回超出保留策略的快照，list 需要按创建时间倒序排列
+// 失败的快照在有更新的成功快照后清理，处理中的快照不会清理
+func (self BackupSchedule) Expired(list []*entity.Backup, retention accessor.BackupRetention) []*entity.Backup {
+	result := make([]*entity.Backup, 0)
+	if retention.IsEmpty() {
+		return result
+	}
+	success := function.PluckArrayWalk(list, func(item *entity.Backup) (*entity.Backup, bool) {
+		return item, item.Setting != nil && item.Setting.Status == define.DockerImageBuildStatusSuccess
+	})
+	keep := make(map[int32]bool)
+	for i := range retention.Keep(function.PluckArrayWalk(success, func(item *entity.Backup) (time.Time, bool) {
+		return item.CreatedAt, true
+	})) {
+		keep[success[i].ID] = true
+	}
+
+	var latestSuccess time.Time
+	if len(success) > 0 {
+		latestSuccess = success[0].CreatedAt
+	}
+	for _, item := range list {
+		if keep[item.ID] || item.Setting == nil || item.Setting.Status == define.DockerImageBuildStatusProcess {
+			continue
+		}
+		if item.Setting.Status != define.DockerImageBuildStatusSuccess && !item.CreatedAt.Before(latestSuccess) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
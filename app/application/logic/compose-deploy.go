@@ -0,0 +1,184 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/compose"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/imports"
+	types2 "github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/notice"
+	"github.com/donknap/dpanel/common/service/plugin"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+)
+
+type ComposeDeployOption struct {
+	Environment   []types2.EnvItem
+	CreatePath    bool
+	RemoveOrphans bool
+	PullImage     bool
+	Build         bool
+	DockerSdk     *docker.Client // 部署到指定的环境，为空时使用当前环境
+}
+
+// Deploy 通过 compose.Task 部署任务并等待命令结束，部署输出写入 output
+// ctx 取消时终止部署命令，部署结果保存到任务状态中，绑定仓库的任务同时记录部署的提交
+func (self Compose) Deploy(ctx context.Context, composeRow *entity.Compose, option ComposeDeployOption, output io.Writer) (*compose.ProjectResult, error) {
+	dockerSdk := option.DockerSdk
+	if dockerSdk == nil {
+		dockerSdk = docker.Sdk
+	}
+	tasker, warning, err := self.GetTasker(&entity.Compose{
+		Name: composeRow.Name,
+		Setting: &accessor.ComposeSettingOption{
+			Type:          composeRow.Setting.Type,
+			Uri:           composeRow.Setting.Uri,
+			RemoteUrl:     composeRow.Setting.RemoteUrl,
+			Environment:   option.Environment,
+			DockerEnvName: composeRow.Setting.DockerEnvName,
+			RunName:       composeRow.Setting.RunName,
+			DockerEnv:     dockerSdk.DockerEnv,
+		},
+	})
+	if err != nil {
+		return nil, function.ErrorMessage(define.ErrorMessageComposeParseYamlIncorrect, "error", errors.Join(warning, err).Error())
+	}
+	tasker.DockerSdk = dockerSdk
+
+	// 添加禁用服务，只有部署的时候需要，避免在获取详情时拿不到全部服务
+	if !function.IsEmptyArray(composeRow.Setting.DeployServiceName) {
+		services, err := tasker.Project.GetServices()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range services {
+			if !function.InArray(composeRow.Setting.DeployServiceName, item.Name) {
+				tasker.Project = tasker.Project.WithServicesDisabled(item.Name)
+			}
+		}
+	}
+
+	// 尝试创建 compose 挂载的目录，如果运行在容器内创建也无效
+	if option.CreatePath {
+		for _, service := range tasker.Project.Services {
+			for _, volume := range service.Volumes {
+				if filepath.IsAbs(volume.Source) {
+					if _, err = os.Stat(volume.Source); err != nil {
+						_ = os.MkdirAll(volume.Source, os.ModePerm)
+					}
+				}
+			}
+		}
+	}
+
+	// 如果是远程连接，尝试将本地的 compose 目录数据同步到端
+	if function.InArray([]string{
+		define.DockerRemoteTypeSSH,
+		define.DockerRemoteTypeTcp,
+	}, dockerSdk.DockerEnv.RemoteType) {
+		_, err := Explorer{}.Afs(dockerSdk, AfsCreateOption{
+			MountPoint: plugin.ExplorerName,
+			Init:       true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(storage.Local{}.GetStorageLocalPath(), tasker.Project.WorkingDir)
+		if err != nil {
+			return nil, err
+		}
+		importRootPath := path.Join("/dpanel", filepath.ToSlash(rel))
+		slog.Debug("compose container sync path", "path", importRootPath)
+
+		importFileList, err := imports.NewFileImport(importRootPath, imports.WithImportPath(tasker.Project.WorkingDir))
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			importFileList.Close()
+		}()
+		err = dockerSdk.ContainerImport(dockerSdk.Ctx, plugin.ExplorerName, "/", importFileList.Reader())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var response io.ReadCloser
+	if option.Build {
+		response, err = tasker.Build()
+	} else {
+		response, err = tasker.Deploy(option.RemoveOrphans, option.PullImage)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	finish := make(chan struct{})
+	defer close(finish)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = response.Close()
+		case <-finish:
+		}
+	}()
+
+	_, err = io.Copy(output, response)
+	if err != nil {
+		// copy 出错的只记录日志，不提示用户
+		slog.Warn("compose container deploy copy", "error", err)
+	}
+	if err != nil {
+		if function.ErrorHasKeyword(err, "denied: You may not login") {
+			_ = notice.Message{}.Error(".imagePullInvalidAuth")
+		} else if function.ErrorHasKeyword(err, "Mounts denied") {
+			_ = notice.Message{}.Error(".containerMountPathDenied")
+		}
+		composeRow.Setting.Message = err.Error()
+		composeRow.Setting.Status = accessor.ComposeStatusError
+	} else {
+		composeRow.Setting.Message = ""
+		composeRow.Setting.Status = ""
+	}
+	if composeRow.ID > 0 {
+		_ = dao.Compose.Save(composeRow)
+	}
+
+	// 查看当前任务下的容器 hash 值是否部署成功
+	runCompose := self.LsItemWithClient(dockerSdk, composeRow.Name)
+	if runCompose == nil || len(runCompose.ContainerList) != len(tasker.Project.Services) {
+		return nil, function.ErrorMessage(define.ErrorMessageComposeDeployIncorrect)
+	}
+
+	for _, item := range runCompose.ContainerList {
+		if item.Container.State != container.StateRunning {
+			return nil, function.ErrorMessage(define.ErrorMessageComposeDeployIncorrect)
+		}
+	}
+
+	// 如果当前容器配置过转发，则加入 dpanel-local 网络
+	for _, item := range runCompose.ContainerList {
+		if row, err := dao.SiteDomain.Where(dao.SiteDomain.ContainerID.In(item.Container.Names...)).First(); err == nil {
+			_ = dockerSdk.NetworkConnect(dockerSdk.Ctx, types2.NetworkItem{
+				Name: define.DPanelProxyNetworkName,
+			}, row.ContainerID)
+		}
+	}
+
+	if composeRow.ID > 0 && composeRow.Setting.Git != nil {
+		ComposeGit{}.Deployed(composeRow, runCompose)
+	}
+	return runCompose, nil
+}
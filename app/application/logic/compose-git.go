@@ -0,0 +1,387 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	exec2 "os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/compose"
+	"github.com/donknap/dpanel/common/service/crontab"
+	"github.com/donknap/dpanel/common/service/exec/local"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/patrickmn/go-cache"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+const (
+	ComposeGitDriftChanged = "changed" // 服务配置在部署后被修改
+	ComposeGitDriftMissing = "missing" // 部署的服务已经不存在
+	ComposeGitDriftAdded   = "added"   // 存在不是由仓库部署的服务
+
+	composeGitRef        = "refs/remotes/origin/dpanel"
+	composeGitHistoryMax = 20
+)
+
+type ComposeGit struct {
+}
+
+type ComposeGitSyncOption struct {
+	Commit string // 部署指定的提交用于回滚，为空时使用远程分支最新的提交
+	Deploy bool   // 为 false 时只拉取仓库，用于检查是否有新的提交
+	Force  bool   // 提交没有变化时也重新部署
+}
+
+type ComposeGitDrift struct {
+	Commit       string            `json:"commit"`
+	RemoteCommit string            `json:"remoteCommit"`
+	RepoChanged  bool              `json:"repoChanged"` // 仓库有新的提交未部署
+	Service      map[string]string `json:"service"`     // 服务名 => changed、missing、added
+}
+
+// RepoPath 每个任务单独保存一份仓库，保留历史提交用于回滚
+func (self ComposeGit) RepoPath(composeRow *entity.Compose) string {
+	return filepath.Join(storage.Local{}.GetSaveRootPath(), "git", fmt.Sprintf("compose-%d", composeRow.ID))
+}
+
+// Fetch 拉取绑定的分支，返回远程最新的提交
+func (self ComposeGit) Fetch(ctx context.Context, composeRow *entity.Compose) (string, error) {
+	if _, err := exec2.LookPath("git"); err != nil {
+		return "", function.ErrorMessage(define.ErrorMessageSystemStoreNotFoundGit)
+	}
+	option := composeRow.Setting.Git
+	repoPath := self.RepoPath(composeRow)
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		if err = os.MkdirAll(repoPath, os.ModePerm); err != nil {
+			return "", err
+		}
+		if _, err = self.git(ctx, repoPath, "init", "-q"); err != nil {
+			return "", err
+		}
+		if _, err = self.git(ctx, repoPath, "remote", "add", "origin", option.Url); err != nil {
+			return "", err
+		}
+	} else if _, err = self.git(ctx, repoPath, "remote", "set-url", "origin", option.Url); err != nil {
+		return "", err
+	}
+	ref := "HEAD"
+	if option.Branch != "" {
+		ref = option.Branch
+	}
+	// 拉取到固定的引用中，分支的历史提交不会被清理
+	if _, err := self.git(ctx, repoPath, "fetch", "-q", "origin", fmt.Sprintf("+%s:%s", ref, composeGitRef)); err != nil {
+		return "", err
+	}
+	return self.git(ctx, repoPath, "rev-parse", composeGitRef)
+}
+
+// Checkout 将指定提交中的文件同步到任务目录，只写入仓库中的文件并删除上次同步后已从仓库移除的文件
+// 任务目录本身及其中不属于仓库的文件（如 .env、覆盖文件、./data 等挂载数据）不会被删除
+func (self ComposeGit) Checkout(ctx context.Context, composeRow *entity.Compose, commit string) error {
+	option := composeRow.Setting.Git
+	tempDir, err := storage.Local{}.CreateTempDir("")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tempDir)
+	}()
+	if _, err = self.git(ctx, self.RepoPath(composeRow), "--work-tree", tempDir, "checkout", "-f", commit, "--", "."); err != nil {
+		return err
+	}
+	sourcePath := function.SafePathJoin(tempDir, option.Path)
+	if info, err := os.Stat(sourcePath); err != nil || !info.IsDir() {
+		return fmt.Errorf("path %s not found in repository", option.Path)
+	}
+
+	targetPath := storage.Local{}.GetComposeProjectPath(composeRow.Setting.DockerEnvName, composeRow.Name)
+	if err = os.MkdirAll(targetPath, os.ModePerm); err != nil {
+		return err
+	}
+	newFile, err := self.trackedFile(ctx, composeRow, commit)
+	if err != nil {
+		return err
+	}
+	for _, name := range newFile {
+		if err = self.copyFile(filepath.Join(sourcePath, name), function.SafePathJoin(targetPath, name)); err != nil {
+			return err
+		}
+	}
+	if option.Checkout != "" && option.Checkout != commit {
+		oldFile, err := self.trackedFile(ctx, composeRow, option.Checkout)
+		if err != nil {
+			slog.Warn("compose git list previous files", "commit", option.Checkout, "error", err)
+		}
+		for _, name := range oldFile {
+			if function.InArray(newFile, name) {
+				continue
+			}
+			removePath := function.SafePathJoin(targetPath, name)
+			if err = os.Remove(removePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			// 清理因此变为空的目录，不会删除任务目录
+			for dir := filepath.Dir(removePath); dir != filepath.Clean(targetPath) && strings.HasPrefix(dir, filepath.Clean(targetPath)); dir = filepath.Dir(dir) {
+				if os.Remove(dir) != nil {
+					break
+				}
+			}
+		}
+	}
+
+	uri := make([]string, 0)
+	safeComposeName := function.SafeFileName(composeRow.Name)
+	for _, suffix := range ComposeFileNameSuffix {
+		if _, err = os.Stat(filepath.Join(targetPath, suffix)); err == nil {
+			uri = append(uri, path.Join(safeComposeName, suffix))
+			if _, err = os.Stat(filepath.Join(targetPath, define.ComposeProjectDeployOverrideFileName)); err == nil {
+				uri = append(uri, path.Join(safeComposeName, define.ComposeProjectDeployOverrideFileName))
+			}
+			break
+		}
+	}
+	if function.IsEmptyArray(uri) {
+		return function.ErrorMessage(define.ErrorMessageComposeNotFoundYaml)
+	}
+	composeRow.Setting.Type = accessor.ComposeTypeStoragePath
+	composeRow.Setting.Uri = uri
+	option.Checkout = commit
+	return dao.Compose.Save(composeRow)
+}
+
+// trackedFile 返回提交中 Path 目录下的全部文件，路径相对于 Path
+func (self ComposeGit) trackedFile(ctx context.Context, composeRow *entity.Compose, commit string) ([]string, error) {
+	args := []string{"ls-tree", "-r", "-z", "--name-only", commit}
+	prefix := strings.Trim(path.Clean("/"+filepath.ToSlash(composeRow.Setting.Git.Path)), "/")
+	if prefix != "" {
+		args = append(args, "--", prefix+"/")
+	}
+	out, err := self.git(ctx, self.RepoPath(composeRow), args...)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, name := range strings.Split(out, "\x00") {
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = strings.TrimPrefix(name, prefix+"/")
+		}
+		result = append(result, filepath.FromSlash(name))
+	}
+	return result, nil
+}
+
+func (self ComposeGit) copyFile(source, target string) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	// 子模块在仓库中只是一个提交记录，不同步
+	if info.IsDir() {
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(target)
+		return os.Symlink(link, target)
+	}
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, info.Mode().Perm())
+}
+
+// Sync 拉取仓库，提交变化时同步任务文件并通过 compose.Task 重新部署，同一任务同时只会运行一个
+func (self ComposeGit) Sync(ctx context.Context, composeRow *entity.Compose, option ComposeGitSyncOption, output io.Writer) (err error) {
+	if composeRow.Setting.Git == nil {
+		return function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	cacheKey := fmt.Sprintf(storage.CacheKeyComposeGitSync, composeRow.ID)
+	if err = storage.Cache.Add(cacheKey, "running", cache.NoExpiration); err != nil {
+		return crontab.SkipRun
+	}
+	git := composeRow.Setting.Git
+	defer func() {
+		storage.Cache.Delete(cacheKey)
+		git.LastSyncAt = function.Ptr(time.Now())
+		git.LastError = ""
+		if err != nil {
+			git.LastError = err.Error()
+			facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+				Event:   define.NotificationEventComposeDeployFailed,
+				Subject: fmt.Sprintf("compose %s sync failed", composeRow.Name),
+				Content: err.Error(),
+			})
+		}
+		_ = dao.Compose.Save(composeRow)
+	}()
+
+	// 只限制仓库操作的时间，部署时拉取镜像可能需要更久
+	gitCtx, cancel := context.WithTimeout(ctx, time.Minute*5)
+	defer cancel()
+	git.RemoteCommit, err = self.Fetch(gitCtx, composeRow)
+	if err != nil {
+		return err
+	}
+	if !option.Deploy {
+		return nil
+	}
+	commit := git.RemoteCommit
+	if option.Commit != "" {
+		if commit, err = self.git(gitCtx, self.RepoPath(composeRow), "rev-parse", "--verify", option.Commit+"^{commit}"); err != nil {
+			return fmt.Errorf("commit %s not found", option.Commit)
+		}
+	}
+	if commit == git.Commit && !option.Force {
+		return nil
+	}
+	// 部署到绑定仓库时的环境，与面板当前切换到的环境无关
+	dockerSdk, closeFn, err := logic.Env{}.GetClient(git.DockerEnvName)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	if err = self.Checkout(gitCtx, composeRow, commit); err != nil {
+		return err
+	}
+	_, err = Compose{}.Deploy(ctx, composeRow, ComposeDeployOption{
+		Environment:   composeRow.Setting.Environment,
+		RemoveOrphans: true,
+		PullImage:     git.PullImage,
+		DockerSdk:     dockerSdk,
+	}, output)
+	return err
+}
+
+// Deployed 部署成功后记录提交及各服务的 config-hash
+func (self ComposeGit) Deployed(composeRow *entity.Compose, runCompose *compose.ProjectResult) {
+	git := composeRow.Setting.Git
+	if git.Checkout != "" && git.Checkout != git.Commit {
+		message, _ := self.git(context.Background(), self.RepoPath(composeRow), "log", "-1", "--format=%s", git.Checkout)
+		git.History = append([]accessor.ComposeGitDeploy{
+			{
+				Commit:     git.Checkout,
+				Message:    message,
+				DeployedAt: time.Now(),
+			},
+		}, git.History...)
+		if len(git.History) > composeGitHistoryMax {
+			git.History = git.History[:composeGitHistoryMax]
+		}
+	}
+	git.Commit = git.Checkout
+	git.ConfigHash = make(map[string]string)
+	for _, item := range runCompose.ContainerList {
+		git.ConfigHash[item.Service] = item.ConfigHash
+	}
+	_ = dao.Compose.Save(composeRow)
+}
+
+// Drift 比较运行中服务的 config-hash 与部署时的记录，以及仓库是否有未部署的提交
+func (self ComposeGit) Drift(composeRow *entity.Compose) ComposeGitDrift {
+	git := composeRow.Setting.Git
+	result := ComposeGitDrift{
+		Commit:       git.Commit,
+		RemoteCommit: git.RemoteCommit,
+		RepoChanged:  git.RemoteCommit != "" && git.RemoteCommit != git.Commit,
+		Service:      make(map[string]string),
+	}
+	running := make(map[string]string)
+	if runCompose := (Compose{}).LsItem(composeRow.Name); runCompose != nil {
+		for _, item := range runCompose.ContainerList {
+			running[item.Service] = item.ConfigHash
+		}
+	}
+	for name, hash := range git.ConfigHash {
+		if v, ok := running[name]; !ok {
+			result.Service[name] = ComposeGitDriftMissing
+		} else if v != hash {
+			result.Service[name] = ComposeGitDriftChanged
+		}
+	}
+	if git.Commit != "" {
+		for name := range running {
+			if _, ok := git.ConfigHash[name]; !ok {
+				result.Service[name] = ComposeGitDriftAdded
+			}
+		}
+	}
+	return result
+}
+
+// Poll 同步到达轮询间隔的任务，由计划任务每分钟调用
+func (self ComposeGit) Poll() (err error) {
+	list, err := dao.Compose.Where(gen.Cond(datatypes.JSONQuery("setting").HasKey("git"))...).Find()
+	if err != nil {
+		return err
+	}
+	for _, row := range list {
+		git := row.Setting.Git
+		if git == nil || git.Interval <= 0 {
+			continue
+		}
+		if git.LastSyncAt != nil && time.Since(*git.LastSyncAt) < time.Duration(git.Interval)*time.Minute {
+			continue
+		}
+		syncErr := self.Sync(context.Background(), row, ComposeGitSyncOption{
+			Deploy: git.AutoDeploy,
+		}, io.Discard)
+		if syncErr != nil && !errors.Is(syncErr, crontab.SkipRun) {
+			slog.Warn("compose git poll", "name", row.Name, "error", syncErr)
+			err = errors.Join(err, fmt.Errorf("%s: %w", row.Name, syncErr))
+		}
+	}
+	return err
+}
+
+// GetByWebhookToken 查找 webhook 对应的任务
+func (self ComposeGit) GetByWebhookToken(token string) (*entity.Compose, error) {
+	if token == "" {
+		return nil, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	return dao.Compose.Where(gen.Cond(
+		datatypes.JSONQuery("setting").Equals(token, "git", "webhookToken"),
+	)...).First()
+}
+
+func (self ComposeGit) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd, err := local.New(
+		local.WithCommandName("git"),
+		local.WithArgs(append([]string{"-C", dir}, args...)...),
+		local.WithEnv(append(os.Environ(), "GIT_TERMINAL_PROMPT=0")),
+		local.WithCtx(ctx),
+	)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.RunWithResult()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
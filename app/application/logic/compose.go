@@ -129,8 +129,13 @@ func (self Compose) Ps(projectNameList ...string) []*compose.ContainerResult {
 }
 
 func (self Compose) Ls() []*compose.ProjectResult {
+	return self.LsWithClient(docker.Sdk)
+}
+
+// LsWithClient 查询指定 docker 环境下的 compose 任务
+func (self Compose) LsWithClient(dockerClient *docker.Client) []*compose.ProjectResult {
 	composeGroupContainerList := make(map[string][]container.Summary)
-	if containerList, err := docker.Sdk.Client.ContainerList(docker.Sdk.Ctx, container.ListOptions{
+	if containerList, err := dockerClient.Client.ContainerList(dockerClient.Ctx, container.ListOptions{
 		All: true,
 	}); err == nil {
 		for _, summary := range containerList {
@@ -206,8 +211,13 @@ func (self Compose) Ls() []*compose.ProjectResult {
 }
 
 func (self Compose) LsItem(name string) *compose.ProjectResult {
+	return self.LsItemWithClient(docker.Sdk, name)
+}
+
+// LsItemWithClient 查询指定 docker 环境下的 compose 任务
+func (self Compose) LsItemWithClient(dockerClient *docker.Client, name string) *compose.ProjectResult {
 	var result *compose.ProjectResult
-	for _, item := range self.Ls() {
+	for _, item := range self.LsWithClient(dockerClient) {
 		if item.Name == name {
 			result = item
 			break
@@ -328,7 +338,7 @@ func (self Compose) ComposeProjectOptionsFn(dbRow *entity.Compose) []cli.Project
 	// 如果开启了独立目录，获取挂载目录也应该只取对应的的
 	mountComposePath := "/dpanel/compose"
 
-	if docker.Sdk.DockerEnv.EnableComposePath {
+	if dbRow.Setting.GetDockerEnv().EnableComposePath {
 		mountComposePath = filepath.Join("/", "dpanel", "compose-"+dbRow.Setting.DockerEnvName)
 	}
 
@@ -0,0 +1,201 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/compose"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+	"github.com/donknap/dpanel/common/service/docker/backup/repository"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/mholt/archives"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+type ComposeBackupOption struct {
+	ContainerBackupOption
+	Pause bool // 备份期间暂停服务代替停止，不能保证服务内存中的数据已经写入磁盘
+}
+
+// CreateCompose 为编排项目创建快照，包含任务目录下的全部文件及每个服务容器
+// 备份期间停止或暂停正在运行的服务，保证文件及挂载数据一致，完成后恢复服务
+func (self ContainerBackup) CreateCompose(ctx context.Context, dockerSdk *docker.Client, backupRow *entity.Backup, composeRow *entity.Compose, option ComposeBackupOption) (backupTar string, err error) {
+	backupRow.Setting.ComposeName = composeRow.Name
+	if function.IsEmptyArray(composeRow.Setting.Uri) || composeRow.Setting.Type == accessor.ComposeTypeDangling {
+		err = function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+		self.saveError(backupRow, err)
+		return "", err
+	}
+	projectDir := filepath.Dir(composeRow.Setting.GetUriFilePath())
+	containerList := make([]container.Summary, 0)
+	if project, _, ok := function.PluckArrayItemWalk(Compose{}.LsWithClient(dockerSdk), func(item *compose.ProjectResult) bool {
+		return item.Name == composeRow.Name
+	}); ok {
+		for _, item := range project.ContainerList {
+			containerList = append(containerList, item.Container)
+		}
+	}
+
+	backupTime := time.Now().Format(define.DateYmdHis)
+	suffix := fmt.Sprintf("dpanel-compose-%s-%s", composeRow.Name, backupTime)
+	backupRelTar := filepath.Join("compose", composeRow.Name, suffix+".snapshot")
+
+	return self.write(ctx, dockerSdk, backupRow, "compose", backupRelTar, option.ContainerBackupOption, func(b *backup.Builder, indexList *[]*repository.Index) ([]backup.Manifest, error) {
+		resume, err := self.quiesce(ctx, dockerSdk, containerList, option.Pause)
+		defer resume()
+		if err != nil {
+			return nil, err
+		}
+
+		// 只打包目录下的内容，恢复时直接解压到新的任务目录
+		files, err := archives.FilesFromDisk(ctx, nil, map[string]string{
+			projectDir + string(filepath.Separator): "",
+		})
+		if err != nil {
+			return nil, err
+		}
+		filesPath, err := b.Writer.WriteBlobFiles(function.Sha256([]byte(projectDir)), files)
+		if err != nil {
+			return nil, err
+		}
+		configPath, err := b.Writer.WriteBlobStruct(composeRow)
+		if err != nil {
+			return nil, err
+		}
+		manifest := []backup.Manifest{
+			{
+				Encryption: b.Writer.Encryption(),
+				Compose: &backup.ManifestCompose{
+					Name:   composeRow.Name,
+					Config: configPath,
+					Files:  filesPath,
+				},
+			},
+		}
+		for _, summary := range containerList {
+			containerInfo, err := dockerSdk.Client.ContainerInspect(ctx, summary.ID)
+			if err != nil {
+				return nil, err
+			}
+			item, err := self.writeContainer(ctx, dockerSdk, b, backupRow, containerInfo, backupTime, option.ContainerBackupOption, indexList)
+			if err != nil {
+				return nil, err
+			}
+			manifest = append(manifest, item)
+		}
+		return manifest, nil
+	})
+}
+
+// RestoreCompose 恢复编排任务的文件及记录，并重建快照中的全部服务容器
+// 任务恢复到当前环境的编排目录中，外部目录的任务转为存储目录任务
+func (self ContainerBackup) RestoreCompose(ctx context.Context, dockerSdk *docker.Client, b *backup.Builder, manifest []backup.Manifest, option ContainerBackupRestoreOption) (*entity.Compose, error) {
+	if option.Progress == nil {
+		option.Progress = func() {}
+	}
+	item, _, ok := function.PluckArrayItemWalk(manifest, func(item backup.Manifest) bool {
+		return item.Compose != nil
+	})
+	if !ok {
+		return nil, errors.New("compose project not found in snapshot")
+	}
+	content, err := b.Reader.ReadBlobsContent(item.Compose.Config)
+	if err != nil {
+		return nil, err
+	}
+	snapshotRow := entity.Compose{}
+	if err = json.Unmarshal(content, &snapshotRow); err != nil || snapshotRow.Setting == nil || function.IsEmptyArray(snapshotRow.Setting.Uri) {
+		return nil, errors.Join(errors.New("failed to parse compose configuration"), err)
+	}
+	if _, _, ok := function.PluckArrayItemWalk(Compose{}.LsWithClient(dockerSdk), func(item *compose.ProjectResult) bool {
+		return item.Name == snapshotRow.Name
+	}); ok {
+		return nil, function.ErrorMessage(define.ErrorMessageCommonIdAlreadyExists, "name", snapshotRow.Name)
+	}
+
+	dockerEnvName := define.DockerDefaultClientName
+	if docker.Sdk.DockerEnv.EnableComposePath {
+		dockerEnvName = docker.Sdk.DockerEnv.Name
+	}
+	composeRow, _ := dao.Compose.Where(dao.Compose.Name.Eq(snapshotRow.Name)).Where(gen.Cond(
+		datatypes.JSONQuery("setting").Equals(dockerEnvName, "dockerEnvName"),
+	)...).First()
+	if composeRow == nil {
+		composeRow = &entity.Compose{
+			Name: snapshotRow.Name,
+		}
+	}
+	setting := *snapshotRow.Setting
+	setting.DockerEnvName = dockerEnvName
+	setting.Status = ""
+	setting.Message = ""
+	setting.RunName = ""
+	setting.UpdatedAt = time.Now().Local().Format(time.DateTime)
+	if setting.Type == accessor.ComposeTypeOutPath {
+		setting.Type = accessor.ComposeTypeStoragePath
+		setting.Uri = function.PluckArrayWalk(setting.Uri, func(uri string) (string, bool) {
+			return filepath.Join(function.SafeFileName(snapshotRow.Name), filepath.Base(uri)), true
+		})
+	}
+	composeRow.Title = snapshotRow.Title
+	composeRow.Setting = &setting
+
+	option.Progress()
+	if err = b.Reader.Extract(item.Compose.Files, filepath.Dir(setting.GetUriFilePath())); err != nil {
+		return nil, err
+	}
+	if err = dao.Compose.Save(composeRow); err != nil {
+		return nil, err
+	}
+	if err = self.Restore(ctx, dockerSdk, b, manifest, option); err != nil {
+		return composeRow, err
+	}
+	return composeRow, nil
+}
+
+// quiesce 停止或暂停正在运行的服务，返回的函数用于恢复这些服务
+func (self ContainerBackup) quiesce(ctx context.Context, dockerSdk *docker.Client, containerList []container.Summary, pause bool) (func(), error) {
+	done := make([]string, 0)
+	resume := func() {
+		// 恢复时不使用传入的 ctx，避免取消后服务一直处于停止状态
+		for _, id := range done {
+			var err error
+			if pause {
+				err = dockerSdk.Client.ContainerUnpause(context.Background(), id)
+			} else {
+				err = dockerSdk.Client.ContainerStart(context.Background(), id, container.StartOptions{})
+			}
+			if err != nil {
+				slog.Warn("compose backup resume service", "id", id, "error", err)
+			}
+		}
+	}
+	for _, item := range containerList {
+		if item.State != container.StateRunning {
+			continue
+		}
+		var err error
+		if pause {
+			err = dockerSdk.Client.ContainerPause(ctx, item.ID)
+		} else {
+			err = dockerSdk.Client.ContainerStop(ctx, item.ID, container.StopOptions{})
+		}
+		if err != nil {
+			return resume, err
+		}
+		done = append(done, item.ID)
+	}
+	return resume, nil
+}
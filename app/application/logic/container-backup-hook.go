@@ -0,0 +1,190 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+)
+
+const backupDumpProfileCustom = "custom"
+
+type BackupDumpProfile struct {
+	Name     string   `json:"name"`
+	Image    []string `json:"image"` // 匹配镜像名称，不包含仓库及命名空间
+	FileName string   `json:"fileName"`
+	Command  string   `json:"command"` // 导出的数据输出到 stdout，账号密码从容器的环境变量中获取
+}
+
+var backupDumpProfileList = []BackupDumpProfile{
+	{
+		Name:     "mysql",
+		Image:    []string{"mysql", "mariadb", "percona", "percona-server"},
+		FileName: "dump.sql",
+		Command: `DUMP=mysqldump; command -v mariadb-dump >/dev/null 2>&1 && DUMP=mariadb-dump; ` +
+			`MYSQL_PWD="${MYSQL_ROOT_PASSWORD:-$MARIADB_ROOT_PASSWORD}" $DUMP -uroot --all-databases --single-transaction --quick --routines --events`,
+	},
+	{
+		Name:     "postgres",
+		Image:    []string{"postgres", "postgis", "timescaledb", "pgvector"},
+		FileName: "dump.sql",
+		Command:  `pg_dumpall -U "${POSTGRES_USER:-postgres}"`,
+	},
+	{
+		Name:     "redis",
+		Image:    []string{"redis", "redis-stack-server", "valkey"},
+		FileName: "dump.rdb",
+		Command: `CLI=redis-cli; command -v valkey-cli >/dev/null 2>&1 && CLI=valkey-cli; ` +
+			`$CLI ${REDIS_PASSWORD:+-a "$REDIS_PASSWORD" --no-auth-warning} --rdb /tmp/dpanel-dump.rdb >&2 && cat /tmp/dpanel-dump.rdb && rm -f /tmp/dpanel-dump.rdb`,
+	},
+	{
+		Name:     "mongo",
+		Image:    []string{"mongo"},
+		FileName: "dump.archive",
+		Command: `mongodump --archive --quiet ` +
+			`${MONGO_INITDB_ROOT_USERNAME:+--username "$MONGO_INITDB_ROOT_USERNAME" --password "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin}`,
+	},
+}
+
+// GetDumpProfileList 返回内置的数据库导出配置
+func (self ContainerBackup) GetDumpProfileList() []BackupDumpProfile {
+	return backupDumpProfileList
+}
+
+// GetDumpProfile 按备份设置获取导出配置，auto 时按镜像名称识别，没有需要导出的数据时返回 false
+func (self ContainerBackup) GetDumpProfile(hook *accessor.BackupHook, imageName string) (BackupDumpProfile, bool, error) {
+	if hook == nil {
+		return BackupDumpProfile{}, false, nil
+	}
+	if hook.DumpCommand != "" {
+		return BackupDumpProfile{
+			Name:     backupDumpProfileCustom,
+			FileName: "dump",
+			Command:  hook.DumpCommand,
+		}, true, nil
+	}
+	if hook.DumpProfile == "" {
+		return BackupDumpProfile{}, false, nil
+	}
+	if hook.DumpProfile == accessor.BackupDumpProfileAuto {
+		name := function.ImageTag(imageName).ImageName
+		profile, _, ok := function.PluckArrayItemWalk(backupDumpProfileList, func(item BackupDumpProfile) bool {
+			return function.InArray(item.Image, name)
+		})
+		return profile, ok, nil
+	}
+	profile, _, ok := function.PluckArrayItemWalk(backupDumpProfileList, func(item BackupDumpProfile) bool {
+		return item.Name == hook.DumpProfile
+	})
+	if !ok {
+		return profile, false, fmt.Errorf("dump profile %s not found", hook.DumpProfile)
+	}
+	return profile, true, nil
+}
+
+// runHook 执行前置命令及数据库导出并暂停容器，返回的函数用于恢复容器及执行后置命令
+// 执行失败时已经完成恢复，不需要再调用返回的函数
+func (self ContainerBackup) runHook(ctx context.Context, dockerSdk *docker.Client, b *backup.Builder, containerInfo container.InspectResponse, hook *accessor.BackupHook) (dump *backup.ManifestDump, finish func() error, err error) {
+	finish = func() error {
+		return nil
+	}
+	if hook == nil {
+		return nil, finish, nil
+	}
+	if containerInfo.State == nil || !containerInfo.State.Running {
+		return nil, nil, errors.New("backup hooks require the container to be running")
+	}
+	profile, enableDump, err := self.GetDumpProfile(hook, containerInfo.Config.Image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paused := false
+	finish = func() error {
+		// 恢复时不使用传入的 ctx，避免取消后容器一直处于暂停状态
+		var err error
+		if paused {
+			if err = dockerSdk.Client.ContainerUnpause(context.Background(), containerInfo.ID); err != nil {
+				slog.Warn("container backup hook unpause", "name", containerInfo.Name, "error", err)
+			}
+		}
+		if hook.PostCommand != "" {
+			if out, postErr := self.execCommand(context.Background(), dockerSdk, containerInfo.ID, hook.PostCommand); postErr != nil {
+				err = errors.Join(err, fmt.Errorf("post command: %w %s", postErr, strings.TrimSpace(out)))
+			}
+		}
+		return err
+	}
+
+	if hook.PreCommand != "" {
+		if out, err := self.execCommand(ctx, dockerSdk, containerInfo.ID, hook.PreCommand); err != nil {
+			return nil, nil, errors.Join(fmt.Errorf("pre command: %w %s", err, strings.TrimSpace(out)), finish())
+		}
+	}
+	if enableDump {
+		if dump, err = self.writeDump(ctx, dockerSdk, b, containerInfo, profile); err != nil {
+			return nil, nil, errors.Join(err, finish())
+		}
+	}
+	if hook.Pause {
+		if err = dockerSdk.Client.ContainerPause(ctx, containerInfo.ID); err != nil {
+			return nil, nil, errors.Join(err, finish())
+		}
+		paused = true
+	}
+	return dump, finish, nil
+}
+
+// writeDump 在容器中执行导出命令，将标准输出作为单独的数据写入快照
+func (self ContainerBackup) writeDump(ctx context.Context, dockerSdk *docker.Client, b *backup.Builder, containerInfo container.InspectResponse, profile BackupDumpProfile) (*backup.ManifestDump, error) {
+	exec, err := dockerSdk.Client.ContainerExecCreate(ctx, containerInfo.ID, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd: []string{
+			"/bin/sh",
+			"-c",
+			profile.Command,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	response, err := dockerSdk.Client.ContainerExecAttach(ctx, exec.ID, container.ExecStartOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Close()
+
+	var stderr bytes.Buffer
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, &stderr, response.Reader)
+		_ = pw.CloseWithError(err)
+	}()
+	savePath, err := b.Writer.WriteBlobReader(function.Sha256([]byte("dump:"+containerInfo.Name)), pr)
+	if err != nil {
+		return nil, err
+	}
+	inspect, err := dockerSdk.Client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.ExitCode != 0 {
+		return nil, fmt.Errorf("dump %s exited with code %d: %s", profile.Name, inspect.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return &backup.ManifestDump{
+		Profile:  profile.Name,
+		FileName: profile.FileName,
+		SavePath: savePath,
+	}, nil
+}
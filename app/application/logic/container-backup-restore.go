@@ -0,0 +1,377 @@
+package logic
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+	"github.com/donknap/dpanel/common/service/docker/imports"
+	"github.com/donknap/dpanel/common/service/plugin"
+	"github.com/donknap/dpanel/common/types/define"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type ContainerBackupRestoreOption struct {
+	NoStart  bool
+	Progress func() // 每完成一个恢复步骤调用一次
+}
+
+// Restore 将快照恢复到指定的 docker 环境中，容器已经存在时只恢复挂载数据
+func (self ContainerBackup) Restore(ctx context.Context, dockerSdk *docker.Client, b *backup.Builder, manifest []backup.Manifest, option ContainerBackupRestoreOption) error {
+	if option.Progress == nil {
+		option.Progress = func() {}
+	}
+	for _, item := range manifest {
+		// 编排项目中的任务文件由 RestoreCompose 恢复
+		if item.Config == "" {
+			continue
+		}
+		config, err := b.Reader.ReadBlobsContent(item.Config)
+		if err != nil {
+			return err
+		}
+		containerInfo := container.InspectResponse{}
+		err = json.Unmarshal(config, &containerInfo)
+		if err != nil || containerInfo.ContainerJSONBase == nil {
+			slog.Warn("container backup restore parse container", "json", string(config), "error", err)
+			return errors.Join(errors.New("failed to parse container configuration"), err)
+		}
+		option.Progress()
+		if _, err = dockerSdk.Client.ImageInspect(ctx, containerInfo.Config.Image); err != nil {
+			if item.Image != "" {
+				imageOut, err := b.Reader.ReadBlobs(item.Image)
+				if err != nil {
+					return err
+				}
+				imageLoadResponse, err := dockerSdk.Client.ImageLoad(ctx, imageOut)
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(io.Discard, imageLoadResponse.Body)
+				if err != nil {
+					return err
+				}
+				if _, err = dockerSdk.Client.ImageInspect(ctx, containerInfo.Config.Image); err != nil {
+					return function.ErrorMessage(define.ErrorMessageContainerBackupRestoreImportImageFailed)
+				}
+			} else {
+				imageNameDetail := function.ImageTag(containerInfo.Config.Image)
+				registryConfig := Image{}.GetRegistryConfig(imageNameDetail.Registry)
+				out, err := dockerSdk.Client.ImagePull(ctx, containerInfo.Config.Image, image.PullOptions{
+					RegistryAuth: registryConfig.AuthString(),
+				})
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(io.Discard, out)
+				if err != nil {
+					return err
+				}
+				_ = out.Close()
+			}
+		}
+
+		runContainer := false
+		var networkCreate []network.Inspect
+
+		newContainerName := containerInfo.Name
+		option.Progress()
+		if _, err := dockerSdk.Client.ContainerInspect(ctx, newContainerName); err != nil {
+			if !function.IsEmptyArray(item.Network) {
+				for _, s := range item.Network {
+					networkConfigContent, err := b.Reader.ReadBlobsContent(s)
+					if err != nil {
+						return err
+					}
+					networkInfo := network.Inspect{}
+					err = json.Unmarshal(networkConfigContent, &networkInfo)
+					if err != nil {
+						return err
+					}
+					if _, err := dockerSdk.Client.NetworkInspect(ctx, networkInfo.Name, network.InspectOptions{}); err != nil {
+						networkCreate = append(networkCreate, networkInfo)
+						if networkInfo.IPAM.Config != nil {
+							for i, ipamConfig := range networkInfo.IPAM.Config {
+								// fix docker 导出 ipv6 网关地址的时候附带了 /64
+								//"IPAM": {
+								//    "Config": [
+								//        {
+								//            "Gateway": "172.18.0.1",
+								//            "Subnet": "172.18.0.0/16"
+								//        },
+								//        {
+								//            "Gateway": "fd86:9ba5:b9cc::1/64",
+								//            "Subnet": "fd86:9ba5:b9cc::/64"
+								//        }
+								//    ],
+								//    "Driver": "default",
+								//    "Options": {}
+								//}
+								if b, _, ok := strings.Cut(ipamConfig.Gateway, "/"); ok {
+									networkInfo.IPAM.Config[i].Gateway = b
+								}
+							}
+						}
+						_, err = dockerSdk.Client.NetworkCreate(ctx, networkInfo.Name, network.CreateOptions{
+							Driver:     networkInfo.Driver,
+							Scope:      networkInfo.Scope,
+							EnableIPv4: &networkInfo.EnableIPv4,
+							EnableIPv6: &networkInfo.EnableIPv6,
+							IPAM:       &networkInfo.IPAM,
+							Internal:   networkInfo.Internal,
+							Attachable: networkInfo.Attachable,
+							Ingress:    networkInfo.Ingress,
+							ConfigOnly: networkInfo.ConfigOnly,
+							ConfigFrom: &networkInfo.ConfigFrom,
+							Options:    networkInfo.Options,
+							Labels:     networkInfo.Labels,
+						})
+						if err != nil {
+							if function.ErrorHasKeyword(err, "Pool overlaps with other one on this address space") {
+								return function.ErrorMessage(
+									".containerBackupRestoreNetworkConflict",
+									"name", networkInfo.Name,
+									"subnet", strings.Join(function.PluckArrayWalk(networkInfo.IPAM.Config, func(i network.IPAMConfig) (string, bool) {
+										return i.Subnet, true
+									}), ","),
+								)
+							}
+							return err
+						}
+					}
+				}
+			}
+
+			networkingConfig := &network.NetworkingConfig{
+				EndpointsConfig: map[string]*network.EndpointSettings{},
+			}
+			if containerInfo.NetworkSettings != nil && !function.IsEmptyMap(containerInfo.NetworkSettings.Networks) {
+				for name, settings := range containerInfo.NetworkSettings.Networks {
+					if name == network.NetworkBridge {
+						continue
+					}
+					settings.EndpointID = ""
+					settings.NetworkID = ""
+
+					if settings.IPAMConfig != nil {
+						settings.IPAMConfig.IPv6Address = ""
+						settings.IPAMConfig.IPv4Address = ""
+					}
+
+					settings.Gateway = ""
+					settings.IPAddress = "" // 这里把 Ip 置空，直接采用网络的子网自动分配，否则可能会造成 ip 与网络不
+
+					networkingConfig.EndpointsConfig[name] = settings
+				}
+			}
+
+			compactContainerInfo, err := dockerSdk.ContainerInspectCompact(containerInfo)
+			if err != nil {
+				return err
+			}
+			_, err = dockerSdk.Client.ContainerCreate(ctx, compactContainerInfo.Config, compactContainerInfo.HostConfig, networkingConfig, &v1.Platform{}, newContainerName)
+			if err != nil {
+				return err
+			}
+			// 创建完成后不能启动，要等恢复完数据后才可以
+			runContainer = true
+		}
+
+		// 兼容旧的数据
+		option.Progress()
+		if !function.IsEmptyArray(item.Volume) && function.IsEmptyArray(item.VolumeList) {
+			item.VolumeList = function.PluckArrayWalk(item.Volume, func(volume string) (backup.ManifestVolumeInfo, bool) {
+				mount, _, ok := function.PluckArrayItemWalk(containerInfo.Mounts, func(item container.MountPoint) bool {
+					return strings.HasSuffix(function.Sha256([]byte(item.Destination)), path.Base(volume))
+				})
+				if !ok {
+					return backup.ManifestVolumeInfo{}, false
+				}
+				return backup.ManifestVolumeInfo{
+					Destination: mount.Destination,
+					Source:      mount.Source,
+					SavePath:    volume,
+					Mode:        os.ModeDir,
+				}, true
+			})
+		}
+
+		if !function.IsEmptyArray(item.VolumeList) {
+			err = func() error {
+				var proxyContainerName string
+
+				// 仅当有挂载文件的时候才新建文件管理助手
+				if _, _, ok := function.PluckArrayItemWalk(item.VolumeList, func(item backup.ManifestVolumeInfo) bool {
+					return item.Mode.IsRegular()
+				}); ok {
+					ctx, ctxCancel := context.WithCancel(ctx)
+					defer ctxCancel()
+					proxyContainerName, err = plugin.NewHostExplorer(ctx, dockerSdk)
+					if err != nil {
+						return err
+					}
+				}
+
+				for _, volume := range item.VolumeList {
+					targetImportContainerName := newContainerName
+					targetImportPath := "/"
+
+					volumeReader, err := b.Reader.ReadVolume(volume)
+					if err != nil {
+						return err
+					}
+					tarReader := tar.NewReader(volumeReader)
+
+					// 因为从 docker 导出目录的时候，不会存储一级目录，而是从二级目录开始。
+					// 例如 docker cp caddy:/etc/caddy/ . 只会保存 caddy 目录，那么这里恢复的时候，也需要脱去一层目录
+					importOption := make([]imports.ImportFileOption, 0)
+					if volume.Mode.IsRegular() {
+						targetImportPath = path.Join("/", "mnt", "host", path.Dir(volume.Source))
+						targetImportContainerName = proxyContainerName
+						importOption = append(importOption, imports.WithImportFileInTar(tarReader, path.Base(volume.Source), func(header *tar.Header) bool {
+							return strings.HasSuffix(volume.Destination, header.Name)
+						}))
+						if p := function.PathClean(targetImportPath); p != "" {
+							_, err = dockerSdk.ContainerExecResult(ctx, proxyContainerName, "mkdir -p "+p)
+						}
+						if err != nil {
+							return err
+						}
+					} else {
+						targetImportPath = path.Dir(volume.Destination)
+						targetImportContainerName = newContainerName
+						importOption = append(importOption, imports.WithImportTar(tarReader))
+					}
+
+					if importFiles, err := imports.NewFileImport("/", importOption...); err == nil {
+						err = dockerSdk.ContainerImport(ctx, targetImportContainerName, targetImportPath, importFiles.Reader())
+						importFiles.Close()
+						if err != nil {
+							return err
+						}
+					}
+					_ = volumeReader.Close()
+				}
+				return nil
+			}()
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if !option.NoStart {
+			option.Progress()
+		}
+		if runContainer && !option.NoStart {
+			err = dockerSdk.Client.ContainerStart(ctx, newContainerName, container.StartOptions{})
+			if err != nil {
+				for _, inspect := range networkCreate {
+					_ = dockerSdk.Client.NetworkRemove(ctx, inspect.Name)
+				}
+				_ = dockerSdk.Client.ContainerRemove(ctx, newContainerName, container.RemoveOptions{})
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type ContainerMigrateOption struct {
+	DockerEnvName  string // 目标环境
+	StopSource     bool   // 迁移前停止源容器，保证挂载数据一致，迁移失败时重新启动
+	NoStart        bool
+	DeleteSnapshot bool // 迁移成功后删除中转快照
+}
+
+// Migrate 为容器创建包含镜像及挂载的快照，并在目标环境中重建容器
+func (self ContainerBackup) Migrate(ctx context.Context, dockerSdk *docker.Client, containerName string, option ContainerMigrateOption) (backupRow *entity.Backup, err error) {
+	if option.DockerEnvName == dockerSdk.Name {
+		return nil, errors.New("the target docker env must be different from the source")
+	}
+	dockerEnv, err := logic.Env{}.GetEnvByName(option.DockerEnvName)
+	if err != nil {
+		return nil, err
+	}
+	targetSdk, err := docker.NewClientWithDockerEnv(dockerEnv)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		targetSdk.Close()
+	}()
+
+	containerInfo, err := dockerSdk.Client.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = targetSdk.Client.ContainerInspect(ctx, containerInfo.Name); err == nil {
+		return nil, function.ErrorMessage(define.ErrorMessageCommonIdAlreadyExists, "name", strings.TrimLeft(containerInfo.Name, "/"))
+	}
+
+	if option.StopSource && containerInfo.State != nil && containerInfo.State.Running {
+		if err = dockerSdk.Client.ContainerStop(ctx, containerInfo.ID, container.StopOptions{}); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				if startErr := dockerSdk.Client.ContainerStart(context.Background(), containerInfo.ID, container.StartOptions{}); startErr != nil {
+					slog.Warn("container migrate restart source", "name", containerInfo.Name, "error", startErr)
+				}
+			}
+		}()
+	}
+
+	backupRow = self.NewRow(containerInfo.Name, fmt.Sprintf("migrate to %s", option.DockerEnvName), 0)
+	backupTar, err := self.Create(ctx, dockerSdk, backupRow, ContainerBackupOption{
+		EnableImage:  true,
+		EnableVolume: true,
+	})
+	if err != nil {
+		return backupRow, err
+	}
+	err = func() error {
+		repo, _ := self.Repository()
+		b, err := backup.New(
+			backup.WithTarPathPrefix(backupRow.ContainerID),
+			backup.WithPath(backupTar),
+			backup.WithReader(),
+			backup.WithRepository(repo),
+		)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = b.Close()
+		}()
+		manifest, err := b.Reader.Manifest()
+		if err != nil {
+			return err
+		}
+		return self.Restore(targetSdk.Ctx, targetSdk, b, manifest, ContainerBackupRestoreOption{
+			NoStart: option.NoStart,
+		})
+	}()
+	if err != nil {
+		return backupRow, err
+	}
+	if option.DeleteSnapshot {
+		_ = self.Delete([]*entity.Backup{backupRow})
+	}
+	return backupRow, nil
+}
@@ -0,0 +1,264 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+	"github.com/donknap/dpanel/common/types/define"
+)
+
+const containerBackupVerifyLabel = "com.dpanel.backup.verify"
+
+type ContainerBackupVerifyOption struct {
+	TestRestore   bool   // 在临时容器及网络中试恢复，完成后删除
+	HealthCommand string // 试恢复的容器启动后执行的检查命令，退出码不为 0 时校验失败
+	Secret        string // 加密快照的口令或私钥
+}
+
+// Verify 校验快照数据是否完整，结果记录在备份记录中
+func (self ContainerBackup) Verify(ctx context.Context, dockerSdk *docker.Client, backupRow *entity.Backup, option ContainerBackupVerifyOption) (*accessor.BackupVerify, error) {
+	result := &accessor.BackupVerify{
+		VerifiedAt:    time.Now(),
+		TestRestore:   option.TestRestore,
+		HealthCommand: option.HealthCommand,
+	}
+	err := func() error {
+		tarFilePath, err := self.GetLocalPath(ctx, backupRow)
+		if err != nil {
+			return err
+		}
+		repo, _ := self.Repository()
+		b, err := backup.New(
+			backup.WithTarPathPrefix(backupRow.ContainerID),
+			backup.WithPath(tarFilePath),
+			backup.WithReader(),
+			backup.WithRepository(repo),
+			logic.BackupEncryption{}.Identity(option.Secret),
+		)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = b.Close()
+		}()
+		manifest, err := b.Reader.Manifest()
+		if err != nil {
+			return err
+		}
+		verifyResult, err := b.Reader.Verify(manifest)
+		result.Blobs, result.Size = verifyResult.Blobs, verifyResult.Size
+		if err != nil {
+			return err
+		}
+		if !option.TestRestore {
+			return nil
+		}
+		for _, item := range manifest {
+			if item.Config == "" {
+				continue
+			}
+			output, err := self.testRestore(ctx, dockerSdk, b, item, option.HealthCommand)
+			result.Output += output
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	// 校验期间记录可能被修改，重新获取后只更新校验结果
+	if row, _ := dao.Backup.Where(dao.Backup.ID.Eq(backupRow.ID)).First(); row != nil {
+		backupRow = row
+	}
+	backupRow.Setting.Verify = result
+	_ = dao.Backup.Save(backupRow)
+	return result, err
+}
+
+// testRestore 在临时网络中创建不映射端口及挂载的容器，导入快照中的数据后启动并执行检查命令
+func (self ContainerBackup) testRestore(ctx context.Context, dockerSdk *docker.Client, b *backup.Builder, item backup.Manifest, healthCommand string) (output string, err error) {
+	config, err := b.Reader.ReadBlobsContent(item.Config)
+	if err != nil {
+		return "", err
+	}
+	containerInfo := container.InspectResponse{}
+	if err = json.Unmarshal(config, &containerInfo); err != nil || containerInfo.ContainerJSONBase == nil {
+		return "", errors.Join(errors.New("failed to parse container configuration"), err)
+	}
+	name := fmt.Sprintf("dpanel-verify-%s-%s", strings.TrimLeft(containerInfo.Name, "/"), time.Now().Format(define.DateYmdHis))
+	// 清理时不使用传入的 ctx，避免取消后残留临时容器
+	cleanCtx := context.Background()
+
+	if _, err = dockerSdk.Client.ImageInspect(ctx, containerInfo.Config.Image); err != nil {
+		if item.Image == "" {
+			if err = (Image{}).Pull(ctx, dockerSdk, containerInfo.Config.Image, io.Discard); err != nil {
+				return "", err
+			}
+		} else {
+			imageOut, err := b.Reader.ReadBlobs(item.Image)
+			if err != nil {
+				return "", err
+			}
+			response, err := dockerSdk.Client.ImageLoad(ctx, imageOut)
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(io.Discard, response.Body)
+			_ = response.Body.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+		defer func() {
+			if _, err := dockerSdk.Client.ImageRemove(cleanCtx, containerInfo.Config.Image, image.RemoveOptions{}); err != nil {
+				slog.Warn("container backup verify remove image", "image", containerInfo.Config.Image, "error", err)
+			}
+		}()
+	}
+
+	_, err = dockerSdk.Client.NetworkCreate(ctx, name, network.CreateOptions{
+		Internal: true,
+		Labels: map[string]string{
+			containerBackupVerifyLabel: "true",
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = dockerSdk.Client.NetworkRemove(cleanCtx, name)
+	}()
+
+	compactInfo, err := dockerSdk.ContainerInspectCompact(containerInfo)
+	if err != nil {
+		return "", err
+	}
+	containerConfig := compactInfo.Config
+	containerConfig.Hostname = ""
+	containerConfig.Labels = function.PluckMapWalk(containerConfig.Labels, func(k string, v string) bool {
+		return !strings.HasPrefix(k, "com.docker.compose.")
+	})
+	if containerConfig.Labels == nil {
+		containerConfig.Labels = make(map[string]string)
+	}
+	containerConfig.Labels[containerBackupVerifyLabel] = "true"
+
+	hostConfig := compactInfo.HostConfig
+	hostConfig.Binds = nil
+	hostConfig.Mounts = nil
+	hostConfig.VolumesFrom = nil
+	hostConfig.Links = nil
+	hostConfig.PortBindings = nil
+	hostConfig.PublishAllPorts = false
+	hostConfig.AutoRemove = false
+	hostConfig.NetworkMode = container.NetworkMode(name)
+	hostConfig.RestartPolicy = container.RestartPolicy{
+		Name: container.RestartPolicyDisabled,
+	}
+
+	_, err = dockerSdk.Client.ContainerCreate(ctx, containerConfig, hostConfig, &network.NetworkingConfig{}, nil, name)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = dockerSdk.Client.ContainerRemove(cleanCtx, name, container.RemoveOptions{
+			RemoveVolumes: true,
+			Force:         true,
+		})
+	}()
+
+	// 原容器的挂载不会带到临时容器中，数据直接导入到容器内的对应目录
+	for _, volume := range item.VolumeList {
+		volumeReader, err := b.Reader.ReadVolume(volume)
+		if err != nil {
+			return "", err
+		}
+		err = dockerSdk.ContainerImport(ctx, name, path.Dir(volume.Destination), volumeReader)
+		_ = volumeReader.Close()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", volume.Destination, err)
+		}
+	}
+
+	if err = dockerSdk.Client.ContainerStart(ctx, name, container.StartOptions{}); err != nil {
+		return "", err
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(time.Second * 5):
+	}
+	info, err := dockerSdk.Client.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if info.State == nil || !info.State.Running {
+		exitCode := 0
+		if info.State != nil {
+			exitCode = info.State.ExitCode
+		}
+		return "", fmt.Errorf("container exited with code %d after restore", exitCode)
+	}
+	if healthCommand == "" {
+		return "", nil
+	}
+	return self.execCommand(ctx, dockerSdk, name, healthCommand)
+}
+
+// execCommand 在容器中执行命令并返回输出，退出码不为 0 时返回错误
+func (self ContainerBackup) execCommand(ctx context.Context, dockerSdk *docker.Client, name string, cmd string) (string, error) {
+	execCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	exec, err := dockerSdk.Client.ContainerExecCreate(execCtx, name, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd: []string{
+			"/bin/sh",
+			"-c",
+			cmd,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	response, err := dockerSdk.Client.ContainerExecAttach(execCtx, exec.ID, container.ExecStartOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer response.Close()
+	var out bytes.Buffer
+	if _, err = stdcopy.StdCopy(&out, &out, response.Reader); err != nil {
+		return out.String(), err
+	}
+	inspect, err := dockerSdk.Client.ContainerExecInspect(execCtx, exec.ID)
+	if err != nil {
+		return out.String(), err
+	}
+	if inspect.ExitCode != 0 {
+		return out.String(), fmt.Errorf("command exited with code %d", inspect.ExitCode)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,413 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+	"github.com/donknap/dpanel/common/service/docker/backup/repository"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+type ContainerBackupOption struct {
+	EnableImage          bool
+	EnableImageContainer bool // 提交当前容器为新镜像后再备份
+	EnableVolume         bool
+	VolumeList           []string // 为空时备份全部挂载
+	TargetName           string   // 备份完成后上传到远程存储
+	EncryptionName       string   // 加密快照使用的密钥名称
+	Incremental          bool     // 挂载数据按内容分块保存到本地仓库中，只保存变化的分块，不支持加密及远程存储
+	Hook                 *accessor.BackupHook
+}
+
+// Check 校验备份选项，创建快照及保存备份计划时调用
+// 增量快照的分块以明文保存在本地仓库中，快照文件只记录分块列表，
+// 加密或上传远程存储后无法还原挂载数据，因此增量快照不支持加密及远程存储，需要时请使用完整快照
+func (self ContainerBackupOption) Check() error {
+	if self.Incremental && (self.EncryptionName != "" || self.TargetName != "") {
+		return function.ErrorMessage(define.ErrorMessageContainerBackupIncrementalUnsupported)
+	}
+	return nil
+}
+
+type ContainerBackup struct {
+}
+
+// NewRow 创建一条处理中的备份记录，调用方可以在备份完成前使用记录 id 跟踪进度
+func (self ContainerBackup) NewRow(containerId string, description string, scheduleId int32) *entity.Backup {
+	backupRow := &entity.Backup{
+		ContainerID: containerId,
+		Setting: &accessor.BackupSettingOption{
+			BackupTargetType: define.DockerContainerBackupTypeSnapshot,
+			VolumePathList:   make([]string, 0),
+			Status:           define.DockerImageBuildStatusProcess,
+			Description:      description,
+			ScheduleId:       scheduleId,
+		},
+	}
+	_ = dao.Backup.Save(backupRow)
+	return backupRow
+}
+
+// Create 为容器创建快照，完成后更新备份记录的状态并返回快照路径
+func (self ContainerBackup) Create(ctx context.Context, dockerSdk *docker.Client, backupRow *entity.Backup, option ContainerBackupOption) (backupTar string, err error) {
+	containerInfo, err := dockerSdk.Client.ContainerInspect(ctx, backupRow.ContainerID)
+	if err != nil {
+		self.saveError(backupRow, err)
+		return "", err
+	}
+	backupTime := time.Now().Format(define.DateYmdHis)
+	suffix := fmt.Sprintf("dpanel-%s-%s", strings.TrimLeft(containerInfo.Name, "/"), backupTime)
+	backupRelTar := filepath.Join(containerInfo.Name, suffix+".snapshot")
+	return self.write(ctx, dockerSdk, backupRow, containerInfo.Name, backupRelTar, option, func(b *backup.Builder, indexList *[]*repository.Index) ([]backup.Manifest, error) {
+		item, err := self.writeContainer(ctx, dockerSdk, b, backupRow, containerInfo, backupTime, option, indexList)
+		if err != nil {
+			return nil, err
+		}
+		return []backup.Manifest{
+			item,
+		}, nil
+	})
+}
+
+// write 创建快照文件并写入 manifest 及 info，完成后保存分块引用或上传到远程存储
+func (self ContainerBackup) write(ctx context.Context, dockerSdk *docker.Client, backupRow *entity.Backup, tarPathPrefix string, backupRelTar string, option ContainerBackupOption,
+	writeManifest func(b *backup.Builder, indexList *[]*repository.Index) ([]backup.Manifest, error)) (backupTar string, err error) {
+	backupTar = filepath.Join(storage.Local{}.GetBackupPath(), backupRelTar)
+	backupRow.Setting.BackupTar = filepath.ToSlash(backupRelTar)
+	backupRow.Setting.Encryption = option.EncryptionName
+	backupRow.Setting.Incremental = option.Incremental
+
+	// 已有的计划任务可能保存了不支持的组合，执行时再次检查
+	if err = option.Check(); err != nil {
+		self.saveError(backupRow, err)
+		return "", err
+	}
+	encryptionOption, err := logic.BackupEncryption{}.Writer(option.EncryptionName)
+	if err != nil {
+		self.saveError(backupRow, err)
+		return "", err
+	}
+	var repo *repository.Repository
+	if option.Incremental {
+		if repo, err = self.Repository(); err != nil {
+			self.saveError(backupRow, err)
+			return "", err
+		}
+		release := repo.Hold()
+		defer release()
+	}
+	indexList := make([]*repository.Index, 0)
+	b, err := backup.New(
+		backup.WithTarPathPrefix(tarPathPrefix),
+		backup.WithPath(backupTar),
+		encryptionOption,
+		backup.WithRepository(repo),
+		backup.WithWriter(),
+	)
+	if err != nil {
+		self.saveError(backupRow, err)
+		return "", err
+	}
+
+	info := backup.Info{}
+	info.Docker, err = dockerSdk.Client.ServerVersion(ctx)
+	if err != nil {
+		_ = b.Close()
+		self.saveError(backupRow, err)
+		return "", err
+	}
+
+	manifest, createErr := writeManifest(b, &indexList)
+
+	backupRow.Setting.Status = define.DockerImageBuildStatusError
+	if createErr != nil {
+		backupRow.Setting.Error = createErr.Error()
+	} else if err = b.Writer.WriteManifest(manifest); err != nil {
+		createErr = err
+		backupRow.Setting.Error = err.Error()
+	} else {
+		backupRow.Setting.Status = define.DockerImageBuildStatusSuccess
+	}
+
+	info.Backup = backupRow
+	if err = b.Writer.WriteConfigFile("info.json", info); err != nil && createErr == nil {
+		createErr = err
+	}
+	if err = b.Close(); err != nil && createErr == nil {
+		createErr = err
+	}
+	if repo != nil && createErr == nil {
+		createErr = repo.SaveRef(backupRow.Setting.BackupTar, indexList...)
+	}
+	if createErr != nil {
+		self.saveError(backupRow, createErr)
+		return backupTar, createErr
+	}
+	if fileInfo, err := os.Stat(backupTar); err == nil {
+		backupRow.Setting.Size = fileInfo.Size()
+	}
+	// 上传失败时保留本地快照，记录错误信息
+	if option.TargetName != "" {
+		if err = (logic.BackupTarget{}).Upload(ctx, option.TargetName, backupTar, backupRow.Setting.BackupTar); err != nil {
+			backupRow.Setting.Error = err.Error()
+			_ = dao.Backup.Save(backupRow)
+			return backupTar, err
+		}
+		backupRow.Setting.TargetName = option.TargetName
+		_ = os.Remove(backupTar)
+	}
+	_ = dao.Backup.Save(backupRow)
+	return backupTar, nil
+}
+
+// writeContainer 将容器的配置、网络及选择的镜像和挂载数据写入快照，返回容器对应的 manifest
+func (self ContainerBackup) writeContainer(ctx context.Context, dockerSdk *docker.Client, b *backup.Builder, backupRow *entity.Backup, containerInfo container.InspectResponse, backupTime string, option ContainerBackupOption, indexList *[]*repository.Index) (item backup.Manifest, err error) {
+	item = backup.Manifest{
+		Encryption: b.Writer.Encryption(),
+	}
+	dump, finish, err := self.runHook(ctx, dockerSdk, b, containerInfo, option.Hook)
+	if err != nil {
+		return item, err
+	}
+	defer func() {
+		if finishErr := finish(); finishErr != nil && err == nil {
+			err = finishErr
+		}
+	}()
+	if dump != nil {
+		item.Dump = dump
+		backupRow.Setting.DumpProfile = dump.Profile
+	}
+	backupRow.Setting.Hook = option.Hook
+	if option.EnableImage {
+		imageId := containerInfo.Image
+		imageName := containerInfo.Config.Image
+		// 提交容器为新镜像
+		if option.EnableImageContainer {
+			imageDetail := function.ImageTag(containerInfo.Config.Image)
+			imageName = fmt.Sprintf("%s-%s", imageDetail.Uri(), backupTime)
+
+			response, err := dockerSdk.Client.ContainerCommit(ctx, containerInfo.ID, container.CommitOptions{
+				Reference: imageName,
+			})
+			if err != nil {
+				return item, err
+			}
+			defer func() {
+				_, err = dockerSdk.Client.ImageRemove(dockerSdk.Ctx, response.ID, image.RemoveOptions{
+					Force: true,
+				})
+				if err != nil {
+					slog.Warn("container backup remove image", "error", err)
+				}
+			}()
+
+			imageId = response.ID
+		}
+
+		// 如果当前容器 commit 自己为新镜像时，需要在配置中更新名称
+		containerInfo.Config.Image = imageName
+
+		out, err := dockerSdk.Client.ImageSave(ctx, []string{
+			imageName,
+		})
+		if err != nil {
+			return item, err
+		}
+		imagePath, err := b.Writer.WriteBlobReader(imageId, out)
+		if err != nil {
+			return item, err
+		}
+		item.Image = imagePath
+	}
+
+	if option.EnableVolume {
+		if !function.IsEmptyArray(containerInfo.Mounts) {
+			for _, mount := range containerInfo.Mounts {
+				if !function.IsEmptyArray(option.VolumeList) && !function.InArray(option.VolumeList, mount.Destination) {
+					continue
+				}
+				stat, err := dockerSdk.Client.ContainerStatPath(ctx, containerInfo.ID, mount.Destination)
+				if err != nil {
+					return item, err
+				}
+
+				out, info, err := dockerSdk.Client.CopyFromContainer(ctx, containerInfo.ID, mount.Destination)
+				if err != nil {
+					return item, err
+				}
+
+				if info.Size > 0 {
+					var savePath string
+					if option.Incremental {
+						var index *repository.Index
+						var added int64
+						savePath, index, added, err = b.Writer.WriteBlobChunks(ctx, out)
+						backupRow.Setting.IncrementalSize += added
+						if err == nil {
+							*indexList = append(*indexList, index)
+						}
+					} else {
+						savePath, err = b.Writer.WriteBlobReader(function.Sha256([]byte(mount.Destination)), out)
+					}
+					if err != nil {
+						return item, err
+					}
+					item.VolumeList = append(item.VolumeList, backup.ManifestVolumeInfo{
+						SavePath:    savePath,
+						Destination: mount.Destination,
+						Source:      mount.Source,
+						Mode:        stat.Mode,
+						Chunked:     option.Incremental,
+					})
+					item.Volume = append(item.Volume, savePath)
+				}
+
+				backupRow.Setting.VolumePathList = append(backupRow.Setting.VolumePathList, mount.Destination)
+			}
+			sort.Slice(item.VolumeList, func(i, j int) bool {
+				if item.VolumeList[i].Mode.IsDir() != item.VolumeList[j].Mode.IsDir() {
+					return !item.VolumeList[i].Mode.IsDir()
+				}
+				return item.VolumeList[i].Source < item.VolumeList[j].Source
+			})
+		}
+	}
+
+	configPath, err := b.Writer.WriteBlobStruct(containerInfo)
+	if err != nil {
+		return item, err
+	}
+	item.Config = configPath
+
+	if containerInfo.NetworkSettings != nil && !function.IsEmptyMap(containerInfo.NetworkSettings.Networks) {
+		for name := range containerInfo.NetworkSettings.Networks {
+			if info, err := dockerSdk.Client.NetworkInspect(dockerSdk.Ctx, name, network.InspectOptions{}); err == nil {
+				configPath, err = b.Writer.WriteBlobStruct(info)
+				if err != nil {
+					return item, err
+				}
+				item.Network = append(item.Network, configPath)
+			}
+		}
+	}
+	return item, nil
+}
+
+// Repository 返回增量备份使用的分块仓库
+func (self ContainerBackup) Repository() (*repository.Repository, error) {
+	return repository.New(storage.Local{}.GetBackupRepositoryPath())
+}
+
+// GetLocalPath 返回快照的本地路径，保存在远程存储的快照会先下载到本地
+func (self ContainerBackup) GetLocalPath(ctx context.Context, backupRow *entity.Backup) (string, error) {
+	backupTar := function.SafePathJoin(storage.Local{}.GetBackupPath(), backupRow.Setting.BackupTar)
+	if _, err := os.Stat(backupTar); err == nil || backupRow.Setting.TargetName == "" {
+		return backupTar, nil
+	}
+	if err := (logic.BackupTarget{}).Download(ctx, backupRow.Setting.TargetName, backupRow.Setting.BackupTar, backupTar); err != nil {
+		return "", err
+	}
+	return backupTar, nil
+}
+
+// SyncTarget 将远程存储中不在备份记录里的快照添加到列表中
+func (self ContainerBackup) SyncTarget(ctx context.Context, targetName string) error {
+	fileList, err := logic.BackupTarget{}.List(ctx, targetName, "")
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool)
+	if list, err := dao.Backup.Where(gen.Cond(
+		datatypes.JSONQuery("setting").Equals(targetName, "targetName"),
+	)...).Find(); err == nil {
+		for _, item := range list {
+			exists[strings.TrimPrefix(item.Setting.BackupTar, "/")] = true
+		}
+	}
+	for _, file := range fileList {
+		// 面板备份保存在 dpanel 目录下，不属于容器快照
+		if !strings.HasSuffix(file.Name, ".snapshot") || strings.HasPrefix(file.Name, "dpanel/") || exists[strings.TrimPrefix(file.Name, "/")] {
+			continue
+		}
+		backupRow := &entity.Backup{
+			ContainerID: strings.Trim(path.Dir(file.Name), "/"),
+			Setting: &accessor.BackupSettingOption{
+				BackupTargetType: define.DockerContainerBackupTypeSnapshot,
+				BackupTar:        file.Name,
+				VolumePathList:   make([]string, 0),
+				Size:             file.Size,
+				Status:           define.DockerImageBuildStatusSuccess,
+				TargetName:       targetName,
+			},
+			CreatedAt: file.ModTime,
+		}
+		// 编排项目快照保存在 compose/项目名称 目录下
+		if composeName, ok := strings.CutPrefix(backupRow.ContainerID, "compose/"); ok {
+			backupRow.ContainerID = ""
+			backupRow.Setting.ComposeName = composeName
+		}
+		_ = dao.Backup.Create(backupRow)
+	}
+	return nil
+}
+
+// Delete 删除备份记录及对应的快照文件
+func (self ContainerBackup) Delete(list []*entity.Backup) error {
+	ids := make([]int32, 0)
+	for _, item := range list {
+		ids = append(ids, item.ID)
+		if item.Setting == nil || item.Setting.BackupTar == "" {
+			continue
+		}
+		if item.Setting.Incremental {
+			if repo, err := self.Repository(); err == nil {
+				_ = repo.DeleteRef(item.Setting.BackupTar)
+			}
+		}
+		if item.Setting.TargetName != "" {
+			if err := (logic.BackupTarget{}).Delete(context.Background(), item.Setting.TargetName, item.Setting.BackupTar); err != nil {
+				slog.Warn("container backup delete remote", "target", item.Setting.TargetName, "error", err)
+			}
+		}
+		_ = function.SafeDelete(storage.Local{}.GetBackupPath(), item.Setting.BackupTar)
+		// 删除临时文件
+		tempGlobDir := function.SafePathJoin(storage.Local{}.GetBackupPath(), filepath.Dir(item.Setting.BackupTar))
+		if tempFileList, err := filepath.Glob(filepath.Join(tempGlobDir, "*.temp")); err == nil {
+			for _, file := range tempFileList {
+				_ = function.SafeDelete(storage.Local{}.GetBackupPath(), file)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := dao.Backup.Where(dao.Backup.ID.In(ids...)).Delete()
+	return err
+}
+
+func (self ContainerBackup) saveError(backupRow *entity.Backup, err error) {
+	backupRow.Setting.Status = define.DockerImageBuildStatusError
+	backupRow.Setting.Error = err.Error()
+	_ = dao.Backup.Save(backupRow)
+}
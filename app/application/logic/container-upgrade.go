@@ -2,18 +2,29 @@ package logic
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/docker"
+	builder "github.com/donknap/dpanel/common/service/docker/container"
+	dockerTypes "github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/service/storage"
 	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
 	"github.com/patrickmn/go-cache"
 	registrySdk "github.com/we7coreteam/registry-go-sdk"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
 )
 
 const containerUpgradeCacheDuration = 10 * time.Minute
@@ -38,6 +49,14 @@ type ContainerUpgradeProgress struct {
 	Total   int      `json:"total"`
 }
 
+type ContainerUpgradeOption struct {
+	ImageTag               string // 更新容器时可以更改镜像 tag
+	EnableBak              bool
+	EnableResetImageConfig bool // 重置镜像内的配置
+	Progress               func(progress ContainerUpgradeProgress)
+	DockerSdk              *docker.Client // 在指定的环境中更新，为空时使用当前环境
+}
+
 type ContainerUpgrade struct{}
 
 type registryRequestContext struct {
@@ -129,3 +148,206 @@ func (ContainerUpgrade) Check(dockerSdk *docker.Client, containerInfo *container
 	}
 	return result, nil
 }
+
+// Upgrade 使用本地镜像重建容器，成功创建新容器后再停止并替换旧容器，结果通过通知发送
+func (self ContainerUpgrade) Upgrade(containerInfo container.InspectResponse, option ContainerUpgradeOption) (newContainerInfo container.InspectResponse, err error) {
+	if containerInfo.Name == "/"+facade.GetConfig().GetString("APP_NAME") {
+		return newContainerInfo, function.ErrorMessage(define.ErrorMessageContainerUpgradeDPanel)
+	}
+	if option.Progress == nil {
+		option.Progress = func(progress ContainerUpgradeProgress) {}
+	}
+	dockerSdk := option.DockerSdk
+	if dockerSdk == nil {
+		dockerSdk = docker.Sdk
+	}
+	defer func() {
+		containerName := strings.TrimLeft(containerInfo.Name, "/")
+		if err != nil {
+			facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+				Event:   define.NotificationEventContainerUpgradeFail,
+				Subject: fmt.Sprintf("container %s upgrade failed", containerName),
+				Content: err.Error(),
+			})
+			return
+		}
+		facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+			Event:   define.NotificationEventContainerUpgrade,
+			Subject: fmt.Sprintf("container %s upgraded", containerName),
+			Content: fmt.Sprintf("%s on %s", containerInfo.Config.Image, dockerSdk.Name),
+		})
+	}()
+	startContainer := containerInfo.State.Running
+	progressSteps := []string{define.ContainerUpgradeStepCreate}
+	if startContainer {
+		progressSteps = append(progressSteps, define.ContainerUpgradeStepStop)
+	}
+	progressSteps = append(progressSteps, define.ContainerUpgradeStepReplace)
+	if startContainer {
+		progressSteps = append(progressSteps, define.ContainerUpgradeStepStart)
+	}
+	progressCurrent := 0
+	progress := func() {
+		option.Progress(ContainerUpgradeProgress{
+			Steps:   progressSteps,
+			Current: progressCurrent,
+			Total:   len(progressSteps),
+		})
+	}
+	progress()
+
+	progressCurrent++
+	progress()
+
+	bakTime := time.Now().Format(define.DateYmdHis)
+
+	imageName := containerInfo.Config.Image
+	if option.ImageTag != "" {
+		imageName = option.ImageTag
+	}
+
+	imageInfo, err := dockerSdk.Client.ImageInspect(dockerSdk.Ctx, imageName)
+	if err != nil {
+		return newContainerInfo, err
+	}
+	// 如果旧的容器使用的镜像和重新拉取的镜像一致则不升级
+	// 多平台下的其它平台镜像推送后，也会导致 digest 不一致
+	// 不一定就是本平台镜像有更新
+	// 这里还是选择更新对齐 digest
+	oldContainerImageId := containerInfo.Image
+
+	// 成功的创建一个新的容器后再对旧的进停止或是删除操作
+	newContainerName := fmt.Sprintf("%s-copy-%s", containerInfo.Name, bakTime)
+
+	options := []builder.Option{
+		builder.WithDockerSdk(dockerSdk),
+		builder.WithContainerInfo(containerInfo),
+		builder.WithContainerName(newContainerName),
+		builder.WithImage(imageName, false),
+	}
+	if containerInfo.NetworkSettings != nil {
+		for name, endpoint := range containerInfo.NetworkSettings.Networks {
+			options = append(options, builder.WithNetworkEndpoint(name, endpoint))
+		}
+	}
+	if option.EnableResetImageConfig {
+		options = append(options,
+			builder.WithEnv(function.PluckArrayWalk(imageInfo.Config.Env, func(item string) (dockerTypes.EnvItem, bool) {
+				return dockerTypes.NewEnvItemFromString(item), true
+			})...),
+			builder.WithLabels(function.PluckMapWalkArray(imageInfo.Config.Labels, func(name string, value string) (dockerTypes.ValueItem, bool) {
+				return dockerTypes.ValueItem{
+					Name:  name,
+					Value: value,
+				}, true
+			})...),
+			builder.WithWorkDir(imageInfo.Config.WorkingDir),
+			builder.WithCommand(imageInfo.Config.Cmd),
+			builder.WithEntrypoint(imageInfo.Config.Entrypoint),
+		)
+	}
+	containerBuilder, err := builder.New(options...)
+	if err != nil {
+		return newContainerInfo, err
+	}
+
+	out, err := containerBuilder.Execute()
+	if err != nil {
+		errRemove := dockerSdk.Client.ContainerRemove(dockerSdk.Ctx, newContainerName, container.RemoveOptions{})
+		return newContainerInfo, errors.Join(err, errRemove)
+	}
+
+	if containerInfo.State.Running {
+		progressCurrent++
+		progress()
+		err = dockerSdk.Client.ContainerStop(dockerSdk.Ctx, containerInfo.Name, container.StopOptions{})
+		if err != nil {
+			return newContainerInfo, err
+		}
+		if containerInfo.HostConfig.AutoRemove {
+			// 如果是旧容器配置了自动删除，则等待容器自动被销毁
+			for {
+				time.Sleep(time.Second * 1)
+				if _, err = dockerSdk.Client.ContainerInspect(dockerSdk.Ctx, containerInfo.Name); err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	bakContainerName := fmt.Sprintf("%s-bak-%s", containerInfo.Name, bakTime)
+	bakImageName := fmt.Sprintf("%s-bak-%s", containerInfo.Config.Image, bakTime)
+	progressCurrent++
+	progress()
+
+	// 未备份旧容器，需要先删除，否则名称会冲突
+	if option.EnableBak {
+		if !containerInfo.HostConfig.AutoRemove {
+			// 备份旧容器
+			err = dockerSdk.Client.ContainerRename(
+				dockerSdk.Ctx,
+				containerInfo.Name,
+				bakContainerName,
+			)
+			if err != nil {
+				return newContainerInfo, err
+			}
+		}
+
+		if oldContainerImageId != imageInfo.ID {
+			// 备份旧镜像
+			err = dockerSdk.Client.ImageTag(
+				dockerSdk.Ctx,
+				containerInfo.Image,
+				bakImageName,
+			)
+			if err != nil {
+				return newContainerInfo, err
+			}
+		}
+	} else {
+		if !containerInfo.HostConfig.AutoRemove {
+			err = dockerSdk.Client.ContainerRemove(dockerSdk.Ctx, containerInfo.Name, container.RemoveOptions{})
+			if err != nil {
+				return newContainerInfo, err
+			}
+		}
+		_, err = dockerSdk.Client.ImageRemove(dockerSdk.Ctx, containerInfo.Image, image.RemoveOptions{})
+		if err != nil {
+			slog.Debug("container upgrade delete image", "error", err.Error())
+		}
+	}
+
+	err = dockerSdk.Client.ContainerRename(
+		dockerSdk.Ctx,
+		newContainerName,
+		containerInfo.Name,
+	)
+	if err != nil {
+		return newContainerInfo, err
+	}
+
+	newContainerInfo, err = dockerSdk.Client.ContainerInspect(dockerSdk.Ctx, out.ID)
+	if err != nil {
+		return newContainerInfo, err
+	}
+	// 容器升级后，将表中的数据更新为新的容器数据
+	if siteRow, _ := dao.Site.Where(gen.Cond(datatypes.JSONQuery("container_info").Equals(containerInfo.ID, "Id"))...).First(); siteRow != nil {
+		siteRow.ContainerInfo = &accessor.SiteContainerInfoOption{
+			Id:   out.ID,
+			Info: newContainerInfo,
+		}
+		_ = dao.Site.Save(siteRow)
+	}
+
+	// 旧容器如果是停止状态，重建后保持不启动
+	if startContainer {
+		progressCurrent++
+		progress()
+		err = dockerSdk.Client.ContainerStart(dockerSdk.Ctx, containerInfo.Name, container.StartOptions{})
+		if err != nil {
+			return newContainerInfo, err
+		}
+	}
+	return newContainerInfo, nil
+}
@@ -0,0 +1,240 @@
+package logic
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/crontab"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/patrickmn/go-cache"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+type DeployWebhook struct {
+}
+
+func (self DeployWebhook) GetList() []accessor.DeployWebhook {
+	list := make([]accessor.DeployWebhook, 0)
+	logic.Setting{}.GetByKey(logic.SettingGroupSetting, logic.SettingGroupSettingDeployWebhook, &list)
+	return list
+}
+
+func (self DeployWebhook) Get(name string) (accessor.DeployWebhook, error) {
+	item, _, ok := function.PluckArrayItemWalk(self.GetList(), func(item accessor.DeployWebhook) bool {
+		return item.Name == name
+	})
+	if !ok {
+		return accessor.DeployWebhook{}, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	return item, nil
+}
+
+// GetByToken 通过地址中的令牌查找，比较时不泄露令牌长度以外的信息
+func (self DeployWebhook) GetByToken(token string) (accessor.DeployWebhook, error) {
+	if token != "" {
+		for _, item := range self.GetList() {
+			if item.Token != "" && subtle.ConstantTimeCompare([]byte(item.Token), []byte(token)) == 1 {
+				return item, nil
+			}
+		}
+	}
+	return accessor.DeployWebhook{}, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+}
+
+func (self DeployWebhook) Save(list []accessor.DeployWebhook) error {
+	return logic.Setting{}.Save(&entity.Setting{
+		GroupName: logic.SettingGroupSetting,
+		Name:      logic.SettingGroupSettingDeployWebhook,
+		Value: &accessor.SettingValueOption{
+			DeployWebhook: list,
+		},
+	})
+}
+
+// Verify 校验请求签名，未设置密钥时不校验
+// 支持 GitHub (X-Hub-Signature-256)、Gitea (X-Gitea-Signature)、Gogs (X-Gogs-Signature) 的 HMAC-SHA256 签名及 GitLab (X-Gitlab-Token) 的密钥头
+func (self DeployWebhook) Verify(item accessor.DeployWebhook, header http.Header, body []byte) error {
+	if item.Secret == "" {
+		return nil
+	}
+	secret, err := function.RSADecode(item.Secret, nil)
+	if err != nil {
+		return err
+	}
+	if v := header.Get("X-Gitlab-Token"); v != "" {
+		if subtle.ConstantTimeCompare([]byte(v), []byte(secret)) != 1 {
+			return errors.New("invalid webhook token")
+		}
+		return nil
+	}
+	signature := ""
+	for _, name := range []string{"X-Hub-Signature-256", "X-Gitea-Signature", "X-Gogs-Signature"} {
+		if v := header.Get(name); v != "" {
+			signature = strings.TrimPrefix(v, "sha256=")
+			break
+		}
+	}
+	if signature == "" {
+		return errors.New("webhook signature not found")
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.New("invalid webhook signature")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), actual) {
+		return errors.New("invalid webhook signature")
+	}
+	return nil
+}
+
+func (self DeployWebhook) IsRunning(name string) bool {
+	_, ok := storage.Cache.Get(fmt.Sprintf(storage.CacheKeyDeployWebhookRun, name))
+	return ok
+}
+
+// LogPath 最近一次运行的日志，每次运行时覆盖
+func (self DeployWebhook) LogPath(name string) string {
+	return filepath.Join(storage.Local{}.GetSaveRootPath(), "deploy-webhook", function.SafeFileName(name)+".log")
+}
+
+// Run 更新容器或部署编排任务，输出写入 output 及运行日志，同一个地址同时只会运行一个
+// 在绑定的环境下执行，与面板当前切换到的环境无关
+func (self DeployWebhook) Run(ctx context.Context, item accessor.DeployWebhook, output io.Writer) (err error) {
+	cacheKey := fmt.Sprintf(storage.CacheKeyDeployWebhookRun, item.Name)
+	if err = storage.Cache.Add(cacheKey, "running", cache.NoExpiration); err != nil {
+		return crontab.SkipRun
+	}
+	logPath := self.LogPath(item.Name)
+	if err = os.MkdirAll(filepath.Dir(logPath), os.ModePerm); err != nil {
+		storage.Cache.Delete(cacheKey)
+		return err
+	}
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		storage.Cache.Delete(cacheKey)
+		return err
+	}
+	output = io.MultiWriter(output, logFile)
+
+	// 容器更新失败时由 ContainerUpgrade.Upgrade 发送通知
+	notify := true
+	defer func() {
+		if err != nil {
+			_, _ = fmt.Fprintf(output, "\nerror: %s\n", err.Error())
+		} else {
+			_, _ = fmt.Fprintf(output, "\ndone\n")
+		}
+		_ = logFile.Close()
+		storage.Cache.Delete(cacheKey)
+		if err != nil && notify {
+			notificationEvent := define.NotificationEventContainerUpgradeFail
+			if item.Type == accessor.DeployWebhookTypeCompose {
+				notificationEvent = define.NotificationEventComposeDeployFailed
+			}
+			facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+				Event:   notificationEvent,
+				Subject: fmt.Sprintf("deploy webhook %s failed", item.Name),
+				Content: err.Error(),
+			})
+		}
+		// 运行期间配置可能被修改，重新获取后只更新运行结果
+		list := self.GetList()
+		if index, ok := function.IndexArrayWalk(list, func(i accessor.DeployWebhook) bool {
+			return i.Name == item.Name
+		}); ok {
+			list[index].LastRunAt = function.Ptr(time.Now())
+			list[index].LastError = ""
+			if err != nil {
+				list[index].LastError = err.Error()
+			}
+			_ = self.Save(list)
+		}
+	}()
+
+	_, _ = fmt.Fprintf(output, "deploy %s %s at %s\n", item.Type, item.Target, time.Now().Format(define.DateShowYmdHis))
+	dockerSdk, closeFn, err := logic.Env{}.GetClient(item.DockerEnvName)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	switch item.Type {
+	case accessor.DeployWebhookTypeContainer:
+		containerInfo, err := dockerSdk.ContainerCopyInspect(ctx, item.Target)
+		if err != nil {
+			return err
+		}
+		if item.PullImage {
+			_, _ = fmt.Fprintf(output, "pull image %s\n", containerInfo.Config.Image)
+			if err = (Image{}).Pull(ctx, dockerSdk, containerInfo.Config.Image, output); err != nil {
+				return err
+			}
+		}
+		notify = false
+		newContainerInfo, err := ContainerUpgrade{}.Upgrade(containerInfo, ContainerUpgradeOption{
+			DockerSdk: dockerSdk,
+			EnableBak: item.EnableBak,
+			Progress: func(progress ContainerUpgradeProgress) {
+				if progress.Current > 0 && progress.Current <= len(progress.Steps) {
+					_, _ = fmt.Fprintf(output, "[%d/%d] %s\n", progress.Current, progress.Total, progress.Steps[progress.Current-1])
+				}
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(output, "container %s upgraded, id %s\n", item.Target, newContainerInfo.ID)
+		return nil
+	case accessor.DeployWebhookTypeCompose:
+		composeRow, err := self.getCompose(dockerSdk, item.Target)
+		if err != nil {
+			return err
+		}
+		_, err = Compose{}.Deploy(ctx, composeRow, ComposeDeployOption{
+			Environment:   composeRow.Setting.Environment,
+			RemoveOrphans: item.RemoveOrphans,
+			PullImage:     item.PullImage,
+			DockerSdk:     dockerSdk,
+		}, output)
+		return err
+	}
+	return fmt.Errorf("unsupported webhook type %s", item.Type)
+}
+
+// getCompose 查找环境下的编排任务
+func (self DeployWebhook) getCompose(dockerSdk *docker.Client, name string) (*entity.Compose, error) {
+	dockerEnvName := define.DockerDefaultClientName
+	if dockerSdk.DockerEnv.EnableComposePath {
+		dockerEnvName = dockerSdk.DockerEnv.Name
+	}
+	composeRow, _ := dao.Compose.Where(dao.Compose.Name.Eq(name)).Where(gen.Cond(
+		datatypes.JSONQuery("setting").Equals(dockerEnvName, "dockerEnvName"),
+	)...).First()
+	if composeRow == nil || composeRow.Setting == nil {
+		return nil, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	return composeRow, nil
+}
@@ -1,13 +1,19 @@
 package logic
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 
+	"github.com/docker/docker/api/types/image"
 	dockerRegistry "github.com/docker/docker/api/types/registry"
 	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/docker"
+	types2 "github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/types/define"
 	"github.com/we7coreteam/registry-go-sdk/types"
 )
@@ -21,7 +27,7 @@ type Registry struct {
 func (self Registry) AuthString() string {
 	authString, err := dockerRegistry.EncodeAuthConfig(self.config)
 	if err != nil {
-		slog.Debug("get registry auth string", err.Error())
+		slog.Debug("get registry auth string", "error", err.Error())
 		return ""
 	}
 	return authString
@@ -59,7 +65,7 @@ func (self Image) GetRegistryConfig(registryUrl string) *Registry {
 	}
 
 	if registryRow.Setting.EnableHttp {
-		result.Address = append(result.Address, fmt.Sprintf("http://"+registryRow.ServerAddress))
+		result.Address = append(result.Address, "http://"+registryRow.ServerAddress)
 	}
 
 	if !function.IsEmptyArray(registryRow.Setting.Proxy) {
@@ -79,3 +85,40 @@ func (self Image) GetRegistryConfig(registryUrl string) *Registry {
 
 	return result
 }
+
+// Pull 使用仓库中配置的账号拉取镜像，每一层的状态变化以文本形式写入 output，忽略下载进度
+func (self Image) Pull(ctx context.Context, dockerSdk *docker.Client, imageName string, output io.Writer) error {
+	registryConfig := self.GetRegistryConfig(function.ImageTag(imageName).Registry)
+	out, err := dockerSdk.Client.ImagePull(ctx, imageName, image.PullOptions{
+		RegistryAuth: registryConfig.AuthString(),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+	decoder := json.NewDecoder(out)
+	lastStatus := make(map[string]string)
+	for {
+		message := types2.BuildMessage{}
+		if err = decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if message.ErrorDetail.Message != "" {
+			return errors.New(message.ErrorDetail.Message)
+		}
+		if message.Status == "" || lastStatus[message.Id] == message.Status {
+			continue
+		}
+		lastStatus[message.Id] = message.Status
+		if message.Id != "" {
+			_, _ = fmt.Fprintf(output, "%s: %s\n", message.Id, message.Status)
+		} else {
+			_, _ = fmt.Fprintln(output, message.Status)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/service/acme"
+	"github.com/donknap/dpanel/common/service/notice"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/patrickmn/go-cache"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+)
+
+var DefaultCertMonitorThreshold = []int{30, 7, 1}
+
+// certMonitorLevelExpired 证书过期后单独提醒一次
+const certMonitorLevelExpired = -1
+
+// CertState 以证书文件路径为键，包含面板管理的证书及站点中手动填写的证书
+func (self SiteCert) CertState() (map[string]*acme.Cert, error) {
+	result := make(map[string]*acme.Cert)
+	builder, err := self.New(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	list, err := builder.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range list {
+		result[filepath.Join(cert.GetRootPath(), CertFileName)] = cert
+	}
+	domainList, _ := dao.SiteDomain.Find()
+	for _, item := range domainList {
+		if item.Setting == nil || item.Setting.SslCrt == "" {
+			continue
+		}
+		if _, ok := result[item.Setting.SslCrt]; ok {
+			continue
+		}
+		if cert, err := acme.ParseCertFile(item.Setting.SslCrt); err == nil {
+			result[item.Setting.SslCrt] = cert
+		}
+	}
+	return result, nil
+}
+
+// Check 证书剩余天数到达阈值时发送站内消息及外部通知，每个阈值只提醒一次
+// 提醒记录只保存在内存中，面板重启后会重新提醒当前所处的阈值
+func (self SiteCert) Check() error {
+	setting := accessor.CertMonitor{}
+	logic.Setting{}.GetByKey(logic.SettingGroupSetting, logic.SettingGroupSettingCertMonitor, &setting)
+	if setting.Disable {
+		return nil
+	}
+	threshold := setting.Threshold
+	if len(threshold) == 0 {
+		threshold = DefaultCertMonitorThreshold
+	}
+	threshold = slices.Clone(threshold)
+	slices.Sort(threshold)
+
+	list, err := self.CertState()
+	if err != nil {
+		return err
+	}
+	for path, cert := range list {
+		level := certMonitorLevelExpired
+		if cert.DaysLeft >= 0 {
+			index := slices.IndexFunc(threshold, func(i int) bool {
+				return cert.DaysLeft <= i
+			})
+			if index == -1 {
+				continue
+			}
+			level = threshold[index]
+		}
+		cacheKey := fmt.Sprintf(storage.CacheKeySiteCertNotified, path, cert.NotAfter.Unix(), level)
+		if err = storage.Cache.Add(cacheKey, true, cache.NoExpiration); err != nil {
+			continue
+		}
+		subject := fmt.Sprintf("certificate %s expires in %d days", cert.MainDomain, cert.DaysLeft)
+		if level == certMonitorLevelExpired {
+			subject = fmt.Sprintf("certificate %s has expired", cert.MainDomain)
+		}
+		content := fmt.Sprintf("domain: %v\nissuer: %s\nnotAfter: %s\npath: %s", cert.Domain, cert.Issuer, cert.NotAfter.Format(define.DateShowYmdHis), path)
+		if cert.LastError != "" {
+			content += "\nrenew error: " + cert.LastError
+		}
+		_ = notice.Message{}.Warning(".siteCertExpiring", "name", cert.MainDomain, "days", strconv.Itoa(cert.DaysLeft))
+		facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+			Event:   define.NotificationEventCertExpiring,
+			Subject: subject,
+			Content: content,
+		})
+	}
+	return nil
+}
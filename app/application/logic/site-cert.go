@@ -0,0 +1,205 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/acme"
+	"github.com/donknap/dpanel/common/service/crontab"
+	"github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/notice"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/patrickmn/go-cache"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+)
+
+type SiteCertIssueOption struct {
+	Domain     []string
+	Email      string
+	CertServer string
+	DnsApi     string // nginx 使用 HTTP-01 验证，其它为 dns 帐号的 ServerName
+	EabKid     string
+	EabHmacKey string
+	AutoRenew  bool
+}
+
+type SiteCert struct {
+}
+
+func (self SiteCert) New(ctx context.Context, opts ...acme.Option) (*acme.Acme, error) {
+	return acme.New(ctx, append([]acme.Option{
+		acme.WithConfigHomePath(storage.Local{}.GetCertDomainPath()),
+	}, opts...)...)
+}
+
+// Issue 申请证书，申请过程写入 output，成功后重新加载 nginx
+func (self SiteCert) Issue(ctx context.Context, option SiteCertIssueOption, output io.Writer) (*acme.Cert, error) {
+	challengeOption, err := self.challengeOption(option.DnsApi)
+	if err != nil {
+		return nil, err
+	}
+	builder, err := self.New(ctx,
+		acme.WithDomain(option.Domain...),
+		acme.WithEmail(option.Email),
+		acme.WithCertServer(option.CertServer),
+		acme.WithEabAccount(option.EabKid, option.EabHmacKey),
+		acme.WithOutput(output),
+		challengeOption,
+	)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := builder.Issue()
+	if err != nil {
+		return nil, err
+	}
+	if !option.AutoRenew {
+		cert.AutoRenew = false
+		if err = builder.Update(cert); err != nil {
+			return nil, err
+		}
+	}
+	if err = (Site{}).ReloadNginx(); err != nil {
+		slog.Warn("site cert reload nginx", "error", err)
+	}
+	return cert, nil
+}
+
+// Renew 续期到达续期时间的证书，同时只会运行一个，失败原因保存到证书信息中
+func (self SiteCert) Renew(ctx context.Context) (err error) {
+	if err = storage.Cache.Add(storage.CacheKeySiteCertRenew, "running", cache.NoExpiration); err != nil {
+		return crontab.SkipRun
+	}
+	defer storage.Cache.Delete(storage.CacheKeySiteCertRenew)
+
+	builder, err := self.New(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := builder.List()
+	if err != nil {
+		return err
+	}
+	for _, cert := range list {
+		if !cert.NeedRenew() {
+			continue
+		}
+		slog.Info("site cert renew", "domain", cert.MainDomain, "notAfter", cert.NotAfter)
+		_, renewErr := self.Issue(ctx, SiteCertIssueOption{
+			Domain:     cert.Domain,
+			Email:      cert.Email,
+			CertServer: cert.Server,
+			DnsApi:     cert.DnsApi,
+			AutoRenew:  true,
+		}, io.Discard)
+		if renewErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", cert.MainDomain, renewErr))
+			cert.LastError = renewErr.Error()
+			_ = builder.Update(cert)
+			facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+				Event:   define.NotificationEventCertRenewFailed,
+				Subject: fmt.Sprintf("certificate %s renew failed", cert.MainDomain),
+				Content: renewErr.Error(),
+			})
+		}
+	}
+	return err
+}
+
+// MigrateLegacy 处理旧版 acme.sh 签发的证书，补全帐号邮箱，
+// 使用了内置客户端不支持的 dns api 或续期缺少邮箱时关闭自动续期，记录原因并提醒重新申请，避免续期任务每天失败
+func (self SiteCert) MigrateLegacy() error {
+	builder, err := self.New(context.Background())
+	if err != nil {
+		return err
+	}
+	list, err := builder.List()
+	if err != nil {
+		return err
+	}
+	for _, cert := range list {
+		if !cert.AutoRenew {
+			continue
+		}
+		if cert.Email == "" {
+			if email := builder.LegacyEmail(cert); email != "" {
+				cert.Email = email
+				if err = builder.Update(cert); err != nil {
+					return err
+				}
+			}
+		}
+		messageKey, messageParams := "", make([]string, 0)
+		if cert.DnsApi != "" && cert.DnsApi != acme.DnsApiNginx && !function.InArray(acme.SupportedDnsApi, cert.DnsApi) {
+			cert.LastError = fmt.Sprintf("dns api %s %s, auto renew is disabled, please apply again with one of %s or http validation",
+				cert.DnsApi, acme.ErrDnsApiNotSupported, strings.Join(acme.SupportedDnsApi, ", "))
+			messageKey, messageParams = ".siteCertDnsApiNotSupported", []string{"name", cert.MainDomain, "dnsApi", cert.DnsApi}
+		} else if cert.RequireEmail() {
+			cert.LastError = "zerossl requires an email or eab account, auto renew is disabled, please apply again with an email"
+			messageKey, messageParams = ".siteCertEmailRequired", []string{"name", cert.MainDomain}
+		} else {
+			continue
+		}
+		cert.AutoRenew = false
+		if err = builder.Update(cert); err != nil {
+			return err
+		}
+		slog.Warn("site cert auto renew disabled", "domain", cert.MainDomain, "error", cert.LastError)
+		_ = notice.Message{}.Warning(messageKey, messageParams...)
+		facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+			Event:   define.NotificationEventCertRenewFailed,
+			Subject: fmt.Sprintf("certificate %s auto renew disabled", cert.MainDomain),
+			Content: cert.LastError,
+		})
+	}
+	return nil
+}
+
+// Start 添加每天检查一次证书续期及证书到期的定时任务，启动前先处理无法续期的旧证书
+func (self SiteCert) Start() error {
+	if err := self.MigrateLegacy(); err != nil {
+		slog.Warn("site cert migrate legacy", "error", err)
+	}
+	_, err := crontab.Client.AddJob("0 30 3 * * *", crontab.New(
+		crontab.WithName("site cert renew"),
+		crontab.WithRunFunc(func(ctx *crontab.RunFuncContext) {
+			ctx.Err = self.Renew(context.Background())
+		}),
+	))
+	if err != nil {
+		return err
+	}
+	_, err = crontab.Client.AddJob("0 0 9 * * *", crontab.New(
+		crontab.WithName("site cert monitor"),
+		crontab.WithRunFunc(func(ctx *crontab.RunFuncContext) {
+			ctx.Err = self.Check()
+		}),
+	))
+	return err
+}
+
+func (self SiteCert) challengeOption(dnsApi string) (acme.Option, error) {
+	if dnsApi == "" || dnsApi == acme.DnsApiNginx {
+		return acme.WithDnsNginx(), nil
+	}
+	dnsApiList := make([]accessor.DnsApi, 0)
+	logic.Setting{}.GetByKey(logic.SettingGroupSetting, logic.SettingGroupSettingDnsApi, &dnsApiList)
+	item, _, ok := function.PluckArrayItemWalk(dnsApiList, func(i accessor.DnsApi) bool {
+		return i.ServerName == dnsApi
+	})
+	if !ok {
+		return nil, fmt.Errorf("dns api %s is not configured", dnsApi)
+	}
+	return acme.WithDnsApi(item.ServerName, function.PluckArrayWalk(item.Env, func(i types.EnvItem) (string, bool) {
+		return fmt.Sprintf("%s=%s", i.Name, i.Value), true
+	})), nil
+}
@@ -0,0 +1,161 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/types/define"
+	"gorm.io/datatypes"
+)
+
+var siteUpstreamRefresh = struct {
+	sync.Mutex
+	timer *time.Timer
+}{}
+
+type SiteUpstream struct {
+}
+
+// Member 在负载均衡所属的环境中查找集合中运行中的容器，加入默认网络后返回排序后的转发地址
+func (self SiteUpstream) Member(dockerSdk *docker.Client, upstream *accessor.SiteDomainUpstream) ([]string, error) {
+	filter := filters.NewArgs(filters.Arg("status", "running"))
+	if upstream.ComposeService != "" && upstream.ComposeProject == "" {
+		// 只按服务名查找会匹配到其它编排中的同名服务
+		return nil, errors.New("upstream compose service requires a compose project")
+	}
+	if upstream.ComposeService != "" {
+		filter.Add("label", fmt.Sprintf("%s=%s", define.ComposeLabelProject, upstream.ComposeProject))
+		filter.Add("label", fmt.Sprintf("%s=%s", define.ComposeLabelService, upstream.ComposeService))
+	} else if function.IsEmptyArray(upstream.ContainerName) {
+		return nil, errors.New("upstream requires containers or a compose service")
+	}
+	list, err := dockerSdk.Client.ContainerList(dockerSdk.Ctx, container.ListOptions{
+		Filters: filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, item := range list {
+		if upstream.ComposeService == "" && !function.InArrayWalk(item.Names, func(name string) bool {
+			return function.InArray(upstream.ContainerName, strings.TrimPrefix(name, "/"))
+		}) {
+			continue
+		}
+		containerRow, err := dockerSdk.Client.ContainerInspect(dockerSdk.Ctx, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		address, err := Site{}.JoinProxyNetwork(dockerSdk, containerRow)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, address)
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// Refresh 重新查找全部负载均衡域名的成员，有变化时重新生成配置并重载 nginx
+// 每个域名在各自所属的环境中查找，与面板当前切换到的环境无关，环境无法连接或没有运行中的容器时保留上次的成员
+// 查找成员需要访问 docker，期间域名可能在页面中被修改，因此写入前重新读取数据，并且只更新成员字段
+func (self SiteUpstream) Refresh() (err error) {
+	list, err := dao.SiteDomain.Find()
+	if err != nil {
+		return err
+	}
+	clientList := make(map[string]*docker.Client)
+	closeList := make([]func(), 0)
+	defer func() {
+		for _, closeFn := range closeList {
+			closeFn()
+		}
+	}()
+	changed := false
+	for _, item := range list {
+		if item.Setting == nil || item.Setting.Upstream == nil {
+			continue
+		}
+		dockerEnvName := item.Setting.Upstream.GetDockerEnvName()
+		dockerSdk, ok := clientList[dockerEnvName]
+		if !ok {
+			// 环境不存在或无法连接时跳过该环境下的全部域名
+			var closeFn func()
+			var clientErr error
+			if dockerSdk, closeFn, clientErr = (logic.Env{}).GetClient(dockerEnvName); clientErr != nil {
+				slog.Warn("site upstream docker env connect", "name", dockerEnvName, "error", clientErr)
+			} else {
+				closeList = append(closeList, closeFn)
+			}
+			clientList[dockerEnvName] = dockerSdk
+		}
+		if dockerSdk == nil {
+			continue
+		}
+		member, memberErr := self.Member(dockerSdk, item.Setting.Upstream)
+		if memberErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", item.ServerName, memberErr))
+			continue
+		}
+		if len(member) == 0 {
+			slog.Warn("site upstream has no running member, keep the last member", "domain", item.ServerName, "member", item.Setting.Upstream.Member)
+			continue
+		}
+		row, findErr := dao.SiteDomain.Where(dao.SiteDomain.ID.Eq(item.ID)).First()
+		if findErr != nil || row.Setting == nil || row.Setting.Upstream == nil ||
+			row.Setting.Upstream.GetDockerEnvName() != dockerEnvName || slices.Equal(member, row.Setting.Upstream.Member) {
+			continue
+		}
+		slog.Debug("site upstream member changed", "domain", row.ServerName, "member", member)
+		row.Setting.Upstream.Member = member
+		if confErr := (Site{}).SaveNginxConf(*row.Setting); confErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", row.ServerName, confErr))
+			continue
+		}
+		if _, saveErr := dao.SiteDomain.Where(dao.SiteDomain.ID.Eq(row.ID)).
+			UpdateColumn(dao.SiteDomain.Setting, datatypes.JSONSet("setting").Set("upstream.member", member)); saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+		changed = true
+	}
+	if changed {
+		err = errors.Join(err, Site{}.ReloadNginx())
+	}
+	return err
+}
+
+// GetMember 连接负载均衡所属的环境查找成员
+func (self SiteUpstream) GetMember(upstream *accessor.SiteDomainUpstream) ([]string, error) {
+	dockerSdk, closeFn, err := logic.Env{}.GetClient(upstream.GetDockerEnvName())
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+	return self.Member(dockerSdk, upstream)
+}
+
+// RefreshLater 容器事件通常连续出现，如编排扩容，合并后只更新一次
+func (self SiteUpstream) RefreshLater() {
+	siteUpstreamRefresh.Lock()
+	defer siteUpstreamRefresh.Unlock()
+	if siteUpstreamRefresh.timer != nil {
+		siteUpstreamRefresh.timer.Stop()
+	}
+	siteUpstreamRefresh.timer = time.AfterFunc(time.Second*3, func() {
+		if err := self.Refresh(); err != nil {
+			slog.Warn("site upstream refresh", "error", err)
+		}
+	})
+}
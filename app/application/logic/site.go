@@ -5,18 +5,27 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/go-units"
+	"github.com/donknap/dpanel/app/common/logic"
 	"github.com/donknap/dpanel/common/accessor"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/docker"
 	"github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/exec/local"
 	"github.com/donknap/dpanel/common/service/storage"
 	"github.com/donknap/dpanel/common/types/define"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -32,6 +41,11 @@ var (
 	}
 )
 
+const (
+	htpasswdBcryptPrefix  = "$2y$"
+	siteAcmeChallengePath = "/.well-known/acme-challenge/"
+)
+
 type Site struct {
 }
 
@@ -215,6 +229,199 @@ func (self Site) GetEnvOptionByContainer(md5 string) (envOption accessor.SiteEnv
 	return envOption, nil
 }
 
+// JoinProxyNetwork 将容器加入到默认 dpanel-local 网络中，并指定 Hostname 用于 Nginx 反向代理，返回转发地址
+// 转发时必须保证当前环境有 dpanel 面板
+func (self Site) JoinProxyNetwork(dockerSdk *docker.Client, containerRow container.InspectResponse) (string, error) {
+	dpanelInfo := logic.Setting{}.GetDPanelInfo()
+	if dpanelInfo.ContainerInfo.ContainerJSONBase == nil {
+		return "", function.ErrorMessage(define.ErrorMessageSiteDomainNotFoundDPanel)
+	}
+
+	if _, err := dockerSdk.Client.NetworkInspect(dockerSdk.Ctx, define.DPanelProxyNetworkName, network.InspectOptions{}); err != nil {
+		slog.Debug("site domain create default network", "name", define.DPanelProxyNetworkName)
+		_, err = dockerSdk.Client.NetworkCreate(dockerSdk.Ctx, define.DPanelProxyNetworkName, network.CreateOptions{
+			Driver: "bridge",
+			Options: map[string]string{
+				"name": define.DPanelProxyNetworkName,
+			},
+			EnableIPv6: function.Ptr(false),
+		})
+		if err != nil {
+			return "", function.ErrorMessage(define.ErrorMessageSiteDomainJoinDefaultNetworkFailed)
+		}
+	}
+
+	dpanelLocalNetwork, err := dockerSdk.Client.NetworkInspect(dockerSdk.Ctx, define.DPanelProxyNetworkName, network.InspectOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	// 当面板自己没有加入默认网络时，加入并配置 hostname
+	// 假如当前转发的容器就是面板自己，则不在这里处理，统一在下面加入网络
+	if _, _, ok := function.PluckMapItemWalk(dpanelLocalNetwork.Containers, func(k string, v network.EndpointResource) bool {
+		return k == dpanelInfo.ContainerInfo.ID
+	}); !ok {
+		err = dockerSdk.Client.NetworkConnect(dockerSdk.Ctx, define.DPanelProxyNetworkName, dpanelInfo.ContainerInfo.ID, &network.EndpointSettings{
+			Aliases: []string{
+				fmt.Sprintf(define.DPanelNetworkHostName, strings.Trim(dpanelInfo.ContainerInfo.Name, "/")),
+			},
+		})
+		if err != nil {
+			return "", function.ErrorMessage(define.ErrorMessageSiteDomainJoinDefaultNetworkFailed, err.Error())
+		}
+	}
+
+	// 当目标容器不在默认网络时，加入默认网络
+	serverAddress := fmt.Sprintf(define.DPanelNetworkHostName, strings.Trim(containerRow.Name, "/"))
+	if _, ok := containerRow.NetworkSettings.Networks[define.DPanelProxyNetworkName]; !ok {
+		slog.Debug("site domain join default network ", "container name", containerRow.Name, "hostname", serverAddress)
+		err = dockerSdk.Client.NetworkConnect(dockerSdk.Ctx, define.DPanelProxyNetworkName, containerRow.ID, &network.EndpointSettings{
+			Aliases: []string{
+				serverAddress,
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return serverAddress, nil
+}
+
+// NormalizeLocation 检查路径规则，规则会直接写入 nginx 配置，不允许包含引号等字符
+// 前缀匹配的路径统一为 /api 的形式，根路径由域名本身的转发目标处理
+// 正则使用 PCRE 语法，由 nginx -t 检查
+func (self Site) NormalizeLocation(item accessor.SiteDomainLocation) (accessor.SiteDomainLocation, error) {
+	if item.MatchType == "" {
+		item.MatchType = accessor.SiteDomainLocationMatchPrefix
+	}
+	if item.MatchType != accessor.SiteDomainLocationMatchPrefix && item.MatchType != accessor.SiteDomainLocationMatchRegex {
+		return item, fmt.Errorf("invalid location match type %s", item.MatchType)
+	}
+	// 末尾的 \ 会转义配置中的引号
+	if strings.ContainsAny(item.Path, "\"'<>&\r\n") || strings.HasSuffix(item.Path, "\\") {
+		return item, fmt.Errorf("invalid location path %s", item.Path)
+	}
+	if item.MatchType == accessor.SiteDomainLocationMatchPrefix {
+		if strings.ContainsAny(item.Path, "{}; \t\\") {
+			return item, fmt.Errorf("invalid location path %s", item.Path)
+		}
+		item.Path = "/" + strings.Trim(item.Path, "/")
+		if item.Path == "/" {
+			return item, errors.New("location path / is already handled by the domain itself")
+		}
+		// 与 challenge.conf 中的证书验证路径冲突
+		if strings.HasPrefix(item.Path+"/", siteAcmeChallengePath) {
+			return item, fmt.Errorf("location path %s is reserved for certificate validation", item.Path)
+		}
+	} else {
+		item.StripPrefix = false
+	}
+	if item.ContainerId == "" && item.ServerAddress == "" {
+		return item, fmt.Errorf("location %s requires a container or server address", item.Path)
+	}
+	if strings.ContainsAny(item.ServerAddress, "\"'{};<>& \t\r\n/") {
+		return item, fmt.Errorf("invalid location server address %s", item.ServerAddress)
+	}
+	if item.ServerProtocol != "" && item.ServerProtocol != "http" && item.ServerProtocol != "https" {
+		return item, fmt.Errorf("invalid location server protocol %s", item.ServerProtocol)
+	}
+	for _, header := range item.Header {
+		if !regexp.MustCompile(`^[A-Za-z0-9-]+$`).MatchString(header.Name) || strings.ContainsAny(header.Value, "\"'<>&\r\n") || strings.HasSuffix(header.Value, "\\") {
+			return item, fmt.Errorf("invalid location header %s", header.Name)
+		}
+	}
+	return item, nil
+}
+
+// NormalizeAccess 检查访问控制配置，新密码转为摘要，未填写密码的用户保留 old 中的密码
+func (self Site) NormalizeAccess(setting *accessor.SiteDomainSettingOption, old *accessor.SiteDomainSettingOption) error {
+	for i, item := range setting.BasicAuth {
+		if item.Username == "" || strings.ContainsAny(item.Username, ": \t\r\n") {
+			return fmt.Errorf("invalid basic auth username %s", item.Username)
+		}
+		if function.InArrayWalk(setting.BasicAuth[:i], func(v accessor.SiteDomainBasicAuth) bool {
+			return v.Username == item.Username
+		}) {
+			return fmt.Errorf("duplicate basic auth username %s", item.Username)
+		}
+		// 提交的密码总是按明文处理，只有未填写时才沿用已保存的摘要
+		if item.Password != "" {
+			hash, err := self.htpasswdHash(item.Password)
+			if err != nil {
+				return err
+			}
+			item.Password = hash
+		} else if old != nil {
+			if v, _, ok := function.PluckArrayItemWalk(old.BasicAuth, func(v accessor.SiteDomainBasicAuth) bool {
+				return v.Username == item.Username
+			}); ok {
+				item.Password = v.Password
+			}
+		}
+		if item.Password == "" {
+			return fmt.Errorf("basic auth user %s requires a password", item.Username)
+		}
+		setting.BasicAuth[i] = item
+	}
+	for _, list := range [][]string{setting.AllowIp, setting.DenyIp} {
+		for i, ip := range list {
+			ip = strings.TrimSpace(ip)
+			if net.ParseIP(ip) == nil {
+				if _, _, err := net.ParseCIDR(ip); err != nil {
+					return fmt.Errorf("invalid ip or cidr %s", ip)
+				}
+			}
+			list[i] = ip
+		}
+	}
+	if setting.RateLimit != nil {
+		if setting.RateLimit.Rate < 0 || setting.RateLimit.Burst < 0 || setting.RateLimit.Connection < 0 {
+			return errors.New("rate limit cannot be negative")
+		}
+		if setting.RateLimit.RateUnit == "" {
+			setting.RateLimit.RateUnit = "s"
+		}
+		if setting.RateLimit.Rate == 0 && setting.RateLimit.Connection == 0 {
+			setting.RateLimit = nil
+		}
+	}
+	return nil
+}
+
+// htpasswdHash 使用 bcrypt 摘要，nginx 通过系统 crypt 校验，与 htpasswd -B 生成的 $2y$ 格式一致
+func (self Site) htpasswdHash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return htpasswdBcryptPrefix + strings.TrimPrefix(string(hash), "$2a$"), nil
+}
+
+func (self Site) makeHtpasswd(setting accessor.SiteDomainSettingOption) error {
+	htpasswdFile := storage.Local{}.GetNginxHtpasswdFilePath(setting.ServerName)
+	if function.IsEmptyArray(setting.BasicAuth) {
+		if err := os.Remove(htpasswdFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(htpasswdFile), os.ModePerm); err != nil {
+		return err
+	}
+	content := function.PluckArrayWalk(setting.BasicAuth, func(i accessor.SiteDomainBasicAuth) (string, bool) {
+		return i.Username + ":" + i.Password, true
+	})
+	return os.WriteFile(htpasswdFile, []byte(strings.Join(content, "\n")+"\n"), 0644)
+}
+
+func (self Site) renderNginxConf(asset fs.FS, writer io.Writer, setting accessor.SiteDomainSettingOption) error {
+	parser, err := template.ParseFS(asset, "asset/nginx/*.tpl")
+	if err != nil {
+		return err
+	}
+	return parser.ExecuteTemplate(writer, "vhost.tpl", function.StructToMap(setting))
+}
+
 func (self Site) MakeNginxConf(setting accessor.SiteDomainSettingOption) error {
 	var asset embed.FS
 	if v, ok := storage.Cache.Get(storage.CacheKeyAsset); ok {
@@ -224,11 +431,6 @@ func (self Site) MakeNginxConf(setting accessor.SiteDomainSettingOption) error {
 	}
 
 	confFileName := setting.VHostFilename()
-	parser, err := template.ParseFS(asset, "asset/nginx/*.tpl")
-	if err != nil {
-		return err
-	}
-
 	nginxSettingPath := storage.Local{}.GetNginxSettingPath()
 
 	// 删除该域名的所有配置文件（包括 .conf 和 .disable）
@@ -244,10 +446,13 @@ func (self Site) MakeNginxConf(setting accessor.SiteDomainSettingOption) error {
 	defer func() {
 		_ = vhostFile.Close()
 	}()
-	err = parser.ExecuteTemplate(vhostFile, "vhost.tpl", function.StructToMap(setting))
+	err = self.renderNginxConf(asset, vhostFile, setting)
 	if err != nil {
 		return err
 	}
+	if err = self.makeHtpasswd(setting); err != nil {
+		return err
+	}
 	if setting.ExtraNginx != "" {
 		extraVhostFile, err := os.OpenFile(filepath.Join(storage.Local{}.GetNginxExtraSettingPath(), confFileName), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
 		if err != nil {
@@ -265,6 +470,63 @@ func (self Site) MakeNginxConf(setting accessor.SiteDomainSettingOption) error {
 	return err
 }
 
+// SaveNginxConf 生成配置后使用 nginx -t 检查，检查不通过时恢复该域名原来的配置及 htpasswd 文件
+func (self Site) SaveNginxConf(setting accessor.SiteDomainSettingOption) error {
+	backup := make(map[string][]byte)
+	for _, name := range []string{fmt.Sprintf(accessor.VhostFileName, setting.ServerName), fmt.Sprintf(accessor.VhostDisableFilename, setting.ServerName)} {
+		backup[storage.Local{}.GetNginxSettingFilePath(name)] = nil
+		backup[storage.Local{}.GetNginxExtraSettingFilePath(name)] = nil
+	}
+	backup[storage.Local{}.GetNginxHtpasswdFilePath(setting.ServerName)] = nil
+	for path := range backup {
+		if content, err := os.ReadFile(path); err == nil {
+			backup[path] = content
+		}
+	}
+	err := self.MakeNginxConf(setting)
+	if err == nil {
+		err = self.TestNginxConf()
+	}
+	if err == nil {
+		return nil
+	}
+	for path, content := range backup {
+		if content == nil {
+			_ = os.Remove(path)
+		} else if writeErr := os.WriteFile(path, content, 0644); writeErr != nil {
+			slog.Warn("site restore nginx conf", "path", path, "error", writeErr)
+		}
+	}
+	return err
+}
+
+// TestNginxConf nginx 未运行时跳过检查
+func (self Site) TestNginxConf() error {
+	if running, _ := local.QuickCheckRunning("nginx"); !running {
+		return nil
+	}
+	out, err := local.QuickRun("nginx -t")
+	if err != nil {
+		return fmt.Errorf("%w %s", err, out)
+	}
+	if !strings.Contains(string(out), "successful") {
+		return errors.New(string(out))
+	}
+	return nil
+}
+
+// ReloadNginx nginx 运行时才重新加载，配置检查不通过时不加载
+func (self Site) ReloadNginx() error {
+	if running, _ := local.QuickCheckRunning("nginx"); !running {
+		return nil
+	}
+	if err := self.TestNginxConf(); err != nil {
+		return err
+	}
+	_, err := local.QuickRun("nginx -s reload")
+	return err
+}
+
 func (self Site) MakeNginxResolver() error {
 	var asset embed.FS
 	if v, ok := storage.Cache.Get(storage.CacheKeyAsset); ok {
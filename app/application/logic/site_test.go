@@ -0,0 +1,214 @@
+package logic
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/service/docker/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSiteNormalizeLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		item   accessor.SiteDomainLocation
+		expect string // 为空时应返回错误
+	}{
+		{"prefix", accessor.SiteDomainLocation{Path: "api", ServerAddress: "web", Port: 80}, "/api"},
+		{"trim slash", accessor.SiteDomainLocation{Path: "/api/v1/", ServerAddress: "web", Port: 80}, "/api/v1"},
+		{"regex", accessor.SiteDomainLocation{Path: `^/static/.*\.js$`, MatchType: accessor.SiteDomainLocationMatchRegex, ServerAddress: "web", Port: 80}, `^/static/.*\.js$`},
+		{"container", accessor.SiteDomainLocation{Path: "/api", ContainerId: "abc", Port: 80}, "/api"},
+		{"root", accessor.SiteDomainLocation{Path: "/", ServerAddress: "web", Port: 80}, ""},
+		{"acme challenge", accessor.SiteDomainLocation{Path: "/.well-known/acme-challenge", ServerAddress: "web", Port: 80}, ""},
+		{"invalid match type", accessor.SiteDomainLocation{Path: "/api", MatchType: "exact", ServerAddress: "web", Port: 80}, ""},
+		{"quote", accessor.SiteDomainLocation{Path: `/api"`, ServerAddress: "web", Port: 80}, ""},
+		{"prefix brace", accessor.SiteDomainLocation{Path: "/api{", ServerAddress: "web", Port: 80}, ""},
+		{"prefix space", accessor.SiteDomainLocation{Path: "/api v1", ServerAddress: "web", Port: 80}, ""},
+		{"regex backslash suffix", accessor.SiteDomainLocation{Path: `^/api\`, MatchType: accessor.SiteDomainLocationMatchRegex, ServerAddress: "web", Port: 80}, ""},
+		{"empty target", accessor.SiteDomainLocation{Path: "/api", Port: 80}, ""},
+		{"server address", accessor.SiteDomainLocation{Path: "/api", ServerAddress: "web;", Port: 80}, ""},
+		{"server protocol", accessor.SiteDomainLocation{Path: "/api", ServerAddress: "web", ServerProtocol: "ftp", Port: 80}, ""},
+		{"header name", accessor.SiteDomainLocation{Path: "/api", ServerAddress: "web", Port: 80, Header: []types.ValueItem{{Name: "X Test", Value: "1"}}}, ""},
+		{"header value", accessor.SiteDomainLocation{Path: "/api", ServerAddress: "web", Port: 80, Header: []types.ValueItem{{Name: "X-Test", Value: `1"`}}}, ""},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			result, err := Site{}.NormalizeLocation(item.item)
+			if item.expect == "" {
+				if err == nil {
+					t.Fatalf("expect error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Path != item.expect {
+				t.Fatalf("expect %s, got %s", item.expect, result.Path)
+			}
+			if result.MatchType == "" {
+				t.Fatal("match type should be filled")
+			}
+		})
+	}
+
+	result, err := Site{}.NormalizeLocation(accessor.SiteDomainLocation{
+		Path: "^/api", MatchType: accessor.SiteDomainLocationMatchRegex, ServerAddress: "web", Port: 80, StripPrefix: true,
+	})
+	if err != nil || result.StripPrefix {
+		t.Fatalf("strip prefix should be disabled for regex locations, got %+v %v", result, err)
+	}
+}
+
+func TestSiteRenderNginxConfLocation(t *testing.T) {
+	setting := accessor.SiteDomainSettingOption{
+		ServerName:    "example.com",
+		TargetName:    "example",
+		ServerAddress: "web",
+		Port:          80,
+		Type:          "proxy",
+	}
+	for _, item := range []accessor.SiteDomainLocation{
+		{Path: "/api/", ServerAddress: "api", Port: 8080, StripPrefix: true},
+		{Path: `^/static/.*\.js$`, MatchType: accessor.SiteDomainLocationMatchRegex, ServerAddress: "static", Port: 8081},
+	} {
+		location, err := Site{}.NormalizeLocation(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setting.Location = append(setting.Location, location)
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := (Site{}).renderNginxConf(os.DirFS("../../.."), buffer, setting); err != nil {
+		t.Fatal(err)
+	}
+	content := buffer.String()
+	for _, expect := range []string{
+		"location = /api {",
+		"location ^~ /api/ {",
+		"rewrite ^/api(?:/(.*))?$ /$1 break;",
+		"set $upstream_endpoint api:8080;",
+		`location ~ "^/static/.*\.js$" {`,
+		"set $upstream_endpoint static:8081;",
+		"location / {",
+	} {
+		if !strings.Contains(content, expect) {
+			t.Errorf("expect %q in vhost:\n%s", expect, content)
+		}
+	}
+	// 前缀规则的精确匹配及前缀匹配都需要转发，正则规则不去掉前缀
+	if count := strings.Count(content, "set $upstream_endpoint api:8080;"); count != 2 {
+		t.Errorf("expect prefix location rendered twice, got %d", count)
+	}
+	if count := strings.Count(content, "rewrite "); count != 2 {
+		t.Errorf("expect 2 rewrite rules, got %d", count)
+	}
+}
+
+func TestSiteNormalizeAccess(t *testing.T) {
+	old := &accessor.SiteDomainSettingOption{
+		BasicAuth: []accessor.SiteDomainBasicAuth{
+			{Username: "admin", Password: "$2y$10$saved"},
+		},
+	}
+	tests := []struct {
+		name    string
+		setting accessor.SiteDomainSettingOption
+		old     *accessor.SiteDomainSettingOption
+		err     bool
+	}{
+		{"empty", accessor.SiteDomainSettingOption{}, nil, false},
+		{"ip and cidr", accessor.SiteDomainSettingOption{AllowIp: []string{" 10.0.0.1 ", "192.168.0.0/16", "::1"}, DenyIp: []string{"fd00::/8"}}, nil, false},
+		{"invalid ip", accessor.SiteDomainSettingOption{AllowIp: []string{"10.0.0.256"}}, nil, true},
+		{"invalid deny ip", accessor.SiteDomainSettingOption{DenyIp: []string{"all"}}, nil, true},
+		{"new password", accessor.SiteDomainSettingOption{BasicAuth: []accessor.SiteDomainBasicAuth{{Username: "user", Password: "secret"}}}, nil, false},
+		{"keep password", accessor.SiteDomainSettingOption{BasicAuth: []accessor.SiteDomainBasicAuth{{Username: "admin"}}}, old, false},
+		{"missing password", accessor.SiteDomainSettingOption{BasicAuth: []accessor.SiteDomainBasicAuth{{Username: "user"}}}, old, true},
+		{"invalid username", accessor.SiteDomainSettingOption{BasicAuth: []accessor.SiteDomainBasicAuth{{Username: "a:b", Password: "secret"}}}, nil, true},
+		{"duplicate username", accessor.SiteDomainSettingOption{BasicAuth: []accessor.SiteDomainBasicAuth{{Username: "user", Password: "a"}, {Username: "user", Password: "b"}}}, nil, true},
+		{"negative rate", accessor.SiteDomainSettingOption{RateLimit: &accessor.SiteDomainRateLimit{Rate: -1}}, nil, true},
+		{"rate", accessor.SiteDomainSettingOption{RateLimit: &accessor.SiteDomainRateLimit{Rate: 10, Burst: 20}}, nil, false},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			setting := item.setting
+			err := Site{}.NormalizeAccess(&setting, item.old)
+			if (err != nil) != item.err {
+				t.Fatalf("expect error %v, got %v", item.err, err)
+			}
+		})
+	}
+
+	setting := accessor.SiteDomainSettingOption{
+		AllowIp: []string{" 10.0.0.1 "},
+		BasicAuth: []accessor.SiteDomainBasicAuth{
+			{Username: "admin"},
+			{Username: "user", Password: "secret"},
+		},
+		RateLimit: &accessor.SiteDomainRateLimit{},
+	}
+	if err := (Site{}).NormalizeAccess(&setting, old); err != nil {
+		t.Fatal(err)
+	}
+	if setting.AllowIp[0] != "10.0.0.1" {
+		t.Errorf("ip should be trimmed, got %q", setting.AllowIp[0])
+	}
+	if setting.BasicAuth[0].Password != "$2y$10$saved" {
+		t.Errorf("empty password should keep the saved hash, got %s", setting.BasicAuth[0].Password)
+	}
+	if !strings.HasPrefix(setting.BasicAuth[1].Password, htpasswdBcryptPrefix) ||
+		bcrypt.CompareHashAndPassword([]byte(setting.BasicAuth[1].Password), []byte("secret")) != nil {
+		t.Errorf("new password should be stored as bcrypt hash, got %s", setting.BasicAuth[1].Password)
+	}
+	if setting.RateLimit != nil {
+		t.Errorf("rate limit without rate and connection should be removed, got %+v", setting.RateLimit)
+	}
+}
+
+func TestSiteRenderNginxConfAccess(t *testing.T) {
+	setting := accessor.SiteDomainSettingOption{
+		ServerName:    "example.com",
+		TargetName:    "example",
+		ServerAddress: "web",
+		Port:          80,
+		Type:          "proxy",
+		AllowIp:       []string{"10.0.0.0/8"},
+		DenyIp:        []string{"10.0.0.1"},
+		BasicAuth:     []accessor.SiteDomainBasicAuth{{Username: "user", Password: "secret"}},
+		RateLimit:     &accessor.SiteDomainRateLimit{Rate: 10, Burst: 20, Connection: 5},
+	}
+	if err := (Site{}).NormalizeAccess(&setting, nil); err != nil {
+		t.Fatal(err)
+	}
+	buffer := &bytes.Buffer{}
+	if err := (Site{}).renderNginxConf(os.DirFS("../../.."), buffer, setting); err != nil {
+		t.Fatal(err)
+	}
+	content := buffer.String()
+	for _, expect := range []string{
+		"limit_req_zone $binary_remote_addr zone=dpanel_req_example:10m rate=10r/s;",
+		"limit_conn_zone $binary_remote_addr zone=dpanel_conn_example:10m;",
+		"include /etc/nginx/conf.d/include/challenge.conf;",
+		"deny 10.0.0.1;",
+		"allow 10.0.0.0/8;",
+		"deny all;",
+		"auth_basic_user_file /dpanel/nginx/htpasswd/example.com;",
+		"limit_req zone=dpanel_req_example burst=20 nodelay;",
+		"limit_conn dpanel_conn_example 5;",
+	} {
+		if !strings.Contains(content, expect) {
+			t.Errorf("expect %q in vhost:\n%s", expect, content)
+		}
+	}
+	// 拒绝规则需要在允许规则之前
+	if strings.Index(content, "deny 10.0.0.1;") > strings.Index(content, "allow 10.0.0.0/8;") {
+		t.Error("deny rules should be rendered before allow rules")
+	}
+	if strings.Contains(content, "secret") || strings.Contains(content, setting.BasicAuth[0].Password) {
+		t.Error("password should not be rendered into vhost")
+	}
+}
@@ -1,10 +1,16 @@
 package application
 
 import (
+	"log/slog"
+
 	"github.com/donknap/dpanel/app/application/http/controller"
+	"github.com/donknap/dpanel/app/application/logic"
+	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/function"
 	common "github.com/donknap/dpanel/common/middleware"
+	"github.com/donknap/dpanel/common/service/crontab"
 	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
 	httpserver "github.com/we7coreteam/w7-rangine-go/v2/src/http/server"
 )
 
@@ -41,6 +47,7 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 			cors.POST("/app/site-cert/get-detail", controller.SiteCert{}.GetDetail)
 			cors.POST("/app/site-cert/import", controller.SiteCert{}.Import)
 			cors.POST("/app/site-cert/download", controller.SiteCert{}.Download)
+			cors.POST("/app/site-cert/renew", controller.SiteCert{}.Renew)
 
 			// 容器相关
 			cors.POST("/app/container/status", controller.Container{}.Status)
@@ -58,14 +65,26 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 			cors.POST("/app/container-upgrade/ignore", controller.ContainerUpgrade{}.Ignore)
 
 			cors.POST("/app/container/get-stat-info", controller.Container{}.GetStatInfo)
+			cors.POST("/app/container/get-stat-history", controller.Container{}.GetStatHistory)
 			cors.POST("/app/container/get-process-info", controller.Container{}.GetProcessInfo)
 
 			// 容器备份相关
 			cors.POST("/app/container-backup/create", controller.ContainerBackup{}.Create)
+			cors.POST("/app/container-backup/create-compose", controller.ContainerBackup{}.CreateCompose)
 			cors.POST("/app/container-backup/get-list", controller.ContainerBackup{}.GetList)
 			cors.POST("/app/container-backup/delete", controller.ContainerBackup{}.Delete)
 			cors.POST("/app/container-backup/restore", controller.ContainerBackup{}.Restore)
 			cors.POST("/app/container-backup/get-detail", controller.ContainerBackup{}.GetDetail)
+			cors.POST("/app/container-backup/migrate", controller.ContainerBackup{}.Migrate)
+			cors.POST("/app/container-backup/verify", controller.ContainerBackup{}.Verify)
+			cors.POST("/app/container-backup/dump-profile", controller.ContainerBackup{}.DumpProfile)
+			cors.POST("/app/container-backup/dump-download", controller.ContainerBackup{}.DumpDownload)
+			cors.POST("/app/container-backup/repository-prune", controller.ContainerBackup{}.RepositoryPrune)
+			cors.POST("/app/container-backup/repository-check", controller.ContainerBackup{}.RepositoryCheck)
+			cors.POST("/app/backup-schedule/create", controller.BackupSchedule{}.Create)
+			cors.POST("/app/backup-schedule/get-list", controller.BackupSchedule{}.GetList)
+			cors.POST("/app/backup-schedule/delete", controller.BackupSchedule{}.Delete)
+			cors.POST("/app/backup-schedule/run-once", controller.BackupSchedule{}.RunOnce)
 
 			// 镜像相关
 			cors.POST("/app/image/import-by-container-tar", controller.Image{}.ImportByContainerTar)
@@ -107,6 +126,7 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 
 			// 日志相关
 			cors.POST("/app/log/run", controller.RunLog{}.Run)
+			cors.POST("/app/deploy-webhook/run-log", controller.RunLog{}.DeployWebhook)
 
 			// 网络相关
 			cors.POST("/app/network/get-detail", controller.Network{}.GetDetail)
@@ -138,6 +158,17 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 			cors.POST("/app/compose/container-ctrl", controller.Compose{}.ContainerCtrl)
 			cors.POST("/app/compose/container-log", controller.Compose{}.ContainerLog)
 
+			cors.POST("/app/compose/git-save", controller.Compose{}.GitSave)
+			cors.POST("/app/compose/git-sync", controller.Compose{}.GitSync)
+			cors.POST("/app/compose/git-status", controller.Compose{}.GitStatus)
+			cors.POST("/app/compose/git-delete", controller.Compose{}.GitDelete)
+			cors.POST("/app/compose/git-webhook/:token", controller.Compose{}.GitWebhook)
+
+			cors.POST("/app/deploy-webhook/create", controller.DeployWebhook{}.Create)
+			cors.POST("/app/deploy-webhook/get-list", controller.DeployWebhook{}.GetList)
+			cors.POST("/app/deploy-webhook/delete", controller.DeployWebhook{}.Delete)
+			cors.POST("/app/deploy-webhook/trigger/:token", controller.DeployWebhook{}.Trigger)
+
 			cors.POST("/app/swarm/info", controller.Swarm{}.Info)
 			cors.POST("/app/swarm/info-join", controller.Swarm{}.InfoJoin)
 			cors.POST("/app/swarm/init", controller.Swarm{}.Init)
@@ -155,4 +186,36 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 			cors.POST("/app/swarm/task-list-in-node", controller.Swarm{}.TaskListInNode)
 		},
 	)
+
+	// 每分钟检查绑定仓库的编排任务，按各自的间隔拉取
+	_, _ = crontab.Client.AddJob("0 * * * * *", crontab.New(
+		crontab.WithName("compose git sync"),
+		crontab.WithRunFunc(func(ctx *crontab.RunFuncContext) {
+			ctx.Err = logic.ComposeGit{}.Poll()
+		}),
+	))
+
+	// 证书续期
+	if err := (logic.SiteCert{}).Start(); err != nil {
+		slog.Warn("init site cert renew error", "error", err.Error())
+	}
+
+	// 面板停止期间容器可能有变化，启动后更新负载均衡的成员
+	logic.SiteUpstream{}.RefreshLater()
+
+	// 启动时，初始化备份计划
+	if scheduleList, err := dao.BackupSchedule.Order(dao.BackupSchedule.ID.Desc()).Find(); err == nil {
+		for _, task := range scheduleList {
+			if task.Setting.Disable {
+				continue
+			}
+			if jobIds, err := (logic.BackupSchedule{}).AddJob(task); err == nil {
+				task.Setting.JobIds = jobIds
+			} else {
+				task.Setting.JobIds = make([]cron.EntryID, 0)
+				slog.Warn("init backup schedule error", "error", err.Error())
+			}
+			_ = dao.BackupSchedule.Save(task)
+		}
+	}
 }
@@ -217,5 +217,7 @@ func (self Docker) Message(e event.DockerMessagePayload) {
 			types.NewEnvItemFromKV("DP_DOCKER_ENV_NAME", e.DockerEnvName),
 			types.NewEnvItemFromKV("DP_CONTAINER_NAME", e.Message.Actor.Attributes["name"]),
 		})
+		// 编排扩缩容及容器启停不会经过面板，同样需要更新负载均衡的成员
+		logic2.SiteUpstream{}.RefreshLater()
 	}
 }
@@ -0,0 +1,20 @@
+package events
+
+import (
+	"log/slog"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/types/event"
+)
+
+type Notification struct {
+}
+
+func (self Notification) Send(e event.NotificationPayload) {
+	// 外部渠道发送可能较慢，不阻塞事件发布方
+	go func() {
+		if err := (logic.Notice{}).Dispatch(e.Event, e.Subject, e.Content); err != nil {
+			slog.Warn("notification send failed", "event", e.Event, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,14 @@
+package events
+
+import (
+	"github.com/donknap/dpanel/app/application/logic"
+	"github.com/donknap/dpanel/common/types/event"
+)
+
+type SiteDomain struct {
+}
+
+// ContainerChange 容器创建或删除后更新负载均衡域名的成员
+func (self SiteDomain) ContainerChange(e event.ContainerPayload) {
+	logic.SiteUpstream{}.RefreshLater()
+}
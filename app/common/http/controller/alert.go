@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/alert"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+type Alert struct {
+	controller.Abstract
+}
+
+func (self Alert) Create(http *gin.Context) {
+	type ParamsValidate struct {
+		Id    int32  `json:"id"`
+		Title string `json:"title" binding:"required"`
+		accessor.AlertRuleSettingOption
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+
+	var ruleRow *entity.AlertRule
+	if params.Id > 0 {
+		ruleRow, _ = dao.AlertRule.Where(dao.AlertRule.ID.Eq(params.Id)).Where(self.envCond()...).First()
+		if ruleRow == nil {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+			return
+		}
+		// 编辑时保留规则原本所属的环境
+		params.AlertRuleSettingOption.DockerEnvName = ruleRow.Setting.DockerEnvName
+		ruleRow.Title = params.Title
+		ruleRow.Setting = &params.AlertRuleSettingOption
+	} else {
+		if _, err := dao.AlertRule.Where(dao.AlertRule.Title.Eq(params.Title)).First(); err == nil {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonIdAlreadyExists, "name", params.Title), 500)
+			return
+		}
+		params.AlertRuleSettingOption.DockerEnvName = docker.Sdk.Name
+		ruleRow = &entity.AlertRule{
+			Title:     params.Title,
+			Setting:   &params.AlertRuleSettingOption,
+			CreatedAt: time.Now(),
+		}
+	}
+
+	err := dao.AlertRule.Save(ruleRow)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	if err = alert.Engine.Reload(); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"id": ruleRow.ID,
+	})
+	return
+}
+
+func (self Alert) GetList(http *gin.Context) {
+	type ParamsValidate struct {
+		Title string `json:"title"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	query := dao.AlertRule.Order(dao.AlertRule.ID.Desc()).Where(self.envCond()...)
+	if params.Title != "" {
+		query = query.Where(dao.AlertRule.Title.Like("%" + params.Title + "%"))
+	}
+	list, _ := query.Find()
+	self.JsonResponseWithoutError(http, gin.H{
+		"list": list,
+		"state": function.PluckArrayMapWalk(list, func(item *entity.AlertRule) (int32, []alert.State, bool) {
+			return item.ID, alert.Engine.GetState(item.ID), true
+		}),
+	})
+	return
+}
+
+func (self Alert) GetDetail(http *gin.Context) {
+	type ParamsValidate struct {
+		Id int32 `json:"id" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	ruleRow, _ := dao.AlertRule.Where(dao.AlertRule.ID.Eq(params.Id)).Where(self.envCond()...).First()
+	if ruleRow == nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"detail": ruleRow,
+		"state":  alert.Engine.GetState(ruleRow.ID),
+	})
+	return
+}
+
+func (self Alert) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Id []int32 `json:"id" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	_, err := dao.AlertRule.Where(dao.AlertRule.ID.In(params.Id...)).Where(self.envCond()...).Delete()
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	_ = alert.Engine.Reload()
+	self.JsonSuccessResponse(http)
+	return
+}
+
+// 只能查看和操作当前环境的规则
+func (self Alert) envCond() []gen.Condition {
+	return gen.Cond(datatypes.JSONQuery("setting").Equals(docker.Sdk.Name, "dockerEnvName"))
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+)
+
+type Audit struct {
+	controller.Abstract
+}
+
+func (self Audit) GetList(http *gin.Context) {
+	type ParamsValidate struct {
+		Page     int `json:"page" binding:"omitempty,gt=0"`
+		PageSize int `json:"pageSize" binding:"omitempty"`
+		logic.AuditSearch
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 20
+	}
+	list, total, err := logic.Audit{}.Query(params.AuditSearch).FindByPage((params.Page-1)*params.PageSize, params.PageSize)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"total": total,
+		"page":  params.Page,
+		"list":  list,
+	})
+	return
+}
+
+func (self Audit) Export(http *gin.Context) {
+	type ParamsValidate struct {
+		Format string `json:"format" binding:"omitempty,oneof=csv json"`
+		logic.AuditSearch
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list, err := logic.Audit{}.Query(params.AuditSearch).Find()
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	fileName := fmt.Sprintf("audit-%s", time.Now().Format("20060102150405"))
+
+	if params.Format == "json" {
+		http.Header("Content-Type", "application/json")
+		http.Header("Content-Disposition", "attachment; filename="+fileName+".json")
+		_ = json.NewEncoder(http.Writer).Encode(list)
+		return
+	}
+
+	http.Header("Content-Type", "text/csv; charset=utf-8")
+	http.Header("Content-Disposition", "attachment; filename="+fileName+".csv")
+	writer := csv.NewWriter(http.Writer)
+	_ = writer.Write([]string{
+		"id", "createdAt", "username", "ip", "dockerEnvName", "uri", "resource", "action", "target", "status", "message",
+	})
+	for _, item := range list {
+		_ = writer.Write([]string{
+			strconv.Itoa(int(item.ID)),
+			item.CreatedAt.Format(time.RFC3339),
+			item.Username,
+			item.IP,
+			item.DockerEnvName,
+			item.Uri,
+			item.Resource,
+			item.Action,
+			item.Target,
+			strconv.Itoa(int(item.Status)),
+			item.Message,
+		})
+	}
+	writer.Flush()
+	return
+}
+
+func (self Audit) Prune(http *gin.Context) {
+	type ParamsValidate struct {
+		All bool `json:"all"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	var err error
+	if params.All {
+		_, err = dao.AuditLog.Where(dao.AuditLog.ID.Gt(0)).Delete()
+	} else {
+		err = logic.Audit{}.Prune()
+	}
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"strings"
+
+	"filippo.io/age"
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+)
+
+type BackupEncryption struct {
+	controller.Abstract
+}
+
+func (self BackupEncryption) Create(http *gin.Context) {
+	type ParamsValidate struct {
+		accessor.BackupEncryption
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	old, _ := logic.BackupEncryption{}.Get(params.Name)
+	item := params.BackupEncryption
+	encode := func(value string, oldValue string) string {
+		if function.IsSensitivePlaceholder(value) {
+			return oldValue
+		}
+		if v, err := function.RSAEncode(value); err == nil && value != "" {
+			return v
+		}
+		return value
+	}
+	switch item.Type {
+	case backup.EncryptionTypePassphrase:
+		item.Recipient, item.Identity = "", ""
+		item.Passphrase = encode(item.Passphrase, old.Passphrase)
+		if item.Passphrase == "" {
+			self.JsonResponseWithError(http, backup.ErrEncryptionIdentityRequired, 500)
+			return
+		}
+	case backup.EncryptionTypeX25519:
+		item.Passphrase = ""
+		if function.IsSensitivePlaceholder(item.Identity) {
+			item.Identity = old.Identity
+		} else if item.Identity != "" {
+			// 提供私钥时以私钥推导出的公钥为准
+			identity, err := age.ParseX25519Identity(strings.TrimSpace(item.Identity))
+			if err != nil {
+				self.JsonResponseWithError(http, err, 500)
+				return
+			}
+			item.Recipient = identity.Recipient().String()
+			item.Identity = encode(identity.String(), "")
+		}
+		if item.Recipient == "" {
+			recipient, identity, err := logic.BackupEncryption{}.Generate()
+			if err != nil {
+				self.JsonResponseWithError(http, err, 500)
+				return
+			}
+			item.Recipient, item.Identity = recipient, encode(identity, "")
+		}
+		if _, err := age.ParseX25519Recipient(item.Recipient); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	list := logic.BackupEncryption{}.GetList()
+	if index, ok := function.IndexArrayWalk(list, func(i accessor.BackupEncryption) bool {
+		return i.Name == item.Name
+	}); ok {
+		list[index] = item
+	} else {
+		list = append(list, item)
+	}
+	if err := self.save(list); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"recipient": item.Recipient,
+	})
+	return
+}
+
+func (self BackupEncryption) GetList(http *gin.Context) {
+	list := logic.BackupEncryption{}.GetList()
+	for i := range list {
+		list[i].Passphrase = function.MaskSensitiveValue(list[i].Passphrase)
+		list[i].Identity = function.MaskSensitiveValue(list[i].Identity)
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list": list,
+	})
+	return
+}
+
+func (self BackupEncryption) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Name []string `json:"name" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list := function.PluckArrayWalk(logic.BackupEncryption{}.GetList(), func(i accessor.BackupEncryption) (accessor.BackupEncryption, bool) {
+		return i, !function.InArray(params.Name, i.Name)
+	})
+	if err := self.save(list); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+// ExportIdentity 导出 x25519 私钥，用于在其它面板中恢复快照
+func (self BackupEncryption) ExportIdentity(http *gin.Context) {
+	type ParamsValidate struct {
+		Name string `json:"name" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	item, err := logic.BackupEncryption{}.Get(params.Name)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	identity := item.Identity
+	if v, err := function.RSADecode(identity, nil); err == nil {
+		identity = v
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"recipient": item.Recipient,
+		"identity":  identity,
+	})
+	return
+}
+
+func (self BackupEncryption) save(list []accessor.BackupEncryption) error {
+	return logic.Setting{}.Save(&entity.Setting{
+		GroupName: logic.SettingGroupSetting,
+		Name:      logic.SettingGroupSettingBackupEncryption,
+		Value: &accessor.SettingValueOption{
+			BackupEncryption: list,
+		},
+	})
+}
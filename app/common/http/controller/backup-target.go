@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+)
+
+type BackupTarget struct {
+	controller.Abstract
+}
+
+func (self BackupTarget) Create(http *gin.Context) {
+	type ParamsValidate struct {
+		accessor.BackupTarget
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list := logic.BackupTarget{}.GetList()
+	old, _ := logic.BackupTarget{}.Get(params.Name)
+	item := self.encodeSecret(params.BackupTarget, old)
+	if index, ok := function.IndexArrayWalk(list, func(i accessor.BackupTarget) bool {
+		return i.Name == item.Name
+	}); ok {
+		list[index] = item
+	} else {
+		list = append(list, item)
+	}
+	if err := self.save(list); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+func (self BackupTarget) GetList(http *gin.Context) {
+	list := logic.BackupTarget{}.GetList()
+	for i, item := range list {
+		if item.S3 != nil {
+			s3 := *item.S3
+			s3.SecretAccessKey = function.MaskSensitiveValue(s3.SecretAccessKey)
+			list[i].S3 = &s3
+		}
+		if item.Sftp != nil {
+			sftp := *item.Sftp
+			sftp.Password = function.MaskSensitiveValue(sftp.Password)
+			sftp.PrivateKey = function.MaskSensitiveValue(sftp.PrivateKey)
+			list[i].Sftp = &sftp
+		}
+		if item.Webdav != nil {
+			webdav := *item.Webdav
+			webdav.Password = function.MaskSensitiveValue(webdav.Password)
+			list[i].Webdav = &webdav
+		}
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list": list,
+	})
+	return
+}
+
+func (self BackupTarget) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Name []string `json:"name" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	for _, name := range params.Name {
+		if err := (logic.BackupTarget{}).CheckInUse(name); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	list := function.PluckArrayWalk(logic.BackupTarget{}.GetList(), func(i accessor.BackupTarget) (accessor.BackupTarget, bool) {
+		return i, !function.InArray(params.Name, i.Name)
+	})
+	if err := self.save(list); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+func (self BackupTarget) Test(http *gin.Context) {
+	type ParamsValidate struct {
+		accessor.BackupTarget
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	old, _ := logic.BackupTarget{}.Get(params.Name)
+	if err := (logic.BackupTarget{}).Test(http.Request.Context(), self.encodeSecret(params.BackupTarget, old)); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+// encodeSecret 加密保存密钥，提交占位符时沿用旧的配置
+func (self BackupTarget) encodeSecret(item accessor.BackupTarget, old accessor.BackupTarget) accessor.BackupTarget {
+	encode := func(value string, oldValue string) string {
+		if function.IsSensitivePlaceholder(value) {
+			return oldValue
+		}
+		if v, err := function.RSAEncode(value); err == nil && value != "" {
+			return v
+		}
+		return value
+	}
+	if item.S3 != nil {
+		oldValue := ""
+		if old.S3 != nil {
+			oldValue = old.S3.SecretAccessKey
+		}
+		item.S3.SecretAccessKey = encode(item.S3.SecretAccessKey, oldValue)
+	}
+	if item.Sftp != nil {
+		oldPassword, oldPrivateKey := "", ""
+		if old.Sftp != nil {
+			oldPassword, oldPrivateKey = old.Sftp.Password, old.Sftp.PrivateKey
+		}
+		item.Sftp.Password = encode(item.Sftp.Password, oldPassword)
+		item.Sftp.PrivateKey = encode(item.Sftp.PrivateKey, oldPrivateKey)
+	}
+	if item.Webdav != nil {
+		oldValue := ""
+		if old.Webdav != nil {
+			oldValue = old.Webdav.Password
+		}
+		item.Webdav.Password = encode(item.Webdav.Password, oldValue)
+	}
+	return item
+}
+
+func (self BackupTarget) save(list []accessor.BackupTarget) error {
+	return logic.Setting{}.Save(&entity.Setting{
+		GroupName: logic.SettingGroupSetting,
+		Name:      logic.SettingGroupSettingBackupTarget,
+		Value: &accessor.SettingValueOption{
+			BackupTarget: list,
+		},
+	})
+}
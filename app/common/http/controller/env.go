@@ -38,6 +38,15 @@ func (self Env) GetList(http *gin.Context) {
 
 	var current types2.DockerEnv
 
+	var userInfo *logic.UserInfo
+	if data, ok := http.Get("userInfo"); ok {
+		userInfo = function.Ptr(data.(logic.UserInfo))
+		// 证书内容仅管理员可以查看
+		if params.EnableCertContent && (logic.UserRole{}).Get(*userInfo, "", "env") != define.UserRoleAdmin {
+			params.EnableCertContent = false
+		}
+	}
+
 	result := make([]*types2.DockerEnv, 0)
 	if setting, err := (logic.Setting{}).GetValue(logic.SettingGroupSetting, logic.SettingGroupSettingDocker); err == nil {
 		for _, item := range setting.Value.Docker {
@@ -33,6 +33,7 @@ import (
 	"github.com/donknap/dpanel/common/service/docker"
 	types2 "github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/service/exec/local"
+	"github.com/donknap/dpanel/common/service/metrics"
 	"github.com/donknap/dpanel/common/service/notice"
 	"github.com/donknap/dpanel/common/service/plugin"
 	"github.com/donknap/dpanel/common/service/ssh"
@@ -778,6 +779,27 @@ func (self Home) Usage(http *gin.Context) {
 	})
 }
 
+func (self Home) GetStatHistory(http *gin.Context) {
+	type ParamsValidate struct {
+		Range         string   `json:"range" binding:"required,oneof=1h 24h 7d"`
+		ContainerName []string `json:"containerName"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list, err := metrics.History.Query(docker.Sdk.Name, params.Range, params.ContainerName...)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list":       list,
+		"resolution": metrics.HistoryRanges[params.Range].Resolution,
+	})
+	return
+}
+
 func (self Home) GetStatList(http *gin.Context) {
 	type ParamsValidate struct {
 		Follow bool `json:"follow"`
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	applicationLogic "github.com/donknap/dpanel/app/application/logic"
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/service/docker"
+	types2 "github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/metrics"
+	"github.com/donknap/dpanel/common/service/notice"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/service/ws"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+)
+
+// 查询单个环境的超时时间，避免一个环境无响应时阻塞整个采集
+const metricsEnvTimeout = time.Second * 5
+
+type Metrics struct {
+	controller.Abstract
+}
+
+// Export 以 Prometheus 文本格式输出面板指标
+func (self Metrics) Export(http *gin.Context) {
+	exposition := metrics.NewExposition()
+
+	down := self.exportCompose(exposition)
+	self.exportDockerEnv(exposition, down)
+	self.exportContainer(exposition)
+	self.exportCron(exposition)
+	self.exportRuntime(exposition)
+
+	http.Header("Content-Type", metrics.ExpositionContentType)
+	_, _ = exposition.WriteTo(http.Writer)
+	return
+}
+
+// exportDockerEnv down 为本次采集中查询超时的环境，直接标记为不可用
+func (self Metrics) exportDockerEnv(exposition *metrics.Exposition, down map[string]bool) {
+	setting, err := (logic.Setting{}).GetValue(logic.SettingGroupSetting, logic.SettingGroupSettingDocker)
+	if err != nil || setting.Value == nil {
+		return
+	}
+	for _, item := range setting.Value.Docker {
+		available := 0.0
+		if v, ok := storage.Cache.Get(fmt.Sprintf(storage.CacheKeyDockerStatus, item.Name)); ok && v.(types2.DockerStatus).Available && !down[item.Name] {
+			available = 1
+		}
+		exposition.Gauge("dpanel_docker_env_available", "Whether the docker environment is reachable (1) or not (0).", available,
+			"env", item.Name, "title", item.Title)
+	}
+}
+
+func (self Metrics) exportContainer(exposition *metrics.Exposition) {
+	for name := range notice.Monitor.Clients() {
+		sample, ok := metrics.Sampler.Latest(name)
+		if !ok {
+			continue
+		}
+		for _, item := range sample.Point {
+			labels := []string{"env", name, "container", item.Name, "id", item.Container}
+			exposition.Gauge("dpanel_container_cpu_percent", "Container cpu usage in percent.", item.Cpu, labels...)
+			exposition.Gauge("dpanel_container_memory_usage_bytes", "Container memory usage in bytes.", item.Memory, labels...)
+			exposition.Gauge("dpanel_container_memory_limit_bytes", "Container memory limit in bytes.", item.MemoryLimit, labels...)
+			exposition.Gauge("dpanel_container_network_receive_bytes_per_second", "Container network receive rate in bytes per second.", item.NetworkRx, labels...)
+			exposition.Gauge("dpanel_container_network_transmit_bytes_per_second", "Container network transmit rate in bytes per second.", item.NetworkTx, labels...)
+			exposition.Gauge("dpanel_container_block_read_bytes_per_second", "Container block read rate in bytes per second.", item.BlockRead, labels...)
+			exposition.Gauge("dpanel_container_block_write_bytes_per_second", "Container block write rate in bytes per second.", item.BlockWrite, labels...)
+		}
+	}
+}
+
+// exportCompose 返回查询超时的环境
+func (self Metrics) exportCompose(exposition *metrics.Exposition) map[string]bool {
+	down := make(map[string]bool)
+	for name, dockerClient := range notice.Monitor.Clients() {
+		if dockerClient == nil || dockerClient.Client == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(dockerClient.Ctx, metricsEnvTimeout)
+		// 复制一份客户端替换上下文，不影响监控中使用的客户端
+		timeoutClient := *dockerClient
+		timeoutClient.Ctx = ctx
+		timeoutClient.CtxCancelFunc = nil
+		projectList := (applicationLogic.Compose{}).LsWithClient(&timeoutClient)
+		timeout := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		cancel()
+		if timeout {
+			down[name] = true
+			continue
+		}
+		for _, project := range projectList {
+			stateCount := make(map[string]int)
+			for _, item := range project.ContainerList {
+				stateCount[item.Container.State] += 1
+			}
+			running := 0.0
+			if stateCount["running"] > 0 {
+				running = 1
+			}
+			exposition.Gauge("dpanel_compose_project_up", "Whether the compose project has running containers (1) or not (0).", running,
+				"env", name, "project", project.Name)
+			states := make([]string, 0, len(stateCount))
+			for state := range stateCount {
+				states = append(states, state)
+			}
+			sort.Strings(states)
+			for _, state := range states {
+				exposition.Gauge("dpanel_compose_project_containers", "Number of compose project containers grouped by state.", float64(stateCount[state]),
+					"env", name, "project", project.Name, "state", state)
+			}
+		}
+	}
+	return down
+}
+
+func (self Metrics) exportCron(exposition *metrics.Exposition) {
+	cronList, err := dao.Cron.Find()
+	if err != nil {
+		return
+	}
+	for _, item := range cronList {
+		cronLog, err := dao.CronLog.Where(dao.CronLog.CronID.Eq(item.ID)).Order(dao.CronLog.ID.Desc()).First()
+		if err != nil || cronLog.Value == nil {
+			continue
+		}
+		labels := []string{"id", strconv.Itoa(int(item.ID)), "title", item.Title}
+		success := 1.0
+		if cronLog.Value.Error != "" {
+			success = 0
+		}
+		exposition.Gauge("dpanel_cron_last_success", "Whether the last run of the cron job succeeded (1) or failed (0).", success, labels...)
+		exposition.Gauge("dpanel_cron_last_duration_seconds", "Duration of the last run of the cron job in seconds.", cronLog.Value.UseTime, labels...)
+		exposition.Gauge("dpanel_cron_last_run_timestamp_seconds", "Unix timestamp of the last run of the cron job.", float64(cronLog.Value.RunTime.Unix()), labels...)
+	}
+}
+
+func (self Metrics) exportRuntime(exposition *metrics.Exposition) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	exposition.Gauge("dpanel_goroutines", "Number of goroutines of the panel process.", float64(runtime.NumGoroutine()))
+	exposition.Gauge("dpanel_memory_alloc_bytes", "Bytes of allocated heap objects.", float64(m.Alloc))
+	exposition.Gauge("dpanel_memory_sys_bytes", "Total bytes of memory obtained from the OS.", float64(m.Sys))
+	exposition.Gauge("dpanel_memory_heap_idle_bytes", "Bytes in idle heap spans.", float64(m.HeapIdle))
+	exposition.Gauge("dpanel_memory_heap_released_bytes", "Bytes of physical memory returned to the OS.", float64(m.HeapReleased))
+	exposition.Gauge("dpanel_memory_stack_inuse_bytes", "Bytes in stack spans.", float64(m.StackInuse))
+	exposition.Counter("dpanel_gc_total", "Number of completed GC cycles.", float64(m.NumGC))
+	exposition.Counter("dpanel_gc_pause_seconds_total", "Cumulative GC stop-the-world pause in seconds.", float64(m.PauseTotalNs)/1e9)
+	exposition.Gauge("dpanel_gc_cpu_fraction", "Fraction of available CPU time used by the GC.", m.GCCPUFraction)
+	exposition.Gauge("dpanel_ws_clients", "Number of connected websocket clients.", float64(ws.GetCollect().Total()))
+	exposition.Gauge("dpanel_ws_progress", "Number of active websocket progress pipes.", float64(ws.GetCollect().ProgressTotal()))
+	exposition.Gauge("dpanel_docker_current_env_info", "Docker environment currently selected by the panel.", 1, "env", docker.Sdk.Name)
+}
@@ -3,10 +3,9 @@ package controller
 import (
 	"bufio"
 	"errors"
-	"fmt"
-	"io/fs"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -18,7 +17,7 @@ import (
 	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/entity"
 	"github.com/donknap/dpanel/common/function"
-	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/crontab"
 	"github.com/donknap/dpanel/common/service/docker/backup"
 	"github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/service/exec/local"
@@ -26,7 +25,6 @@ import (
 	"github.com/donknap/dpanel/common/types/define"
 	"github.com/gin-gonic/gin"
 	"github.com/mcuadros/go-version"
-	"github.com/mholt/archives"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/we7coreteam/registry-go-sdk"
 	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
@@ -95,6 +93,8 @@ func (self Panel) Backup(http *gin.Context) {
 		EnableBackupVolume     bool     `json:"enableBackupVolume"`
 		EnableBackupApp        bool     `json:"enableBackupApp"`
 		IgnoreVolumePathPrefix []string `json:"ignoreVolumePathPrefix"`
+		TargetName             string   `json:"targetName"`
+		EncryptionName         string   `json:"encryptionName"`
 	}
 
 	params := ParamsValidate{}
@@ -102,95 +102,16 @@ func (self Panel) Backup(http *gin.Context) {
 		return
 	}
 
-	params.IgnoreVolumePathPrefix = append(params.IgnoreVolumePathPrefix, "backup/dpanel/dpanel-main", "storage/temp")
-
-	panelAllPath := function.PluckArrayWalk((logic.Panel{}).GetPanelPath(), func(item *types.ValueItem) (string, bool) {
-		return item.Value, true
+	backupTar, err := logic.Panel{}.Backup(http.Request.Context(), logic.PanelBackupOption{
+		BackupPathList:   params.BackupVolumePathList,
+		IgnorePathPrefix: params.IgnoreVolumePathPrefix,
+		TargetName:       params.TargetName,
+		EncryptionName:   params.EncryptionName,
 	})
-
-	if function.IsEmptyArray(params.BackupVolumePathList) {
-		params.BackupVolumePathList = panelAllPath
-	}
-
-	backupTime := time.Now().Format(define.DateYmdHis)
-	suffix := fmt.Sprintf("dpanel-main-%s", backupTime)
-	backupRelTar := filepath.Join("dpanel", suffix+".snapshot")
-	backupTar := filepath.Join(storage.Local{}.GetBackupPath(), backupRelTar)
-
-	b, err := backup.New(
-		backup.WithTarPathPrefix("dpanel"),
-		backup.WithPath(backupTar),
-		backup.WithWriter(),
-	)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	defer func() {
-		_ = b.Close()
-	}()
-
-	info := backup.Info{
-		Extend: gin.H{
-			"Version": facade.GetConfig().Get("app.version"),
-			"Family":  facade.GetConfig().Get("app.family"),
-		},
-		Backup: &entity.Backup{
-			ID:          0,
-			ContainerID: "",
-			Setting: &accessor.BackupSettingOption{
-				BackupTargetType: define.DockerContainerBackupTypeSnapshot,
-				BackupTar:        filepath.ToSlash(backupRelTar),
-				VolumePathList:   make([]string, 0),
-				Status:           define.DockerImageBuildStatusSuccess,
-			},
-			CreatedAt: time.Now(),
-		},
-	}
-	info.Docker, err = docker.Sdk.Client.ServerVersion(b.Context())
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
 	}
-	params.BackupVolumePathList = append(params.BackupVolumePathList, "dpanel.lic")
-	manifest := make([]backup.Manifest, 0)
-	targetFile, err := archives.FilesFromDisk(b.Context(), nil, function.PluckArrayMapWalk(params.BackupVolumePathList, func(item string) (string, string, bool) {
-		if !function.InArray(panelAllPath, item) {
-			return "", "", false
-		}
-		return filepath.Join(storage.Local{}.GetStorageLocalPath(), item), item, true
-	}))
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	hash := make([]string, 0)
-	targetFile = function.PluckArrayWalk(targetFile, func(item archives.FileInfo) (archives.FileInfo, bool) {
-		if ok := function.InArrayWalk(params.IgnoreVolumePathPrefix, func(p string) bool {
-			return strings.HasPrefix(item.NameInArchive, p)
-		}); ok {
-			return item, false
-		}
-		hash = append(hash, item.NameInArchive)
-		return item, true
-	})
-
-	volumePath, err := b.Writer.WriteBlobFiles(function.Sha256Struct(hash), targetFile)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	manifest = append(manifest, backup.Manifest{
-		Volume: []string{
-			volumePath,
-		},
-	})
-	err = b.Writer.WriteConfigFile("manifest.json", manifest)
-	if err != nil {
-		self.JsonResponseWithError(http, err, 500)
-		return
-	}
-	err = b.Writer.WriteConfigFile("info.json", info)
 
 	self.JsonResponseWithoutError(http, gin.H{
 		"path": backupTar,
@@ -342,38 +263,18 @@ func (self Panel) Update(http *gin.Context) {
 }
 
 func (self Panel) BackupList(http *gin.Context) {
-	root := logic.Panel{}.SaveRootPath()
-	type backupFileItem struct {
-		Path      string    `json:"path"`
-		CreatedAt time.Time `json:"createdAt"`
-		Size      int64     `json:"size"`
-	}
-	backupList := make([]backupFileItem, 0)
-	err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
-		if path == root || !strings.HasSuffix(path, ".snapshot") {
-			return nil
-		}
-		if info.IsDir() {
-			return filepath.SkipDir
-		}
-		rel, err := filepath.Rel(root, path)
-		if err != nil {
-			return err
-		}
-		backupList = append(backupList, backupFileItem{
-			Path:      rel,
-			Size:      info.Size(),
-			CreatedAt: info.ModTime(),
-		})
-		return nil
-	})
+	type ParamsValidate struct {
+		TargetName string `json:"targetName"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	backupList, err := logic.Panel{}.BackupList(http.Request.Context(), params.TargetName)
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
 	}
-	sort.Slice(backupList, func(i, j int) bool {
-		return backupList[i].CreatedAt.After(backupList[j].CreatedAt)
-	})
 
 	self.JsonResponseWithoutError(http, gin.H{
 		"list": backupList,
@@ -383,32 +284,104 @@ func (self Panel) BackupList(http *gin.Context) {
 
 func (self Panel) BackupDelete(http *gin.Context) {
 	type ParamsValidate struct {
-		Name []string `json:"name"`
+		Name       []string `json:"name"`
+		TargetName string   `json:"targetName"`
 	}
 	params := ParamsValidate{}
 	if !self.Validate(http, &params) {
 		return
 	}
 	for _, s := range params.Name {
-		backupFilePath := function.SafePathJoin(logic.Panel{}.SaveRootPath(), s)
-		if _, err := os.Stat(backupFilePath); errors.Is(err, os.ErrNotExist) {
+		if err := (logic.Panel{}).BackupDelete(http.Request.Context(), params.TargetName, s); err != nil {
 			self.JsonResponseWithError(http, err, 500)
 			return
 		}
-		err := function.SafeDelete(
-			logic.Panel{}.SaveRootPath(),
-			s,
-		)
-		if err != nil {
+	}
+
+	self.JsonSuccessResponse(http)
+	return
+}
+
+func (self Panel) BackupSchedule(http *gin.Context) {
+	self.JsonResponseWithoutError(http, gin.H{
+		"schedule": logic.PanelBackupSchedule{}.Get(),
+	})
+	return
+}
+
+func (self Panel) BackupScheduleSave(http *gin.Context) {
+	type ParamsValidate struct {
+		Enable           bool                             `json:"enable"`
+		Expression       []accessor.CronSettingExpression `json:"expression"`
+		BackupPathList   []string                         `json:"backupPathList"`
+		IgnorePathPrefix []string                         `json:"ignorePathPrefix"`
+		Retention        accessor.BackupRetention         `json:"retention"`
+		TargetName       string                           `json:"targetName"`
+		EncryptionName   string                           `json:"encryptionName"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if params.TargetName != "" {
+		if _, err := (logic.BackupTarget{}).Get(params.TargetName); err != nil {
 			self.JsonResponseWithError(http, err, 500)
 			return
 		}
 	}
-
+	if params.EncryptionName != "" {
+		if _, err := (logic.BackupEncryption{}).Get(params.EncryptionName); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	if params.Enable {
+		if function.IsEmptyArray(params.Expression) {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageContainerCronExpressionInCorrect, "message", "expression is empty"), 500)
+			return
+		}
+		err := crontab.Client.CheckExpression(function.PluckArrayWalk(params.Expression, func(item accessor.CronSettingExpression) (string, bool) {
+			return item.ToString(), true
+		})...)
+		if err != nil {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageContainerCronExpressionInCorrect, "message", err.Error()), 500)
+			return
+		}
+	}
+	err := logic.PanelBackupSchedule{}.Save(accessor.PanelBackup{
+		Enable:           params.Enable,
+		Expression:       params.Expression,
+		BackupPathList:   params.BackupPathList,
+		IgnorePathPrefix: params.IgnorePathPrefix,
+		Retention:        params.Retention,
+		TargetName:       params.TargetName,
+		EncryptionName:   params.EncryptionName,
+	})
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
 	self.JsonSuccessResponse(http)
 	return
 }
 
+// BackupScheduleRun 立即按定时备份的配置执行一次，包含过期快照的清理
+func (self Panel) BackupScheduleRun(http *gin.Context) {
+	err := logic.PanelBackupSchedule{}.Run(http.Request.Context())
+	if errors.Is(err, crontab.SkipRun) {
+		self.JsonResponseWithError(http, errors.New("panel backup is running"), 500)
+		return
+	}
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"schedule": logic.PanelBackupSchedule{}.Get(),
+	})
+	return
+}
+
 func (self Panel) BackupDownload(http *gin.Context) {
 	type ParamsValidate struct {
 		Name string `json:"name"`
@@ -435,13 +408,23 @@ func (self Panel) BackupDownload(http *gin.Context) {
 
 func (self Panel) BackupRestore(http *gin.Context) {
 	type ParamsValidate struct {
-		Name string `json:"name"`
+		Name       string `json:"name"`
+		TargetName string `json:"targetName"`
+		Secret     string `json:"secret"` // 加密备份的口令或私钥
 	}
 	params := ParamsValidate{}
 	if !self.Validate(http, &params) {
 		return
 	}
 	backupTar := filepath.Join(logic.Panel{}.SaveRootPath(), function.SafeFileName(params.Name))
+	if _, err := os.Stat(backupTar); err != nil && params.TargetName != "" {
+		// 远程存储中的备份先下载到本地备份目录
+		err = logic.BackupTarget{}.Download(http.Request.Context(), params.TargetName, path.Join("dpanel", function.SafeFileName(params.Name)), backupTar)
+		if err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
 	if _, err := os.Stat(backupTar); err != nil {
 		self.JsonResponseWithError(http, err, 500)
 		return
@@ -450,6 +433,7 @@ func (self Panel) BackupRestore(http *gin.Context) {
 		backup.WithTarPathPrefix("dpanel"),
 		backup.WithPath(backupTar),
 		backup.WithReader(),
+		logic.BackupEncryption{}.Identity(params.Secret),
 	)
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
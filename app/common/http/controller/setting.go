@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -113,6 +114,10 @@ func (self Setting) SaveConfig(http *gin.Context) {
 		Notification *accessor.Notification       `json:"notification"`
 		Login        *accessor.Login              `json:"login"`
 		TwoFa        *accessor.TwoFa              `json:"twoFa"`
+		Audit        *accessor.Audit              `json:"audit"`
+		Oidc         *accessor.Oidc               `json:"oidc"`
+		Ldap         *accessor.Ldap               `json:"ldap"`
+		CertMonitor  *accessor.CertMonitor        `json:"certMonitor"`
 		SaveCache    bool                         `json:"saveCache"`
 	}
 	params := ParamsValidate{}
@@ -173,6 +178,62 @@ func (self Setting) SaveConfig(http *gin.Context) {
 		value = params.Login
 	}
 
+	if params.Audit != nil {
+		settingRow = &entity.Setting{
+			GroupName: logic.SettingGroupSetting,
+			Name:      logic.SettingGroupSettingAudit,
+			Value: &accessor.SettingValueOption{
+				Audit: params.Audit,
+			},
+		}
+		value = params.Audit
+	}
+
+	if params.Oidc != nil {
+		if err := (logic.UserRole{}).CheckBinding(function.PluckArrayWalk(params.Oidc.RoleMapping, func(i accessor.UserRoleMapping) (accessor.UserRoleBinding, bool) {
+			return i.UserRoleBinding, true
+		})); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+		settingRow = &entity.Setting{
+			GroupName: logic.SettingGroupSetting,
+			Name:      logic.SettingGroupSettingOidc,
+			Value: &accessor.SettingValueOption{
+				Oidc: params.Oidc,
+			},
+		}
+		value = params.Oidc
+	}
+
+	if params.Ldap != nil {
+		if err := (logic.UserRole{}).CheckBinding(function.PluckArrayWalk(params.Ldap.RoleMapping, func(i accessor.UserRoleMapping) (accessor.UserRoleBinding, bool) {
+			return i.UserRoleBinding, true
+		})); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+		settingRow = &entity.Setting{
+			GroupName: logic.SettingGroupSetting,
+			Name:      logic.SettingGroupSettingLdap,
+			Value: &accessor.SettingValueOption{
+				Ldap: params.Ldap,
+			},
+		}
+		value = params.Ldap
+	}
+
+	if params.CertMonitor != nil {
+		settingRow = &entity.Setting{
+			GroupName: logic.SettingGroupSetting,
+			Name:      logic.SettingGroupSettingCertMonitor,
+			Value: &accessor.SettingValueOption{
+				CertMonitor: params.CertMonitor,
+			},
+		}
+		value = params.CertMonitor
+	}
+
 	err := logic.Setting{}.Save(settingRow)
 	if err != nil {
 		self.JsonResponseWithError(http, err, 500)
@@ -227,12 +288,33 @@ func (self Home) NotificationEmailTest(http *gin.Context) {
 	self.JsonSuccessResponse(http)
 }
 
+func (self Home) NotificationChannelTest(http *gin.Context) {
+	type ParamsValidate struct {
+		Channel *accessor.NotificationChannel `json:"channel" binding:"required"`
+		Subject string                        `json:"subject" binding:"required"`
+		Content string                        `json:"content" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	setting := accessor.Notification{}
+	logic.Setting{}.GetByKey(logic.SettingGroupSetting, logic.SettingGroupSettingNotification, &setting)
+
+	err := logic.Notice{}.SendChannel(*params.Channel, setting.EmailServer, "test", params.Subject, params.Content)
+	if err != nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSettingNotificationChannelInvalid, "error", err.Error()), 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+}
+
 func (self Home) Notification(http *gin.Context) {
 	type ParamsValidate struct {
 		Channel string `json:"channel"`
 		Subject string `json:"subject"`
 		Content string `json:"content" binding:"required"`
-		Target  string `json:"target" binding:"required"`
+		Target  string `json:"target"`
 	}
 	params := ParamsValidate{}
 	if !self.Validate(http, &params) {
@@ -245,12 +327,31 @@ func (self Home) Notification(http *gin.Context) {
 		params.Subject = "DPanel Notification"
 	}
 
-	if params.Channel == "email" {
+	if params.Channel == "" || params.Channel == define.NotificationChannelEmail {
 		err := logic.Notice{}.Send(setting.EmailServer, params.Target, params.Subject, params.Content)
 		if err != nil {
 			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSettingBasicEmailInvalid, err.Error()), 500)
 			return
 		}
+		self.JsonSuccessResponse(http)
+		return
+	}
+
+	// 其它渠道按配置的渠道名称发送，target 不为空时覆盖渠道中的接收者
+	channelOption, _, ok := function.PluckArrayItemWalk(setting.Channel, func(item accessor.NotificationChannel) bool {
+		return item.Name == params.Channel
+	})
+	if !ok {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+		return
+	}
+	if params.Target != "" {
+		channelOption.Target = params.Target
+	}
+	err := logic.Notice{}.SendChannel(channelOption, setting.EmailServer, "manual", params.Subject, params.Content)
+	if err != nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageSettingNotificationChannelInvalid, "error", err.Error()), 500)
+		return
 	}
 	self.JsonSuccessResponse(http)
 }
@@ -288,3 +389,25 @@ func (self Home) Cache(http *gin.Context) {
 	})
 	return
 }
+
+func (self Setting) LdapTest(http *gin.Context) {
+	type ParamsValidate struct {
+		accessor.Ldap
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if params.Url == "" {
+		self.JsonResponseWithError(http, errors.New("ldap url is required"), 500)
+		return
+	}
+	conn, err := logic.Ldap{}.Connect(params.Ldap)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	_ = conn.Close()
+	self.JsonSuccessResponse(http)
+	return
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+)
+
+type UserToken struct {
+	controller.Abstract
+}
+
+func (self UserToken) Create(http *gin.Context) {
+	type ParamsValidate struct {
+		Title      string `json:"title" binding:"required"`
+		Role       string `json:"role" binding:"omitempty,oneof=admin operator readonly"`
+		ExpireDays int    `json:"expireDays" binding:"omitempty,min=1"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	userInfo, ok := self.getUserInfo(http)
+	if !ok {
+		return
+	}
+	option := &accessor.UserTokenSettingOption{
+		Role: params.Role,
+	}
+	if params.ExpireDays > 0 {
+		option.ExpireAt = function.Ptr(time.Now().AddDate(0, 0, params.ExpireDays))
+	}
+	token, userToken, err := logic.UserToken{}.Create(userInfo.UserId, params.Title, option)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"id":    userToken.ID,
+		"token": token,
+	})
+	return
+}
+
+func (self UserToken) GetList(http *gin.Context) {
+	userInfo, ok := self.getUserInfo(http)
+	if !ok {
+		return
+	}
+	list, err := dao.UserToken.Where(dao.UserToken.UserID.Eq(userInfo.UserId)).Order(dao.UserToken.ID.Desc()).Find()
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	for _, item := range list {
+		item.Token = ""
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list": list,
+	})
+	return
+}
+
+func (self UserToken) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Id []int32 `json:"id" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	userInfo, ok := self.getUserInfo(http)
+	if !ok {
+		return
+	}
+	_, err := dao.UserToken.Where(dao.UserToken.ID.In(params.Id...), dao.UserToken.UserID.Eq(userInfo.UserId)).Delete()
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+// 令牌只能通过登录后的会话管理，不能使用令牌再创建令牌
+func (self UserToken) getUserInfo(http *gin.Context) (logic.UserInfo, bool) {
+	data, exists := http.Get("userInfo")
+	if !exists {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserLogin), 401)
+		return logic.UserInfo{}, false
+	}
+	userInfo := data.(logic.UserInfo)
+	if userInfo.TokenId > 0 {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserNoPermission), 403)
+		return logic.UserInfo{}, false
+	}
+	return userInfo, true
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/controller"
+)
+
+type UserWebauthn struct {
+	controller.Abstract
+}
+
+func (self UserWebauthn) RegisterBegin(http *gin.Context) {
+	type ParamsValidate struct {
+		Name string `json:"name"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	user, ok := self.getUser(http)
+	if !ok {
+		return
+	}
+	creation, sessionId, err := logic.Webauthn{}.BeginRegister(http.Request, user, params.Name)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"options":   creation,
+		"sessionId": sessionId,
+	})
+	return
+}
+
+func (self UserWebauthn) RegisterFinish(http *gin.Context) {
+	type ParamsValidate struct {
+		SessionId  string          `json:"sessionId" binding:"required"`
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	user, ok := self.getUser(http)
+	if !ok {
+		return
+	}
+	passkey, err := logic.Webauthn{}.FinishRegister(http.Request, user, params.SessionId, params.Credential)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	result := gin.H{
+		"id":   logic.Webauthn{}.PasskeyId(*passkey),
+		"name": passkey.Name,
+	}
+	// 首次开启两步验证时生成恢复码，使用验证码的用户在首次验证码登录时生成
+	if len(user.Value.RecoveryCode) == 0 {
+		recoveryCode, err := logic.Webauthn{}.CreateRecoveryCode(user)
+		if err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+		result["recoveryCode"] = recoveryCode
+	}
+	self.JsonResponseWithoutError(http, result)
+	return
+}
+
+func (self UserWebauthn) GetList(http *gin.Context) {
+	user, ok := self.getUser(http)
+	if !ok {
+		return
+	}
+	type item struct {
+		Id         string     `json:"id"`
+		Name       string     `json:"name"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		LastUsedAt *time.Time `json:"lastUsedAt"`
+	}
+	list := make([]item, 0)
+	for _, passkey := range user.Value.Passkey {
+		list = append(list, item{
+			Id:         logic.Webauthn{}.PasskeyId(passkey),
+			Name:       passkey.Name,
+			CreatedAt:  passkey.CreatedAt,
+			LastUsedAt: passkey.LastUsedAt,
+		})
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list":         list,
+		"recoveryCode": len(user.Value.RecoveryCode),
+	})
+	return
+}
+
+func (self UserWebauthn) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Id []string `json:"id" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	user, ok := self.getUser(http)
+	if !ok {
+		return
+	}
+	if err := (logic.Webauthn{}).DeletePasskey(user, params.Id); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
+
+func (self UserWebauthn) LoginBegin(http *gin.Context) {
+	type ParamsValidate struct {
+		Username  string `json:"username"`
+		AutoLogin bool   `json:"autoLogin"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if params.Username != "" {
+		if err := (logic.User{}).CheckLock(params.Username); err != nil {
+			self.JsonResponseWithError(http, err, 500)
+			return
+		}
+	}
+	assertion, sessionId, err := logic.Webauthn{}.BeginLogin(http.Request, params.Username, params.AutoLogin)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"options":   assertion,
+		"sessionId": sessionId,
+	})
+	return
+}
+
+// LoginFinish 用于密码登录后的二次验证及无密码登录
+func (self UserWebauthn) LoginFinish(http *gin.Context) {
+	type ParamsValidate struct {
+		SessionId  string          `json:"sessionId" binding:"required"`
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	user, session, err := logic.Webauthn{}.FinishLogin(http.Request, params.SessionId, params.Credential)
+	// 与密码登录共用失败计数，锁定后不再接受凭证
+	username := ""
+	if user != nil {
+		username = user.Value.Username
+	} else if session != nil {
+		username = session.Username
+	}
+	if username != "" {
+		if lockErr := (logic.User{}).CheckLock(username); lockErr != nil {
+			self.JsonResponseWithError(http, lockErr, 500)
+			return
+		}
+	}
+	if err != nil {
+		if username != "" {
+			logic.User{}.Lock(username, true)
+		}
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserTwoFaNotCorrect), 500)
+		return
+	}
+	logic.User{}.Lock(username, false)
+	if err = (User{}).checkLoginUser(user); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	_, _ = User{}.loginSuccess(http, user, session.AutoLogin, nil)
+	return
+}
+
+func (self UserWebauthn) CreateRecoveryCode(http *gin.Context) {
+	user, ok := self.getUser(http)
+	if !ok {
+		return
+	}
+	recoveryCode, err := logic.Webauthn{}.CreateRecoveryCode(user)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"recoveryCode": recoveryCode,
+	})
+	return
+}
+
+// 通行密钥及恢复码只能在登录后的会话中管理，不能使用令牌操作
+func (self UserWebauthn) getUser(http *gin.Context) (*entity.Setting, bool) {
+	data, exists := http.Get("userInfo")
+	if !exists {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserLogin), 401)
+		return nil, false
+	}
+	userInfo := data.(logic.UserInfo)
+	if userInfo.TokenId > 0 {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserNoPermission), 403)
+		return nil, false
+	}
+	user, err := logic.Setting{}.GetValueById(userInfo.UserId)
+	if err != nil || user.GroupName != logic.SettingGroupUser || user.Value == nil {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserLogin), 401)
+		return nil, false
+	}
+	return user, true
+}
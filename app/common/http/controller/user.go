@@ -1,11 +1,15 @@
 package controller
 
 import (
+	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/donknap/dpanel/app/common/logic"
 	"github.com/donknap/dpanel/app/common/logic/oauth"
 	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
 	"github.com/donknap/dpanel/common/function"
 	"github.com/donknap/dpanel/common/service/docker"
 	"github.com/donknap/dpanel/common/service/family"
@@ -24,10 +28,11 @@ type User struct {
 
 func (self User) Login(http *gin.Context) {
 	type ParamsValidate struct {
-		Username  string `json:"username" binding:"required"`
-		Password  string `json:"password" binding:"required"`
-		AutoLogin bool   `json:"autoLogin"`
-		Code      string `json:"code"`
+		Username     string `json:"username" binding:"required"`
+		Password     string `json:"password" binding:"required"`
+		AutoLogin    bool   `json:"autoLogin"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
 	}
 	params := ParamsValidate{}
 	if !self.Validate(http, &params) {
@@ -42,32 +47,39 @@ func (self User) Login(http *gin.Context) {
 		return
 	}
 
+	// 等待通行密钥验证时密码已经正确，不计入失败次数
+	pending := false
 	defer func() {
-		logic.User{}.Lock(params.Username, code == "")
+		logic.User{}.Lock(params.Username, code == "" && !pending)
 	}()
 
+	// 优先使用本地用户登录，本地用户不存在或是第三方用户时再尝试 LDAP
+	ldapLogin := false
 	currentUser, err := logic.User{}.GetUserByUsername(params.Username)
+	if (err != nil || currentUser.Value.Password == "") && (logic.Ldap{}).Enable() {
+		currentUser, err = logic.Ldap{}.Login(params.Username, params.Password)
+		if err != nil {
+			slog.Debug("user login ldap failed", "username", params.Username, "error", err.Error())
+		}
+		ldapLogin = err == nil
+	}
 	if err != nil {
 		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserUsernameOrPasswordError), 500)
 		return
 	}
-	if currentUser.Value.Password == "" {
+	if currentUser.Value.Password == "" && !ldapLogin {
 		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserUsernameOrPasswordError), 500)
 		return
 	}
 
-	if currentUser.Value.UserStatus == logic.SettingGroupUserStatusDisable {
-		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserDisable), 500)
-		return
-	}
-
-	if !function.InArray((family.Provider{}).Feature(), types.FeatureFamilyEe) && currentUser.Name != logic.SettingGroupUserFounder {
-		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserDisable), 500)
+	if err = self.checkLoginUser(currentUser); err != nil {
+		self.JsonResponseWithError(http, err, 500)
 		return
 	}
 
 	password := logic.User{}.GetMd5Password(params.Password, params.Username)
-	if params.Username == currentUser.Value.Username && currentUser.Value.Password == password {
+	totpPassed := false
+	if ldapLogin || (params.Username == currentUser.Value.Username && currentUser.Value.Password == password) {
 		if !function.InArray((family.Provider{}).Feature(), types.FeatureFamilyCe) {
 			twoFa := accessor.TwoFa{}
 			exists := logic.Setting{}.GetByKey(logic.SettingGroupSetting, logic.SettingGroupSettingTwoFa, &twoFa)
@@ -76,26 +88,45 @@ func (self User) Login(http *gin.Context) {
 					self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserTwoFaEmpty), 500)
 					return
 				}
-				if !totp.Validate(params.Code, twoFa.Secret) {
+				// 验证码不正确时也可以使用恢复码
+				totpPassed = totp.Validate(params.Code, twoFa.Secret)
+				if !totpPassed && !(logic.Webauthn{}).UseRecoveryCode(currentUser, params.Code) {
 					self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserTwoFaNotCorrect), 500)
 					return
 				}
 			}
 		}
-		code, err = logic.User{}.GetUserOauthToken(currentUser, params.AutoLogin)
-		if err != nil {
-			self.JsonResponseWithError(http, err, 500)
-			return
+		// 注册了通行密钥的用户需要再次验证，无法使用通行密钥时可以使用恢复码
+		if len(currentUser.Value.Passkey) > 0 {
+			if params.RecoveryCode != "" {
+				if !(logic.Webauthn{}).UseRecoveryCode(currentUser, params.RecoveryCode) {
+					self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserTwoFaNotCorrect), 500)
+					return
+				}
+			} else {
+				assertion, sessionId, err := logic.Webauthn{}.BeginLogin(http.Request, currentUser.Value.Username, params.AutoLogin)
+				if err != nil {
+					self.JsonResponseWithError(http, err, 500)
+					return
+				}
+				pending = true
+				self.JsonResponseWithoutError(http, gin.H{
+					"twoFa":     "webauthn",
+					"options":   assertion,
+					"sessionId": sessionId,
+				})
+				return
+			}
 		}
-		redirect := "/home/overview"
-		loginSetting := accessor.Login{}
-		if ok := (logic.Setting{}).GetByKey(logic.SettingGroupSetting, logic.SettingGroupSettingLogin, &loginSetting); ok && loginSetting.DefaultRedirect != "" {
-			redirect = loginSetting.DefaultRedirect
+		// 使用验证码登录且没有恢复码的用户，首次登录时生成恢复码，明文只返回这一次
+		var recoveryCode []string
+		if totpPassed && len(currentUser.Value.RecoveryCode) == 0 {
+			if recoveryCode, err = (logic.Webauthn{}).CreateRecoveryCode(currentUser); err != nil {
+				self.JsonResponseWithError(http, err, 500)
+				return
+			}
 		}
-		self.JsonResponseWithoutError(http, gin.H{
-			"accessToken": code,
-			"redirect":    redirect,
-		})
+		code, err = self.loginSuccess(http, currentUser, params.AutoLogin, recoveryCode)
 		return
 	} else {
 		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserUsernameOrPasswordError), 500)
@@ -103,6 +134,41 @@ func (self User) Login(http *gin.Context) {
 	}
 }
 
+// checkLoginUser 校验用户当前是否允许登录
+func (self User) checkLoginUser(user *entity.Setting) error {
+	if user.Value.UserStatus == logic.SettingGroupUserStatusDisable {
+		return function.ErrorMessage(define.ErrorMessageUserDisable)
+	}
+	// 社区版仅允许创始人及配置了角色的用户登录
+	if !function.InArray((family.Provider{}).Feature(), types.FeatureFamilyEe) && user.Name != logic.SettingGroupUserFounder && len(user.Value.UserRole) == 0 {
+		return function.ErrorMessage(define.ErrorMessageUserDisable)
+	}
+	return nil
+}
+
+// loginSuccess 签发登录令牌并返回登录后的跳转地址
+func (self User) loginSuccess(http *gin.Context, user *entity.Setting, autoLogin bool, recoveryCode []string) (string, error) {
+	code, err := logic.User{}.GetUserOauthToken(user, autoLogin)
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return "", err
+	}
+	redirect := "/home/overview"
+	loginSetting := accessor.Login{}
+	if ok := (logic.Setting{}).GetByKey(logic.SettingGroupSetting, logic.SettingGroupSettingLogin, &loginSetting); ok && loginSetting.DefaultRedirect != "" {
+		redirect = loginSetting.DefaultRedirect
+	}
+	result := gin.H{
+		"accessToken": code,
+		"redirect":    redirect,
+	}
+	if len(recoveryCode) > 0 {
+		result["recoveryCode"] = recoveryCode
+	}
+	self.JsonResponseWithoutError(http, result)
+	return code, nil
+}
+
 func (self User) GetUserInfo(http *gin.Context) {
 	result := gin.H{
 		"menu":      make([]string, 0),
@@ -117,6 +183,7 @@ func (self User) GetUserInfo(http *gin.Context) {
 		return
 	}
 	result["user"] = data.(logic.UserInfo)
+	result["role"] = logic.UserRole{}.Get(data.(logic.UserInfo), docker.Sdk.Name, "")
 
 	feature := make([]string, 0)
 
@@ -248,6 +315,7 @@ func (self User) OauthProviders(http *gin.Context) {
 	result := make([]oauth.Item, 0)
 	for _, provider := range []oauth.Provider{
 		oauth.Fnnas{},
+		oauth.Oidc{},
 	} {
 		if item, ok := provider.Item(); ok {
 			result = append(result, item)
@@ -272,3 +340,135 @@ func (self User) OauthAuthorize(http *gin.Context) {
 	http.Redirect(302, callbackURL)
 	return
 }
+
+func (self User) Create(http *gin.Context) {
+	type ParamsValidate struct {
+		Id         int32                      `json:"id"`
+		Username   string                     `json:"username" binding:"required"`
+		Password   string                     `json:"password"`
+		Email      string                     `json:"email" binding:"omitempty,email"`
+		UserStatus uint8                      `json:"userStatus" binding:"omitempty,oneof=1 2"`
+		UserRemark string                     `json:"userRemark"`
+		UserRole   []accessor.UserRoleBinding `json:"userRole" binding:"required,min=1,dive"`
+		ExternalId *string                    `json:"externalId" binding:"omitempty,startswith=oidc:|startswith=ldap:|len=0"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	if params.ExternalId != nil && *params.ExternalId != "" {
+		if exists, err := (logic.User{}).GetUserByExternalId(*params.ExternalId); err == nil && exists.ID != params.Id {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonIdAlreadyExists, "name", *params.ExternalId), 500)
+			return
+		}
+	}
+	if err := (logic.UserRole{}).CheckBinding(params.UserRole); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	if params.UserStatus == 0 {
+		params.UserStatus = logic.SettingGroupUserStatusEnable
+	}
+	if (logic.User{}.GetBuiltInPublicUsername()) == params.Username {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserUsernameExists), 500)
+		return
+	}
+	if exists, err := (logic.User{}).GetUserByUsername(params.Username); err == nil && exists.ID != params.Id {
+		self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserUsernameExists), 500)
+		return
+	}
+
+	var user *entity.Setting
+	if params.Id > 0 {
+		user, _ = dao.Setting.Where(dao.Setting.ID.Eq(params.Id)).
+			Where(dao.Setting.GroupName.Eq(logic.SettingGroupUser)).
+			Where(dao.Setting.Name.Neq(logic.SettingGroupUserFounder)).First()
+		if user == nil || user.Value == nil {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted), 500)
+			return
+		}
+		// 修改用户名后需要重新计算密码
+		if params.Password == "" && user.Value.Username != params.Username {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserPasswordConfirmFailed), 500)
+			return
+		}
+	} else {
+		if params.Password == "" {
+			self.JsonResponseWithError(http, function.ErrorMessage(define.ErrorMessageUserPasswordConfirmFailed), 500)
+			return
+		}
+		user = &entity.Setting{
+			GroupName: logic.SettingGroupUser,
+			Name:      logic.SettingGroupUserMember,
+			Value: &accessor.SettingValueOption{
+				RegisterAt: function.Ptr(time.Now()),
+			},
+		}
+	}
+	user.Value.Username = params.Username
+	if params.Password != "" {
+		user.Value.Password = logic.User{}.GetMd5Password(params.Password, params.Username)
+	}
+	user.Value.Email = params.Email
+	user.Value.UserStatus = params.UserStatus
+	user.Value.UserRemark = params.UserRemark
+	user.Value.UserRole = params.UserRole
+	// 第三方账号只能由管理员在这里绑定到已有的本地用户，未传递时保持原绑定
+	if params.ExternalId != nil {
+		user.Value.ExternalId = *params.ExternalId
+	}
+
+	if err := dao.Setting.Save(user); err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	// 禁用用户后立即退出登录
+	if user.Value.UserStatus == logic.SettingGroupUserStatusDisable {
+		storage.Cache.Delete(fmt.Sprintf(storage.CacheKeyCommonUserInfo, user.ID))
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"id": user.ID,
+	})
+	return
+}
+
+func (self User) GetList(http *gin.Context) {
+	list, err := dao.Setting.Where(dao.Setting.GroupName.Eq(logic.SettingGroupUser)).
+		Where(dao.Setting.Name.Neq(logic.SettingGroupUserFounder)).
+		Order(dao.Setting.ID.Desc()).Find()
+	if err != nil {
+		self.JsonResponseWithError(http, err, 500)
+		return
+	}
+	for _, item := range list {
+		if item.Value != nil {
+			item.Value.Password = ""
+			item.Value.Passkey = nil
+			item.Value.RecoveryCode = nil
+		}
+	}
+	self.JsonResponseWithoutError(http, gin.H{
+		"list": list,
+	})
+	return
+}
+
+func (self User) Delete(http *gin.Context) {
+	type ParamsValidate struct {
+		Id []int32 `json:"id" binding:"required"`
+	}
+	params := ParamsValidate{}
+	if !self.Validate(http, &params) {
+		return
+	}
+	list, _ := dao.Setting.Where(dao.Setting.ID.In(params.Id...)).
+		Where(dao.Setting.GroupName.Eq(logic.SettingGroupUser)).
+		Where(dao.Setting.Name.Neq(logic.SettingGroupUserFounder)).Find()
+	for _, item := range list {
+		_, _ = dao.UserToken.Where(dao.UserToken.UserID.Eq(item.ID)).Delete()
+		_, _ = dao.Setting.Where(dao.Setting.ID.Eq(item.ID)).Delete()
+		storage.Cache.Delete(fmt.Sprintf(storage.CacheKeyCommonUserInfo, item.ID))
+	}
+	self.JsonSuccessResponse(http)
+	return
+}
@@ -0,0 +1,84 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+)
+
+const (
+	AuditStatusSuccess = "success"
+	AuditStatusFailed  = "failed"
+
+	auditDefaultRetentionDays = 90
+)
+
+type AuditSearch struct {
+	Username      string     `json:"username"`
+	Resource      string     `json:"resource"`
+	DockerEnvName string     `json:"dockerEnvName"`
+	Status        string     `json:"status" binding:"omitempty,oneof=success failed"`
+	Keyword       string     `json:"keyword"`
+	StartTime     *time.Time `json:"startTime"`
+	EndTime       *time.Time `json:"endTime"`
+}
+
+type Audit struct {
+}
+
+func (self Audit) Create(row *entity.AuditLog) error {
+	if row.CreatedAt.IsZero() {
+		row.CreatedAt = time.Now()
+	}
+	return dao.AuditLog.Create(row)
+}
+
+// Query 按条件筛选审计日志，列表与导出共用
+func (self Audit) Query(search AuditSearch) dao.IAuditLogDo {
+	query := dao.AuditLog.Order(dao.AuditLog.ID.Desc())
+	if search.Username != "" {
+		query = query.Where(dao.AuditLog.Username.Eq(search.Username))
+	}
+	if search.Resource != "" {
+		query = query.Where(dao.AuditLog.Resource.Eq(search.Resource))
+	}
+	if search.DockerEnvName != "" {
+		query = query.Where(dao.AuditLog.DockerEnvName.Eq(search.DockerEnvName))
+	}
+	switch search.Status {
+	case AuditStatusSuccess:
+		query = query.Where(dao.AuditLog.Status.Lt(400))
+	case AuditStatusFailed:
+		query = query.Where(dao.AuditLog.Status.Gte(400))
+	}
+	if search.Keyword != "" {
+		query = query.Where(
+			dao.AuditLog.Where(dao.AuditLog.Target.Like("%" + search.Keyword + "%")).
+				Or(dao.AuditLog.Uri.Like("%" + search.Keyword + "%")).
+				Or(dao.AuditLog.IP.Like("%" + search.Keyword + "%")),
+		)
+	}
+	if search.StartTime != nil {
+		query = query.Where(dao.AuditLog.CreatedAt.Gte(*search.StartTime))
+	}
+	if search.EndTime != nil {
+		query = query.Where(dao.AuditLog.CreatedAt.Lte(*search.EndTime))
+	}
+	return query
+}
+
+// Prune 按保留天数清理过期的审计日志
+func (self Audit) Prune() error {
+	setting := accessor.Audit{}
+	Setting{}.GetByKey(SettingGroupSetting, SettingGroupSettingAudit, &setting)
+	if setting.RetentionDays < 0 {
+		return nil
+	}
+	if setting.RetentionDays == 0 {
+		setting.RetentionDays = auditDefaultRetentionDays
+	}
+	_, err := dao.AuditLog.Where(dao.AuditLog.CreatedAt.Lt(time.Now().AddDate(0, 0, -setting.RetentionDays))).Delete()
+	return err
+}
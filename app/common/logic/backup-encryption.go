@@ -0,0 +1,114 @@
+package logic
+
+import (
+	"errors"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+	"github.com/donknap/dpanel/common/types/define"
+)
+
+type BackupEncryption struct {
+}
+
+func (self BackupEncryption) GetList() []accessor.BackupEncryption {
+	list := make([]accessor.BackupEncryption, 0)
+	Setting{}.GetByKey(SettingGroupSetting, SettingGroupSettingBackupEncryption, &list)
+	return list
+}
+
+func (self BackupEncryption) Get(name string) (accessor.BackupEncryption, error) {
+	item, _, ok := function.PluckArrayItemWalk(self.GetList(), func(item accessor.BackupEncryption) bool {
+		return item.Name == name
+	})
+	if !ok {
+		return accessor.BackupEncryption{}, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	return item, nil
+}
+
+// Generate 生成一对 age x25519 密钥
+func (self BackupEncryption) Generate() (recipient string, identity string, err error) {
+	key, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", err
+	}
+	return key.Recipient().String(), key.String(), nil
+}
+
+// Writer 返回创建快照时使用的加密选项，name 为空时不加密
+func (self BackupEncryption) Writer(name string) (backup.Option, error) {
+	if name == "" {
+		return backup.WithEncryption(nil), nil
+	}
+	item, err := self.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	encryption := &backup.ManifestEncryption{
+		Type: item.Type,
+		Name: item.Name,
+	}
+	var recipient age.Recipient
+	switch item.Type {
+	case backup.EncryptionTypePassphrase:
+		recipient, err = age.NewScryptRecipient(self.decode(item.Passphrase))
+	case backup.EncryptionTypeX25519:
+		var r *age.X25519Recipient
+		if r, err = age.ParseX25519Recipient(item.Recipient); err == nil {
+			recipient = r
+			encryption.Recipient = r.String()
+		}
+	default:
+		err = errors.New("unsupported backup encryption type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return backup.WithEncryption(encryption, recipient), nil
+}
+
+// Identity 返回恢复快照时使用的解密选项
+// secret 为用户临时提供的口令或 AGE-SECRET-KEY 私钥，为空时使用面板中保存的同名密钥
+func (self BackupEncryption) Identity(secret string) backup.Option {
+	return backup.WithIdentity(func(encryption *backup.ManifestEncryption) ([]age.Identity, error) {
+		if secret != "" {
+			return self.parseIdentity(encryption.Type, secret)
+		}
+		item, err := self.Get(encryption.Name)
+		if err != nil || item.Type != encryption.Type {
+			return nil, backup.ErrEncryptionIdentityRequired
+		}
+		switch item.Type {
+		case backup.EncryptionTypePassphrase:
+			return self.parseIdentity(item.Type, self.decode(item.Passphrase))
+		case backup.EncryptionTypeX25519:
+			if item.Identity == "" || (encryption.Recipient != "" && item.Recipient != encryption.Recipient) {
+				return nil, backup.ErrEncryptionIdentityRequired
+			}
+			return self.parseIdentity(item.Type, self.decode(item.Identity))
+		}
+		return nil, backup.ErrEncryptionIdentityRequired
+	})
+}
+
+func (self BackupEncryption) parseIdentity(encryptionType string, secret string) ([]age.Identity, error) {
+	if encryptionType == backup.EncryptionTypeX25519 {
+		return age.ParseIdentities(strings.NewReader(secret))
+	}
+	identity, err := age.NewScryptIdentity(secret)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{identity}, nil
+}
+
+func (self BackupEncryption) decode(value string) string {
+	if v, err := function.RSADecode(value, nil); err == nil {
+		return v
+	}
+	return value
+}
@@ -0,0 +1,163 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/storage/target"
+	"github.com/donknap/dpanel/common/types/define"
+	"gorm.io/datatypes"
+	"gorm.io/gen"
+)
+
+type BackupTarget struct {
+}
+
+func (self BackupTarget) GetList() []accessor.BackupTarget {
+	list := make([]accessor.BackupTarget, 0)
+	Setting{}.GetByKey(SettingGroupSetting, SettingGroupSettingBackupTarget, &list)
+	return list
+}
+
+func (self BackupTarget) Get(name string) (accessor.BackupTarget, error) {
+	item, _, ok := function.PluckArrayItemWalk(self.GetList(), func(item accessor.BackupTarget) bool {
+		return item.Name == name
+	})
+	if !ok {
+		return accessor.BackupTarget{}, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	return item, nil
+}
+
+// CheckInUse 远程存储被备份计划使用时不允许删除
+func (self BackupTarget) CheckInUse(name string) error {
+	scheduleRow, _ := dao.BackupSchedule.Where(gen.Cond(
+		datatypes.JSONQuery("setting").Equals(name, "targetName"),
+	)...).First()
+	if scheduleRow != nil {
+		return function.ErrorMessage(define.ErrorMessageSettingBackupTargetInUse, "name", name, "title", scheduleRow.Title)
+	}
+	if (PanelBackupSchedule{}).Get().TargetName == name {
+		return function.ErrorMessage(define.ErrorMessageSettingBackupTargetInUse, "name", name, "title", "dpanel")
+	}
+	return nil
+}
+
+func (self BackupTarget) New(name string) (target.Target, error) {
+	option, err := self.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return target.New(option)
+}
+
+// Upload 上传本地文件到远程存储
+func (self BackupTarget) Upload(ctx context.Context, name string, localPath string, remoteName string) error {
+	t, err := self.New(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = t.Close()
+	}()
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return t.Put(ctx, filepath.ToSlash(remoteName), file, stat.Size())
+}
+
+// Download 下载远程文件到本地，下载失败时删除不完整的文件
+func (self BackupTarget) Download(ctx context.Context, name string, remoteName string, localPath string) (err error) {
+	t, err := self.New(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = t.Close()
+	}()
+	reader, err := t.Get(ctx, filepath.ToSlash(remoteName))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if err = os.MkdirAll(filepath.Dir(localPath), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+		if err != nil {
+			_ = os.Remove(localPath)
+		}
+	}()
+	_, err = io.Copy(file, reader)
+	return err
+}
+
+func (self BackupTarget) Delete(ctx context.Context, name string, remoteName string) error {
+	t, err := self.New(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = t.Close()
+	}()
+	return t.Delete(ctx, filepath.ToSlash(remoteName))
+}
+
+func (self BackupTarget) List(ctx context.Context, name string, prefix string) ([]target.FileInfo, error) {
+	t, err := self.New(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = t.Close()
+	}()
+	return t.List(ctx, prefix)
+}
+
+// Test 写入、列出并删除一个测试文件，检查配置是否可用
+func (self BackupTarget) Test(ctx context.Context, option accessor.BackupTarget) error {
+	t, err := target.New(option)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = t.Close()
+	}()
+	content := []byte("dpanel backup target test")
+	name := ".dpanel-test"
+	if err = t.Put(ctx, name, bytes.NewReader(content), int64(len(content))); err != nil {
+		return err
+	}
+	list, err := t.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	if _, ok := function.IndexArrayWalk(list, func(i target.FileInfo) bool {
+		return i.Name == name
+	}); !ok {
+		return errors.New("backup target test file not found after upload")
+	}
+	return t.Delete(ctx, name)
+}
@@ -22,8 +22,11 @@ import (
 	"github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/service/exec/local"
 	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
 	"github.com/patrickmn/go-cache"
 	"github.com/robfig/cron/v3"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
 )
 
 var (
@@ -236,6 +239,11 @@ func (self Cron) AddCronJob(task *entity.Cron) (ids []cron.EntryID, err error) {
 		}
 		if ctx.Err != nil {
 			log.Value.Error = ctx.Err.Error()
+			facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+				Event:   define.NotificationEventCronFailed,
+				Subject: fmt.Sprintf("cron task %s failed", task.Title),
+				Content: ctx.Err.Error(),
+			})
 		}
 		_ = dao.CronLog.Create(log)
 
@@ -7,6 +7,7 @@ import (
 	"github.com/donknap/dpanel/common/accessor"
 	"github.com/donknap/dpanel/common/entity"
 	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
 	"github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/types/define"
 	"golang.org/x/exp/maps"
@@ -46,6 +47,27 @@ func (self Env) GetEnvByName(name string) (*types.DockerEnv, error) {
 	}
 }
 
+// GetClient 获取环境的连接，当前环境直接使用 docker.Sdk，其它环境新建连接，使用完成后调用 closeFn
+// 后台任务需要使用保存时的环境，不能依赖面板当前切换到的环境
+func (self Env) GetClient(name string) (dockerSdk *docker.Client, closeFn func(), err error) {
+	if docker.Sdk != nil && (name == "" || docker.Sdk.Name == name) {
+		return docker.Sdk, func() {}, nil
+	}
+	dockerEnv, err := self.GetEnvByName(name)
+	if err != nil {
+		return nil, nil, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	dockerSdk, err = docker.NewClientWithDockerEnv(dockerEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = dockerSdk.Client.Ping(dockerSdk.GetTryCtx()); err != nil {
+		dockerSdk.Close()
+		return nil, nil, function.ErrorMessage(define.ErrorMessageSystemEnvDockerApiFailed, "error", err.Error())
+	}
+	return dockerSdk, dockerSdk.Close, nil
+}
+
 func (self Env) GetDefaultEnv() (*types.DockerEnv, error) {
 	dockerEnvList := make(map[string]*types.DockerEnv)
 	Setting{}.GetByKey(SettingGroupSetting, SettingGroupSettingDocker, &dockerEnvList)
@@ -0,0 +1,154 @@
+package logic
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapTimeout = 10 * time.Second
+)
+
+type Ldap struct {
+}
+
+func (self Ldap) Enable() bool {
+	setting, ok := self.setting()
+	return ok && setting.Enable
+}
+
+// Login 使用 LDAP 账号登录，成功后返回对应的本地用户
+func (self Ldap) Login(username string, password string) (*entity.Setting, error) {
+	setting, ok := self.setting()
+	if !ok || !setting.Enable {
+		return nil, errors.New("ldap is not enabled")
+	}
+	// 空密码会被服务端当作匿名绑定直接通过
+	if username == "" || password == "" {
+		return nil, errors.New("ldap username or password is empty")
+	}
+	conn, err := self.Connect(setting)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	userFilter := setting.UserFilter
+	if userFilter == "" {
+		userFilter = "(uid=%s)"
+	}
+	emailAttribute := setting.EmailAttribute
+	if emailAttribute == "" {
+		emailAttribute = "mail"
+	}
+	groupAttribute := setting.GroupAttribute
+	if groupAttribute == "" {
+		groupAttribute = "memberOf"
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		setting.BaseDn,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		strings.ReplaceAll(userFilter, "%s", ldap.EscapeFilter(username)),
+		[]string{"dn", emailAttribute, groupAttribute},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		return nil, fmt.Errorf("ldap user %s not found or not unique", username)
+	}
+	entry := result.Entries[0]
+	if err = conn.Bind(entry.DN, password); err != nil {
+		return nil, err
+	}
+
+	// 组同时支持完整 DN 及 CN 匹配
+	group := make([]string, 0)
+	for _, item := range entry.GetAttributeValues(groupAttribute) {
+		group = append(group, item)
+		if dn, err := ldap.ParseDN(item); err == nil && len(dn.RDNs) > 0 {
+			for _, attr := range dn.RDNs[0].Attributes {
+				if strings.EqualFold(attr.Type, "cn") {
+					group = append(group, attr.Value)
+				}
+			}
+		}
+	}
+	return User{}.GetExternalUser(ExternalUserOption{
+		ExternalId:  self.ExternalId(entry.DN),
+		Username:    username,
+		Email:       entry.GetAttributeValue(emailAttribute),
+		Group:       group,
+		AutoCreate:  setting.AutoCreate,
+		SyncRole:    setting.SyncRole,
+		RoleMapping: setting.RoleMapping,
+	})
+}
+
+// ExternalId 使用规范化后的 DN 作为用户绑定标识
+func (self Ldap) ExternalId(dn string) string {
+	if parsed, err := ldap.ParseDN(dn); err == nil {
+		rdn := make([]string, 0, len(parsed.RDNs))
+		for _, item := range parsed.RDNs {
+			attr := make([]string, 0, len(item.Attributes))
+			for _, a := range item.Attributes {
+				attr = append(attr, strings.ToLower(a.Type)+"="+a.Value)
+			}
+			rdn = append(rdn, strings.Join(attr, "+"))
+		}
+		dn = strings.Join(rdn, ",")
+	}
+	return "ldap:" + dn
+}
+
+// Connect 连接服务器，配置了查找账号时会先绑定该账号
+func (self Ldap) Connect(setting accessor.Ldap) (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: setting.InsecureSkipVerify,
+	}
+	conn, err := ldap.DialURL(setting.Url,
+		ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+	if setting.StartTls {
+		if u, err := url.Parse(setting.Url); err == nil {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if setting.BindDn != "" {
+		err = conn.Bind(setting.BindDn, setting.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (self Ldap) setting() (accessor.Ldap, bool) {
+	setting := accessor.Ldap{}
+	ok := Setting{}.GetByKey(SettingGroupSetting, SettingGroupSettingLdap, &setting)
+	return setting, ok && setting.Url != ""
+}
@@ -1,36 +1,62 @@
 package logic
 
 import (
-	"crypto/tls"
-	"net/smtp"
-	"strconv"
+	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/donknap/dpanel/common/accessor"
 	"github.com/donknap/dpanel/common/function"
-	"github.com/jordan-wright/email"
+	"github.com/donknap/dpanel/common/service/notice/channel"
+	"github.com/donknap/dpanel/common/types/define"
 )
 
 type Notice struct {
 }
 
 func (self Notice) Send(emailServer *accessor.NotificationEmailServer, toEmail string, subject string, htmlContent string) error {
-	e := email.NewEmail()
-	e.From = emailServer.Email
-	e.Subject = subject
-	e.To = []string{toEmail}
-	e.HTML = []byte(htmlContent)
-	err := e.SendWithTLS(
-		emailServer.Host+":"+strconv.Itoa(emailServer.Port),
-		smtp.PlainAuth("", emailServer.Email, emailServer.Code, emailServer.Host),
-		&tls.Config{
-			ServerName: emailServer.Host, // 必须与证书匹配的域名
-		},
-	)
+	return channel.Email{
+		Server: emailServer,
+		To:     toEmail,
+	}.Send(context.Background(), subject, htmlContent)
+}
+
+// SendChannel 使用渠道自身的模板渲染消息并发送
+func (self Notice) SendChannel(option accessor.NotificationChannel, emailServer *accessor.NotificationEmailServer, event string, subject string, content string) error {
+	c, err := channel.New(option, emailServer)
 	if err != nil {
-		if function.ErrorHasKeyword(err, "535 Login fail") {
-			return err
-		}
+		return err
+	}
+	body, err := channel.Render(option, channel.TemplateData{
+		Event:   event,
+		Subject: subject,
+		Content: content,
+		Time:    time.Now().Format(define.DateShowYmdHis),
+	})
+	if err != nil {
+		return err
+	}
+	return c.Send(context.Background(), subject, body)
+}
+
+// Dispatch 将事件消息发送到所有已启用并订阅了该事件的渠道
+func (self Notice) Dispatch(event string, subject string, content string) error {
+	setting := accessor.Notification{}
+	if !(Setting{}).GetByKey(SettingGroupSetting, SettingGroupSettingNotification, &setting) {
 		return nil
 	}
-	return nil
+	var err error
+	for _, item := range setting.Channel {
+		if !item.Enable {
+			continue
+		}
+		if !function.IsEmptyArray(item.Event) && !function.InArray(item.Event, event) {
+			continue
+		}
+		if sendErr := self.SendChannel(item, setting.EmailServer, event, subject, content); sendErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", item.Name, sendErr))
+		}
+	}
+	return err
 }
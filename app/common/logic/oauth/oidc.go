@@ -0,0 +1,370 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	commonLogic "github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcStateTTL     = 5 * time.Minute
+	oidcDiscoveryTTL = time.Hour
+	oidcHttpTimeout  = 10 * time.Second
+)
+
+type Oidc struct {
+}
+
+type OidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type OidcState struct {
+	Provider     string
+	RedirectURI  string
+	Nonce        string
+	CodeVerifier string
+}
+
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IdToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (self Oidc) Item() (Item, bool) {
+	setting, ok := self.setting()
+	if !ok {
+		return Item{}, false
+	}
+	name := setting.Name
+	if name == "" {
+		name = "OpenID Connect"
+	}
+	return Item{
+		Provider:     ProviderOidc,
+		Name:         name,
+		AuthorizeURL: function.RouterApiUri("/common/user/oauth/authorize") + "?provider=" + ProviderOidc,
+	}, true
+}
+
+func (self Oidc) Authorize(request *http.Request) (string, error) {
+	setting, ok := self.setting()
+	if !ok {
+		return "", errors.New("oidc is not enabled")
+	}
+	discovery, err := self.Discovery(setting.Issuer)
+	if err != nil {
+		return "", err
+	}
+	redirectURI := setting.RedirectUri
+	if redirectURI == "" {
+		redirectURI = self.RedirectURI(request)
+	}
+
+	state, nonce, codeVerifier := self.random(), self.random(), self.random()
+	storage.Cache.Set(fmt.Sprintf(storage.CacheKeyOauthState, state), &OidcState{
+		Provider:     ProviderOidc,
+		RedirectURI:  redirectURI,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	}, oidcStateTTL)
+
+	scope := setting.Scope
+	if len(scope) == 0 {
+		scope = []string{"openid", "profile", "email"}
+	}
+	if !function.InArray(scope, "openid") {
+		scope = append([]string{"openid"}, scope...)
+	}
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	authorizeURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := authorizeURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", setting.ClientId)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", strings.Join(scope, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	authorizeURL.RawQuery = query.Encode()
+	return authorizeURL.String(), nil
+}
+
+func (self Oidc) Exchange(option ExchangeOption) (string, error) {
+	setting, ok := self.setting()
+	if !ok {
+		return "", errors.New("oidc is not enabled")
+	}
+	cacheKey := fmt.Sprintf(storage.CacheKeyOauthState, option.State)
+	item, exists := storage.Cache.Get(cacheKey)
+	if option.State == "" || !exists {
+		return "", errors.New("oauth state is invalid")
+	}
+	// state 只能使用一次
+	storage.Cache.Delete(cacheKey)
+	state, ok := item.(*OidcState)
+	if !ok || state.Provider != ProviderOidc {
+		return "", errors.New("oauth state is invalid")
+	}
+	if option.RedirectURI != "" && option.RedirectURI != state.RedirectURI {
+		return "", errors.New("oauth redirect uri is invalid")
+	}
+
+	discovery, err := self.Discovery(setting.Issuer)
+	if err != nil {
+		return "", err
+	}
+	token, err := self.token(setting, discovery, state, option.Code)
+	if err != nil {
+		return "", err
+	}
+	claims, err := self.verify(setting, discovery, state, token.IdToken)
+	if err != nil {
+		slog.Debug("oidc exchange verify id token failed", "error", err.Error())
+		return "", err
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return "", errors.New("oidc id token sub is empty")
+	}
+	// 部分服务商 id_token 中不包含 profile 信息，需要从 userinfo 中补充
+	if discovery.UserinfoEndpoint != "" && token.AccessToken != "" {
+		if userinfo, err := self.userinfo(discovery, token.AccessToken); err == nil && userinfo["sub"] == claims["sub"] {
+			for key, value := range userinfo {
+				if _, ok := claims[key]; !ok {
+					claims[key] = value
+				}
+			}
+		} else if err != nil {
+			slog.Debug("oidc exchange userinfo failed", "error", err.Error())
+		}
+	}
+
+	usernameClaim := []string{"preferred_username", "email", "sub"}
+	if setting.UsernameClaim != "" {
+		usernameClaim = []string{setting.UsernameClaim}
+	}
+	groupClaim := setting.GroupClaim
+	if groupClaim == "" {
+		groupClaim = "groups"
+	}
+	username := ""
+	for _, key := range usernameClaim {
+		if v, ok := claims[key].(string); ok && v != "" {
+			username = v
+			break
+		}
+	}
+	email, _ := claims["email"].(string)
+
+	user, err := commonLogic.User{}.GetExternalUser(commonLogic.ExternalUserOption{
+		ExternalId:  self.ExternalId(discovery.Issuer, sub),
+		Username:    username,
+		Email:       email,
+		Group:       self.claimStrings(claims[groupClaim]),
+		AutoCreate:  setting.AutoCreate,
+		SyncRole:    setting.SyncRole,
+		RoleMapping: setting.RoleMapping,
+	})
+	if err != nil {
+		slog.Debug("oidc exchange user failed", "username", username, "error", err.Error())
+		return "", err
+	}
+	return commonLogic.User{}.GetUserOauthToken(user, false)
+}
+
+// Discovery 获取服务商的 .well-known/openid-configuration 配置
+func (self Oidc) Discovery(issuer string) (*OidcDiscovery, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	cacheKey := fmt.Sprintf(storage.CacheKeyOauthOidcDiscovery, issuer)
+	if v, ok := storage.Cache.Get(cacheKey); ok {
+		return v.(*OidcDiscovery), nil
+	}
+	discovery := &OidcDiscovery{}
+	if err := self.getJson(issuer+"/.well-known/openid-configuration", "", discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc issuer mismatch, expected %s got %s", issuer, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksUri == "" {
+		return nil, errors.New("oidc discovery document is incomplete")
+	}
+	storage.Cache.Set(cacheKey, discovery, oidcDiscoveryTTL)
+	return discovery, nil
+}
+
+// ExternalId 服务商内 iss + sub 唯一且不可修改，作为用户绑定标识
+func (self Oidc) ExternalId(issuer string, sub string) string {
+	return "oidc:" + strings.TrimSuffix(issuer, "/") + "#" + sub
+}
+
+func (self Oidc) RedirectURI(request *http.Request) string {
+	scheme := "http"
+	if request.TLS != nil {
+		scheme = "https"
+	}
+	if forwardedProto := request.Header.Get("X-Forwarded-Proto"); forwardedProto != "" {
+		scheme = strings.TrimSpace(strings.Split(forwardedProto, ",")[0])
+	}
+	host := request.Host
+	if forwardedHost := request.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+		host = strings.TrimSpace(strings.Split(forwardedHost, ",")[0])
+	}
+	return scheme + "://" + host + function.RouterUri("/dpanel/ui/user/oauth/callback/"+ProviderOidc)
+}
+
+func (self Oidc) token(setting accessor.Oidc, discovery *OidcDiscovery, state *OidcState, code string) (*oidcTokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", state.RedirectURI)
+	form.Set("client_id", setting.ClientId)
+	form.Set("code_verifier", state.CodeVerifier)
+	if setting.ClientSecret != "" {
+		form.Set("client_secret", setting.ClientSecret)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oidcHttpTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	token := &oidcTokenResponse{}
+	if err = json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(token); err != nil {
+		return nil, err
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("oidc token error: %s %s", token.Error, token.Description)
+	}
+	if response.StatusCode != http.StatusOK || token.IdToken == "" {
+		return nil, fmt.Errorf("oidc token request failed, status %d", response.StatusCode)
+	}
+	return token, nil
+}
+
+// verify 使用服务商的 jwks 校验 id_token 的签名及 iss、aud、nonce
+func (self Oidc) verify(setting accessor.Oidc, discovery *OidcDiscovery, state *OidcState, idToken string) (jwt.MapClaims, error) {
+	keySet := jose.JSONWebKeySet{}
+	if err := self.getJson(discovery.JwksUri, "", &keySet); err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		for _, key := range keySet.Keys {
+			if (kid == "" || key.KeyID == kid) && key.Use != "enc" {
+				return key.Key, nil
+			}
+		}
+		return nil, errors.New("oidc signing key not found")
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256", "PS384", "PS512"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(setting.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != state.Nonce {
+		return nil, errors.New("oidc nonce is invalid")
+	}
+	return claims, nil
+}
+
+func (self Oidc) userinfo(discovery *OidcDiscovery, accessToken string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	err := self.getJson(discovery.UserinfoEndpoint, accessToken, &result)
+	return result, err
+}
+
+func (self Oidc) getJson(uri string, accessToken string, value interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), oidcHttpTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc request %s failed, status %d", uri, response.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(value)
+}
+
+func (self Oidc) setting() (accessor.Oidc, bool) {
+	setting := accessor.Oidc{}
+	if !(commonLogic.Setting{}).GetByKey(commonLogic.SettingGroupSetting, commonLogic.SettingGroupSettingOidc, &setting) {
+		return setting, false
+	}
+	return setting, setting.Enable && setting.Issuer != "" && setting.ClientId != ""
+}
+
+func (self Oidc) claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		return function.PluckArrayWalk(v, func(item interface{}) (string, bool) {
+			s, ok := item.(string)
+			return s, ok
+		})
+	}
+	return nil
+}
+
+func (self Oidc) random() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
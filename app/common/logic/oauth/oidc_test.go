@@ -0,0 +1,184 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testOidcProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	code   string
+	form   map[string]string
+}
+
+func newTestOidcProvider(t *testing.T) *testOidcProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &testOidcProvider{
+		key:  key,
+		code: "test-code",
+		form: make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OidcDiscovery{
+			Issuer:                provider.URL,
+			AuthorizationEndpoint: provider.URL + "/authorize",
+			TokenEndpoint:         provider.URL + "/token",
+			JwksUri:               provider.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{
+				{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+			},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		for k := range r.PostForm {
+			provider.form[k] = r.PostForm.Get(k)
+		}
+		if r.PostForm.Get("code") != provider.code {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(oidcTokenResponse{Error: "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(oidcTokenResponse{
+			AccessToken: "access",
+			TokenType:   "Bearer",
+			IdToken:     provider.sign(t, provider.claims),
+		})
+	})
+	provider.Server = httptest.NewServer(mux)
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+func (self *testOidcProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	result, err := token.SignedString(self.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestOidcLoginFlow(t *testing.T) {
+	provider := newTestOidcProvider(t)
+	setting := accessor.Oidc{
+		Issuer:   provider.URL + "/",
+		ClientId: "dpanel",
+	}
+	state := &OidcState{
+		Provider:     ProviderOidc,
+		RedirectURI:  "http://dpanel.test/callback",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+	}
+	provider.claims = jwt.MapClaims{
+		"iss":                provider.URL,
+		"sub":                "user-1",
+		"aud":                "dpanel",
+		"nonce":              state.Nonce,
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"preferred_username": "admin",
+	}
+
+	discovery, err := Oidc{}.Discovery(setting.Issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discovery.TokenEndpoint != provider.URL+"/token" {
+		t.Fatalf("unexpected token endpoint %s", discovery.TokenEndpoint)
+	}
+
+	token, err := Oidc{}.token(setting, discovery, state, provider.code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.form["code_verifier"] != state.CodeVerifier || provider.form["redirect_uri"] != state.RedirectURI {
+		t.Fatalf("unexpected token request %v", provider.form)
+	}
+	if _, err = (Oidc{}).token(setting, discovery, state, "wrong"); err == nil {
+		t.Fatal("token request with invalid code should fail")
+	}
+
+	claims, err := Oidc{}.verify(setting, discovery, state, token.IdToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["sub"] != "user-1" {
+		t.Fatalf("unexpected sub %v", claims["sub"])
+	}
+	if id := (Oidc{}).ExternalId(discovery.Issuer, "user-1"); id != "oidc:"+provider.URL+"#user-1" {
+		t.Fatalf("unexpected external id %s", id)
+	}
+}
+
+func TestOidcVerifyReject(t *testing.T) {
+	provider := newTestOidcProvider(t)
+	setting := accessor.Oidc{
+		Issuer:   provider.URL,
+		ClientId: "dpanel",
+	}
+	state := &OidcState{Provider: ProviderOidc, Nonce: "nonce"}
+	discovery, err := Oidc{}.Discovery(setting.Issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   provider.URL,
+			"sub":   "user-1",
+			"aud":   "dpanel",
+			"nonce": state.Nonce,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherToken := jwt.NewWithClaims(jwt.SigningMethodRS256, valid())
+	otherToken.Header["kid"] = "test"
+	forged, err := otherToken.SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"forged signature": forged,
+	}
+	for name, modify := range map[string]func(jwt.MapClaims){
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.test" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "other" },
+		"nonce":    func(c jwt.MapClaims) { c["nonce"] = "replay" },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no exp":   func(c jwt.MapClaims) { delete(c, "exp") },
+	} {
+		claims := valid()
+		modify(claims)
+		tests[name] = provider.sign(t, claims)
+	}
+	for name, idToken := range tests {
+		if _, err := (Oidc{}).verify(setting, discovery, state, idToken); err == nil {
+			t.Errorf("%s: id token should be rejected", name)
+		}
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"net/http"
 )
 
-const ProviderFnnas = "fnnas"
+const (
+	ProviderFnnas = "fnnas"
+	ProviderOidc  = "oidc"
+)
 
 type Provider interface {
 	Item() (Item, bool)
@@ -45,6 +48,8 @@ func providerByName(provider string) (Provider, error) {
 	switch provider {
 	case ProviderFnnas:
 		return Fnnas{}, nil
+	case ProviderOidc:
+		return Oidc{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported oauth provider: %s", provider)
 	}
@@ -0,0 +1,357 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/crontab"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/backup"
+	"github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/mholt/archives"
+	"github.com/patrickmn/go-cache"
+	"github.com/robfig/cron/v3"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+)
+
+const (
+	panelBackupPrefix         = "dpanel-main"
+	panelBackupSchedulePrefix = "dpanel-main-schedule"
+)
+
+type PanelBackupOption struct {
+	BackupPathList   []string // 为空时备份面板全部数据
+	IgnorePathPrefix []string
+	TargetName       string
+	EncryptionName   string
+	Schedule         bool // 定时任务创建的快照，保留策略只清理这部分快照
+}
+
+type PanelBackupFile struct {
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"createdAt"`
+	Size      int64     `json:"size"`
+}
+
+// Backup 打包面板数据目录创建快照，指定远程存储时上传后删除本地文件
+func (self Panel) Backup(ctx context.Context, option PanelBackupOption) (backupTar string, err error) {
+	ignorePathPrefix := append([]string{"backup/dpanel/dpanel-main", "storage/temp"}, option.IgnorePathPrefix...)
+
+	panelAllPath := function.PluckArrayWalk(self.GetPanelPath(), func(item *types.ValueItem) (string, bool) {
+		return item.Value, true
+	})
+	backupPathList := option.BackupPathList
+	if function.IsEmptyArray(backupPathList) {
+		backupPathList = panelAllPath
+	}
+
+	prefix := panelBackupPrefix
+	if option.Schedule {
+		prefix = panelBackupSchedulePrefix
+	}
+	backupTime := time.Now().Format(define.DateYmdHis)
+	suffix := fmt.Sprintf("%s-%s", prefix, backupTime)
+	backupRelTar := filepath.Join("dpanel", suffix+".snapshot")
+	backupTar = filepath.Join(storage.Local{}.GetBackupPath(), backupRelTar)
+
+	encryptionOption, err := BackupEncryption{}.Writer(option.EncryptionName)
+	if err != nil {
+		return "", err
+	}
+	b, err := backup.New(
+		backup.WithTarPathPrefix("dpanel"),
+		backup.WithPath(backupTar),
+		encryptionOption,
+		backup.WithWriter(),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	info := backup.Info{
+		Extend: map[string]interface{}{
+			"Version": facade.GetConfig().Get("app.version"),
+			"Family":  facade.GetConfig().Get("app.family"),
+		},
+		Backup: &entity.Backup{
+			ID:          0,
+			ContainerID: "",
+			Setting: &accessor.BackupSettingOption{
+				BackupTargetType: define.DockerContainerBackupTypeSnapshot,
+				BackupTar:        filepath.ToSlash(backupRelTar),
+				VolumePathList:   make([]string, 0),
+				Status:           define.DockerImageBuildStatusSuccess,
+				Encryption:       option.EncryptionName,
+			},
+			CreatedAt: time.Now(),
+		},
+	}
+	info.Docker, err = docker.Sdk.Client.ServerVersion(b.Context())
+	if err != nil {
+		return "", err
+	}
+	backupPathList = append(backupPathList, "dpanel.lic")
+	manifest := make([]backup.Manifest, 0)
+	targetFile, err := archives.FilesFromDisk(b.Context(), nil, function.PluckArrayMapWalk(backupPathList, func(item string) (string, string, bool) {
+		if !function.InArray(panelAllPath, item) {
+			return "", "", false
+		}
+		return filepath.Join(storage.Local{}.GetStorageLocalPath(), item), item, true
+	}))
+	if err != nil {
+		return "", err
+	}
+	hash := make([]string, 0)
+	targetFile = function.PluckArrayWalk(targetFile, func(item archives.FileInfo) (archives.FileInfo, bool) {
+		if ok := function.InArrayWalk(ignorePathPrefix, func(p string) bool {
+			return strings.HasPrefix(item.NameInArchive, p)
+		}); ok {
+			return item, false
+		}
+		hash = append(hash, item.NameInArchive)
+		return item, true
+	})
+
+	volumePath, err := b.Writer.WriteBlobFiles(function.Sha256Struct(hash), targetFile)
+	if err != nil {
+		return "", err
+	}
+	manifest = append(manifest, backup.Manifest{
+		Volume: []string{
+			volumePath,
+		},
+		Encryption: b.Writer.Encryption(),
+	})
+	if err = b.Writer.WriteManifest(manifest); err != nil {
+		return "", err
+	}
+	if err = b.Writer.WriteConfigFile("info.json", info); err != nil {
+		return "", err
+	}
+
+	if option.TargetName != "" {
+		// 上传前需要先关闭写入，保证快照文件完整
+		_ = b.Close()
+		if err = (BackupTarget{}).Upload(ctx, option.TargetName, backupTar, backupRelTar); err != nil {
+			return "", err
+		}
+		_ = os.Remove(backupTar)
+	}
+	return backupTar, nil
+}
+
+// BackupList 列出本地或远程存储中的面板快照，按时间倒序排列
+func (self Panel) BackupList(ctx context.Context, targetName string) ([]PanelBackupFile, error) {
+	backupList := make([]PanelBackupFile, 0)
+	if targetName != "" {
+		fileList, err := BackupTarget{}.List(ctx, targetName, "dpanel")
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range fileList {
+			if !strings.HasSuffix(item.Name, ".snapshot") {
+				continue
+			}
+			backupList = append(backupList, PanelBackupFile{
+				Path:      path.Base(item.Name),
+				Size:      item.Size,
+				CreatedAt: item.ModTime,
+			})
+		}
+	} else {
+		root := self.SaveRootPath()
+		err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+			if path == root || !strings.HasSuffix(path, ".snapshot") {
+				return nil
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			backupList = append(backupList, PanelBackupFile{
+				Path:      rel,
+				Size:      info.Size(),
+				CreatedAt: info.ModTime(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(backupList, func(i, j int) bool {
+		return backupList[i].CreatedAt.After(backupList[j].CreatedAt)
+	})
+	return backupList, nil
+}
+
+func (self Panel) BackupDelete(ctx context.Context, targetName string, name string) error {
+	if targetName != "" {
+		return BackupTarget{}.Delete(ctx, targetName, path.Join("dpanel", function.SafeFileName(name)))
+	}
+	backupFilePath := function.SafePathJoin(self.SaveRootPath(), name)
+	if _, err := os.Stat(backupFilePath); err != nil {
+		return err
+	}
+	return function.SafeDelete(self.SaveRootPath(), name)
+}
+
+type PanelBackupSchedule struct {
+}
+
+func (self PanelBackupSchedule) Get() accessor.PanelBackup {
+	setting := accessor.PanelBackup{}
+	Setting{}.GetByKey(SettingGroupSetting, SettingGroupSettingPanelBackup, &setting)
+	return setting
+}
+
+// Save 保存配置并重新添加定时任务
+func (self PanelBackupSchedule) Save(setting accessor.PanelBackup) error {
+	old := self.Get()
+	setting.JobIds = make([]cron.EntryID, 0)
+	setting.LastRunAt = old.LastRunAt
+	setting.LastError = old.LastError
+	if setting.Enable {
+		ids, err := self.AddJob(setting)
+		if err != nil {
+			return err
+		}
+		setting.JobIds = ids
+	}
+	crontab.Client.RemoveJob(old.JobIds...)
+	return self.save(setting)
+}
+
+// Start 面板启动时添加定时任务，上次运行时的任务编号已经失效，直接覆盖
+func (self PanelBackupSchedule) Start() error {
+	setting := self.Get()
+	if !setting.Enable {
+		return nil
+	}
+	ids, err := self.AddJob(setting)
+	if err != nil {
+		ids = make([]cron.EntryID, 0)
+	}
+	setting.JobIds = ids
+	return errors.Join(err, self.save(setting))
+}
+
+func (self PanelBackupSchedule) AddJob(setting accessor.PanelBackup) (ids []cron.EntryID, err error) {
+	cronJob := crontab.New(
+		crontab.WithName("panel backup"),
+		crontab.WithRunFunc(func(ctx *crontab.RunFuncContext) {
+			ctx.Err = self.Run(context.Background())
+		}),
+	)
+	ids = make([]cron.EntryID, 0)
+	for _, exp := range setting.Expression {
+		if id, err1 := crontab.Client.AddJob(exp.ToString(), cronJob); err1 == nil {
+			ids = append(ids, id)
+		} else {
+			err = errors.Join(err, err1)
+		}
+	}
+	if err != nil {
+		crontab.Client.RemoveJob(ids...)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Run 按配置备份一次面板并清理过期快照，同时只会运行一个
+func (self PanelBackupSchedule) Run(ctx context.Context) (err error) {
+	if err = storage.Cache.Add(storage.CacheKeyPanelBackupStatus, "running", cache.NoExpiration); err != nil {
+		return crontab.SkipRun
+	}
+	setting := self.Get()
+	defer func() {
+		storage.Cache.Delete(storage.CacheKeyPanelBackupStatus)
+		if err != nil {
+			facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+				Event:   define.NotificationEventBackupFailed,
+				Subject: "panel backup failed",
+				Content: err.Error(),
+			})
+		}
+		// 运行期间配置可能被修改，重新获取后只更新运行结果
+		latest := self.Get()
+		latest.LastRunAt = function.Ptr(time.Now())
+		latest.LastError = ""
+		if err != nil {
+			latest.LastError = err.Error()
+		}
+		_ = self.save(latest)
+	}()
+
+	_, err = Panel{}.Backup(ctx, PanelBackupOption{
+		BackupPathList:   setting.BackupPathList,
+		IgnorePathPrefix: setting.IgnorePathPrefix,
+		TargetName:       setting.TargetName,
+		EncryptionName:   setting.EncryptionName,
+		Schedule:         true,
+	})
+	if err != nil {
+		return err
+	}
+	if pruneErr := self.Prune(ctx, setting); pruneErr != nil {
+		slog.Warn("panel backup prune", "error", pruneErr)
+	}
+	return nil
+}
+
+// Prune 按保留策略清理定时任务创建的快照，手动创建的快照不会清理
+func (self PanelBackupSchedule) Prune(ctx context.Context, setting accessor.PanelBackup) (err error) {
+	if setting.Retention.IsEmpty() {
+		return nil
+	}
+	list, err := Panel{}.BackupList(ctx, setting.TargetName)
+	if err != nil {
+		return err
+	}
+	list = function.PluckArrayWalk(list, func(item PanelBackupFile) (PanelBackupFile, bool) {
+		return item, strings.HasPrefix(filepath.Base(item.Path), panelBackupSchedulePrefix+"-")
+	})
+	keep := setting.Retention.Keep(function.PluckArrayWalk(list, func(item PanelBackupFile) (time.Time, bool) {
+		return item.CreatedAt, true
+	}))
+	for i, item := range list {
+		if keep[i] {
+			continue
+		}
+		if err1 := (Panel{}).BackupDelete(ctx, setting.TargetName, item.Path); err1 != nil {
+			err = errors.Join(err, err1)
+		}
+	}
+	return err
+}
+
+func (self PanelBackupSchedule) save(setting accessor.PanelBackup) error {
+	return Setting{}.Save(&entity.Setting{
+		GroupName: SettingGroupSetting,
+		Name:      SettingGroupSettingPanelBackup,
+		Value: &accessor.SettingValueOption{
+			PanelBackup: &setting,
+		},
+	})
+}
@@ -36,6 +36,14 @@ var (
 	SettingGroupSettingLogin                = "login"
 	SettingGroupSettingNotification         = "notification"
 	SettingGroupSettingConsoleInstance      = "consoleInstance"
+	SettingGroupSettingAudit                = "audit"
+	SettingGroupSettingOidc                 = "oidc"
+	SettingGroupSettingLdap                 = "ldap"
+	SettingGroupSettingBackupTarget         = "backupTarget"
+	SettingGroupSettingBackupEncryption     = "backupEncryption"
+	SettingGroupSettingPanelBackup          = "panelBackup"
+	SettingGroupSettingDeployWebhook        = "deployWebhook"
+	SettingGroupSettingCertMonitor          = "certMonitor"
 )
 
 // 用户相关数据
@@ -240,6 +248,31 @@ func (self Setting) GetByKey(group, name string, value interface{}) (exists bool
 				exists = true
 				*v = *setting.Value.ContainerCheckAllUpgrade
 			}
+		case *[]accessor.BackupTarget:
+			if setting.Value.BackupTarget != nil {
+				exists = true
+				*v = setting.Value.BackupTarget
+			}
+		case *[]accessor.BackupEncryption:
+			if setting.Value.BackupEncryption != nil {
+				exists = true
+				*v = setting.Value.BackupEncryption
+			}
+		case *accessor.PanelBackup:
+			if setting.Value.PanelBackup != nil {
+				exists = true
+				*v = *setting.Value.PanelBackup
+			}
+		case *[]accessor.DeployWebhook:
+			if setting.Value.DeployWebhook != nil {
+				exists = true
+				*v = setting.Value.DeployWebhook
+			}
+		case *accessor.CertMonitor:
+			if setting.Value.CertMonitor != nil {
+				exists = true
+				*v = *setting.Value.CertMonitor
+			}
 		case *[]accessor.Tag:
 			if setting.Value.Tag != nil {
 				exists = true
@@ -255,6 +288,21 @@ func (self Setting) GetByKey(group, name string, value interface{}) (exists bool
 				exists = true
 				*v = *setting.Value.ConsoleInstance
 			}
+		case *accessor.Audit:
+			if setting.Value.Audit != nil {
+				exists = true
+				*v = *setting.Value.Audit
+			}
+		case *accessor.Oidc:
+			if setting.Value.Oidc != nil {
+				exists = true
+				*v = *setting.Value.Oidc
+			}
+		case *accessor.Ldap:
+			if setting.Value.Ldap != nil {
+				exists = true
+				*v = *setting.Value.Ldap
+			}
 		case *entity.Setting:
 			*v = *setting
 		}
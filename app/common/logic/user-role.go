@@ -0,0 +1,217 @@
+package logic
+
+import (
+	"strings"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/types/define"
+)
+
+var userRoleLevel = map[string]int{
+	define.UserRoleReadonly: 1,
+	define.UserRoleOperator: 2,
+	define.UserRoleAdmin:    3,
+}
+
+var (
+	// 登录用户均可访问的接口
+	userRolePublicUri = []string{
+		"/common/user/get-user-info",
+		"/common/user/token/",
+		"/common/user/webauthn/",
+		"/common/user/recovery-code/",
+		"/common/env/get-list",
+	}
+	// 仅管理员可访问的接口，切换环境会改变全部用户当前的 docker 环境，同样只允许管理员操作
+	userRoleAdminUri = []string{
+		"/common/user/",
+		"/common/setting/",
+		"/common/backup-target/",
+		"/common/backup-encryption/",
+		"/common/env/",
+		"/common/panel/",
+		"/common/audit/",
+		"/common/console/shell",
+		"/common/console/ssh/",
+		"/app/deploy-webhook/",
+	}
+	// 只读角色可以访问的接口，不能包含读取文件、凭据或访问外部地址的接口
+	userRoleReadonlyUri = []string{
+		"/common/home/info",
+		"/common/home/usage",
+		"/common/home/get-stat-list",
+		"/common/home/get-stat-history",
+		"/common/notice",
+		"/common/notice/get-list",
+		"/common/notice/unread",
+		"/common/metrics",
+		"/common/event/get-list",
+		"/common/tag/get-list",
+		"/common/store/get-list",
+		"/common/registry/get-list",
+		"/common/cron/get-list",
+		"/common/cron/get-log-list",
+		"/common/alert/get-list",
+		"/common/alert/get-detail",
+		"/app/container/get-list",
+		"/app/container/get-detail",
+		"/app/container/get-stat-info",
+		"/app/container/get-stat-history",
+		"/app/container/get-process-info",
+		"/app/container-upgrade/get-list",
+		"/app/container-backup/get-list",
+		"/app/container-backup/get-detail",
+		"/app/backup-schedule/get-list",
+		"/app/log/run",
+		"/app/compose/get-list",
+		"/app/compose/get-task",
+		"/app/compose/container-log",
+		"/app/image/get-list",
+		"/app/image/get-detail",
+		"/app/image-build/get-list",
+		"/app/image-build/get-detail",
+		"/app/image-buildx/get-detail",
+		"/app/network/get-list",
+		"/app/network/get-detail",
+		"/app/network/get-container-list",
+		"/app/volume/get-list",
+		"/app/volume/get-detail",
+		"/app/site/get-list",
+		"/app/site/get-detail",
+		"/app/site-domain/get-list",
+		"/app/site-domain/get-detail",
+		"/app/site-domain/nginx-log",
+		"/app/site-cert/get-list",
+		"/app/site-cert/get-detail",
+		"/app/swarm/info",
+		"/app/swarm/log",
+		"/app/swarm/node-list",
+		"/app/swarm/service-list",
+		"/app/swarm/service-detail",
+		"/app/swarm/task-list",
+		"/app/swarm/task-list-in-node",
+	}
+)
+
+type UserRole struct {
+}
+
+// Get 获取用户在指定环境及资源下的角色，没有任何授权时返回空
+// dockerEnvName 为空时只匹配不限定环境的授权，resource 为空时不限制资源
+func (self UserRole) Get(userInfo UserInfo, dockerEnvName string, resource string) string {
+	role := ""
+	if userInfo.RoleIdentity == SettingGroupUserFounder {
+		role = define.UserRoleAdmin
+	} else if binding, managed := self.getBinding(userInfo); managed {
+		// 由专业版权限控制的用户，这里不再限制
+		role = define.UserRoleAdmin
+	} else {
+		role = self.match(binding, dockerEnvName, resource)
+	}
+	if role != "" && userInfo.TokenRole != "" && userRoleLevel[userInfo.TokenRole] < userRoleLevel[role] {
+		role = userInfo.TokenRole
+	}
+	return role
+}
+
+// Check 校验用户是否可以在当前 docker 环境中访问接口
+// 管理员接口与环境无关，只有不限定环境的授权才可以访问
+func (self UserRole) Check(userInfo UserInfo, dockerEnvName string, urlPath string) error {
+	uri, resource, _ := self.ParseUri(urlPath)
+	if function.InArrayWalk(userRolePublicUri, func(i string) bool {
+		return strings.HasPrefix(uri, i)
+	}) {
+		return nil
+	}
+	if self.isAdminUri(uri) {
+		dockerEnvName = ""
+	}
+	if self.Allow(self.Get(userInfo, dockerEnvName, resource), uri) {
+		return nil
+	}
+	return function.ErrorMessage(define.ErrorMessageUserNoPermission)
+}
+
+// Allow 角色是否可以访问接口
+func (self UserRole) Allow(role string, uri string) bool {
+	switch role {
+	case define.UserRoleAdmin:
+		return true
+	case define.UserRoleOperator:
+		return !self.isAdminUri(uri)
+	case define.UserRoleReadonly:
+		return self.IsReadonlyUri(uri)
+	}
+	return false
+}
+
+// ParseUri 接口统一为 /模块/资源/动作，返回去掉根路径后的地址、资源及动作
+func (self UserRole) ParseUri(urlPath string) (uri string, resource string, action string) {
+	uri = strings.TrimPrefix(strings.TrimPrefix(urlPath, function.RouterRootApi()), function.RouterRootWs())
+	segment := strings.Split(strings.Trim(uri, "/"), "/")
+	if len(segment) > 1 {
+		resource, action = segment[1], segment[1]
+	}
+	if len(segment) > 2 {
+		action = segment[2]
+	}
+	return uri, resource, action
+}
+
+// IsReadonlyUri 是否为只读接口，不会修改任何数据
+func (self UserRole) IsReadonlyUri(uri string) bool {
+	return function.InArray(userRoleReadonlyUri, strings.TrimSuffix(uri, "/"))
+}
+
+func (self UserRole) isAdminUri(uri string) bool {
+	return function.InArrayWalk(userRoleAdminUri, func(i string) bool {
+		return strings.HasPrefix(uri, i)
+	})
+}
+
+// CheckBinding 校验授权中的环境是否存在
+func (self UserRole) CheckBinding(binding []accessor.UserRoleBinding) error {
+	for _, item := range binding {
+		if item.DockerEnvName == "" {
+			continue
+		}
+		if _, err := (Env{}).GetEnvByName(item.DockerEnvName); err != nil {
+			return function.ErrorMessage(define.ErrorMessageUserRoleEnvNotFound, "name", item.DockerEnvName)
+		}
+	}
+	return nil
+}
+
+// match 在授权中查找最高的角色，限定环境的授权只在对应环境中生效
+func (self UserRole) match(binding []accessor.UserRoleBinding, dockerEnvName string, resource string) string {
+	role := ""
+	for _, item := range binding {
+		if item.DockerEnvName != "" && item.DockerEnvName != dockerEnvName {
+			continue
+		}
+		if resource != "" && len(item.Resource) > 0 && !function.InArray(item.Resource, resource) {
+			continue
+		}
+		if userRoleLevel[item.Role] > userRoleLevel[role] {
+			role = item.Role
+		}
+	}
+	return role
+}
+
+func (self UserRole) getBinding(userInfo UserInfo) (binding []accessor.UserRoleBinding, managed bool) {
+	user, err := Setting{}.GetValueById(userInfo.UserId)
+	if err != nil || user.Value == nil || user.Value.UserStatus == SettingGroupUserStatusDisable {
+		return nil, false
+	}
+	if len(user.Value.UserRole) > 0 {
+		return user.Value.UserRole, false
+	}
+	// 没有配置角色的用户，如果存在专业版的权限数据，则交由专业版处理
+	if _, err := dao.UserPermission.Where(dao.UserPermission.Username.Eq(user.Value.Username)).First(); err == nil {
+		return nil, true
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/spf13/viper"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+)
+
+func TestMain(m *testing.M) {
+	// 接口地址的根路径读取配置
+	facade.Config = viper.New()
+	m.Run()
+}
+
+func TestUserRoleIsReadonlyUri(t *testing.T) {
+	tests := []struct {
+		uri    string
+		expect bool
+	}{
+		{"/app/container/get-list", true},
+		{"/app/container/get-detail", true},
+		{"/common/notice", true},
+		{"/common/notice/", true},
+		{"/app/swarm/node-list", true},
+		// 读取文件、凭据或访问外部地址
+		{"/app/explorer/get-content", false},
+		{"/common/explorer/get-content", false},
+		{"/common/registry/get-detail", false},
+		{"/app/compose/get-from-git", false},
+		{"/app/compose/get-from-uri", false},
+		{"/common/setting/get-setting", false},
+		{"/common/env/get-detail", false},
+		// 修改数据
+		{"/app/container/delete", false},
+		{"/common/env/switch", false},
+		{"/app/container/get-list/../delete", false},
+	}
+	for _, item := range tests {
+		if result := (UserRole{}).IsReadonlyUri(item.uri); result != item.expect {
+			t.Errorf("%s: expect %v, got %v", item.uri, item.expect, result)
+		}
+	}
+}
+
+func TestUserRoleCheck(t *testing.T) {
+	tests := []struct {
+		role   string
+		uri    string
+		expect bool
+	}{
+		{define.UserRoleAdmin, "/common/env/switch", true},
+		{define.UserRoleAdmin, "/common/user/create", true},
+		{define.UserRoleOperator, "/app/container/delete", true},
+		{define.UserRoleOperator, "/app/explorer/get-content", true},
+		{define.UserRoleOperator, "/common/env/switch", false},
+		{define.UserRoleOperator, "/common/user/create", false},
+		{define.UserRoleOperator, "/common/console/ssh/local", false},
+		{define.UserRoleReadonly, "/app/container/get-list", true},
+		{define.UserRoleReadonly, "/app/container/delete", false},
+		{define.UserRoleReadonly, "/app/explorer/get-content", false},
+		{define.UserRoleReadonly, "/common/registry/get-detail", false},
+		{define.UserRoleReadonly, "/common/env/switch", false},
+		{define.UserRoleReadonly, "/common/user/get-list", false},
+		// 全部用户可以访问
+		{define.UserRoleReadonly, "/common/user/get-user-info", true},
+		{define.UserRoleReadonly, "/common/env/get-list", true},
+	}
+	for _, item := range tests {
+		// 创始人不读取授权数据，通过令牌角色限定实际角色
+		userInfo := UserInfo{
+			RoleIdentity: SettingGroupUserFounder,
+			TokenRole:    item.role,
+		}
+		err := (UserRole{}).Check(userInfo, "local", "/dpanel/api"+item.uri)
+		if (err == nil) != item.expect {
+			t.Errorf("%s %s: expect %v, got %v", item.role, item.uri, item.expect, err)
+		}
+	}
+}
+
+func TestUserRoleMatch(t *testing.T) {
+	binding := []accessor.UserRoleBinding{
+		{Role: define.UserRoleReadonly},
+		{Role: define.UserRoleOperator, DockerEnvName: "dev"},
+		{Role: define.UserRoleAdmin, DockerEnvName: "prod", Resource: []string{"image"}},
+	}
+	tests := []struct {
+		dockerEnvName string
+		resource      string
+		expect        string
+	}{
+		{"local", "container", define.UserRoleReadonly},
+		{"dev", "container", define.UserRoleOperator},
+		{"prod", "container", define.UserRoleReadonly},
+		{"prod", "image", define.UserRoleAdmin},
+		// 管理员接口只匹配不限定环境的授权
+		{"", "user", define.UserRoleReadonly},
+	}
+	for _, item := range tests {
+		if role := (UserRole{}).match(binding, item.dockerEnvName, item.resource); role != item.expect {
+			t.Errorf("%s %s: expect %s, got %s", item.dockerEnvName, item.resource, item.expect, role)
+		}
+	}
+	if role := (UserRole{}).match([]accessor.UserRoleBinding{
+		{Role: define.UserRoleAdmin, DockerEnvName: "prod"},
+	}, "dev", ""); role != "" {
+		t.Errorf("binding of other env should not match, got %s", role)
+	}
+}
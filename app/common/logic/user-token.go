@@ -0,0 +1,76 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/types/define"
+)
+
+type UserToken struct {
+}
+
+// Create 生成 API 令牌，数据库中只保存摘要，明文仅在创建时返回一次
+func (self UserToken) Create(userId int32, title string, option *accessor.UserTokenSettingOption) (string, *entity.UserToken, error) {
+	randomBytes := make([]byte, 20)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", nil, err
+	}
+	token := define.UserTokenPrefix + hex.EncodeToString(randomBytes)
+	if option == nil {
+		option = &accessor.UserTokenSettingOption{}
+	}
+	option.Prefix = token[:len(define.UserTokenPrefix)+6]
+	userToken := &entity.UserToken{
+		UserID:  userId,
+		Title:   title,
+		Token:   self.digest(token),
+		Setting: option,
+	}
+	if err := dao.UserToken.Create(userToken); err != nil {
+		return "", nil, err
+	}
+	return token, userToken, nil
+}
+
+// GetUserInfo 校验令牌并返回所属用户的信息
+func (self UserToken) GetUserInfo(token string) (*UserInfo, error) {
+	userToken, err := dao.UserToken.Where(dao.UserToken.Token.Eq(self.digest(token))).First()
+	if err != nil {
+		return nil, function.ErrorMessage(define.ErrorMessageUserLogin)
+	}
+	if userToken.Setting == nil {
+		userToken.Setting = &accessor.UserTokenSettingOption{}
+	}
+	if userToken.Setting.ExpireAt != nil && userToken.Setting.ExpireAt.Before(time.Now()) {
+		return nil, function.ErrorMessage(define.ErrorMessageUserLogin)
+	}
+	user, err := Setting{}.GetValueById(userToken.UserID)
+	if err != nil || user.GroupName != SettingGroupUser || user.Value == nil || user.Value.UserStatus == SettingGroupUserStatusDisable {
+		return nil, function.ErrorMessage(define.ErrorMessageUserLogin)
+	}
+	// 最后使用时间每分钟最多更新一次，避免每个请求都写库
+	if userToken.Setting.LastUsedAt == nil || time.Since(*userToken.Setting.LastUsedAt) > time.Minute {
+		userToken.Setting.LastUsedAt = function.Ptr(time.Now())
+		_, _ = dao.UserToken.Where(dao.UserToken.ID.Eq(userToken.ID)).Updates(&entity.UserToken{
+			Setting: userToken.Setting,
+		})
+	}
+	return &UserInfo{
+		UserId:       user.ID,
+		Username:     user.Value.Username,
+		Email:        user.Value.Email,
+		RoleIdentity: user.Name,
+		TokenId:      userToken.ID,
+		TokenRole:    userToken.Setting.Role,
+	}, nil
+}
+
+func (self UserToken) digest(token string) string {
+	return function.Sha256([]byte(token))
+}
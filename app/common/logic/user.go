@@ -37,6 +37,8 @@ type UserInfo struct {
 	RoleIdentity     string                          `json:"roleIdentity"`
 	Permission       *accessor.PermissionValueOption `json:"permission"`
 	AutoLogin        bool                            `json:"autoLogin"`
+	TokenId          int32                           `json:"tokenId,omitempty"`   // 使用 API 令牌访问时的令牌 id
+	TokenRole        string                          `json:"tokenRole,omitempty"` // API 令牌可使用的最高角色
 	jwt.RegisteredClaims
 }
 
@@ -95,6 +97,12 @@ func (self User) GetUserByUsername(username string) (*entity.Setting, error) {
 		Where(gen.Cond(datatypes.JSONQuery("value").Equals(username, "username"))...).First()
 }
 
+// GetUserByExternalId 按绑定的第三方账号标识查找用户
+func (self User) GetUserByExternalId(externalId string) (*entity.Setting, error) {
+	return dao.Setting.Where(dao.Setting.GroupName.Eq(SettingGroupUser)).
+		Where(gen.Cond(datatypes.JSONQuery("value").Equals(externalId, "externalId"))...).First()
+}
+
 func (self User) GetFounderUser() (*entity.Setting, error) {
 	founder, err := dao.Setting.Where(dao.Setting.GroupName.Eq(SettingGroupUser)).
 		Where(dao.Setting.Name.Eq(SettingGroupUserFounder)).
@@ -161,3 +169,77 @@ func (self User) GetUserOauthToken(user *entity.Setting, autoLogin bool) (string
 	storage.Cache.Set(fmt.Sprintf(storage.CacheKeyCommonUserInfo, userInfo.UserId), userInfo, cache.DefaultExpiration)
 	return jwtClaims.SignedString(privateKey)
 }
+
+type ExternalUserOption struct {
+	ExternalId  string // 第三方账号的稳定标识，不能使用用户可修改的用户名、邮箱
+	Username    string
+	Email       string
+	Group       []string
+	AutoCreate  bool
+	SyncRole    bool
+	RoleMapping []accessor.UserRoleMapping
+}
+
+// GetExternalUser 第三方登录（OIDC、LDAP）后获取对应的本地用户，按配置自动创建或同步角色
+// 只通过绑定的第三方账号标识查找用户，同名的本地用户需要管理员手动绑定后才能登录
+// 第三方用户不会关联到创始人，创始人只能使用本地密码登录
+func (self User) GetExternalUser(option ExternalUserOption) (*entity.Setting, error) {
+	if option.ExternalId == "" || option.Username == "" || option.Username == self.GetBuiltInPublicUsername() {
+		return nil, function.ErrorMessage(define.ErrorMessageUserUsernameOrPasswordError)
+	}
+	userRole := make([]accessor.UserRoleBinding, 0)
+	for _, item := range option.RoleMapping {
+		if item.Group == "*" || function.InArray(option.Group, item.Group) {
+			userRole = append(userRole, item.UserRoleBinding)
+		}
+	}
+
+	user, err := self.GetUserByExternalId(option.ExternalId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if user == nil {
+		if exists, err := self.GetUserByUsername(option.Username); err == nil && exists != nil {
+			return nil, function.ErrorMessage(define.ErrorMessageUserExternalNotBound, "name", option.Username)
+		}
+		if !option.AutoCreate || len(userRole) == 0 {
+			return nil, function.ErrorMessage(define.ErrorMessageUserNoPermission)
+		}
+		user = &entity.Setting{
+			GroupName: SettingGroupUser,
+			Name:      SettingGroupUserMember,
+			Value: &accessor.SettingValueOption{
+				Username:   option.Username,
+				Email:      option.Email,
+				ExternalId: option.ExternalId,
+				UserStatus: SettingGroupUserStatusEnable,
+				RegisterAt: function.Ptr(time.Now()),
+				UserRole:   userRole,
+			},
+		}
+		if err = dao.Setting.Create(user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if user.Name == SettingGroupUserFounder || user.Value == nil {
+		return nil, function.ErrorMessage(define.ErrorMessageUserNoPermission)
+	}
+	if user.Value.UserStatus == SettingGroupUserStatusDisable {
+		return nil, function.ErrorMessage(define.ErrorMessageUserDisable)
+	}
+	if option.SyncRole {
+		if len(userRole) == 0 {
+			return nil, function.ErrorMessage(define.ErrorMessageUserNoPermission)
+		}
+		user.Value.UserRole = userRole
+		if option.Email != "" {
+			user.Value.Email = option.Email
+		}
+		if err = dao.Setting.Save(user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
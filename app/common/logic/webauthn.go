@@ -0,0 +1,350 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/storage"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+)
+
+const (
+	webauthnSessionTimeout = 5 * time.Minute
+	recoveryCodeTotal      = 10
+)
+
+type WebauthnSession struct {
+	UserId    int32  // 为 0 时表示无密码登录，由凭证确定用户
+	Username  string // 按用户名登录时的用户名，用于登录失败计数
+	Invalid   bool   // 用户不存在或没有凭证，校验总是失败
+	Data      webauthn.SessionData
+	Name      string // 注册时填写的凭证名称
+	AutoLogin bool
+}
+
+type Webauthn struct {
+}
+
+// New 依据请求来源创建实例，RPID 使用访问面板的域名，更换域名后需要重新注册
+func (self Webauthn) New(request *http.Request) (*webauthn.WebAuthn, error) {
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		scheme := "http"
+		if request.TLS != nil || request.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		origin = fmt.Sprintf("%s://%s", scheme, request.Host)
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Hostname() == "" {
+		return nil, errors.New("webauthn origin is invalid")
+	}
+	name := facade.GetConfig().GetString("app.name")
+	if name == "" {
+		name = "DPanel"
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: name,
+		RPOrigins:     []string{origin},
+	})
+}
+
+// BeginRegister 开始注册新的凭证，返回浏览器需要的参数及会话 id
+func (self Webauthn) BeginRegister(request *http.Request, user *entity.Setting, name string) (*protocol.CredentialCreation, string, error) {
+	w, err := self.New(request)
+	if err != nil {
+		return nil, "", err
+	}
+	u := webauthnUser{user: user}
+	exclusions := make([]protocol.CredentialDescriptor, 0)
+	for _, item := range u.WebAuthnCredentials() {
+		exclusions = append(exclusions, item.Descriptor())
+	}
+	creation, session, err := w.BeginRegistration(u,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	sessionId := self.saveSession(&WebauthnSession{
+		UserId: user.ID,
+		Data:   *session,
+		Name:   name,
+	})
+	return creation, sessionId, nil
+}
+
+// FinishRegister 校验浏览器返回的凭证并保存到用户数据中
+func (self Webauthn) FinishRegister(request *http.Request, user *entity.Setting, sessionId string, body []byte) (*accessor.Passkey, error) {
+	session, err := self.getSession(sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserId != user.ID {
+		return nil, function.ErrorMessage(define.ErrorMessageUserNoPermission)
+	}
+	w, err := self.New(request)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(body)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := w.CreateCredential(webauthnUser{user: user}, session.Data, parsed)
+	if err != nil {
+		return nil, err
+	}
+	passkey := accessor.Passkey{
+		Name:       session.Name,
+		Credential: *credential,
+		CreatedAt:  time.Now(),
+	}
+	if passkey.Name == "" {
+		passkey.Name = fmt.Sprintf("passkey-%d", len(user.Value.Passkey)+1)
+	}
+	user.Value.Passkey = append(user.Value.Passkey, passkey)
+	if err = dao.Setting.Save(user); err != nil {
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// BeginLogin 开始登录，username 为空时使用无密码登录，由浏览器选择保存的凭证
+// 用户不存在或没有凭证时返回同样格式的请求，凭证 id 由用户名生成，避免通过接口探测用户名
+func (self Webauthn) BeginLogin(request *http.Request, username string, autoLogin bool) (*protocol.CredentialAssertion, string, error) {
+	w, err := self.New(request)
+	if err != nil {
+		return nil, "", err
+	}
+	var assertion *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	loginSession := &WebauthnSession{
+		Username:  username,
+		AutoLogin: autoLogin,
+	}
+	if username != "" {
+		user, err := User{}.GetUserByUsername(username)
+		if err != nil || user.Value == nil || len(user.Value.Passkey) == 0 {
+			loginSession.Invalid = true
+			user = &entity.Setting{
+				Value: &accessor.SettingValueOption{
+					Username: username,
+					Passkey: []accessor.Passkey{
+						{Credential: webauthn.Credential{ID: self.fakeCredentialId(username)}},
+					},
+				},
+			}
+		}
+		loginSession.UserId = user.ID
+		assertion, session, err = w.BeginLogin(webauthnUser{user: user})
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		assertion, session, err = w.BeginDiscoverableLogin()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	loginSession.Data = *session
+	return assertion, self.saveSession(loginSession), nil
+}
+
+// FinishLogin 校验登录凭证，成功后更新凭证的使用时间及计数器并返回用户
+// 校验失败时如果已经确定了用户也会返回，用于登录失败计数
+func (self Webauthn) FinishLogin(request *http.Request, sessionId string, body []byte) (*entity.Setting, *WebauthnSession, error) {
+	session, err := self.getSession(sessionId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.Invalid {
+		return nil, session, errors.New("webauthn credential is invalid")
+	}
+	w, err := self.New(request)
+	if err != nil {
+		return nil, session, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(body)
+	if err != nil {
+		return nil, session, err
+	}
+	var user *entity.Setting
+	var credential *webauthn.Credential
+	if session.UserId > 0 {
+		user, err = self.getUser(session.UserId)
+		if err != nil {
+			return nil, session, err
+		}
+		credential, err = w.ValidateLogin(webauthnUser{user: user}, session.Data, parsed)
+	} else {
+		credential, err = w.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			id, err := strconv.Atoi(string(userHandle))
+			if err != nil {
+				return nil, err
+			}
+			user, err = self.getUser(int32(id))
+			if err != nil {
+				return nil, err
+			}
+			return webauthnUser{user: user}, nil
+		}, session.Data, parsed)
+	}
+	if err != nil {
+		return user, session, err
+	}
+	// 计数器回退说明凭证可能被复制
+	if credential.Authenticator.CloneWarning {
+		return user, session, errors.New("webauthn authenticator may be cloned")
+	}
+	for i, item := range user.Value.Passkey {
+		if bytes.Equal(item.Credential.ID, credential.ID) {
+			user.Value.Passkey[i].Credential.Authenticator = credential.Authenticator
+			user.Value.Passkey[i].Credential.Flags = credential.Flags
+			user.Value.Passkey[i].LastUsedAt = function.Ptr(time.Now())
+		}
+	}
+	if err = dao.Setting.Save(user); err != nil {
+		return nil, session, err
+	}
+	return user, session, nil
+}
+
+// DeletePasskey 删除用户的凭证，id 为凭证 id 的 base64url 编码
+func (self Webauthn) DeletePasskey(user *entity.Setting, id []string) error {
+	passkey := make([]accessor.Passkey, 0)
+	for _, item := range user.Value.Passkey {
+		if !function.InArray(id, self.PasskeyId(item)) {
+			passkey = append(passkey, item)
+		}
+	}
+	user.Value.Passkey = passkey
+	return dao.Setting.Save(user)
+}
+
+func (self Webauthn) PasskeyId(passkey accessor.Passkey) string {
+	return base64.RawURLEncoding.EncodeToString(passkey.Credential.ID)
+}
+
+// CreateRecoveryCode 重新生成恢复码，旧的恢复码全部失效，明文只在生成时返回一次
+func (self Webauthn) CreateRecoveryCode(user *entity.Setting) ([]string, error) {
+	code := make([]string, 0, recoveryCodeTotal)
+	digest := make([]string, 0, recoveryCodeTotal)
+	for i := 0; i < recoveryCodeTotal; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		item := hex.EncodeToString(buf)
+		item = item[0:5] + "-" + item[5:]
+		code = append(code, item)
+		digest = append(digest, self.recoveryCodeDigest(item))
+	}
+	user.Value.RecoveryCode = digest
+	if err := dao.Setting.Save(user); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// UseRecoveryCode 校验恢复码，成功后该恢复码作废
+func (self Webauthn) UseRecoveryCode(user *entity.Setting, code string) bool {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return false
+	}
+	digest := self.recoveryCodeDigest(code)
+	for i, item := range user.Value.RecoveryCode {
+		if item == digest {
+			user.Value.RecoveryCode = append(user.Value.RecoveryCode[:i], user.Value.RecoveryCode[i+1:]...)
+			return dao.Setting.Save(user) == nil
+		}
+	}
+	return false
+}
+
+// fakeCredentialId 使用面板密钥生成，同一用户名每次返回相同的凭证 id
+func (self Webauthn) fakeCredentialId(username string) []byte {
+	key := make([]byte, 0)
+	if v, ok := storage.Cache.Get(storage.CacheKeyRsaKey); ok {
+		key = v.([]byte)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("webauthn:" + username))
+	return mac.Sum(nil)
+}
+
+func (self Webauthn) recoveryCodeDigest(code string) string {
+	return function.Sha256([]byte(code))
+}
+
+func (self Webauthn) saveSession(session *WebauthnSession) string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	sessionId := base64.RawURLEncoding.EncodeToString(buf)
+	storage.Cache.Set(fmt.Sprintf(storage.CacheKeyWebauthnSession, sessionId), session, webauthnSessionTimeout)
+	return sessionId
+}
+
+// getSession 会话只能使用一次
+func (self Webauthn) getSession(sessionId string) (*WebauthnSession, error) {
+	key := fmt.Sprintf(storage.CacheKeyWebauthnSession, sessionId)
+	v, ok := storage.Cache.Get(key)
+	if !ok || sessionId == "" {
+		return nil, errors.New("webauthn session expired")
+	}
+	storage.Cache.Delete(key)
+	return v.(*WebauthnSession), nil
+}
+
+func (self Webauthn) getUser(id int32) (*entity.Setting, error) {
+	user, err := dao.Setting.Where(dao.Setting.ID.Eq(id), dao.Setting.GroupName.Eq(SettingGroupUser)).First()
+	if err != nil || user.Value == nil {
+		return nil, function.ErrorMessage(define.ErrorMessageUserUsernameOrPasswordError)
+	}
+	return user, nil
+}
+
+type webauthnUser struct {
+	user *entity.Setting
+}
+
+func (self webauthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(int(self.user.ID)))
+}
+
+func (self webauthnUser) WebAuthnName() string {
+	return self.user.Value.Username
+}
+
+func (self webauthnUser) WebAuthnDisplayName() string {
+	return self.user.Value.Username
+}
+
+func (self webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	result := make([]webauthn.Credential, 0)
+	for _, item := range self.user.Value.Passkey {
+		result = append(result, item.Credential)
+	}
+	return result
+}
@@ -11,9 +11,11 @@ import (
 	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/function"
 	common "github.com/donknap/dpanel/common/middleware"
+	"github.com/donknap/dpanel/common/service/alert"
 	"github.com/donknap/dpanel/common/service/crontab"
 	types2 "github.com/donknap/dpanel/common/service/docker/types"
 	"github.com/donknap/dpanel/common/service/family"
+	"github.com/donknap/dpanel/common/service/metrics"
 	"github.com/donknap/dpanel/common/service/notice"
 	"github.com/donknap/dpanel/common/types"
 	"github.com/donknap/dpanel/common/types/event"
@@ -44,6 +46,11 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 		cors.POST("/common/event/get-list", controller.Event{}.GetList)
 		cors.POST("/common/event/prune", controller.Event{}.Prune)
 
+		// 审计日志
+		cors.POST("/common/audit/get-list", controller.Audit{}.GetList)
+		cors.POST("/common/audit/export", controller.Audit{}.Export)
+		cors.POST("/common/audit/prune", controller.Audit{}.Prune)
+
 		cors.POST("/common/notice/unread", controller.Notice{}.Unread)
 		cors.POST("/common/notice/get-list", controller.Notice{}.GetList)
 		cors.POST("/common/notice/delete", controller.Notice{}.Delete)
@@ -52,6 +59,8 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 		feature := new(family.Provider).Feature()
 		if !function.InArrayArray(feature, types.FeatureFamilyXk) {
 			cors.POST("/common/user/login", controller.User{}.Login)
+			cors.POST("/common/user/webauthn/login-begin", controller.UserWebauthn{}.LoginBegin)
+			cors.POST("/common/user/webauthn/login-finish", controller.UserWebauthn{}.LoginFinish)
 		}
 
 		if !function.InArrayArray(feature, types.FeatureFamilyXk) {
@@ -62,13 +71,34 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 		cors.POST("/common/user/create-founder", controller.User{}.CreateFounder)
 		cors.POST("/common/user/login-info", controller.User{}.LoginInfo)
 		cors.POST("/common/user/get-user-info", controller.User{}.GetUserInfo)
+		cors.POST("/common/user/create", controller.User{}.Create)
+		cors.POST("/common/user/get-list", controller.User{}.GetList)
+		cors.POST("/common/user/delete", controller.User{}.Delete)
+		cors.POST("/common/user/token/create", controller.UserToken{}.Create)
+		cors.POST("/common/user/token/get-list", controller.UserToken{}.GetList)
+		cors.POST("/common/user/token/delete", controller.UserToken{}.Delete)
+		cors.POST("/common/user/webauthn/register-begin", controller.UserWebauthn{}.RegisterBegin)
+		cors.POST("/common/user/webauthn/register-finish", controller.UserWebauthn{}.RegisterFinish)
+		cors.POST("/common/user/webauthn/get-list", controller.UserWebauthn{}.GetList)
+		cors.POST("/common/user/webauthn/delete", controller.UserWebauthn{}.Delete)
+		cors.POST("/common/user/recovery-code/create", controller.UserWebauthn{}.CreateRecoveryCode)
 
 		// 配置
 		cors.POST("/common/setting/founder", controller.Setting{}.Founder)
 		cors.POST("/common/setting/get-setting", controller.Setting{}.GetSetting)
 		cors.POST("/common/setting/save-config", controller.Setting{}.SaveConfig)
 		cors.POST("/common/setting/delete", controller.Setting{}.Delete)
+		cors.POST("/common/backup-target/create", controller.BackupTarget{}.Create)
+		cors.POST("/common/backup-target/get-list", controller.BackupTarget{}.GetList)
+		cors.POST("/common/backup-target/delete", controller.BackupTarget{}.Delete)
+		cors.POST("/common/backup-target/test", controller.BackupTarget{}.Test)
+		cors.POST("/common/backup-encryption/create", controller.BackupEncryption{}.Create)
+		cors.POST("/common/backup-encryption/get-list", controller.BackupEncryption{}.GetList)
+		cors.POST("/common/backup-encryption/delete", controller.BackupEncryption{}.Delete)
+		cors.POST("/common/backup-encryption/export-identity", controller.BackupEncryption{}.ExportIdentity)
 		cors.POST("/common/setting/notification-email-test", controller.Home{}.NotificationEmailTest)
+		cors.POST("/common/setting/notification-channel-test", controller.Home{}.NotificationChannelTest)
+		cors.POST("/common/setting/ldap-test", controller.Setting{}.LdapTest)
 		cors.POST("/common/setting/cache", controller.Home{}.Cache)
 		cors.POST("/common/setting/notification", controller.Home{}.Notification)
 
@@ -77,6 +107,8 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 
 		cors.POST("/common/home/usage", controller.Home{}.Usage)
 		cors.POST("/common/home/get-stat-list", controller.Home{}.GetStatList)
+		cors.POST("/common/home/get-stat-history", controller.Home{}.GetStatHistory)
+		cors.GET("/common/metrics", controller.Metrics{}.Export)
 		cors.POST("/common/home/prune", controller.Home{}.Prune)
 
 		// 环境管理
@@ -105,6 +137,12 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 		cors.POST("/common/cron/prune-log", controller.Cron{}.PruneLog)
 		cors.POST("/common/cron/template", controller.Cron{}.Template)
 
+		// 告警规则
+		cors.POST("/common/alert/create", controller.Alert{}.Create)
+		cors.POST("/common/alert/get-list", controller.Alert{}.GetList)
+		cors.POST("/common/alert/get-detail", controller.Alert{}.GetDetail)
+		cors.POST("/common/alert/delete", controller.Alert{}.Delete)
+
 		// 标签及分组
 		cors.POST("/common/tag/create", controller.Tag{}.Create)
 		cors.POST("/common/tag/get-list", controller.Tag{}.GetList)
@@ -133,6 +171,9 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 		cors.POST("/common/panel/backup-download", controller.Panel{}.BackupDownload)
 		cors.POST("/common/panel/backup-restore", controller.Panel{}.BackupRestore)
 		cors.POST("/common/panel/backup-import", controller.Panel{}.BackupImport)
+		cors.POST("/common/panel/backup-schedule", controller.Panel{}.BackupSchedule)
+		cors.POST("/common/panel/backup-schedule-save", controller.Panel{}.BackupScheduleSave)
+		cors.POST("/common/panel/backup-schedule-run", controller.Panel{}.BackupScheduleRun)
 		cors.POST("/common/panel/check-new-version", controller.Panel{}.CheckNewVersion)
 	})
 
@@ -149,9 +190,25 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 	_ = facade.GetEvent().Subscribe(event.DockerMessageEvent, events.Docker{}.Message)
 
 	_ = facade.Event.Subscribe(event.PluginDestroyExplorer, events.Plugin{}.DestroyExplorer)
+	_ = facade.GetEvent().Subscribe(event.NotificationEvent, events.Notification{}.Send)
+	_ = facade.GetEvent().Subscribe(event.ContainerCreateEvent, events.SiteDomain{}.ContainerChange)
+	_ = facade.GetEvent().Subscribe(event.ContainerDeleteEvent, events.SiteDomain{}.ContainerChange)
 	// 启动时，初始化计划任务
 	crontab.Client.Cron.Start()
 
+	// 每天清理过期的审计日志
+	_, _ = crontab.Client.AddJob("0 30 3 * * *", crontab.New(
+		crontab.WithName("audit prune"),
+		crontab.WithRunFunc(func(ctx *crontab.RunFuncContext) {
+			ctx.Err = logic.Audit{}.Prune()
+		}),
+	))
+
+	// 面板定时备份
+	if err := (logic.PanelBackupSchedule{}).Start(); err != nil {
+		slog.Warn("init panel backup task error", "error", err.Error())
+	}
+
 	if cronList, err := dao.Cron.Order(dao.Cron.ID.Desc()).Find(); err == nil {
 		for _, task := range cronList {
 			if task.Setting.Disable {
@@ -184,4 +241,12 @@ func (provider *Provider) Register(httpServer *httpserver.Server) {
 	for _, env := range dockerEnvList {
 		notice.Monitor.Join(env)
 	}
+
+	// 启动容器资源采样及告警规则
+	if err := alert.Engine.Reload(); err != nil {
+		slog.Warn("init alert rule error", "error", err.Error())
+	}
+	metrics.Sampler.Subscribe(alert.Engine.Evaluate)
+	metrics.Sampler.Subscribe(metrics.History.Write)
+	metrics.Sampler.Start()
 }
@@ -28,6 +28,13 @@ func (self Backup) Configure(command *cobra.Command) {
 	command.Flags().String("backup-image", "", "Backup image type: 'image' (registry image) or 'container' (commit container)")
 	command.Flags().Bool("enable-volume", false, "Enable backup of mounted volumes")
 	command.Flags().StringArray("backup-volume", []string{}, "Specific volume mount to backup")
+	command.Flags().String("target", "", "Remote backup target name to upload the snapshot to")
+	command.Flags().String("encryption", "", "Encryption key name used to encrypt the snapshot")
+	command.Flags().Bool("incremental", false, "Store volumes as deduplicated chunks in the local backup repository, cannot be combined with --target or --encryption")
+	command.Flags().String("dump", "", "Database dump profile stored in the snapshot: 'auto' detects it from the image name")
+	command.Flags().String("pre-command", "", "Command executed in the container before the backup")
+	command.Flags().String("post-command", "", "Command executed in the container after the backup")
+	command.Flags().Bool("pause", false, "Pause the container while copying the image and volumes")
 	_ = command.MarkFlagRequired("name")
 }
 
@@ -38,6 +45,13 @@ func (self Backup) Handle(cmd *cobra.Command, args []string) {
 	backupImage, _ := cmd.Flags().GetString("backup-image")
 	enableVolume, _ := cmd.Flags().GetBool("enable-volume")
 	backupVolumeList, _ := cmd.Flags().GetStringArray("backup-volume")
+	targetName, _ := cmd.Flags().GetString("target")
+	encryptionName, _ := cmd.Flags().GetString("encryption")
+	incremental, _ := cmd.Flags().GetBool("incremental")
+	dumpProfile, _ := cmd.Flags().GetString("dump")
+	preCommand, _ := cmd.Flags().GetString("pre-command")
+	postCommand, _ := cmd.Flags().GetString("post-command")
+	pause, _ := cmd.Flags().GetBool("pause")
 
 	proxyClient, err := proxy.NewProxyClient()
 	if err != nil {
@@ -60,9 +74,12 @@ func (self Backup) Handle(cmd *cobra.Command, args []string) {
 		}()
 	}
 	params := &app.ContainerBackupOption{
-		Id:           name,
-		BackupVolume: "none",
-		BackupImage:  "none",
+		Id:             name,
+		BackupVolume:   "none",
+		BackupImage:    "none",
+		TargetName:     targetName,
+		EncryptionName: encryptionName,
+		Incremental:    incremental,
 	}
 	if enableImage {
 		params.BackupImage = "image"
@@ -76,6 +93,14 @@ func (self Backup) Handle(cmd *cobra.Command, args []string) {
 	if !function.IsEmptyArray(backupVolumeList) {
 		params.BackupVolumeList = backupVolumeList
 	}
+	if dumpProfile != "" || preCommand != "" || postCommand != "" || pause {
+		params.Hook = &app.ContainerBackupHook{
+			PreCommand:  preCommand,
+			PostCommand: postCommand,
+			Pause:       pause,
+			DumpProfile: dumpProfile,
+		}
+	}
 	result, err := proxyClient.AppContainerBackupCreate(params)
 	if err != nil {
 		utils.Result{}.Error(err)
@@ -0,0 +1,52 @@
+package system
+
+import (
+	"github.com/donknap/dpanel/app/ctrl/sdk/proxy"
+	"github.com/donknap/dpanel/app/ctrl/sdk/types/common"
+	"github.com/donknap/dpanel/app/ctrl/sdk/utils"
+	"github.com/spf13/cobra"
+)
+
+type Backup struct {
+}
+
+func (self Backup) GetName() string {
+	return "system:backup"
+}
+
+func (self Backup) GetDescription() string {
+	return "Backup panel data, including database, certificates, nginx and compose files"
+}
+
+func (self Backup) Configure(cmd *cobra.Command) {
+	cmd.Flags().String("target", "", "Remote backup target name to upload the snapshot to")
+	cmd.Flags().String("encryption", "", "Encryption key name used to encrypt the snapshot")
+	cmd.Flags().Bool("schedule", false, "Run with the panel backup schedule settings and apply its retention")
+}
+
+func (self Backup) Handle(cmd *cobra.Command, args []string) {
+	targetName, _ := cmd.Flags().GetString("target")
+	encryptionName, _ := cmd.Flags().GetString("encryption")
+	schedule, _ := cmd.Flags().GetBool("schedule")
+
+	proxyClient, err := proxy.NewProxyClient()
+	if err != nil {
+		utils.Result{}.Error(err)
+		return
+	}
+
+	var result interface{}
+	if schedule {
+		result, err = proxyClient.CommonPanelBackupScheduleRun()
+	} else {
+		result, err = proxyClient.CommonPanelBackup(common.PanelBackupOption{
+			TargetName:     targetName,
+			EncryptionName: encryptionName,
+		})
+	}
+	if err != nil {
+		utils.Result{}.Error(err)
+		return
+	}
+	utils.Result{}.Success(result)
+}
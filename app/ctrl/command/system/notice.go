@@ -21,10 +21,9 @@ func (self Notice) GetDescription() string {
 func (self Notice) Configure(cmd *cobra.Command) {
 	cmd.Flags().String("content", "", `The content of the notification`)
 	cmd.Flags().String("subject", "", `The subject of the notification`)
-	cmd.Flags().String("target", "", `The recipient of the notification; when using email, please enter the email address.`)
-	cmd.Flags().String("channel", "email", `Channels for sending notifications ("email")`)
+	cmd.Flags().String("target", "", `The recipient of the notification; when using email, please enter the email address. Overrides the recipient of a configured channel`)
+	cmd.Flags().String("channel", "email", `Channels for sending notifications ("email" or the name of a configured notification channel)`)
 	_ = cmd.MarkFlagRequired("content")
-	_ = cmd.MarkFlagRequired("target")
 }
 
 func (self Notice) Handle(cmd *cobra.Command, args []string) {
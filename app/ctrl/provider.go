@@ -24,4 +24,5 @@ func (provider Provider) Command(console console.Console) {
 	console.RegisterCommand(new(system.Cache))
 	console.RegisterCommand(new(system.Notice))
 	console.RegisterCommand(new(system.Prune))
+	console.RegisterCommand(new(system.Backup))
 }
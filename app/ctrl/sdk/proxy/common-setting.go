@@ -34,3 +34,21 @@ func (self *Client) CommonPrune(params common.PruneOption) (result interface{},
 	err = json.NewDecoder(data).Decode(&result)
 	return result, err
 }
+
+func (self *Client) CommonPanelBackup(params common.PanelBackupOption) (result interface{}, err error) {
+	data, err := self.Post(function.RouterApiUri("/common/panel/backup"), params)
+	if err != nil {
+		return result, err
+	}
+	err = json.NewDecoder(data).Decode(&result)
+	return result, err
+}
+
+func (self *Client) CommonPanelBackupScheduleRun() (result interface{}, err error) {
+	data, err := self.Post(function.RouterApiUri("/common/panel/backup-schedule-run"), nil)
+	if err != nil {
+		return result, err
+	}
+	err = json.NewDecoder(data).Decode(&result)
+	return result, err
+}
@@ -32,10 +32,21 @@ type ContainerCheckUpgradeResult struct {
 }
 
 type ContainerBackupOption struct {
-	Id               string   `json:"id"`
-	BackupImage      string   `json:"backupImage"`
-	BackupVolume     string   `json:"backupVolume"`
-	BackupVolumeList []string `json:"backupVolumeList"`
+	Id               string               `json:"id"`
+	BackupImage      string               `json:"backupImage"`
+	BackupVolume     string               `json:"backupVolume"`
+	BackupVolumeList []string             `json:"backupVolumeList"`
+	TargetName       string               `json:"targetName,omitempty"`
+	EncryptionName   string               `json:"encryptionName,omitempty"`
+	Incremental      bool                 `json:"enableIncremental,omitempty"`
+	Hook             *ContainerBackupHook `json:"hook,omitempty"`
+}
+
+type ContainerBackupHook struct {
+	PreCommand  string `json:"preCommand,omitempty"`
+	PostCommand string `json:"postCommand,omitempty"`
+	Pause       bool   `json:"pause,omitempty"`
+	DumpProfile string `json:"dumpProfile,omitempty"`
 }
 
 type ContainerBackupResult struct {
@@ -22,3 +22,8 @@ type PruneOption struct {
 	EnableNotice   bool `json:"enableNotice"`
 	EnableTempFile bool `json:"enableTempFile"`
 }
+
+type PanelBackupOption struct {
+	TargetName     string `json:"targetName"`
+	EncryptionName string `json:"encryptionName"`
+}
@@ -1,5 +1,25 @@
 # Created by DPanel. DO NOT EDIT OR DELETE!!!
 
+{{if and .rateLimit .rateLimit.rate}}
+limit_req_zone $binary_remote_addr zone=dpanel_req_{{.targetName}}:10m rate={{.rateLimit.rate}}r/{{.rateLimit.rateUnit}};
+{{end}}
+{{if and .rateLimit .rateLimit.connection}}
+limit_conn_zone $binary_remote_addr zone=dpanel_conn_{{.targetName}}:10m;
+{{end}}
+
+{{if and (eq .type "proxy") .upstream .upstream.member}}
+upstream dpanel_{{.targetName}} {
+    {{if eq .upstream.method "least_conn"}}
+    least_conn;
+    {{else if eq .upstream.method "ip_hash"}}
+    ip_hash;
+    {{end}}
+    {{range .upstream.member}}
+    server {{.}}:{{$.port}}{{if $.upstream.maxFails}} max_fails={{$.upstream.maxFails}}{{end}}{{if $.upstream.failTimeout}} fail_timeout={{$.upstream.failTimeout}}s{{end}};
+    {{end}}
+}
+{{end}}
+
 {{if and .enableSSL (eq (print .serverPort) "443")}}
 server {
     listen 80;
@@ -47,11 +67,59 @@ server {
     include /etc/nginx/conf.d/include/block-exploits.conf;
     {{end}}
 
+    {{if or .basicAuth .allowIp .denyIp}}
+    # Access Control
+    include /etc/nginx/conf.d/include/challenge.conf;
+    {{range .denyIp}}
+    deny {{.}};
+    {{end}}
+    {{range .allowIp}}
+    allow {{.}};
+    {{end}}
+    {{if .allowIp}}
+    deny all;
+    {{end}}
+    {{if .basicAuth}}
+    auth_basic "Restricted";
+    auth_basic_user_file /dpanel/nginx/htpasswd/{{.serverName}};
+    {{end}}
+    {{end}}
+
+    {{if .rateLimit}}
+    # Rate Limit
+    {{if .rateLimit.rate}}
+    limit_req zone=dpanel_req_{{.targetName}}{{if .rateLimit.burst}} burst={{.rateLimit.burst}} nodelay{{end}};
+    limit_req_status 429;
+    {{end}}
+    {{if .rateLimit.connection}}
+    limit_conn dpanel_conn_{{.targetName}} {{.rateLimit.connection}};
+    limit_conn_status 429;
+    {{end}}
+    {{end}}
+
     {{if .extraNginx}}
     # Extra Nginx Configuration
     include /dpanel/nginx/extra_host/{{.serverName}}.conf;
     {{end}}
 
+    {{if ne .type "redirect"}}
+    {{range $index, $location := .location}}
+    # Location {{$location.path}}
+    {{if eq $location.matchType "regex"}}
+    location ~ "{{$location.path}}" {
+        {{template "vhostLocation" $location}}
+    }
+    {{else}}
+    location = {{$location.path}} {
+        {{template "vhostLocation" $location}}
+    }
+    location ^~ {{$location.path}}/ {
+        {{template "vhostLocation" $location}}
+    }
+    {{end}}
+    {{end}}
+    {{end}}
+
     {{if eq .type "proxy"}}
     location / {
         {{if .enableWs}}
@@ -74,7 +142,15 @@ server {
         client_max_body_size 0;
         proxy_buffering off;
 
-        {{if eq .serverAddress "host.dpanel.local"}}
+        {{if .upstream}}
+        {{if .upstream.member}}
+        proxy_next_upstream error timeout http_502 http_503 http_504;
+        proxy_pass $forward_scheme://dpanel_{{.targetName}};
+        {{else}}
+        # No running container in upstream
+        return 502;
+        {{end}}
+        {{else if eq .serverAddress "host.dpanel.local"}}
         proxy_pass $forward_scheme://host.dpanel.local:{{.port}};
         {{else}}
         set $upstream_endpoint {{.serverAddress}}:{{.port}};
@@ -107,4 +183,40 @@ server {
         fastcgi_intercept_errors off;
     }
     {{end}}
-}
\ No newline at end of file
+}
+
+{{/* 路径规则的转发配置，前缀匹配时同时用于 location = 及 location ^~ */}}
+{{define "vhostLocation"}}
+        {{if .enableWs}}
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection $connection_upgrade;
+        proxy_http_version 1.1;
+        proxy_read_timeout 3600s;
+        proxy_send_timeout 3600s;
+        {{end}}
+
+        add_header X-Served-By $host;
+
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-Host   $host;
+        proxy_set_header X-Forwarded-Scheme $scheme;
+        proxy_set_header X-Forwarded-Proto  $scheme;
+        proxy_set_header X-Forwarded-For    $proxy_add_x_forwarded_for;
+        proxy_set_header X-Real-IP          $remote_addr;
+        {{range .header}}
+        proxy_set_header {{.name}} "{{.value}}";
+        {{end}}
+
+        client_max_body_size 0;
+        proxy_buffering off;
+
+        {{if .stripPrefix}}
+        rewrite ^{{.path}}(?:/(.*))?$ /$1 break;
+        {{end}}
+        {{if eq .serverAddress "host.dpanel.local"}}
+        proxy_pass {{if .serverProtocol}}{{.serverProtocol}}{{else}}$forward_scheme{{end}}://host.dpanel.local:{{.port}};
+        {{else}}
+        set $upstream_endpoint {{.serverAddress}}:{{.port}};
+        proxy_pass {{if .serverProtocol}}{{.serverProtocol}}{{else}}$forward_scheme{{end}}://$upstream_endpoint;
+        {{end}}
+{{end}}
@@ -0,0 +1,40 @@
+package accessor
+
+import (
+	"time"
+
+	"github.com/donknap/dpanel/common/service/docker/types"
+)
+
+const (
+	AlertMetricCpu           = "cpu"         // 百分比
+	AlertMetricMemory        = "memory"      // 占内存限制的百分比
+	AlertMetricMemoryUsage   = "memoryUsage" // 字节
+	AlertMetricNetworkRx     = "networkRx"   // 字节/秒
+	AlertMetricNetworkTx     = "networkTx"   // 字节/秒
+	AlertMetricBlockRead     = "blockRead"   // 字节/秒
+	AlertMetricBlockWrite    = "blockWrite"  // 字节/秒
+	AlertOperatorGreaterThan = "gt"
+	AlertOperatorLessThan    = "lt"
+)
+
+type AlertRuleSettingOption struct {
+	Enable          bool              `json:"enable"`
+	DockerEnvName   string            `json:"dockerEnvName,omitempty"`
+	Metric          string            `json:"metric" binding:"required,oneof=cpu memory memoryUsage networkRx networkTx blockRead blockWrite"`
+	Operator        string            `json:"operator" binding:"required,oneof=gt lt"`
+	Threshold       float64           `json:"threshold"`
+	Duration        int               `json:"duration,omitempty"`        // 持续满足条件多少秒后才触发
+	Cooldown        int               `json:"cooldown,omitempty"`        // 触发后持续异常时，间隔多少秒重复通知，0 为不重复，恢复后在此时间内再次触发也不通知
+	ResolveDuration int               `json:"resolveDuration,omitempty"` // 持续恢复正常多少秒后才视为恢复，为 0 时与 Duration 相同
+	ContainerName   []string          `json:"containerName,omitempty"`   // 为空时匹配全部容器
+	Label           []types.ValueItem `json:"label,omitempty"`           // 容器需同时包含全部标签，value 为空时只判断 key
+	NotifyResolve   bool              `json:"notifyResolve,omitempty"`   // 恢复正常时是否通知
+}
+
+func (self AlertRuleSettingOption) GetResolveDuration() time.Duration {
+	if self.ResolveDuration > 0 {
+		return time.Duration(self.ResolveDuration) * time.Second
+	}
+	return time.Duration(self.Duration) * time.Second
+}
@@ -0,0 +1,11 @@
+package accessor
+
+// BackupEncryption 快照加密密钥，passphrase 类型使用口令，x25519 类型使用 age 公私钥
+// 仅填写公钥时面板只能加密，恢复时需要另外提供私钥
+type BackupEncryption struct {
+	Name       string `json:"name" binding:"required"`
+	Type       string `json:"type" binding:"required,oneof=passphrase x25519"`
+	Passphrase string `json:"passphrase,omitempty"`
+	Recipient  string `json:"recipient,omitempty"`
+	Identity   string `json:"identity,omitempty"`
+}
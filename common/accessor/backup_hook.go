@@ -0,0 +1,15 @@
+package accessor
+
+const (
+	BackupDumpProfileAuto = "auto"
+)
+
+// BackupHook 备份前后在容器中执行的操作，保证数据库等应用的数据一致
+// 执行顺序为 前置命令、导出数据、暂停容器、备份、恢复容器、后置命令
+type BackupHook struct {
+	PreCommand  string `json:"preCommand,omitempty"`  // 退出码不为 0 时取消备份
+	PostCommand string `json:"postCommand,omitempty"` // 备份失败时同样执行
+	Pause       bool   `json:"pause,omitempty"`       // 备份镜像及挂载数据期间暂停容器
+	DumpProfile string `json:"dumpProfile,omitempty"` // 内置的导出配置，auto 时按镜像名称识别，识别不到时不导出
+	DumpCommand string `json:"dumpCommand,omitempty"` // 自定义导出命令，输出到 stdout 的内容保存到快照中
+}
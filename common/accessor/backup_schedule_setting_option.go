@@ -0,0 +1,93 @@
+package accessor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type BackupScheduleSettingOption struct {
+	DockerEnvName        string                  `json:"dockerEnvName"`
+	Disable              bool                    `json:"disable,omitempty"`
+	Expression           []CronSettingExpression `json:"expression"`
+	ContainerNames       []string                `json:"containerNames,omitempty"`
+	ComposeName          string                  `json:"composeName,omitempty"` // 备份编排项目下的所有容器
+	EnableImage          bool                    `json:"enableImage,omitempty"`
+	EnableImageContainer bool                    `json:"enableImageContainer,omitempty"`
+	EnableVolume         bool                    `json:"enableVolume,omitempty"`
+	VolumeList           []string                `json:"volumeList,omitempty"`
+	Retention            BackupRetention         `json:"retention"`
+	TargetName           string                  `json:"targetName,omitempty"` // 快照上传的远程存储
+	EncryptionName       string                  `json:"encryptionName,omitempty"`
+	Incremental          bool                    `json:"incremental,omitempty"` // 不支持加密及远程存储
+	Verify               bool                    `json:"verify,omitempty"`      // 备份完成后校验快照
+	TestRestore          bool                    `json:"testRestore,omitempty"` // 校验时在临时容器中试恢复
+	HealthCommand        string                  `json:"healthCommand,omitempty"`
+	Hook                 *BackupHook             `json:"hook,omitempty"`
+	JobIds               []cron.EntryID          `json:"jobIds,omitempty"`
+	NextRunTime          []time.Time             `json:"nextRunTime,omitempty"`
+	LastRunAt            *time.Time              `json:"lastRunAt,omitempty"`
+	LastError            string                  `json:"lastError,omitempty"`
+}
+
+// BackupRetention 快照保留策略，各项同时生效，全部为 0 时不清理
+type BackupRetention struct {
+	KeepLast    int `json:"keepLast,omitempty" binding:"omitempty,min=0"`
+	KeepDaily   int `json:"keepDaily,omitempty" binding:"omitempty,min=0"`
+	KeepWeekly  int `json:"keepWeekly,omitempty" binding:"omitempty,min=0"`
+	KeepMonthly int `json:"keepMonthly,omitempty" binding:"omitempty,min=0"`
+}
+
+// IsEmpty 未设置任何保留项时不清理
+func (self BackupRetention) IsEmpty() bool {
+	return self.KeepLast <= 0 && self.KeepDaily <= 0 && self.KeepWeekly <= 0 && self.KeepMonthly <= 0
+}
+
+// Keep 返回需要保留的下标，timeList 需要按时间倒序排列
+func (self BackupRetention) Keep(timeList []time.Time) map[int]bool {
+	keep := make(map[int]bool)
+	for i := range timeList {
+		if i < self.KeepLast {
+			keep[i] = true
+		}
+	}
+	keepBucket := func(total int, bucket func(t time.Time) string) {
+		seen := make(map[string]bool)
+		for i, t := range timeList {
+			if len(seen) >= total {
+				break
+			}
+			key := bucket(t)
+			if !seen[key] {
+				seen[key] = true
+				keep[i] = true
+			}
+		}
+	}
+	keepBucket(self.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepBucket(self.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+	keepBucket(self.KeepMonthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+	return keep
+}
+
+// PanelBackup 面板自身数据的定时备份
+type PanelBackup struct {
+	Enable           bool                    `json:"enable,omitempty"`
+	Expression       []CronSettingExpression `json:"expression"`
+	BackupPathList   []string                `json:"backupPathList,omitempty"` // 为空时备份面板全部数据
+	IgnorePathPrefix []string                `json:"ignorePathPrefix,omitempty"`
+	Retention        BackupRetention         `json:"retention"` // 只清理定时任务创建的快照
+	TargetName       string                  `json:"targetName,omitempty"`
+	EncryptionName   string                  `json:"encryptionName,omitempty"`
+	JobIds           []cron.EntryID          `json:"jobIds,omitempty"`
+	LastRunAt        *time.Time              `json:"lastRunAt,omitempty"`
+	LastError        string                  `json:"lastError,omitempty"`
+}
@@ -0,0 +1,79 @@
+package accessor
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBackupRetentionKeep(t *testing.T) {
+	at := func(value string) time.Time {
+		result, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	// 按时间倒序排列
+	timeList := []time.Time{
+		at("2024-03-05 12:00"), // 0 周二
+		at("2024-03-05 00:00"), // 1
+		at("2024-03-04 12:00"), // 2 周一
+		at("2024-03-03 12:00"), // 3 周日，上一周
+		at("2024-03-01 12:00"), // 4
+		at("2024-02-26 12:00"), // 5 周一，上一周
+		at("2024-02-20 12:00"), // 6
+		at("2024-01-31 12:00"), // 7
+		at("2023-12-31 12:00"), // 8 周日，属于 2023 年第 52 周
+		at("2023-12-25 12:00"), // 9
+	}
+	tests := []struct {
+		name      string
+		retention BackupRetention
+		expect    []int
+	}{
+		{"empty", BackupRetention{}, []int{}},
+		{"last", BackupRetention{KeepLast: 3}, []int{0, 1, 2}},
+		{"last more than total", BackupRetention{KeepLast: 20}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"daily", BackupRetention{KeepDaily: 3}, []int{0, 2, 3}},
+		{"weekly", BackupRetention{KeepWeekly: 3}, []int{0, 3, 6}},
+		{"monthly", BackupRetention{KeepMonthly: 3}, []int{0, 5, 7}},
+		{"monthly across year", BackupRetention{KeepMonthly: 5}, []int{0, 5, 7, 8}},
+		// 各项同时生效，取并集
+		{"combined", BackupRetention{KeepLast: 1, KeepDaily: 2, KeepMonthly: 2}, []int{0, 2, 5}},
+		{"negative", BackupRetention{KeepLast: -1, KeepDaily: -1}, []int{}},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			keep := item.retention.Keep(timeList)
+			result := make([]int, 0, len(keep))
+			for i, ok := range keep {
+				if ok {
+					result = append(result, i)
+				}
+			}
+			sort.Ints(result)
+			if len(result) != len(item.expect) {
+				t.Fatalf("expect %v, got %v", item.expect, result)
+			}
+			for i := range result {
+				if result[i] != item.expect[i] {
+					t.Fatalf("expect %v, got %v", item.expect, result)
+				}
+			}
+		})
+	}
+
+	if keep := (BackupRetention{KeepDaily: 1}).Keep(nil); len(keep) != 0 {
+		t.Fatalf("expect nothing kept for empty list, got %v", keep)
+	}
+}
+
+func TestBackupRetentionIsEmpty(t *testing.T) {
+	if !(BackupRetention{}).IsEmpty() || !(BackupRetention{KeepLast: -1}).IsEmpty() {
+		t.Fatal("retention without positive values should be empty")
+	}
+	if (BackupRetention{KeepWeekly: 1}).IsEmpty() {
+		t.Fatal("retention with weekly value should not be empty")
+	}
+}
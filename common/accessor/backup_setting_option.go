@@ -1,12 +1,34 @@
 package accessor
 
+import "time"
+
 type BackupSettingOption struct {
-	BackupTargetType string   `json:"backupTargetType"`     // 兼容旧的数据，将来统一都是 snapshot 类型
-	BackupTar        string   `json:"backupTar,omitempty"`  // tar 包的位置
-	BackupPath       string   `json:"backupPath,omitempty"` // 废弃，快照默认放到 /dpanel/backup 目录下，不支持保存至主机
-	VolumePathList   []string `json:"volumePathList"`
-	Size             int64    `json:"size"`
-	Error            string   `json:"error,omitempty"`
-	Status           int      `json:"status,omitempty"`
-	Description      string   `json:"description"`
+	BackupTargetType string        `json:"backupTargetType"`     // 兼容旧的数据，将来统一都是 snapshot 类型
+	BackupTar        string        `json:"backupTar,omitempty"`  // tar 包的位置
+	BackupPath       string        `json:"backupPath,omitempty"` // 废弃，快照默认放到 /dpanel/backup 目录下，不支持保存至主机
+	VolumePathList   []string      `json:"volumePathList"`
+	Size             int64         `json:"size"`
+	Error            string        `json:"error,omitempty"`
+	Status           int           `json:"status,omitempty"`
+	Description      string        `json:"description"`
+	ScheduleId       int32         `json:"scheduleId,omitempty"`      // 由备份计划创建时的计划 id
+	ComposeName      string        `json:"composeName,omitempty"`     // 编排项目快照，包含任务文件及全部服务容器
+	TargetName       string        `json:"targetName,omitempty"`      // 远程存储名称，为空时快照保存在本地
+	Encryption       string        `json:"encryption,omitempty"`      // 加密使用的密钥名称，为空时未加密
+	Incremental      bool          `json:"incremental,omitempty"`     // 挂载数据保存在本地分块仓库中，不支持加密及远程存储
+	IncrementalSize  int64         `json:"incrementalSize,omitempty"` // 本次备份新增的分块大小
+	Verify           *BackupVerify `json:"verify,omitempty"`          // 最近一次校验结果
+	Hook             *BackupHook   `json:"hook,omitempty"`            // 备份时执行的前后置操作
+	DumpProfile      string        `json:"dumpProfile,omitempty"`     // 快照中包含的数据库导出使用的配置
+}
+
+type BackupVerify struct {
+	VerifiedAt    time.Time `json:"verifiedAt"`
+	Success       bool      `json:"success"`
+	Error         string    `json:"error,omitempty"`
+	Blobs         int       `json:"blobs"`
+	Size          int64     `json:"size"`
+	TestRestore   bool      `json:"testRestore,omitempty"` // 是否在临时容器中试恢复
+	HealthCommand string    `json:"healthCommand,omitempty"`
+	Output        string    `json:"output,omitempty"` // 健康检查命令的输出
 }
@@ -0,0 +1,37 @@
+package accessor
+
+import (
+	"github.com/donknap/dpanel/common/service/ssh"
+)
+
+const (
+	BackupTargetTypeS3     = "s3"
+	BackupTargetTypeSftp   = "sftp"
+	BackupTargetTypeWebdav = "webdav"
+)
+
+// BackupTarget 远程备份存储，快照及面板备份可以同时上传到远程
+type BackupTarget struct {
+	Name   string              `json:"name" binding:"required"`
+	Type   string              `json:"type" binding:"required,oneof=s3 sftp webdav"`
+	Path   string              `json:"path,omitempty"` // 远程存储中的根目录，SFTP 以 / 开头时为绝对路径
+	S3     *BackupTargetS3     `json:"s3,omitempty"`
+	Sftp   *ssh.ServerInfo     `json:"sftp,omitempty"`
+	Webdav *BackupTargetWebdav `json:"webdav,omitempty"`
+}
+
+type BackupTargetS3 struct {
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region,omitempty"`
+	Bucket          string `json:"bucket"`
+	AccessKeyId     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	UseSsl          bool   `json:"useSsl,omitempty"`
+	PathStyle       bool   `json:"pathStyle,omitempty"` // 兼容 minio 等不支持虚拟主机访问的服务
+}
+
+type BackupTargetWebdav struct {
+	Url      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
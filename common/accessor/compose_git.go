@@ -0,0 +1,30 @@
+package accessor
+
+import (
+	"time"
+)
+
+// ComposeGit 任务绑定的 git 仓库，仓库提交变化时重新部署
+type ComposeGit struct {
+	Url           string             `json:"url"`
+	Branch        string             `json:"branch,omitempty"`
+	Path          string             `json:"path,omitempty"`     // compose 文件在仓库中的目录，为空时为仓库根目录
+	Interval      int                `json:"interval,omitempty"` // 轮询间隔（分钟），为 0 时只通过 webhook 或手动同步
+	AutoDeploy    bool               `json:"autoDeploy,omitempty"`
+	PullImage     bool               `json:"pullImage,omitempty"`
+	WebhookToken  string             `json:"webhookToken,omitempty"`
+	DockerEnvName string             `json:"dockerEnvName,omitempty"` // 部署时使用的 docker 环境，同步时只在该环境下部署
+	Checkout      string             `json:"checkout,omitempty"`      // 当前任务目录中文件对应的提交
+	Commit        string             `json:"commit,omitempty"`        // 最近一次部署成功的提交
+	RemoteCommit  string             `json:"remoteCommit,omitempty"`  // 最近一次拉取到的远程提交
+	ConfigHash    map[string]string  `json:"configHash,omitempty"`    // 部署成功时各服务的 config-hash，用于检查是否被修改
+	History       []ComposeGitDeploy `json:"history,omitempty"`
+	LastSyncAt    *time.Time         `json:"lastSyncAt,omitempty"`
+	LastError     string             `json:"lastError,omitempty"`
+}
+
+type ComposeGitDeploy struct {
+	Commit     string    `json:"commit"`
+	Message    string    `json:"message,omitempty"`
+	DeployedAt time.Time `json:"deployedAt"`
+}
@@ -24,18 +24,20 @@ const (
 )
 
 type ComposeSettingOption struct {
-	Status            string          `json:"status,omitempty"`
-	Type              string          `json:"type"`
-	Uri               []string        `json:"uri,omitempty"`
-	RemoteUrl         string          `json:"remoteUrl,omitempty"`
-	Store             string          `json:"store,omitempty"`
-	Environment       []types.EnvItem `json:"environment,omitempty"`
-	DockerEnvName     string          `json:"dockerEnvName,omitempty"`
-	DeployServiceName []string        `json:"deployServiceName,omitempty"`
-	CreatedAt         string          `json:"createdAt,omitempty"`
-	UpdatedAt         string          `json:"updatedAt,omitempty"`
-	Message           string          `json:"message,omitempty"`
-	RunName           string          `json:"-"` // Deprecated: 兼容旧版有前缀的名称
+	Status            string           `json:"status,omitempty"`
+	Type              string           `json:"type"`
+	Uri               []string         `json:"uri,omitempty"`
+	RemoteUrl         string           `json:"remoteUrl,omitempty"`
+	Store             string           `json:"store,omitempty"`
+	Environment       []types.EnvItem  `json:"environment,omitempty"`
+	DockerEnvName     string           `json:"dockerEnvName,omitempty"`
+	DeployServiceName []string         `json:"deployServiceName,omitempty"`
+	CreatedAt         string           `json:"createdAt,omitempty"`
+	UpdatedAt         string           `json:"updatedAt,omitempty"`
+	Message           string           `json:"message,omitempty"`
+	Git               *ComposeGit      `json:"git,omitempty"`
+	RunName           string           `json:"-"` // Deprecated: 兼容旧版有前缀的名称
+	DockerEnv         *types.DockerEnv `json:"-"` // 部署到其它环境时指定，为空时使用当前环境
 }
 
 func (self ComposeSettingOption) GetUriFilePath() string {
@@ -64,13 +66,20 @@ func (self ComposeSettingOption) GetDefaultEnv() (envFile string, envFileContent
 
 func (self ComposeSettingOption) GetWorkingDir() string {
 	workDir := storage.Local{}.GetComposePath("")
-	if docker.Sdk.DockerEnv.EnableComposePath {
-		workDir = storage.Local{}.GetComposePath(docker.Sdk.DockerEnv.Name)
+	if dockerEnv := self.GetDockerEnv(); dockerEnv.EnableComposePath {
+		workDir = storage.Local{}.GetComposePath(dockerEnv.Name)
 	}
 	slog.Debug("compose get container working dir", "dir", workDir)
 	return workDir
 }
 
+func (self ComposeSettingOption) GetDockerEnv() *types.DockerEnv {
+	if self.DockerEnv != nil {
+		return self.DockerEnv
+	}
+	return docker.Sdk.DockerEnv
+}
+
 func (self ComposeSettingOption) GetYaml() [2]string {
 	yaml := [2]string{
 		"", "",
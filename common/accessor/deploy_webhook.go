@@ -0,0 +1,25 @@
+package accessor
+
+import (
+	"time"
+)
+
+const (
+	DeployWebhookTypeContainer = "container"
+	DeployWebhookTypeCompose   = "compose"
+)
+
+// DeployWebhook 供 CI 调用的部署地址，通过地址中的令牌触发容器更新或编排任务部署
+type DeployWebhook struct {
+	Name          string     `json:"name" binding:"required"`
+	Type          string     `json:"type" binding:"required,oneof=container compose"`
+	Target        string     `json:"target" binding:"required"` // 容器名称或编排任务名称
+	DockerEnvName string     `json:"dockerEnvName"`             // 只在该环境下执行
+	Token         string     `json:"token"`
+	Secret        string     `json:"secret,omitempty"` // 签名密钥，为空时只校验令牌
+	PullImage     bool       `json:"pullImage,omitempty"`
+	EnableBak     bool       `json:"enableBak,omitempty"`     // 更新容器时保留旧容器
+	RemoveOrphans bool       `json:"removeOrphans,omitempty"` // 部署编排任务时删除多余的服务
+	LastRunAt     *time.Time `json:"lastRunAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/docker/docker/api/types"
 	types2 "github.com/donknap/dpanel/common/service/docker/types"
 	types3 "github.com/donknap/dpanel/common/types"
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 type SettingValueOption struct {
@@ -15,6 +16,10 @@ type SettingValueOption struct {
 	UserStatus                  uint8                        `json:"userStatus,omitempty"`
 	UserRemark                  string                       `json:"userRemark,omitempty"`
 	RegisterAt                  *time.Time                   `json:"registerAt,omitempty"`
+	UserRole                    []UserRoleBinding            `json:"userRole,omitempty"`
+	Passkey                     []Passkey                    `json:"passkey,omitempty"`
+	RecoveryCode                []string                     `json:"recoveryCode,omitempty"` // 两步验证恢复码的摘要，使用后删除
+	ExternalId                  string                       `json:"externalId,omitempty"`   // 绑定的第三方账号，如 oidc:{issuer}#{sub}、ldap:{dn}
 	Docker                      map[string]*types2.DockerEnv `json:"docker,omitempty"`
 	DiskUsage                   *DiskUsage                   `json:"diskUsage,omitempty"`
 	TwoFa                       *TwoFa                       `json:"twoFa,omitempty"`
@@ -29,10 +34,37 @@ type SettingValueOption struct {
 	Tag                         []Tag                        `json:"tag,omitempty"`
 	Login                       *Login                       `json:"login,omitempty"`
 	ConsoleInstance             *ConsoleInstance             `json:"consoleInstance,omitempty"`
+	Audit                       *Audit                       `json:"audit,omitempty"`
+	Oidc                        *Oidc                        `json:"oidc,omitempty"`
+	Ldap                        *Ldap                        `json:"ldap,omitempty"`
+	BackupTarget                []BackupTarget               `json:"backupTarget,omitempty"`
+	BackupEncryption            []BackupEncryption           `json:"backupEncryption,omitempty"`
+	PanelBackup                 *PanelBackup                 `json:"panelBackup,omitempty"`
+	DeployWebhook               []DeployWebhook              `json:"deployWebhook,omitempty"`
+	CertMonitor                 *CertMonitor                 `json:"certMonitor,omitempty"`
 }
 
 type ContainerCheckIgnoreUpgrade []string
 
+type UserRoleBinding struct {
+	Role          string   `json:"role" binding:"required,oneof=admin operator readonly"`
+	DockerEnvName string   `json:"dockerEnvName,omitempty"` // 为空时授权在全部环境中生效
+	Resource      []string `json:"resource,omitempty"`      // 为空时匹配全部资源，如 container、image、compose
+}
+
+type Passkey struct {
+	Name       string              `json:"name"`
+	Credential webauthn.Credential `json:"credential"`
+	CreatedAt  time.Time           `json:"createdAt"`
+	LastUsedAt *time.Time          `json:"lastUsedAt,omitempty"`
+}
+
+// UserRoleMapping 第三方登录时，按用户所在的组授予角色
+type UserRoleMapping struct {
+	Group string `json:"group" binding:"required"` // 为 * 时匹配全部用户
+	UserRoleBinding
+}
+
 type ConsoleInstance struct {
 	Host      []string `json:"host"`
 	Container []string `json:"container"`
@@ -117,6 +149,46 @@ type Login struct {
 	DefaultRedirect     string   `json:"defaultRedirect,omitempty"`
 }
 
+type Audit struct {
+	RetentionDays int `json:"retentionDays,omitempty"` // 审计日志保留天数，为 0 时使用默认值，-1 为永久保留
+}
+
+type CertMonitor struct {
+	Disable   bool  `json:"disable,omitempty"`
+	Threshold []int `json:"threshold,omitempty"` // 证书剩余天数小于等于阈值时提醒，为空时使用 30 7 1
+}
+
+type Oidc struct {
+	Enable        bool              `json:"enable,omitempty"`
+	Name          string            `json:"name,omitempty"` // 登录页按钮显示的名称
+	Issuer        string            `json:"issuer,omitempty"`
+	ClientId      string            `json:"clientId,omitempty"`
+	ClientSecret  string            `json:"clientSecret,omitempty"`
+	Scope         []string          `json:"scope,omitempty"`         // 为空时使用 openid profile email
+	RedirectUri   string            `json:"redirectUri,omitempty"`   // 为空时根据请求地址生成
+	UsernameClaim string            `json:"usernameClaim,omitempty"` // 为空时依次使用 preferred_username、email、sub
+	GroupClaim    string            `json:"groupClaim,omitempty"`    // 为空时使用 groups
+	AutoCreate    bool              `json:"autoCreate,omitempty"`    // 用户不存在时自动创建
+	SyncRole      bool              `json:"syncRole,omitempty"`      // 每次登录时按映射重新设置用户角色
+	RoleMapping   []UserRoleMapping `json:"roleMapping,omitempty"`
+}
+
+type Ldap struct {
+	Enable             bool              `json:"enable,omitempty"`
+	Url                string            `json:"url,omitempty"` // ldap://host:389 或 ldaps://host:636
+	StartTls           bool              `json:"startTls,omitempty"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify,omitempty"`
+	BindDn             string            `json:"bindDn,omitempty"` // 用于查找用户的账号，为空时匿名查找
+	BindPassword       string            `json:"bindPassword,omitempty"`
+	BaseDn             string            `json:"baseDn,omitempty"`
+	UserFilter         string            `json:"userFilter,omitempty"`     // 为空时使用 (uid=%s)，AD 可使用 (sAMAccountName=%s)
+	EmailAttribute     string            `json:"emailAttribute,omitempty"` // 为空时使用 mail
+	GroupAttribute     string            `json:"groupAttribute,omitempty"` // 为空时使用 memberOf，组可以填写完整 DN 或 CN
+	AutoCreate         bool              `json:"autoCreate,omitempty"`
+	SyncRole           bool              `json:"syncRole,omitempty"`
+	RoleMapping        []UserRoleMapping `json:"roleMapping,omitempty"`
+}
+
 type NotificationEmailServer struct {
 	Host  string `json:"host,omitempty" binding:"required"`
 	Port  int    `json:"port,omitempty" binding:"required"`
@@ -124,7 +196,20 @@ type NotificationEmailServer struct {
 	Code  string `json:"code,omitempty" binding:"required"`
 }
 
+type NotificationChannel struct {
+	Name     string             `json:"name" binding:"required"`
+	Type     string             `json:"type" binding:"required,oneof=email webhook telegram slack gotify ntfy"`
+	Enable   bool               `json:"enable"`
+	Event    []string           `json:"event,omitempty"`    // 订阅的事件，为空时接收全部事件
+	Template string             `json:"template,omitempty"` // text/template 格式的消息模板，为空时使用默认模板
+	Url      string             `json:"url,omitempty"`      // webhook、slack、gotify、ntfy 的服务地址
+	Token    string             `json:"token,omitempty"`    // telegram bot token、gotify app token、ntfy access token
+	Target   string             `json:"target,omitempty"`   // 邮箱地址、telegram chat id、ntfy topic
+	Header   []types2.ValueItem `json:"header,omitempty"`   // webhook 附加请求头
+}
+
 type Notification struct {
 	EmailServer *NotificationEmailServer `json:"emailServer,omitempty"`
 	Status      []string                 `json:"status,omitempty"`
+	Channel     []NotificationChannel    `json:"channel,omitempty"`
 }
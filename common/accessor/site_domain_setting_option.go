@@ -3,6 +3,9 @@ package accessor
 import (
 	"fmt"
 	"html/template"
+
+	"github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/types/define"
 )
 
 const (
@@ -10,28 +13,91 @@ const (
 	VhostDisableFilename = VhostFileName + ".disable"
 )
 
+const (
+	SiteDomainLocationMatchPrefix = "prefix"
+	SiteDomainLocationMatchRegex  = "regex"
+)
+
+const (
+	SiteDomainUpstreamRoundRobin = "round_robin"
+	SiteDomainUpstreamLeastConn  = "least_conn"
+	SiteDomainUpstreamIpHash     = "ip_hash"
+)
+
 type SiteDomainSettingOption struct {
-	Title                     string        `json:"title,omitempty"` // 域名描述说明
-	ServerName                string        `json:"serverName" binding:"required"`
-	ServerNameAlias           []string      `json:"serverNameAlias,omitempty"`
-	ServerAddress             string        `json:"serverAddress"`
-	ServerProtocol            string        `json:"serverProtocol"`
-	ServerPort                string        `json:"serverPort"`
-	TargetName                string        `json:"targetName"`
-	Port                      int32         `json:"port" binding:"required"` // 目标转发转发端口 TargetPort
-	TargetPort                int32         `json:"targetPort"`
-	EnableDisable             bool          `json:"enableDisable"` // 是否禁用, 会增加 .disable 后缀
-	EnableBlockCommonExploits bool          `json:"enableBlockCommonExploits"`
-	EnableAssetCache          bool          `json:"enableAssetCache"`
-	EnableWs                  bool          `json:"enableWs"`
-	EnableSSL                 bool          `json:"enableSSL"`
-	ExtraNginx                template.HTML `json:"extraNginx,omitempty"`
-	SslCrt                    string        `json:"sslCrt,omitempty"`
-	SslKey                    string        `json:"sslKey,omitempty"`
-	CertName                  string        `json:"certName,omitempty"`
-	Type                      string        `json:"type"`
-	WWWRoot                   string        `json:"wwwRoot,omitempty"`
-	FPMRoot                   string        `json:"fpmRoot,omitempty"`
+	Title                     string                `json:"title,omitempty"` // 域名描述说明
+	ServerName                string                `json:"serverName" binding:"required"`
+	ServerNameAlias           []string              `json:"serverNameAlias,omitempty"`
+	ServerAddress             string                `json:"serverAddress"`
+	ServerProtocol            string                `json:"serverProtocol"`
+	ServerPort                string                `json:"serverPort"`
+	TargetName                string                `json:"targetName"`
+	Port                      int32                 `json:"port" binding:"required"` // 目标转发转发端口 TargetPort
+	TargetPort                int32                 `json:"targetPort"`
+	EnableDisable             bool                  `json:"enableDisable"` // 是否禁用, 会增加 .disable 后缀
+	EnableBlockCommonExploits bool                  `json:"enableBlockCommonExploits"`
+	EnableAssetCache          bool                  `json:"enableAssetCache"`
+	EnableWs                  bool                  `json:"enableWs"`
+	EnableSSL                 bool                  `json:"enableSSL"`
+	ExtraNginx                template.HTML         `json:"extraNginx,omitempty"`
+	SslCrt                    string                `json:"sslCrt,omitempty"`
+	SslKey                    string                `json:"sslKey,omitempty"`
+	CertName                  string                `json:"certName,omitempty"`
+	Type                      string                `json:"type"`
+	WWWRoot                   string                `json:"wwwRoot,omitempty"`
+	FPMRoot                   string                `json:"fpmRoot,omitempty"`
+	Location                  []SiteDomainLocation  `json:"location,omitempty" binding:"omitempty,dive"` // 按路径转发到其它目标，未匹配的请求仍转发到 ServerAddress:Port
+	Upstream                  *SiteDomainUpstream   `json:"upstream,omitempty"`                          // 设置后转发到一组容器，替代 ServerAddress
+	BasicAuth                 []SiteDomainBasicAuth `json:"basicAuth,omitempty" binding:"omitempty,dive"`
+	AllowIp                   []string              `json:"allowIp,omitempty"` // 设置后只允许列表中的 IP 或 CIDR 访问
+	DenyIp                    []string              `json:"denyIp,omitempty"`
+	RateLimit                 *SiteDomainRateLimit  `json:"rateLimit,omitempty"`
+}
+
+// SiteDomainBasicAuth 保存时密码转为 bcrypt 摘要，写入 htpasswd 文件
+type SiteDomainBasicAuth struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password,omitempty"` // 编辑时为空则保留原密码，接口返回时清空
+}
+
+// SiteDomainRateLimit 按客户端 IP 限制，超出限制时返回 429
+type SiteDomainRateLimit struct {
+	Rate       int    `json:"rate,omitempty"` // 每个时间单位的请求数，为 0 时不限制
+	RateUnit   string `json:"rateUnit,omitempty" binding:"omitempty,oneof=s m"`
+	Burst      int    `json:"burst,omitempty"`
+	Connection int    `json:"connection,omitempty"` // 同时连接数，为 0 时不限制
+}
+
+// SiteDomainUpstream 指定容器或编排服务的全部副本，只有运行中的容器会加入 upstream
+type SiteDomainUpstream struct {
+	Method         string   `json:"method" binding:"omitempty,oneof=round_robin least_conn ip_hash"`
+	ContainerName  []string `json:"containerName,omitempty"`
+	ComposeProject string   `json:"composeProject,omitempty"`
+	ComposeService string   `json:"composeService,omitempty"` // 编排服务的全部副本，优先于 ContainerName
+	MaxFails       int      `json:"maxFails,omitempty"`       // 被动健康检查，失败次数达到后在 FailTimeout 内不再转发
+	FailTimeout    int      `json:"failTimeout,omitempty"`    // 秒
+	Member         []string `json:"member,omitempty"`         // 当前的成员地址，容器创建或删除后自动更新
+	DockerEnvName  string   `json:"dockerEnvName,omitempty"`  // 容器所在的环境，保存后不随面板切换环境改变
+}
+
+// GetDockerEnvName 旧数据没有记录环境，按默认环境处理
+func (self SiteDomainUpstream) GetDockerEnvName() string {
+	if self.DockerEnvName == "" {
+		return define.DockerDefaultClientName
+	}
+	return self.DockerEnvName
+}
+
+type SiteDomainLocation struct {
+	Path           string            `json:"path" binding:"required"`
+	MatchType      string            `json:"matchType" binding:"omitempty,oneof=prefix regex"` // 为空时按前缀匹配
+	ContainerId    string            `json:"containerId,omitempty"`                            // 转发到容器时自动加入默认网络并填充 ServerAddress
+	ServerAddress  string            `json:"serverAddress"`
+	ServerProtocol string            `json:"serverProtocol,omitempty"` // 为空时与域名的转发协议一致
+	Port           int32             `json:"port" binding:"required"`
+	StripPrefix    bool              `json:"stripPrefix"` // 去掉匹配的前缀后再转发，仅前缀匹配时有效
+	EnableWs       bool              `json:"enableWs"`
+	Header         []types.ValueItem `json:"header,omitempty"` // 转发时附加的请求头
 }
 
 // VHostFilename 返回 vhost 配置文件名
@@ -42,3 +108,13 @@ func (s *SiteDomainSettingOption) VHostFilename() string {
 	}
 	return fmt.Sprintf(VhostFileName, s.ServerName)
 }
+
+// ClearBasicAuthPassword 接口返回前清空密码摘要
+func (s *SiteDomainSettingOption) ClearBasicAuthPassword() {
+	if s == nil {
+		return
+	}
+	for i := range s.BasicAuth {
+		s.BasicAuth[i].Password = ""
+	}
+}
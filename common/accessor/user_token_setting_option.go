@@ -0,0 +1,10 @@
+package accessor
+
+import "time"
+
+type UserTokenSettingOption struct {
+	Role       string     `json:"role,omitempty"`     // 令牌可使用的最高角色，为空时与所属用户一致
+	Prefix     string     `json:"prefix,omitempty"`   // 令牌明文的前几位，仅用于列表中区分
+	ExpireAt   *time.Time `json:"expireAt,omitempty"` // 为空时永不过期
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
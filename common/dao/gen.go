@@ -17,12 +17,16 @@ import (
 
 var (
 	Q              = new(Query)
+	AlertRule      *alertRule
+	AuditLog       *auditLog
 	Backup         *backup
+	BackupSchedule *backupSchedule
 	Compose        *compose
 	Cron           *cron
 	CronLog        *cronLog
 	Event          *event
 	Image          *image
+	Metric         *metric
 	Notice         *notice
 	Registry       *registry
 	Setting        *setting
@@ -31,16 +35,21 @@ var (
 	SiteUpgrade    *siteUpgrade
 	Store          *store
 	UserPermission *userPermission
+	UserToken      *userToken
 )
 
 func SetDefault(db *gorm.DB, opts ...gen.DOOption) {
 	*Q = *Use(db, opts...)
+	AlertRule = &Q.AlertRule
+	AuditLog = &Q.AuditLog
 	Backup = &Q.Backup
+	BackupSchedule = &Q.BackupSchedule
 	Compose = &Q.Compose
 	Cron = &Q.Cron
 	CronLog = &Q.CronLog
 	Event = &Q.Event
 	Image = &Q.Image
+	Metric = &Q.Metric
 	Notice = &Q.Notice
 	Registry = &Q.Registry
 	Setting = &Q.Setting
@@ -49,17 +58,22 @@ func SetDefault(db *gorm.DB, opts ...gen.DOOption) {
 	SiteUpgrade = &Q.SiteUpgrade
 	Store = &Q.Store
 	UserPermission = &Q.UserPermission
+	UserToken = &Q.UserToken
 }
 
 func Use(db *gorm.DB, opts ...gen.DOOption) *Query {
 	return &Query{
 		db:             db,
+		AlertRule:      newAlertRule(db, opts...),
+		AuditLog:       newAuditLog(db, opts...),
 		Backup:         newBackup(db, opts...),
+		BackupSchedule: newBackupSchedule(db, opts...),
 		Compose:        newCompose(db, opts...),
 		Cron:           newCron(db, opts...),
 		CronLog:        newCronLog(db, opts...),
 		Event:          newEvent(db, opts...),
 		Image:          newImage(db, opts...),
+		Metric:         newMetric(db, opts...),
 		Notice:         newNotice(db, opts...),
 		Registry:       newRegistry(db, opts...),
 		Setting:        newSetting(db, opts...),
@@ -68,18 +82,23 @@ func Use(db *gorm.DB, opts ...gen.DOOption) *Query {
 		SiteUpgrade:    newSiteUpgrade(db, opts...),
 		Store:          newStore(db, opts...),
 		UserPermission: newUserPermission(db, opts...),
+		UserToken:      newUserToken(db, opts...),
 	}
 }
 
 type Query struct {
 	db *gorm.DB
 
+	AlertRule      alertRule
+	AuditLog       auditLog
 	Backup         backup
+	BackupSchedule backupSchedule
 	Compose        compose
 	Cron           cron
 	CronLog        cronLog
 	Event          event
 	Image          image
+	Metric         metric
 	Notice         notice
 	Registry       registry
 	Setting        setting
@@ -88,6 +107,7 @@ type Query struct {
 	SiteUpgrade    siteUpgrade
 	Store          store
 	UserPermission userPermission
+	UserToken      userToken
 }
 
 func (q *Query) Available() bool { return q.db != nil }
@@ -95,12 +115,16 @@ func (q *Query) Available() bool { return q.db != nil }
 func (q *Query) clone(db *gorm.DB) *Query {
 	return &Query{
 		db:             db,
+		AlertRule:      q.AlertRule.clone(db),
+		AuditLog:       q.AuditLog.clone(db),
 		Backup:         q.Backup.clone(db),
+		BackupSchedule: q.BackupSchedule.clone(db),
 		Compose:        q.Compose.clone(db),
 		Cron:           q.Cron.clone(db),
 		CronLog:        q.CronLog.clone(db),
 		Event:          q.Event.clone(db),
 		Image:          q.Image.clone(db),
+		Metric:         q.Metric.clone(db),
 		Notice:         q.Notice.clone(db),
 		Registry:       q.Registry.clone(db),
 		Setting:        q.Setting.clone(db),
@@ -109,6 +133,7 @@ func (q *Query) clone(db *gorm.DB) *Query {
 		SiteUpgrade:    q.SiteUpgrade.clone(db),
 		Store:          q.Store.clone(db),
 		UserPermission: q.UserPermission.clone(db),
+		UserToken:      q.UserToken.clone(db),
 	}
 }
 
@@ -123,12 +148,16 @@ func (q *Query) WriteDB() *Query {
 func (q *Query) ReplaceDB(db *gorm.DB) *Query {
 	return &Query{
 		db:             db,
+		AlertRule:      q.AlertRule.replaceDB(db),
+		AuditLog:       q.AuditLog.replaceDB(db),
 		Backup:         q.Backup.replaceDB(db),
+		BackupSchedule: q.BackupSchedule.replaceDB(db),
 		Compose:        q.Compose.replaceDB(db),
 		Cron:           q.Cron.replaceDB(db),
 		CronLog:        q.CronLog.replaceDB(db),
 		Event:          q.Event.replaceDB(db),
 		Image:          q.Image.replaceDB(db),
+		Metric:         q.Metric.replaceDB(db),
 		Notice:         q.Notice.replaceDB(db),
 		Registry:       q.Registry.replaceDB(db),
 		Setting:        q.Setting.replaceDB(db),
@@ -137,16 +166,21 @@ func (q *Query) ReplaceDB(db *gorm.DB) *Query {
 		SiteUpgrade:    q.SiteUpgrade.replaceDB(db),
 		Store:          q.Store.replaceDB(db),
 		UserPermission: q.UserPermission.replaceDB(db),
+		UserToken:      q.UserToken.replaceDB(db),
 	}
 }
 
 type queryCtx struct {
+	AlertRule      IAlertRuleDo
+	AuditLog       IAuditLogDo
 	Backup         IBackupDo
+	BackupSchedule IBackupScheduleDo
 	Compose        IComposeDo
 	Cron           ICronDo
 	CronLog        ICronLogDo
 	Event          IEventDo
 	Image          IImageDo
+	Metric         IMetricDo
 	Notice         INoticeDo
 	Registry       IRegistryDo
 	Setting        ISettingDo
@@ -155,16 +189,21 @@ type queryCtx struct {
 	SiteUpgrade    ISiteUpgradeDo
 	Store          IStoreDo
 	UserPermission IUserPermissionDo
+	UserToken      IUserTokenDo
 }
 
 func (q *Query) WithContext(ctx context.Context) *queryCtx {
 	return &queryCtx{
+		AlertRule:      q.AlertRule.WithContext(ctx),
+		AuditLog:       q.AuditLog.WithContext(ctx),
 		Backup:         q.Backup.WithContext(ctx),
+		BackupSchedule: q.BackupSchedule.WithContext(ctx),
 		Compose:        q.Compose.WithContext(ctx),
 		Cron:           q.Cron.WithContext(ctx),
 		CronLog:        q.CronLog.WithContext(ctx),
 		Event:          q.Event.WithContext(ctx),
 		Image:          q.Image.WithContext(ctx),
+		Metric:         q.Metric.WithContext(ctx),
 		Notice:         q.Notice.WithContext(ctx),
 		Registry:       q.Registry.WithContext(ctx),
 		Setting:        q.Setting.WithContext(ctx),
@@ -173,6 +212,7 @@ func (q *Query) WithContext(ctx context.Context) *queryCtx {
 		SiteUpgrade:    q.SiteUpgrade.WithContext(ctx),
 		Store:          q.Store.WithContext(ctx),
 		UserPermission: q.UserPermission.WithContext(ctx),
+		UserToken:      q.UserToken.WithContext(ctx),
 	}
 }
 
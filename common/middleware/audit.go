@@ -0,0 +1,155 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/app/common/logic"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/gin-gonic/gin"
+	"github.com/we7coreteam/w7-rangine-go/v2/src/http/middleware"
+)
+
+const (
+	auditMaxBodySize     = 1 << 20
+	auditMaxResponseSize = 4 << 10
+	auditMaxTargetLength = 512
+)
+
+var (
+	// 从请求参数中提取操作对象的字段
+	auditTargetKey = []string{
+		"id", "md5", "name", "title", "tag", "username", "containerName", "siteName", "path",
+	}
+)
+
+type AuditMiddleware struct {
+	middleware.Abstract
+}
+
+// Process 记录所有修改数据的接口调用，只读接口及未登录的请求不记录
+func (self AuditMiddleware) Process(http *gin.Context) {
+	currentUrlPath := http.Request.URL.Path
+	if !strings.HasPrefix(currentUrlPath, function.RouterRootApi()) && !strings.HasPrefix(currentUrlPath, function.RouterRootWs()) {
+		http.Next()
+		return
+	}
+	uri, resource, action := logic.UserRole{}.ParseUri(currentUrlPath)
+	if resource == "" || (logic.UserRole{}).IsReadonlyUri(uri) {
+		http.Next()
+		return
+	}
+
+	startTime := time.Now()
+	dockerEnvName := docker.Sdk.Name
+	target := self.target(http)
+	writer := &auditResponseWriter{
+		ResponseWriter: http.Writer,
+	}
+	http.Writer = writer
+
+	http.Next()
+
+	data, ok := http.Get("userInfo")
+	if !ok {
+		return
+	}
+	userInfo := data.(logic.UserInfo)
+	row := &entity.AuditLog{
+		UserID:        userInfo.UserId,
+		Username:      userInfo.Username,
+		IP:            http.ClientIP(),
+		DockerEnvName: dockerEnvName,
+		Uri:           uri,
+		Resource:      resource,
+		Action:        action,
+		Target:        target,
+		Status:        int32(writer.Status()),
+		CreatedAt:     startTime,
+	}
+	if writer.Status() >= 400 {
+		result := struct {
+			Error string `json:"error"`
+		}{}
+		if err := json.Unmarshal(writer.body.Bytes(), &result); err == nil {
+			row.Message = result.Error
+		}
+	}
+	if err := (logic.Audit{}).Create(row); err != nil {
+		slog.Debug("audit middleware", "url", currentUrlPath, "error", err)
+	}
+}
+
+func (self AuditMiddleware) target(http *gin.Context) string {
+	values := make(map[string]string)
+	for _, param := range http.Params {
+		values[param.Key] = param.Value
+	}
+	if http.Request.Body != nil && strings.HasPrefix(http.ContentType(), gin.MIMEJSON) {
+		body, _ := io.ReadAll(io.LimitReader(http.Request.Body, auditMaxBodySize))
+		// 读取后放回，不影响后续的参数绑定
+		http.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), http.Request.Body))
+		params := make(map[string]interface{})
+		if err := json.Unmarshal(body, &params); err == nil {
+			for _, key := range auditTargetKey {
+				if v, ok := params[key]; ok {
+					values[key] = self.format(v)
+				}
+			}
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key+"="+values[key])
+	}
+	target := strings.Join(result, ", ")
+	if len(target) > auditMaxTargetLength {
+		target = target[:auditMaxTargetLength]
+	}
+	return target
+}
+
+func (self AuditMiddleware) format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		return strings.Join(function.PluckArrayWalk(v, func(item interface{}) (string, bool) {
+			return self.format(item), true
+		}), "|")
+	case float64:
+		return fmt.Sprintf("%v", v)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
+
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write 出错时保留部分响应内容，用于记录错误信息
+func (self *auditResponseWriter) Write(data []byte) (int, error) {
+	if self.Status() >= 400 && self.body.Len() < auditMaxResponseSize {
+		self.body.Write(data)
+	}
+	return self.ResponseWriter.Write(data)
+}
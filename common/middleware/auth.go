@@ -8,6 +8,7 @@ import (
 
 	"github.com/donknap/dpanel/app/common/logic"
 	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
 	"github.com/donknap/dpanel/common/service/storage"
 	"github.com/donknap/dpanel/common/types/define"
 	"github.com/gin-gonic/gin"
@@ -28,8 +29,11 @@ func (self AuthMiddleware) Process(http *gin.Context) {
 	if strings.Contains(currentUrlPath, "/common/user/login") ||
 		strings.Contains(currentUrlPath, "/common/user/create-founder") ||
 		strings.Contains(currentUrlPath, "/common/user/oauth/") ||
+		strings.Contains(currentUrlPath, "/common/user/webauthn/login-") ||
 		strings.Contains(currentUrlPath, "/pro/home/login-info") ||
 		strings.Contains(currentUrlPath, "/pro/user/reset-info") ||
+		strings.Contains(currentUrlPath, "/app/compose/git-webhook/") ||
+		strings.Contains(currentUrlPath, "/app/deploy-webhook/trigger/") ||
 		(!strings.HasPrefix(currentUrlPath, function.RouterRootApi()) && !strings.HasPrefix(currentUrlPath, function.RouterRootWs())) {
 		http.Next()
 		return
@@ -54,6 +58,19 @@ func (self AuthMiddleware) Process(http *gin.Context) {
 		return
 	}
 
+	// API 令牌不依赖 Jwt，服务重启后依然有效
+	if strings.HasPrefix(authCode[1], define.UserTokenPrefix) {
+		tokenUserInfo, err := logic.UserToken{}.GetUserInfo(authCode[1])
+		if err != nil {
+			slog.Debug("auth middleware", "url", currentUrlPath, "error", err)
+			self.JsonResponseWithError(http, ErrLogin, 401)
+			http.AbortWithStatus(401)
+			return
+		}
+		self.next(http, *tokenUserInfo)
+		return
+	}
+
 	myUserInfo := logic.UserInfo{}
 	token, err := jwt.ParseWithClaims(authCode[1], &myUserInfo, func(t *jwt.Token) (interface{}, error) {
 		var rsaKeyContent []byte
@@ -93,16 +110,14 @@ func (self AuthMiddleware) Process(http *gin.Context) {
 		if myUserInfo.AutoLogin {
 			if _, err := new(logic.Setting).GetValueById(myUserInfo.UserId); err == nil {
 				myUserInfo.Fd = http.GetHeader("AuthorizationFd")
-				http.Set("userInfo", myUserInfo)
-				http.Next()
+				self.next(http, myUserInfo)
 				return
 			}
 		} else {
 			if v, ok := storage.Cache.Get(fmt.Sprintf(storage.CacheKeyCommonUserInfo, myUserInfo.UserId)); ok {
 				if _, ok := v.(logic.UserInfo); ok {
 					myUserInfo.Fd = http.GetHeader("AuthorizationFd")
-					http.Set("userInfo", myUserInfo)
-					http.Next()
+					self.next(http, myUserInfo)
 					return
 				}
 			}
@@ -117,3 +132,15 @@ func (self AuthMiddleware) Process(http *gin.Context) {
 	http.AbortWithStatus(401)
 	return
 }
+
+func (self AuthMiddleware) next(http *gin.Context, userInfo logic.UserInfo) {
+	// 先写入用户信息，没有权限的请求也会被审计记录
+	http.Set("userInfo", userInfo)
+	if err := (logic.UserRole{}).Check(userInfo, docker.Sdk.Name, http.Request.URL.Path); err != nil {
+		slog.Debug("auth middleware", "url", http.Request.URL.Path, "username", userInfo.Username, "error", err)
+		self.JsonResponseWithError(http, err, 403)
+		http.AbortWithStatus(403)
+		return
+	}
+	http.Next()
+}
@@ -0,0 +1,31 @@
+package migrate
+
+import (
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+)
+
+type Upgrade20261018 struct{}
+
+func (self Upgrade20261018) Version() string {
+	return "1.9.4"
+}
+
+func (self Upgrade20261018) Upgrade() error {
+	db, err := facade.GetDbFactory().Channel("default")
+	if err != nil {
+		return err
+	}
+	for _, sql := range []string{
+		// 历史数据按环境、精度及时间查询和清理
+		`CREATE INDEX IF NOT EXISTS idx_ims_metric_query ON ims_metric (docker_env_name, resolution, created_at)`,
+		// API 令牌按摘要查询
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_ims_user_token_token ON ims_user_token (token)`,
+		// 审计日志按时间筛选及清理
+		`CREATE INDEX IF NOT EXISTS idx_ims_audit_log_created_at ON ims_audit_log (created_at)`,
+	} {
+		if err = db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
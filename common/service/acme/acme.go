@@ -1,231 +1,364 @@
 package acme
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/donknap/dpanel/common/function"
-	"github.com/donknap/dpanel/common/service/exec"
-	"github.com/donknap/dpanel/common/service/exec/local"
-	"github.com/donknap/dpanel/common/types/define"
+	acmeSdk "golang.org/x/crypto/acme"
 )
 
 const (
-	DefaultCommandName     = "/root/.acme.sh/acme.sh"
-	EnvOverrideCommandName = "DP_ACME_COMMAND_NAME"
-	EnvOverrideConfigHome  = "DP_ACME_CONFIG_HOME"
+	EnvOverrideConfigHome = "DP_ACME_CONFIG_HOME"
+	EnvOverrideWebroot    = "DP_ACME_WEBROOT"
+	EnvCaCertificates     = "DP_ACME_CA_CERTIFICATES" // 额外信任的 CA 证书文件，对接本地 Pebble 测试时使用
+	DefaultWebroot        = "/var/www/challenges"
+	DefaultCertServer     = "letsencrypt"
 )
 
+// 兼容 acme.sh 的服务器简称，也可以直接使用目录地址
+var certServerDirectory = map[string]string{
+	"letsencrypt":      "https://acme-v02.api.letsencrypt.org/directory",
+	"letsencrypt_test": "https://acme-staging-v02.api.letsencrypt.org/directory",
+	"zerossl":          "https://acme.zerossl.com/v2/DV90",
+	"google":           "https://dv.acme-v02.api.pki.goog/directory",
+	"googletest":       "https://dv.acme-v02.test-api.pki.goog/directory",
+	"buypass":          "https://api.buypass.com/acme/directory",
+	"buypass_test":     "https://api.test4.buypass.no/acme/directory",
+	"sslcom":           "https://acme.ssl.com/sslcom-dv-ecc",
+}
+
 func New(ctx context.Context, opts ...Option) (*Acme, error) {
 	b := &Acme{
-		commandName: DefaultCommandName,
-		argv:        make([]string, 0),
-		env:         make([]string, 0),
-		ctx:         ctx,
-	}
-	if override := os.Getenv(EnvOverrideCommandName); override != "" {
-		b.commandName = override
-	}
-	if override := os.Getenv(EnvOverrideConfigHome); override != "" {
-		b.configHome = override
-		b.argv = append(b.argv, "--config-home", b.configHome)
-	} else {
-		b.configHome = filepath.Dir(b.commandName)
+		ctx:                   ctx,
+		configHome:            os.Getenv(EnvOverrideConfigHome),
+		domain:                make([]string, 0),
+		output:                io.Discard,
+		dnsPropagationTimeout: time.Minute * 2,
 	}
-	b.env = append(b.env, "HTTP_PROXY="+os.Getenv("HTTP_PROXY"), "HTTPS_PROXY="+os.Getenv("HTTP_PROXY"))
 	for _, opt := range opts {
-		err := opt(b)
-		if err != nil {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+	if b.server == "" {
+		if err := WithCertServer(DefaultCertServer)(b); err != nil {
 			return nil, err
 		}
 	}
+	if b.configHome == "" {
+		return nil, errors.New("acme config home is required")
+	}
 	return b, nil
 }
 
 type Acme struct {
-	commandName string
-	argv        []string
-	env         []string
-	configHome  string
-	ctx         context.Context
+	ctx                   context.Context
+	configHome            string
+	server                string
+	email                 string
+	eab                   *acmeSdk.ExternalAccountBinding
+	domain                []string
+	solver                Solver
+	dnsApi                string
+	dnsPropagationTimeout time.Duration
+	output                io.Writer
 }
 
-func (self Acme) Run() (exec.Executor, error) {
-	argv := append(self.argv, "--ecc")
-	options := []local.Option{
-		local.WithCommandName(self.commandName),
-		local.WithArgs(argv...),
-		local.WithCtx(self.ctx),
-	}
-	if !function.IsEmptyArray(self.env) {
-		options = append(options, local.WithEnv(self.env))
-	}
-	return local.New(options...)
+type account struct {
+	Uri   string `json:"uri"`
+	Email string `json:"email"`
 }
 
-func (self Acme) Result() ([]byte, error) {
-	argv := append(self.argv, "--register-account")
-	options := []local.Option{
-		local.WithCommandName(self.commandName),
-		local.WithArgs(argv...),
-		local.WithCtx(self.ctx),
+// Issue 为全部域名申请一张证书，第一个域名为主域名，签发成功后保存到 {configHome}/{mainDomain}_ecc 目录
+// 证书已存在时直接覆盖，续期也使用该方法
+func (self *Acme) Issue() (*Cert, error) {
+	if function.IsEmptyArray(self.domain) {
+		return nil, errors.New("domain is required")
 	}
-	if !function.IsEmptyArray(self.env) {
-		options = append(options, local.WithEnv(self.env))
+	if self.solver == nil {
+		return nil, errors.New("challenge type is required, use nginx or a dns api")
 	}
-	cmd, err := local.New(options...)
+	client, err := self.client()
 	if err != nil {
 		return nil, err
 	}
-	return cmd.RunWithResult()
-}
-
-type Cert struct {
-	RootPath      string   `json:"-"`
-	MainDomain    string   `json:"mainDomain"`
-	Domain        []string `json:"domain"`
-	CA            string   `json:"CA"`
-	CreatedAt     string   `json:"createdAt"`
-	RenewAt       string   `json:"renewAt"`
-	Success       bool     `json:"success"`
-	DnsApi        string   `json:"dnsApi"`
-	SslCrtContent string   `json:"sslCrtContent"`
-	SslKeyContent string   `json:"sslKeyContent"`
-}
-
-func (self *Cert) IsImport() bool {
-	return self.CA == "import"
-}
-
-func (self *Cert) FillCertContent() {
-	if content, err := os.ReadFile(filepath.Join(self.GetRootPath(), "fullchain.cer")); err == nil {
-		self.SslCrtContent = string(content)
+	self.log("create order for %s", strings.Join(self.domain, ", "))
+	order, err := client.AuthorizeOrder(self.ctx, acmeSdk.DomainIDs(self.domain...))
+	if err != nil {
+		return nil, err
 	}
-	if content, err := os.ReadFile(filepath.Join(self.GetRootPath(), self.Domain[0]+".key")); err == nil {
-		self.SslKeyContent = string(content)
+	for _, authzUrl := range order.AuthzURLs {
+		if err = self.authorize(client, authzUrl); err != nil {
+			return nil, err
+		}
+	}
+	order, err = client.WaitOrder(self.ctx, order.URI)
+	if err != nil {
+		return nil, err
 	}
-}
-
-func (self *Cert) GetRootPath() string {
-	return self.RootPath + "_ecc"
-}
 
-func (self Acme) List() ([]*Cert, error) {
-	argv := append(self.argv, "--list", "--listraw")
-	cmd, err := local.New(
-		local.WithCommandName(self.commandName),
-		local.WithArgs(argv...),
-	)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, err
 	}
-	out, err := cmd.RunWithResult()
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: self.domain[0]},
+		DNSNames: self.domain,
+	}, key)
 	if err != nil {
 		return nil, err
 	}
-	return self.ParseListRaw(out), nil
-}
-
-func (self Acme) Info(mainDomain string) (*Cert, error) {
-	list, err := self.List()
+	self.log("finalize order")
+	der, _, err := client.CreateOrderCert(self.ctx, order.FinalizeURL, csr, true)
 	if err != nil {
 		return nil, err
 	}
-	cert, _, ok := function.PluckArrayItemWalk(list, func(item *Cert) bool {
-		return item.MainDomain == mainDomain
+	chain := make([]byte, 0)
+	for _, item := range der {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: item})...)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := self.save(self.domain[0], chain, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), Cert{
+		Server: self.server,
+		Email:  self.email,
+		DnsApi: self.dnsApi,
 	})
-	if !ok {
-		return nil, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
-	}
-	if !cert.IsImport() {
-		argv := append(self.argv, "--info", "-d", cert.MainDomain)
-		if cmd, err := local.New(
-			local.WithCommandName(self.commandName),
-			local.WithArgs(argv...),
-		); err == nil {
-			if info, err := cmd.RunWithResult(); err == nil {
-				item := function.PluckArrayWalk(strings.Split(string(info), "\n"), func(i string) (string, bool) {
-					if k, v, exists := strings.Cut(i, "="); exists && k == "Le_Webroot" {
-						return strings.Trim(v, "'"), true
-					}
-					return "", false
-				})
-				cert.DnsApi = strings.Join(item, "")
-			}
-		}
+	if err != nil {
+		return nil, err
 	}
+	self.log("certificate issued, expires at %s", cert.NotAfter.Local().Format(time.DateTime))
 	return cert, nil
 }
 
-func (self Acme) ParseListRaw(out []byte) []*Cert {
-	certList := make([]map[string]string, 0)
-	certHeader := make([]string, 0)
-
-	scanner := bufio.NewScanner(bytes.NewBuffer(out))
-	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), "Main_Domain") {
-			certHeader = strings.Split(scanner.Text(), "|")
-			continue
-		}
-		if values := strings.Split(scanner.Text(), "|"); len(values) >= 6 {
-			entry := make(map[string]string)
-			for i, value := range values {
-				if key := strings.TrimSpace(certHeader[i]); key != "" {
-					entry[key] = value
-				}
-			}
-			certList = append(certList, entry)
+func (self *Acme) authorize(client *acmeSdk.Client, authzUrl string) (err error) {
+	authz, err := client.GetAuthorization(self.ctx, authzUrl)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acmeSdk.StatusValid {
+		return nil
+	}
+	domain := authz.Identifier.Value
+	var challenge *acmeSdk.Challenge
+	for _, item := range authz.Challenges {
+		if item.Type == self.solver.Type() {
+			challenge = item
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("challenge %s is not offered for %s", self.solver.Type(), domain)
+	}
+	var keyAuth string
+	if challenge.Type == ChallengeTypeDns {
+		keyAuth, err = client.DNS01ChallengeRecord(challenge.Token)
+	} else {
+		keyAuth, err = client.HTTP01ChallengeResponse(challenge.Token)
+	}
+	if err != nil {
+		return err
+	}
+	self.log("verify %s with %s", domain, challenge.Type)
+	if err = self.solver.Present(self.ctx, domain, challenge.Token, keyAuth); err != nil {
+		return err
+	}
+	defer func() {
+		// 清理时不使用传入的 ctx，取消申请后也要删除验证记录
+		if cleanErr := self.solver.CleanUp(context.Background(), domain, challenge.Token, keyAuth); cleanErr != nil {
+			self.log("clean up %s challenge failed, %s", domain, cleanErr.Error())
 		}
+	}()
+	if _, err = client.Accept(self.ctx, challenge); err != nil {
+		return err
+	}
+	if _, err = client.WaitAuthorization(self.ctx, authz.URI); err != nil {
+		return err
 	}
+	self.log("%s verified", domain)
+	return nil
+}
 
-	result := make([]*Cert, 0)
-	for _, item := range certList {
-		domain := []string{
-			item["Main_Domain"],
+// client 获取当前服务器的帐号，不存在时注册，帐号密钥保存在 {configHome}/account/{host} 目录
+func (self *Acme) client() (*acmeSdk.Client, error) {
+	httpClient, err := self.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	serverUrl, err := url.Parse(self.server)
+	if err != nil {
+		return nil, err
+	}
+	accountPath := filepath.Join(self.configHome, "account", function.SafeFileName(serverUrl.Host))
+	if err = os.MkdirAll(accountPath, os.ModePerm); err != nil {
+		return nil, err
+	}
+	client := &acmeSdk.Client{
+		DirectoryURL: self.server,
+		HTTPClient:   httpClient,
+		UserAgent:    "dpanel",
+	}
+
+	keyFile := filepath.Join(accountPath, "account.key")
+	if content, err := os.ReadFile(keyFile); err == nil {
+		block, _ := pem.Decode(content)
+		if block == nil {
+			return nil, errors.New("invalid acme account key")
+		}
+		if client.Key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	} else {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
 		}
-		if item["SAN_Domains"] != "" && item["SAN_Domains"] != "no" {
-			domain = append(domain, strings.Split(item["SAN_Domains"], ",")...)
+		if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+			return nil, err
 		}
+		client.Key = key
+	}
+
+	accountFile := filepath.Join(accountPath, "account.json")
+	accountInfo := account{}
+	if content, err := os.ReadFile(accountFile); err == nil {
+		_ = json.Unmarshal(content, &accountInfo)
+	}
+	if accountInfo.Uri != "" && accountInfo.Email == self.email {
+		return client, nil
+	}
 
-		success := false
-		if item["CA"] != "" && item["Created"] != "" {
-			success = true
+	acmeAccount := &acmeSdk.Account{}
+	if self.email != "" {
+		acmeAccount.Contact = []string{"mailto:" + self.email}
+	}
+	if self.eab == nil && strings.HasPrefix(self.server, certServerDirectory["zerossl"]) {
+		if self.eab, err = self.zeroSslEab(httpClient); err != nil {
+			return nil, err
 		}
-		cert := &Cert{
-			MainDomain: item["Main_Domain"],
-			RootPath:   filepath.Join(self.configHome, item["Main_Domain"]),
-			Domain:     domain,
-			CA:         item["CA"],
-			CreatedAt:  item["Created"],
-			RenewAt:    item["Renew"],
-			Success:    success,
+	}
+	acmeAccount.ExternalAccountBinding = self.eab
+	self.log("register account %s", self.email)
+	if accountInfo.Uri == "" {
+		result, err := client.Register(self.ctx, acmeAccount, acmeSdk.AcceptTOS)
+		if err != nil && !errors.Is(err, acmeSdk.ErrAccountAlreadyExists) {
+			return nil, err
+		}
+		if result == nil {
+			if result, err = client.GetReg(self.ctx, ""); err != nil {
+				return nil, err
+			}
+		}
+		accountInfo.Uri = result.URI
+	} else {
+		acmeAccount.URI = accountInfo.Uri
+		if _, err = client.UpdateReg(self.ctx, acmeAccount); err != nil {
+			return nil, err
 		}
-		result = append(result, cert)
 	}
-	return result
+	accountInfo.Email = self.email
+	content, err := json.Marshal(accountInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(accountFile, content, 0600); err != nil {
+		return nil, err
+	}
+	return client, nil
 }
 
-func (self Acme) Remove(name string) error {
-	self.argv = append(self.argv, "--remove", "-d", name)
-	cmd, err := local.New(
-		local.WithCommandName(self.commandName),
-		local.WithArgs(self.argv...),
-	)
+// zeroSslEab ZeroSSL 需要 EAB 才能注册，未提供时通过邮箱获取
+func (self *Acme) zeroSslEab(httpClient *http.Client) (*acmeSdk.ExternalAccountBinding, error) {
+	if self.email == "" {
+		return nil, errors.New("zerossl requires an email or eab account")
+	}
+	request, err := http.NewRequestWithContext(self.ctx, http.MethodPost, "https://api.zerossl.com/acme/eab-credentials-email", strings.NewReader(url.Values{
+		"email": []string{self.email},
+	}.Encode()))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	out, err := cmd.RunWithResult()
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := httpClient.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if strings.Contains(string(out), "has been removed") {
-		return nil
-	} else {
-		return errors.New(string(out))
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	result := struct {
+		Success    bool   `json:"success"`
+		EabKid     string `json:"eab_kid"`
+		EabHmacKey string `json:"eab_hmac_key"`
+	}{}
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New("failed to get zerossl eab credentials")
+	}
+	return newEab(result.EabKid, result.EabHmacKey)
+}
+
+func (self *Acme) httpClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile := os.Getenv(EnvCaCertificates); caFile != "" {
+		content, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("invalid ca certificates %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: pool,
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Minute,
+	}, nil
+}
+
+func (self *Acme) log(format string, args ...any) {
+	_, _ = fmt.Fprintf(self.output, "[%s] %s\n", time.Now().Format(time.DateTime), fmt.Sprintf(format, args...))
+}
+
+func newEab(kid, hmacKey string) (*acmeSdk.ExternalAccountBinding, error) {
+	key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(hmacKey, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid eab hmac key, %w", err)
 	}
+	return &acmeSdk.ExternalAccountBinding{
+		KID: kid,
+		Key: key,
+	}, nil
 }
@@ -0,0 +1,264 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 对接本地 Pebble 测试签发流程，Pebble 需要设置 PEBBLE_VA_ALWAYS_VALID=1 跳过 HTTP-01 验证
+// DP_ACME_PEBBLE_DIRECTORY=https://localhost:14000/dir DP_ACME_CA_CERTIFICATES=pebble.minica.pem go test ./common/service/acme/
+const envPebbleDirectory = "DP_ACME_PEBBLE_DIRECTORY"
+
+func TestPebbleIssue(t *testing.T) {
+	directory := os.Getenv(envPebbleDirectory)
+	if directory == "" {
+		t.Skipf("%s is not set", envPebbleDirectory)
+	}
+	configHome := t.TempDir()
+	builder, err := New(context.Background(),
+		WithConfigHomePath(configHome),
+		WithCertServer(directory),
+		WithEmail("test@example.com"),
+		WithDomain("dpanel.example.com", "www.dpanel.example.com"),
+		WithHttpWebroot(t.TempDir()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := builder.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.MainDomain != "dpanel.example.com" || len(cert.Domain) != 2 || !cert.AutoRenew {
+		t.Fatalf("unexpected cert %+v", cert)
+	}
+	if cert.GetRootPath() != filepath.Join(configHome, "dpanel.example.com_ecc") {
+		t.Fatalf("unexpected root path %s", cert.GetRootPath())
+	}
+
+	info, err := builder.Info("dpanel.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Server != directory || info.DnsApi != DnsApiNginx || info.NeedRenew() {
+		t.Fatalf("unexpected cert info %+v", info)
+	}
+	info.FillCertContent()
+	if info.SslCrtContent == "" || info.SslKeyContent == "" {
+		t.Fatal("cert content is empty")
+	}
+
+	// 续期使用同一个账号，直接覆盖原证书
+	if _, err = builder.Issue(); err != nil {
+		t.Fatal(err)
+	}
+	renew, err := builder.Info("dpanel.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renew.FillCertContent()
+	if renew.SslCrtContent == info.SslCrtContent {
+		t.Fatal("cert is not renewed")
+	}
+
+	if err = builder.Remove("dpanel.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := builder.List(); len(list) != 0 {
+		t.Fatalf("cert is not removed %v", list)
+	}
+}
+
+func TestCertPathIsSafe(t *testing.T) {
+	root := t.TempDir()
+	configHome := filepath.Join(root, "cert")
+	builder, err := New(context.Background(), WithConfigHomePath(configHome))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, key := testSelfSignedCert(t, "dpanel.example.com")
+
+	cert, err := builder.save("../../outside", chain, key, Cert{CA: CertTypeImport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(cert.GetRootPath()) != configHome {
+		t.Fatalf("cert saved outside config home %s", cert.GetRootPath())
+	}
+	if _, err = os.Stat(filepath.Join(root, "outside_ecc")); !os.IsNotExist(err) {
+		t.Fatal("cert saved outside config home")
+	}
+
+	victim := filepath.Join(root, "victim_ecc")
+	if err = os.MkdirAll(victim, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = builder.Remove("../victim"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(victim); err != nil {
+		t.Fatal("remove deleted a directory outside config home")
+	}
+
+	// 元数据中的主域名同样不能跳出 configHome
+	cert.MainDomain = "../victim"
+	if err = builder.Update(cert); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := builder.load(strings.TrimSuffix(filepath.Base(cert.GetRootPath()), "_ecc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(loaded.GetRootPath()) != configHome {
+		t.Fatalf("cert loaded outside config home %s", loaded.GetRootPath())
+	}
+}
+
+func TestCertRenewTime(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		cert   Cert
+		expect time.Time
+		renew  bool
+	}{
+		{"90 days", Cert{NotBefore: notBefore, NotAfter: notBefore.AddDate(0, 0, 90), AutoRenew: true}, notBefore.AddDate(0, 0, 60), true},
+		{"6 days", Cert{NotBefore: notBefore, NotAfter: notBefore.AddDate(0, 0, 6), AutoRenew: true}, notBefore.AddDate(0, 0, 4), true},
+		{"auto renew disabled", Cert{NotBefore: notBefore, NotAfter: notBefore.AddDate(0, 0, 90)}, notBefore.AddDate(0, 0, 60), false},
+		{"import", Cert{NotBefore: notBefore, NotAfter: notBefore.AddDate(0, 0, 90), AutoRenew: true, CA: CertTypeImport}, notBefore.AddDate(0, 0, 60), false},
+	}
+	for _, item := range tests {
+		if result := item.cert.renewTime(); !result.Equal(item.expect) {
+			t.Errorf("%s: expect %s, got %s", item.name, item.expect, result)
+		}
+		// 测试数据均已过期，开启自动续期时需要续期
+		if item.cert.NeedRenew() != item.renew {
+			t.Errorf("%s: expect need renew %v", item.name, item.renew)
+		}
+	}
+
+	fresh := Cert{NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().AddDate(0, 0, 90), AutoRenew: true}
+	if fresh.NeedRenew() {
+		t.Error("a fresh cert should not be renewed")
+	}
+	if (&Cert{AutoRenew: true}).NeedRenew() {
+		t.Error("a cert without validity should not be renewed")
+	}
+}
+
+func TestCertLoadLegacy(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    string
+		files   map[string]string
+		expect  Cert
+		require bool
+	}{
+		{
+			name: "http webroot",
+			conf: "Le_Domain='example.com'\nLe_Webroot='/dpanel/acme'\nLe_API='https://acme-v02.api.letsencrypt.org/directory'\n",
+			files: map[string]string{
+				"ca/acme-v02.api.letsencrypt.org/directory/ca.conf": "CA_EMAIL='ca@example.com'\n",
+				"account.conf": "ACCOUNT_EMAIL='account@example.com'\n",
+			},
+			expect: Cert{Server: "https://acme-v02.api.letsencrypt.org/directory", DnsApi: DnsApiNginx, AutoRenew: true, Email: "ca@example.com"},
+		},
+		{
+			name: "dns api with account email",
+			conf: "Le_Webroot='dns_ali'\nLe_API='https://acme.zerossl.com/v2/DV90'\n",
+			files: map[string]string{
+				"account.conf": "ACCOUNT_EMAIL=\"account@example.com\"\n",
+			},
+			expect: Cert{Server: "https://acme.zerossl.com/v2/DV90", DnsApi: "dns_ali", AutoRenew: true, Email: "account@example.com"},
+		},
+		{
+			name:    "zerossl without email",
+			conf:    "Le_Webroot='dns_cf'\nLe_API='https://acme.zerossl.com/v2/DV90'\n",
+			expect:  Cert{Server: "https://acme.zerossl.com/v2/DV90", DnsApi: "dns_cf", AutoRenew: true},
+			require: true,
+		},
+		{
+			name:   "import",
+			conf:   "Le_API='import'\n",
+			expect: Cert{CA: CertTypeImport},
+		},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			configHome := t.TempDir()
+			builder, err := New(context.Background(), WithConfigHomePath(configHome))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := map[string]string{
+				"example.com_ecc/example.com.conf": item.conf,
+			}
+			for name, content := range item.files {
+				files[name] = content
+			}
+			for name, content := range files {
+				name = filepath.Join(configHome, filepath.FromSlash(name))
+				if err = os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(name, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			chain, _ := testSelfSignedCert(t, "example.com")
+			if err = os.WriteFile(filepath.Join(configHome, "example.com_ecc", certFileName), chain, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cert, err := builder.Info("example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cert.MainDomain != "example.com" || cert.IsImport() != item.expect.IsImport() || cert.Server != item.expect.Server ||
+				cert.DnsApi != item.expect.DnsApi || cert.AutoRenew != item.expect.AutoRenew || cert.Email != item.expect.Email {
+				t.Fatalf("unexpected cert %+v", cert)
+			}
+			if cert.RequireEmail() != item.require {
+				t.Fatalf("expect require email %v", item.require)
+			}
+			if len(cert.Domain) != 1 || cert.NotAfter.IsZero() {
+				t.Fatalf("cert content is not filled %+v", cert)
+			}
+		})
+	}
+}
+
+func testSelfSignedCert(t *testing.T, domain string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24 * 90),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
@@ -0,0 +1,315 @@
+package acme
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/types/define"
+)
+
+const (
+	CertTypeImport   = "import"
+	certFileName     = "fullchain.cer"
+	keyFileName      = "%s.key"
+	metadataFileName = "cert.json"
+)
+
+type Cert struct {
+	RootPath      string    `json:"-"`
+	MainDomain    string    `json:"mainDomain"`
+	Domain        []string  `json:"domain"`
+	CA            string    `json:"CA"`     // 签发机构，导入的证书为 import
+	Issuer        string    `json:"issuer"` // 证书中的签发者
+	Server        string    `json:"server,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	DnsApi        string    `json:"dnsApi"`
+	AutoRenew     bool      `json:"autoRenew"`
+	CreatedAt     string    `json:"createdAt"`
+	RenewAt       string    `json:"renewAt"`
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+	DaysLeft      int       `json:"daysLeft"` // 读取证书时计算，过期后为负数
+	Success       bool      `json:"success"`
+	LastError     string    `json:"lastError,omitempty"` // 最近一次续期失败的原因
+	SslCrtContent string    `json:"sslCrtContent,omitempty"`
+	SslKeyContent string    `json:"sslKeyContent,omitempty"`
+}
+
+func (self *Cert) IsImport() bool {
+	return self.CA == CertTypeImport
+}
+
+// RequireEmail ZeroSSL 没有 EAB 帐号时需要通过邮箱获取，续期时没有邮箱会失败
+func (self *Cert) RequireEmail() bool {
+	return self.Email == "" && strings.HasPrefix(self.Server, certServerDirectory["zerossl"])
+}
+
+// NeedRenew 到达续期时间并且开启了自动续期
+func (self *Cert) NeedRenew() bool {
+	if self.IsImport() || !self.AutoRenew || self.NotAfter.IsZero() {
+		return false
+	}
+	return !time.Now().Before(self.renewTime())
+}
+
+func (self *Cert) FillCertContent() {
+	if content, err := os.ReadFile(filepath.Join(self.GetRootPath(), certFileName)); err == nil {
+		self.SslCrtContent = string(content)
+	}
+	if content, err := os.ReadFile(filepath.Join(self.GetRootPath(), fmt.Sprintf(keyFileName, function.SafeFileName(self.MainDomain)))); err == nil {
+		self.SslKeyContent = string(content)
+	}
+}
+
+func (self *Cert) GetRootPath() string {
+	return self.RootPath + "_ecc"
+}
+
+// renewTime 证书有效期剩余三分之一时续期，90 天的证书在签发 60 天后续期
+func (self *Cert) renewTime() time.Time {
+	return self.NotAfter.Add(-self.NotAfter.Sub(self.NotBefore) / 3)
+}
+
+// fill 通过证书内容补全域名及有效期
+func (self *Cert) fill(chain []byte) error {
+	block, _ := pem.Decode(chain)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return errors.New("invalid cert file")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+	if len(cert.DNSNames) <= 0 {
+		return function.ErrorMessage(define.ErrorMessageSiteDomainCertHasNotDNSName)
+	}
+	if self.MainDomain == "" {
+		self.MainDomain = cert.DNSNames[0]
+	}
+	self.Domain = append([]string{self.MainDomain}, function.PluckArrayWalk(cert.DNSNames, func(i string) (string, bool) {
+		return i, i != self.MainDomain
+	})...)
+	self.Issuer = strings.Join(cert.Issuer.Organization, ",")
+	if self.Issuer == "" {
+		self.Issuer = cert.Issuer.CommonName
+	}
+	if self.CA == "" {
+		self.CA = self.Issuer
+	}
+	self.NotBefore = cert.NotBefore
+	self.NotAfter = cert.NotAfter
+	self.DaysLeft = int(math.Floor(time.Until(cert.NotAfter).Hours() / 24))
+	self.CreatedAt = cert.NotBefore.Format(time.RFC3339)
+	self.RenewAt = cert.NotAfter.Format(time.RFC3339)
+	if !self.IsImport() {
+		self.RenewAt = self.renewTime().Format(time.RFC3339)
+	}
+	self.Success = true
+	return nil
+}
+
+// ParseCertFile 解析任意位置的证书文件，如站点手动填写的证书路径
+func ParseCertFile(path string) (*Cert, error) {
+	chain, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cert := &Cert{
+		CA: CertTypeImport,
+	}
+	if err = cert.fill(chain); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// List 读取 configHome 下的全部证书，旧版 acme.sh 签发的证书通过证书文件及 .conf 补全信息
+func (self *Acme) List() ([]*Cert, error) {
+	entries, err := os.ReadDir(self.configHome)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]*Cert, 0), nil
+		}
+		return nil, err
+	}
+	result := make([]*Cert, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), "_ecc") {
+			continue
+		}
+		cert, err := self.load(strings.TrimSuffix(entry.Name(), "_ecc"))
+		if err != nil {
+			continue
+		}
+		result = append(result, cert)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MainDomain < result[j].MainDomain
+	})
+	return result, nil
+}
+
+func (self *Acme) Info(mainDomain string) (*Cert, error) {
+	cert, err := self.load(mainDomain)
+	if err != nil {
+		return nil, function.ErrorMessage(define.ErrorMessageCommonDataNotFoundOrDeleted)
+	}
+	return cert, nil
+}
+
+func (self *Acme) Remove(mainDomain string) error {
+	cert := &Cert{
+		RootPath: self.rootPath(mainDomain),
+	}
+	return os.RemoveAll(cert.GetRootPath())
+}
+
+// Import 导入已有的证书，不会自动续期
+func (self *Acme) Import(sslCrtContent, sslKeyContent string) (*Cert, error) {
+	cert := &Cert{
+		CA: CertTypeImport,
+	}
+	if err := cert.fill([]byte(sslCrtContent)); err != nil {
+		return nil, err
+	}
+	return self.save(cert.MainDomain, []byte(sslCrtContent), []byte(sslKeyContent), *cert)
+}
+
+// Update 更新证书的元数据，证书及私钥保持不变
+func (self *Acme) Update(cert *Cert) error {
+	metadata := *cert
+	metadata.SslCrtContent = ""
+	metadata.SslKeyContent = ""
+	content, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cert.GetRootPath(), metadataFileName), content, 0644)
+}
+
+func (self *Acme) save(mainDomain string, chain []byte, key []byte, metadata Cert) (*Cert, error) {
+	cert := &metadata
+	cert.RootPath = self.rootPath(mainDomain)
+	cert.MainDomain = mainDomain
+	cert.AutoRenew = !cert.IsImport()
+	cert.LastError = ""
+	if err := cert.fill(chain); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cert.GetRootPath(), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(cert.GetRootPath(), certFileName), chain, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(cert.GetRootPath(), fmt.Sprintf(keyFileName, function.SafeFileName(mainDomain))), key, 0600); err != nil {
+		return nil, err
+	}
+	if err := self.Update(cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+func (self *Acme) load(mainDomain string) (*Cert, error) {
+	cert := &Cert{
+		RootPath: self.rootPath(mainDomain),
+	}
+	chain, err := os.ReadFile(filepath.Join(cert.GetRootPath(), certFileName))
+	if err != nil {
+		return nil, err
+	}
+	if content, err := os.ReadFile(filepath.Join(cert.GetRootPath(), metadataFileName)); err == nil {
+		if err = json.Unmarshal(content, cert); err != nil {
+			return nil, err
+		}
+		cert.RootPath = self.rootPath(cert.MainDomain)
+	} else {
+		cert.MainDomain = mainDomain
+		self.loadLegacy(cert)
+	}
+	if err = cert.fill(chain); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// rootPath 域名来自请求参数或证书内容，只能作为 configHome 下的一级目录
+func (self *Acme) rootPath(mainDomain string) string {
+	return filepath.Join(self.configHome, function.SafeFileName(mainDomain))
+}
+
+// loadLegacy 读取 acme.sh 保存的 {mainDomain}.conf，迁移后由面板继续续期
+func (self *Acme) loadLegacy(cert *Cert) {
+	conf, err := readLegacyConf(self.legacyConfPath(cert))
+	if err != nil {
+		return
+	}
+	if conf["Le_API"] == CertTypeImport {
+		cert.CA = CertTypeImport
+		return
+	}
+	cert.Server = conf["Le_API"]
+	cert.DnsApi = conf["Le_Webroot"]
+	if strings.HasPrefix(cert.DnsApi, "/") {
+		cert.DnsApi = DnsApiNginx
+	}
+	cert.AutoRenew = cert.Server != ""
+	cert.Email = self.LegacyEmail(cert)
+}
+
+// LegacyEmail acme.sh 的邮箱记录在帐号配置中，优先使用证书对应 CA 的 ca.conf，其次使用 account.conf
+// 不是 acme.sh 签发的证书返回空
+func (self *Acme) LegacyEmail(cert *Cert) string {
+	if cert.Server == "" || cert.IsImport() {
+		return ""
+	}
+	if _, err := os.Stat(self.legacyConfPath(cert)); err != nil {
+		return ""
+	}
+	// 与 acme.sh 一致，CA 目录为 ca/{host}/{path}
+	if serverUrl, err := url.Parse(cert.Server); err == nil && serverUrl.Host != "" {
+		caPath := filepath.Join(self.configHome, "ca", function.SafeFileName(serverUrl.Host),
+			filepath.FromSlash(strings.Trim(path.Clean("/"+serverUrl.Path), "/")), "ca.conf")
+		if conf, err := readLegacyConf(caPath); err == nil && conf["CA_EMAIL"] != "" {
+			return conf["CA_EMAIL"]
+		}
+	}
+	if conf, err := readLegacyConf(filepath.Join(self.configHome, "account.conf")); err == nil {
+		return conf["ACCOUNT_EMAIL"]
+	}
+	return ""
+}
+
+func (self *Acme) legacyConfPath(cert *Cert) string {
+	return filepath.Join(cert.GetRootPath(), function.SafeFileName(cert.MainDomain)+".conf")
+}
+
+func readLegacyConf(file string) (map[string]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	conf := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok {
+			conf[strings.TrimSpace(k)] = strings.Trim(v, "'\"")
+		}
+	}
+	return conf, nil
+}
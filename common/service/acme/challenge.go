@@ -0,0 +1,91 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/common/function"
+)
+
+const (
+	ChallengeTypeHttp = "http-01"
+	ChallengeTypeDns  = "dns-01"
+	DnsApiNginx       = "nginx"
+)
+
+var ErrAddTxtRecord = errors.New("error adding TXT record to domain")
+
+type Solver interface {
+	Type() string
+	Present(ctx context.Context, domain, token, keyAuth string) error
+	CleanUp(ctx context.Context, domain, token, keyAuth string) error
+}
+
+// httpSolver 将验证文件写入 nginx 的 webroot，由 /.well-known/acme-challenge/ 对外提供
+type httpSolver struct {
+	webroot string
+}
+
+func (self *httpSolver) Type() string {
+	return ChallengeTypeHttp
+}
+
+func (self *httpSolver) Present(ctx context.Context, domain, token, keyAuth string) error {
+	filePath := self.path(token)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, []byte(keyAuth), 0644)
+}
+
+func (self *httpSolver) CleanUp(ctx context.Context, domain, token, keyAuth string) error {
+	return os.Remove(self.path(token))
+}
+
+func (self *httpSolver) path(token string) string {
+	return filepath.Join(self.webroot, ".well-known", "acme-challenge", function.SafeFileName(token))
+}
+
+// dnsSolver 通过 dns 服务商接口添加 _acme-challenge 记录，添加后等待记录生效
+type dnsSolver struct {
+	provider DnsProvider
+	acme     *Acme
+}
+
+func (self *dnsSolver) Type() string {
+	return ChallengeTypeDns
+}
+
+func (self *dnsSolver) Present(ctx context.Context, domain, token, keyAuth string) error {
+	fqdn := self.fqdn(domain)
+	if err := self.provider.AddTxt(ctx, fqdn, keyAuth); err != nil {
+		return errors.Join(ErrAddTxtRecord, err)
+	}
+	self.acme.log("TXT record %s added, waiting for dns propagation", fqdn)
+	// 超时后继续验证，由 ACME 服务器给出最终结果
+	deadline := time.Now().Add(self.acme.dnsPropagationTimeout)
+	for time.Now().Before(deadline) {
+		if values, err := net.DefaultResolver.LookupTXT(ctx, fqdn); err == nil && function.InArray(values, keyAuth) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second * 5):
+		}
+	}
+	return nil
+}
+
+func (self *dnsSolver) CleanUp(ctx context.Context, domain, token, keyAuth string) error {
+	return self.provider.RemoveTxt(ctx, self.fqdn(domain), keyAuth)
+}
+
+func (self *dnsSolver) fqdn(domain string) string {
+	return "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+}
@@ -0,0 +1,93 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DnsProvider 添加及删除 TXT 记录，fqdn 不包含末尾的点
+type DnsProvider interface {
+	AddTxt(ctx context.Context, fqdn, value string) error
+	RemoveTxt(ctx context.Context, fqdn, value string) error
+}
+
+// SupportedDnsApi 内置客户端支持的 dns api，acme.sh 中的其它服务商需要重新选择后申请
+var SupportedDnsApi = []string{"dns_ali", "dns_tencent", "dns_huaweicloud", "dns_cf"}
+
+var ErrDnsApiNotSupported = errors.New("is not supported")
+
+// NewDnsProvider name 及 env 与 acme.sh 的 dns api 保持一致，已保存的帐号可以直接使用
+func NewDnsProvider(name string, env map[string]string) (DnsProvider, error) {
+	required := func(keys ...string) error {
+		for _, key := range keys {
+			if env[key] == "" {
+				return fmt.Errorf("dns api %s requires %s", name, key)
+			}
+		}
+		return nil
+	}
+	switch name {
+	case "dns_ali":
+		if err := required("Ali_Key", "Ali_Secret"); err != nil {
+			return nil, err
+		}
+		return &dnsAli{
+			accessKeyId:     env["Ali_Key"],
+			accessKeySecret: env["Ali_Secret"],
+		}, nil
+	case "dns_tencent":
+		if err := required("Tencent_SecretId", "Tencent_SecretKey"); err != nil {
+			return nil, err
+		}
+		return &dnsTencent{
+			secretId:  env["Tencent_SecretId"],
+			secretKey: env["Tencent_SecretKey"],
+		}, nil
+	case "dns_huaweicloud":
+		if err := required("HUAWEICLOUD_Username", "HUAWEICLOUD_Password", "HUAWEICLOUD_DomainName"); err != nil {
+			return nil, err
+		}
+		return &dnsHuaweiCloud{
+			username:   env["HUAWEICLOUD_Username"],
+			password:   env["HUAWEICLOUD_Password"],
+			domainName: env["HUAWEICLOUD_DomainName"],
+		}, nil
+	case "dns_cf":
+		if env["CF_Token"] == "" {
+			if err := required("CF_Key", "CF_Email"); err != nil {
+				return nil, err
+			}
+		}
+		return &dnsCloudflare{
+			token:  env["CF_Token"],
+			key:    env["CF_Key"],
+			email:  env["CF_Email"],
+			zoneId: env["CF_Zone_ID"],
+		}, nil
+	}
+	return nil, fmt.Errorf("dns api %s %w, supported: %s", name, ErrDnsApiNotSupported, strings.Join(SupportedDnsApi, ", "))
+}
+
+// findZone 从长到短依次尝试 fqdn 的上级域名，返回托管的根域名及主机记录
+func findZone(fqdn string, exists func(zone string) (bool, error)) (zone string, rr string, err error) {
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+	for i := 1; i < len(labels)-1; i++ {
+		candidate := strings.Join(labels[i:], ".")
+		ok, err := exists(candidate)
+		if err != nil {
+			return "", "", err
+		}
+		if ok {
+			return candidate, strings.Join(labels[:i], "."), nil
+		}
+	}
+	return "", "", fmt.Errorf("no dns zone found for %s", fqdn)
+}
+
+var dnsHttpClient = &http.Client{
+	Timeout: time.Second * 30,
+}
@@ -0,0 +1,170 @@
+package acme
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dnsAli 阿里云解析 RPC 接口
+type dnsAli struct {
+	accessKeyId     string
+	accessKeySecret string
+}
+
+func (self *dnsAli) AddTxt(ctx context.Context, fqdn, value string) error {
+	zone, rr, err := self.zone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	return self.request(ctx, "AddDomainRecord", map[string]string{
+		"DomainName": zone,
+		"RR":         rr,
+		"Type":       "TXT",
+		"Value":      value,
+	}, nil)
+}
+
+func (self *dnsAli) RemoveTxt(ctx context.Context, fqdn, value string) error {
+	zone, rr, err := self.zone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		DomainRecords struct {
+			Record []struct {
+				RecordId string `json:"RecordId"`
+				RR       string `json:"RR"`
+				Value    string `json:"Value"`
+			} `json:"Record"`
+		} `json:"DomainRecords"`
+	}{}
+	err = self.request(ctx, "DescribeDomainRecords", map[string]string{
+		"DomainName":  zone,
+		"RRKeyWord":   rr,
+		"TypeKeyWord": "TXT",
+		"PageSize":    "100",
+	}, &result)
+	if err != nil {
+		return err
+	}
+	for _, item := range result.DomainRecords.Record {
+		if item.RR != rr || item.Value != value {
+			continue
+		}
+		if err = self.request(ctx, "DeleteDomainRecord", map[string]string{
+			"RecordId": item.RecordId,
+		}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *dnsAli) zone(ctx context.Context, fqdn string) (string, string, error) {
+	return findZone(fqdn, func(zone string) (bool, error) {
+		result := struct {
+			Domains struct {
+				Domain []struct {
+					DomainName string `json:"DomainName"`
+				} `json:"Domain"`
+			} `json:"Domains"`
+		}{}
+		err := self.request(ctx, "DescribeDomains", map[string]string{
+			"KeyWord":    zone,
+			"SearchMode": "EXACT",
+		}, &result)
+		if err != nil {
+			return false, err
+		}
+		for _, item := range result.Domains.Domain {
+			if item.DomainName == zone {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func (self *dnsAli) request(ctx context.Context, action string, params map[string]string, result any) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	query := map[string]string{
+		"Action":           action,
+		"Format":           "JSON",
+		"Version":          "2015-01-09",
+		"AccessKeyId":      self.accessKeyId,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   hex.EncodeToString(nonce),
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	for k, v := range params {
+		query[k] = v
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://alidns.aliyuncs.com/?"+self.sign(query), nil)
+	if err != nil {
+		return err
+	}
+	response, err := dnsHttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		errResult := struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		}{}
+		_ = json.Unmarshal(body, &errResult)
+		return fmt.Errorf("alidns %s: %s %s", action, errResult.Code, errResult.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
+
+// sign RPC 接口签名，返回附加了 Signature 的请求参数
+func (self *dnsAli) sign(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	canonicalized := make([]string, 0, len(keys))
+	for _, k := range keys {
+		canonicalized = append(canonicalized, self.encode(k)+"="+self.encode(query[k]))
+	}
+	canonicalizedQuery := strings.Join(canonicalized, "&")
+	mac := hmac.New(sha1.New, []byte(self.accessKeySecret+"&"))
+	mac.Write([]byte("GET&%2F&" + self.encode(canonicalizedQuery)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return canonicalizedQuery + "&Signature=" + self.encode(signature)
+}
+
+func (self *dnsAli) encode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
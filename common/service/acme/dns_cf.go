@@ -0,0 +1,140 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const dnsCloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+
+// dnsCloudflare 优先使用 API Token，未设置时使用 Global API Key
+type dnsCloudflare struct {
+	token  string
+	key    string
+	email  string
+	zoneId string
+}
+
+func (self *dnsCloudflare) AddTxt(ctx context.Context, fqdn, value string) error {
+	zoneId, err := self.getZoneId(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	return self.request(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", zoneId), map[string]any{
+		"type":    "TXT",
+		"name":    fqdn,
+		"content": value,
+		"ttl":     120,
+	}, nil)
+}
+
+func (self *dnsCloudflare) RemoveTxt(ctx context.Context, fqdn, value string) error {
+	zoneId, err := self.getZoneId(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	records := make([]struct {
+		Id      string `json:"id"`
+		Content string `json:"content"`
+	}, 0)
+	err = self.request(ctx, http.MethodGet, fmt.Sprintf("/zones/%s/dns_records?", zoneId)+url.Values{
+		"type": []string{"TXT"},
+		"name": []string{fqdn},
+	}.Encode(), nil, &records)
+	if err != nil {
+		return err
+	}
+	for _, item := range records {
+		// 部分情况下返回的内容会包含引号
+		if strings.Trim(item.Content, "\"") != value {
+			continue
+		}
+		if err = self.request(ctx, http.MethodDelete, fmt.Sprintf("/zones/%s/dns_records/%s", zoneId, item.Id), nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *dnsCloudflare) getZoneId(ctx context.Context, fqdn string) (string, error) {
+	if self.zoneId != "" {
+		return self.zoneId, nil
+	}
+	_, _, err := findZone(fqdn, func(zone string) (bool, error) {
+		zones := make([]struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		}, 0)
+		err := self.request(ctx, http.MethodGet, "/zones?"+url.Values{
+			"name": []string{zone},
+		}.Encode(), nil, &zones)
+		if err != nil {
+			return false, err
+		}
+		for _, item := range zones {
+			if item.Name == zone {
+				self.zoneId = item.Id
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return self.zoneId, err
+}
+
+func (self *dnsCloudflare) request(ctx context.Context, method string, uri string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, dnsCloudflareEndpoint+uri, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if self.token != "" {
+		request.Header.Set("Authorization", "Bearer "+self.token)
+	} else {
+		request.Header.Set("X-Auth-Key", self.key)
+		request.Header.Set("X-Auth-Email", self.email)
+	}
+	response, err := dnsHttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	content := struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}{}
+	if err = json.NewDecoder(response.Body).Decode(&content); err != nil {
+		return err
+	}
+	if !content.Success {
+		message := make([]string, 0, len(content.Errors))
+		for _, item := range content.Errors {
+			message = append(message, fmt.Sprintf("%d %s", item.Code, item.Message))
+		}
+		return fmt.Errorf("cloudflare %s %s: %s", method, uri, strings.Join(message, ", "))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content.Result, result)
+}
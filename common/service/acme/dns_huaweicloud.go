@@ -0,0 +1,213 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/donknap/dpanel/common/function"
+)
+
+const (
+	dnsHuaweiCloudIam      = "https://iam.myhuaweicloud.com/v3/auth/tokens"
+	dnsHuaweiCloudEndpoint = "https://dns.ap-southeast-1.myhuaweicloud.com"
+)
+
+// dnsHuaweiCloud 华为云解析，使用 IAM 用户密码获取令牌
+// 同名的 TXT 记录只能有一条记录集，泛域名与主域名同时验证时追加到已有的记录集中
+type dnsHuaweiCloud struct {
+	username   string
+	password   string
+	domainName string
+	token      string
+}
+
+type dnsHuaweiCloudRecordSet struct {
+	Id      string   `json:"id,omitempty"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Ttl     int      `json:"ttl"`
+	Records []string `json:"records"`
+}
+
+func (self *dnsHuaweiCloud) AddTxt(ctx context.Context, fqdn, value string) error {
+	zoneId, err := self.zoneId(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	recordSet, err := self.recordSet(ctx, zoneId, fqdn)
+	if err != nil {
+		return err
+	}
+	record := strconv.Quote(value)
+	if recordSet == nil {
+		return self.request(ctx, http.MethodPost, fmt.Sprintf("/v2/zones/%s/recordsets", zoneId), dnsHuaweiCloudRecordSet{
+			Name:    fqdn + ".",
+			Type:    "TXT",
+			Ttl:     1,
+			Records: []string{record},
+		}, nil)
+	}
+	if function.InArray(recordSet.Records, record) {
+		return nil
+	}
+	recordSet.Records = append(recordSet.Records, record)
+	return self.request(ctx, http.MethodPut, fmt.Sprintf("/v2/zones/%s/recordsets/%s", zoneId, recordSet.Id), recordSet, nil)
+}
+
+func (self *dnsHuaweiCloud) RemoveTxt(ctx context.Context, fqdn, value string) error {
+	zoneId, err := self.zoneId(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	recordSet, err := self.recordSet(ctx, zoneId, fqdn)
+	if err != nil || recordSet == nil {
+		return err
+	}
+	record := strconv.Quote(value)
+	recordSet.Records = function.PluckArrayWalk(recordSet.Records, func(i string) (string, bool) {
+		return i, i != record
+	})
+	if len(recordSet.Records) == 0 {
+		return self.request(ctx, http.MethodDelete, fmt.Sprintf("/v2/zones/%s/recordsets/%s", zoneId, recordSet.Id), nil, nil)
+	}
+	return self.request(ctx, http.MethodPut, fmt.Sprintf("/v2/zones/%s/recordsets/%s", zoneId, recordSet.Id), recordSet, nil)
+}
+
+func (self *dnsHuaweiCloud) zoneId(ctx context.Context, fqdn string) (string, error) {
+	zoneId := ""
+	_, _, err := findZone(fqdn, func(zone string) (bool, error) {
+		result := struct {
+			Zones []struct {
+				Id   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"zones"`
+		}{}
+		err := self.request(ctx, http.MethodGet, "/v2/zones?"+url.Values{
+			"type": []string{"public"},
+			"name": []string{zone},
+		}.Encode(), nil, &result)
+		if err != nil {
+			return false, err
+		}
+		for _, item := range result.Zones {
+			if item.Name == zone+"." {
+				zoneId = item.Id
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return zoneId, err
+}
+
+func (self *dnsHuaweiCloud) recordSet(ctx context.Context, zoneId, fqdn string) (*dnsHuaweiCloudRecordSet, error) {
+	result := struct {
+		RecordSets []dnsHuaweiCloudRecordSet `json:"recordsets"`
+	}{}
+	err := self.request(ctx, http.MethodGet, fmt.Sprintf("/v2/zones/%s/recordsets?", zoneId)+url.Values{
+		"type": []string{"TXT"},
+		"name": []string{fqdn + "."},
+	}.Encode(), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range result.RecordSets {
+		if item.Name == fqdn+"." {
+			return &item, nil
+		}
+	}
+	return nil, nil
+}
+
+func (self *dnsHuaweiCloud) login(ctx context.Context) error {
+	if self.token != "" {
+		return nil
+	}
+	body := map[string]any{
+		"auth": map[string]any{
+			"identity": map[string]any{
+				"methods": []string{"password"},
+				"password": map[string]any{
+					"user": map[string]any{
+						"name":     self.username,
+						"password": self.password,
+						"domain": map[string]any{
+							"name": self.domainName,
+						},
+					},
+				},
+			},
+			"scope": map[string]any{
+				"project": map[string]any{
+					"name": "ap-southeast-1",
+				},
+			},
+		},
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, dnsHuaweiCloudIam, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := dnsHttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	self.token = response.Header.Get("X-Subject-Token")
+	if self.token == "" {
+		content, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("huaweicloud login failed: %s", string(content))
+	}
+	return nil
+}
+
+func (self *dnsHuaweiCloud) request(ctx context.Context, method string, uri string, body any, result any) error {
+	if err := self.login(ctx); err != nil {
+		return err
+	}
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, dnsHuaweiCloudEndpoint+uri, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Auth-Token", self.token)
+	response, err := dnsHttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("huaweicloud dns %s %s: %s", method, uri, string(content))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
@@ -0,0 +1,185 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dnsTencentHost    = "dnspod.tencentcloudapi.com"
+	dnsTencentService = "dnspod"
+	dnsTencentVersion = "2021-03-23"
+)
+
+// dnsTencent 腾讯云 DNSPod API 3.0，使用 TC3-HMAC-SHA256 签名
+type dnsTencent struct {
+	secretId  string
+	secretKey string
+}
+
+func (self *dnsTencent) AddTxt(ctx context.Context, fqdn, value string) error {
+	zone, rr, err := self.zone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	return self.request(ctx, "CreateRecord", map[string]any{
+		"Domain":     zone,
+		"SubDomain":  rr,
+		"RecordType": "TXT",
+		"RecordLine": "默认",
+		"Value":      value,
+	}, nil)
+}
+
+func (self *dnsTencent) RemoveTxt(ctx context.Context, fqdn, value string) error {
+	zone, rr, err := self.zone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		RecordList []struct {
+			RecordId uint64 `json:"RecordId"`
+			Value    string `json:"Value"`
+		} `json:"RecordList"`
+	}{}
+	err = self.request(ctx, "DescribeRecordList", map[string]any{
+		"Domain":     zone,
+		"Subdomain":  rr,
+		"RecordType": "TXT",
+	}, &result)
+	if err != nil {
+		if strings.Contains(err.Error(), "ResourceNotFound") {
+			return nil
+		}
+		return err
+	}
+	for _, item := range result.RecordList {
+		if item.Value != value {
+			continue
+		}
+		if err = self.request(ctx, "DeleteRecord", map[string]any{
+			"Domain":   zone,
+			"RecordId": item.RecordId,
+		}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *dnsTencent) zone(ctx context.Context, fqdn string) (string, string, error) {
+	return findZone(fqdn, func(zone string) (bool, error) {
+		result := struct {
+			DomainList []struct {
+				Name string `json:"Name"`
+			} `json:"DomainList"`
+		}{}
+		err := self.request(ctx, "DescribeDomainList", map[string]any{
+			"Keyword": zone,
+		}, &result)
+		if err != nil {
+			if strings.Contains(err.Error(), "ResourceNotFound") {
+				return false, nil
+			}
+			return false, err
+		}
+		for _, item := range result.DomainList {
+			if item.Name == zone {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func (self *dnsTencent) request(ctx context.Context, action string, params map[string]any, result any) error {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	contentType := "application/json; charset=utf-8"
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+dnsTencentHost, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Host", dnsTencentHost)
+	request.Header.Set("X-TC-Action", action)
+	request.Header.Set("X-TC-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	request.Header.Set("X-TC-Version", dnsTencentVersion)
+	request.Header.Set("Authorization", self.sign(dnsTencentHost, dnsTencentService, contentType, payload, now))
+	response, err := dnsHttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	body := struct {
+		Response json.RawMessage `json:"Response"`
+	}{}
+	if err = json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return err
+	}
+	errResult := struct {
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}{}
+	if err = json.Unmarshal(body.Response, &errResult); err != nil {
+		return err
+	}
+	if errResult.Error != nil {
+		return fmt.Errorf("dnspod %s: %s %s", action, errResult.Error.Code, errResult.Error.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body.Response, result)
+}
+
+// sign TC3-HMAC-SHA256 签名，返回 Authorization 请求头
+func (self *dnsTencent) sign(host, service, contentType string, payload []byte, now time.Time) string {
+	now = now.UTC()
+	date := now.Format(time.DateOnly)
+
+	hashPayload := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		"content-type:" + contentType + "\nhost:" + host + "\n",
+		"content-type;host",
+		hex.EncodeToString(hashPayload[:]),
+	}, "\n")
+	credentialScope := date + "/" + service + "/tc3_request"
+	hashCanonicalRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"TC3-HMAC-SHA256",
+		strconv.FormatInt(now.Unix(), 10),
+		credentialScope,
+		hex.EncodeToString(hashCanonicalRequest[:]),
+	}, "\n")
+	sign := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+	secretSigning := sign(sign(sign([]byte("TC3"+self.secretKey), date), service), "tc3_request")
+	return fmt.Sprintf(
+		"TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host, Signature=%s",
+		self.secretId, credentialScope, hex.EncodeToString(sign(secretSigning, stringToSign)),
+	)
+}
@@ -0,0 +1,285 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dnsTestRoundTripper func(request *http.Request) (*http.Response, error)
+
+func (self dnsTestRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	return self(request)
+}
+
+// dnsTestServer 拦截 dns api 的请求，交给 handler 处理
+func dnsTestServer(t *testing.T, handler http.HandlerFunc) {
+	transport := dnsHttpClient.Transport
+	dnsHttpClient.Transport = dnsTestRoundTripper(func(request *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		return recorder.Result(), nil
+	})
+	t.Cleanup(func() {
+		dnsHttpClient.Transport = transport
+	})
+}
+
+func TestFindZone(t *testing.T) {
+	tests := []struct {
+		fqdn   string
+		zones  []string
+		zone   string
+		rr     string
+		called []string
+	}{
+		{"_acme-challenge.example.com", []string{"example.com"}, "example.com", "_acme-challenge", []string{"example.com"}},
+		{"_acme-challenge.www.example.com.", []string{"example.com"}, "example.com", "_acme-challenge.www", []string{"www.example.com", "example.com"}},
+		{"_acme-challenge.example.com.cn", []string{"example.com.cn"}, "example.com.cn", "_acme-challenge", []string{"example.com.cn"}},
+		// 子域名单独托管时优先使用子域名
+		{"_acme-challenge.dev.example.com", []string{"example.com", "dev.example.com"}, "dev.example.com", "_acme-challenge", []string{"dev.example.com"}},
+		// 不会查询顶级域名
+		{"_acme-challenge.example.com", []string{"com"}, "", "", []string{"example.com"}},
+	}
+	for _, item := range tests {
+		called := make([]string, 0)
+		zone, rr, err := findZone(item.fqdn, func(zone string) (bool, error) {
+			called = append(called, zone)
+			for _, v := range item.zones {
+				if v == zone {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		if item.zone == "" {
+			if err == nil {
+				t.Errorf("%s: expect error, got %s %s", item.fqdn, zone, rr)
+			}
+		} else if err != nil || zone != item.zone || rr != item.rr {
+			t.Errorf("%s: expect %s %s, got %s %s %v", item.fqdn, item.zone, item.rr, zone, rr, err)
+		}
+		if strings.Join(called, ",") != strings.Join(item.called, ",") {
+			t.Errorf("%s: expect lookup %v, got %v", item.fqdn, item.called, called)
+		}
+	}
+
+	lookupErr := errors.New("lookup failed")
+	if _, _, err := findZone("_acme-challenge.example.com", func(zone string) (bool, error) {
+		return false, lookupErr
+	}); !errors.Is(err, lookupErr) {
+		t.Errorf("expect lookup error, got %v", err)
+	}
+}
+
+// 阿里云 RPC 签名文档中的示例
+func TestDnsAliSign(t *testing.T) {
+	provider := &dnsAli{
+		accessKeyId:     "testid",
+		accessKeySecret: "testsecret",
+	}
+	query := provider.sign(map[string]string{
+		"Timestamp":        "2016-02-23T12:46:24Z",
+		"Format":           "XML",
+		"AccessKeyId":      "testid",
+		"Action":           "DescribeRegions",
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf",
+		"Version":          "2014-05-26",
+		"SignatureVersion": "1.0",
+	})
+	expect := "AccessKeyId=testid&Action=DescribeRegions&Format=XML&SignatureMethod=HMAC-SHA1" +
+		"&SignatureNonce=3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf&SignatureVersion=1.0" +
+		"&Timestamp=2016-02-23T12%3A46%3A24Z&Version=2014-05-26" +
+		"&Signature=OLeaidS1JvxuMvnyHOwuJ%2BuX5qY%3D"
+	if query != expect {
+		t.Fatalf("expect %s, got %s", expect, query)
+	}
+}
+
+// 腾讯云 API 3.0 签名文档中的示例
+func TestDnsTencentSign(t *testing.T) {
+	provider := &dnsTencent{
+		secretId:  "AKIDz8krbsJ5yKBZQpn74WFkmLPx3EXAMPLE",
+		secretKey: "Gu5t9xGARNpq86cd98joQYCN3EXAMPLE",
+	}
+	payload := `{"Limit": 1, "Filters": [{"Values": ["\u672a\u547d\u540d"], "Name": "instance-name"}]}`
+	authorization := provider.sign("cvm.tencentcloudapi.com", "cvm", "application/json; charset=utf-8", []byte(payload), time.Unix(1551113065, 0))
+	expect := "TC3-HMAC-SHA256 Credential=AKIDz8krbsJ5yKBZQpn74WFkmLPx3EXAMPLE/2019-02-25/cvm/tc3_request, " +
+		"SignedHeaders=content-type;host, Signature=72e494ea809ad7a8c8f7a4507b9bddcbaa8e581f516e8da2f66e2c5a96525168"
+	if authorization != expect {
+		t.Fatalf("expect %s, got %s", expect, authorization)
+	}
+}
+
+func TestDnsHuaweiCloud(t *testing.T) {
+	login := 0
+	recordSets := make(map[string]dnsHuaweiCloudRecordSet)
+	actions := make([]string, 0)
+	dnsTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "iam.myhuaweicloud.com" {
+			login++
+			body := struct {
+				Auth struct {
+					Identity struct {
+						Methods  []string `json:"methods"`
+						Password struct {
+							User struct {
+								Name     string `json:"name"`
+								Password string `json:"password"`
+								Domain   struct {
+									Name string `json:"name"`
+								} `json:"domain"`
+							} `json:"user"`
+						} `json:"password"`
+					} `json:"identity"`
+				} `json:"auth"`
+			}{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			user := body.Auth.Identity.Password.User
+			if r.Method != http.MethodPost || r.URL.Path != "/v3/auth/tokens" ||
+				strings.Join(body.Auth.Identity.Methods, ",") != "password" ||
+				user.Name != "user" || user.Password != "password" || user.Domain.Name != "account" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("X-Subject-Token", "token")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/zones":
+			zones := make([]map[string]string, 0)
+			if r.URL.Query().Get("name") == "example.com" {
+				zones = append(zones, map[string]string{"id": "zone", "name": "example.com."})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"zones": zones})
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/zones/zone/recordsets":
+			list := make([]dnsHuaweiCloudRecordSet, 0)
+			if item, ok := recordSets[r.URL.Query().Get("name")]; ok {
+				list = append(list, item)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"recordsets": list})
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/zones/zone/recordsets",
+			r.Method == http.MethodPut && r.URL.Path == "/v2/zones/zone/recordsets/set":
+			item := dnsHuaweiCloudRecordSet{}
+			_ = json.NewDecoder(r.Body).Decode(&item)
+			item.Id = "set"
+			recordSets[item.Name] = item
+			actions = append(actions, r.Method+" "+strings.Join(item.Records, ","))
+		case r.Method == http.MethodDelete && r.URL.Path == "/v2/zones/zone/recordsets/set":
+			delete(recordSets, "_acme-challenge.example.com.")
+			actions = append(actions, r.Method)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	provider, err := NewDnsProvider("dns_huaweicloud", map[string]string{
+		"HUAWEICLOUD_Username":   "user",
+		"HUAWEICLOUD_Password":   "password",
+		"HUAWEICLOUD_DomainName": "account",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	fqdn := "_acme-challenge.example.com"
+	// 泛域名与主域名同时验证时追加到同一个记录集
+	for _, step := range []func() error{
+		func() error { return provider.AddTxt(ctx, fqdn, "a") },
+		func() error { return provider.AddTxt(ctx, fqdn, "b") },
+		func() error { return provider.AddTxt(ctx, fqdn, "b") },
+		func() error { return provider.RemoveTxt(ctx, fqdn, "a") },
+		func() error { return provider.RemoveTxt(ctx, fqdn, "b") },
+	} {
+		if err = step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := []string{`POST "a"`, `PUT "a","b"`, `PUT "b"`, "DELETE"}
+	if strings.Join(actions, "|") != strings.Join(expect, "|") {
+		t.Fatalf("expect %v, got %v", expect, actions)
+	}
+	if login != 1 {
+		t.Fatalf("expect token to be reused, login %d times", login)
+	}
+}
+
+func TestDnsCloudflare(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		header map[string]string
+	}{
+		{"token", map[string]string{"CF_Token": "token"}, map[string]string{"Authorization": "Bearer token"}},
+		{"global key", map[string]string{"CF_Key": "key", "CF_Email": "user@example.com"}, map[string]string{"X-Auth-Key": "key", "X-Auth-Email": "user@example.com"}},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			actions := make([]string, 0)
+			dnsTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range item.header {
+					if r.Header.Get(k) != v {
+						w.WriteHeader(http.StatusForbidden)
+						_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`))
+						return
+					}
+				}
+				var result any
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/client/v4/zones":
+					zones := make([]map[string]string, 0)
+					if r.URL.Query().Get("name") == "example.com" {
+						zones = append(zones, map[string]string{"id": "zone", "name": "example.com"})
+					}
+					result = zones
+				case r.Method == http.MethodPost && r.URL.Path == "/client/v4/zones/zone/dns_records":
+					body := make(map[string]any)
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					actions = append(actions, r.Method+" "+body["type"].(string)+" "+body["name"].(string)+" "+body["content"].(string))
+				case r.Method == http.MethodGet && r.URL.Path == "/client/v4/zones/zone/dns_records":
+					result = []map[string]string{
+						{"id": "other", "content": "other"},
+						{"id": "record", "content": `"value"`},
+					}
+				case r.Method == http.MethodDelete:
+					actions = append(actions, r.Method+" "+r.URL.Path)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "result": result})
+			})
+
+			provider, err := NewDnsProvider("dns_cf", item.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fqdn := "_acme-challenge.www.example.com"
+			if err = provider.AddTxt(context.Background(), fqdn, "value"); err != nil {
+				t.Fatal(err)
+			}
+			if err = provider.RemoveTxt(context.Background(), fqdn, "value"); err != nil {
+				t.Fatal(err)
+			}
+			expect := []string{"POST TXT " + fqdn + " value", "DELETE /client/v4/zones/zone/dns_records/record"}
+			if strings.Join(actions, "|") != strings.Join(expect, "|") {
+				t.Fatalf("expect %v, got %v", expect, actions)
+			}
+		})
+	}
+
+	if _, err := NewDnsProvider("dns_cf", map[string]string{"CF_Key": "key"}); err == nil {
+		t.Fatal("global key requires email")
+	}
+}
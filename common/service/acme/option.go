@@ -1,111 +1,108 @@
 package acme
 
 import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/donknap/dpanel/common/function"
 )
 
 type Option func(self *Acme) error
 
 func WithDomain(list ...string) Option {
-	if function.IsEmptyArray(list) {
-		return nil
-	}
 	return func(self *Acme) error {
 		for _, d := range list {
-			self.argv = append(self.argv, "--domain", d)
+			if d = strings.TrimSpace(d); d != "" && !function.InArray(self.domain, d) {
+				self.domain = append(self.domain, d)
+			}
 		}
 		return nil
 	}
 }
 
+// WithCertServer 支持 acme.sh 中的服务器简称或是 ACME 目录地址
 func WithCertServer(server string) Option {
 	if server == "" {
-		server = "letsencrypt"
+		server = DefaultCertServer
 	}
 	return func(self *Acme) error {
-		self.argv = append(self.argv, "--server", server)
-		return nil
-	}
-}
-
-func WithEmail(email string) Option {
-	return func(self *Acme) error {
-		self.argv = append(self.argv, "--email", email)
-		return nil
-	}
-}
-
-func WithAutoUpgrade(b bool) Option {
-	return func(self *Acme) error {
-		if b {
-			self.argv = append(self.argv, "--auto-upgrade", "1")
+		if v, ok := certServerDirectory[strings.ToLower(server)]; ok {
+			self.server = v
 		} else {
-			self.argv = append(self.argv, "--auto-upgrade", "0")
+			self.server = server
 		}
 		return nil
 	}
 }
 
-func WithForce() Option {
+func WithEmail(email string) Option {
 	return func(self *Acme) error {
-		self.argv = append(self.argv, "--force")
+		self.email = email
 		return nil
 	}
 }
 
+// WithDnsNginx 通过 nginx 的 webroot 完成 HTTP-01 验证
 func WithDnsNginx() Option {
-	return func(self *Acme) error {
-		self.argv = append(self.argv, "--webroot", "/var/www/challenges")
-		return nil
+	webroot := DefaultWebroot
+	if override := os.Getenv(EnvOverrideWebroot); override != "" {
+		webroot = override
 	}
+	return WithHttpWebroot(webroot)
 }
 
-func WithRenew() Option {
+func WithHttpWebroot(path string) Option {
 	return func(self *Acme) error {
-		self.argv = append(self.argv, "--renew")
-		return nil
-	}
-}
-
-func WithIssue() Option {
-	return func(self *Acme) error {
-		self.argv = append(self.argv, "--issue")
-		return nil
-	}
-}
-
-func WithCertRootPath(path string) Option {
-	return func(self *Acme) error {
-		self.argv = append(self.argv, "--key-file", path, "--fullchain-file", path)
+		self.solver = &httpSolver{
+			webroot: path,
+		}
+		self.dnsApi = DnsApiNginx
 		return nil
 	}
 }
 
+// WithDnsApi 通过 dns 服务商的接口完成 DNS-01 验证，env 为 acme.sh 中对应的环境变量，格式为 name=value
 func WithDnsApi(apiType string, env []string) Option {
 	return func(self *Acme) error {
-		self.argv = append(self.argv, "--dns", apiType)
-		self.env = append(self.env, env...)
+		values := make(map[string]string)
+		for _, item := range env {
+			if k, v, ok := strings.Cut(item, "="); ok {
+				values[k] = v
+			}
+		}
+		provider, err := NewDnsProvider(apiType, values)
+		if err != nil {
+			return err
+		}
+		self.solver = &dnsSolver{
+			provider: provider,
+			acme:     self,
+		}
+		self.dnsApi = apiType
 		return nil
 	}
 }
 
-func WithConfigHomePath(path string) Option {
+func WithDnsPropagationTimeout(timeout time.Duration) Option {
 	return func(self *Acme) error {
-		self.argv = append(self.argv, "--config-home", path)
+		self.dnsPropagationTimeout = timeout
 		return nil
 	}
 }
 
-func WithDebug() Option {
+func WithConfigHomePath(path string) Option {
 	return func(self *Acme) error {
-		self.argv = append(self.argv, "--debug")
+		self.configHome = path
 		return nil
 	}
 }
 
-func WithReloadCommand(file string) Option {
+// WithOutput 申请过程的日志写入 w
+func WithOutput(w io.Writer) Option {
 	return func(self *Acme) error {
-		self.argv = append(self.argv, "--reloadcmd", file)
+		self.output = w
 		return nil
 	}
 }
@@ -115,7 +112,11 @@ func WithEabAccount(kid, hmacKey string) Option {
 		if kid == "" || hmacKey == "" {
 			return nil
 		}
-		self.argv = append(self.argv, "--eab-kid", kid, "--eab-hmac-key", hmacKey)
+		eab, err := newEab(kid, hmacKey)
+		if err != nil {
+			return err
+		}
+		self.eab = eab
 		return nil
 	}
 }
@@ -0,0 +1,250 @@
+package alert
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/metrics"
+	"github.com/donknap/dpanel/common/service/notice"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
+)
+
+var Engine = NewEngine()
+
+func NewEngine() *engine {
+	return &engine{
+		rules: make([]*entity.AlertRule, 0),
+		state: make(map[string]*State),
+	}
+}
+
+// State 规则在某个容器上的运行状态，只保存在内存中
+type State struct {
+	RuleId        int32     `json:"ruleId"`
+	DockerEnvName string    `json:"dockerEnvName"`
+	Container     string    `json:"container"`
+	Name          string    `json:"name"`
+	Value         float64   `json:"value"`
+	Firing        bool      `json:"firing"`
+	PendingAt     time.Time `json:"pendingAt,omitempty"`
+	FiredAt       time.Time `json:"firedAt,omitempty"`
+	NotifiedAt    time.Time `json:"notifiedAt,omitempty"`
+	ResolvingAt   time.Time `json:"resolvingAt,omitempty"`
+	seenAt        time.Time
+	notified      bool // 本次触发是否已经通知，未通知时恢复也不通知
+}
+
+type engine struct {
+	mu    sync.Mutex
+	rules []*entity.AlertRule
+	state map[string]*State // ruleId:env:container
+}
+
+// Reload 规则变更后需要重新加载，已删除或禁用的规则的状态一并清理
+func (self *engine) Reload() error {
+	list, err := dao.AlertRule.Find()
+	if err != nil {
+		return err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.rules = function.PluckArrayWalk(list, func(item *entity.AlertRule) (*entity.AlertRule, bool) {
+		return item, item.Setting != nil && item.Setting.Enable
+	})
+	for key, item := range self.state {
+		if !function.InArrayWalk(self.rules, func(rule *entity.AlertRule) bool {
+			return rule.ID == item.RuleId
+		}) {
+			delete(self.state, key)
+		}
+	}
+	return nil
+}
+
+func (self *engine) GetState(ruleId int32) []State {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := make([]State, 0)
+	for _, item := range self.state {
+		if item.RuleId == ruleId {
+			result = append(result, *item)
+		}
+	}
+	return result
+}
+
+// Evaluate 作为采样的处理函数，对当前环境的全部规则进行判断
+func (self *engine) Evaluate(sample *metrics.Sample) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, rule := range self.rules {
+		if rule.Setting.DockerEnvName != "" && rule.Setting.DockerEnvName != sample.DockerEnvName {
+			continue
+		}
+		for _, point := range sample.Point {
+			if !match(rule.Setting, point) {
+				continue
+			}
+			key := fmt.Sprintf("%d:%s:%s", rule.ID, sample.DockerEnvName, point.Container)
+			item, ok := self.state[key]
+			if !ok {
+				item = &State{
+					RuleId:        rule.ID,
+					DockerEnvName: sample.DockerEnvName,
+					Container:     point.Container,
+				}
+				self.state[key] = item
+			}
+			item.Name = point.Name
+			item.Value = value(rule.Setting.Metric, point)
+			item.seenAt = sample.Time
+			self.transition(rule, item, sample.Time)
+		}
+		// 容器已经停止或删除，直接视为恢复
+		for key, item := range self.state {
+			if item.RuleId == rule.ID && item.DockerEnvName == sample.DockerEnvName && item.seenAt != sample.Time {
+				if item.Firing {
+					self.resolve(rule, item)
+				}
+				delete(self.state, key)
+			}
+		}
+	}
+}
+
+func (self *engine) transition(rule *entity.AlertRule, item *State, now time.Time) {
+	cooldown := time.Duration(rule.Setting.Cooldown) * time.Second
+	if !compare(rule.Setting.Operator, item.Value, rule.Setting.Threshold) {
+		item.PendingAt = time.Time{}
+		if !item.Firing {
+			return
+		}
+		// 持续恢复正常后才视为恢复，避免指标在阈值附近波动时反复触发
+		if item.ResolvingAt.IsZero() {
+			item.ResolvingAt = now
+		}
+		if now.Sub(item.ResolvingAt) >= rule.Setting.GetResolveDuration() {
+			self.resolve(rule, item)
+		}
+		return
+	}
+	item.ResolvingAt = time.Time{}
+	if item.PendingAt.IsZero() {
+		item.PendingAt = now
+	}
+	if !item.Firing {
+		if now.Sub(item.PendingAt) < time.Duration(rule.Setting.Duration)*time.Second {
+			return
+		}
+		item.Firing = true
+		item.FiredAt = now
+		// 恢复后在冷却时间内再次触发，不重复通知
+		if cooldown > 0 && !item.NotifiedAt.IsZero() && now.Sub(item.NotifiedAt) < cooldown {
+			return
+		}
+		item.NotifiedAt = now
+		item.notified = true
+		self.fire(rule, item)
+		return
+	}
+	// 持续异常时，冷却时间过后再次通知
+	if cooldown > 0 && now.Sub(item.NotifiedAt) >= cooldown {
+		item.NotifiedAt = now
+		item.notified = true
+		self.fire(rule, item)
+	}
+}
+
+func (self *engine) fire(rule *entity.AlertRule, item *State) {
+	slog.Info("alert firing", "rule", rule.Title, "env", item.DockerEnvName, "container", item.Name, "value", item.Value)
+	content := fmt.Sprintf("%s %s %s %s %s", item.Name, rule.Setting.Metric, format(rule.Setting.Metric, item.Value), rule.Setting.Operator, format(rule.Setting.Metric, rule.Setting.Threshold))
+	go func() {
+		_ = notice.Message{}.Warning(".alertFiring", "title", rule.Title, "env", item.DockerEnvName, "container", item.Name, "value", format(rule.Setting.Metric, item.Value))
+	}()
+	facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+		Event:   define.NotificationEventAlertFiring,
+		Subject: fmt.Sprintf("[%s] %s", item.DockerEnvName, rule.Title),
+		Content: content,
+	})
+}
+
+func (self *engine) resolve(rule *entity.AlertRule, item *State) {
+	notified := item.notified
+	item.Firing = false
+	item.PendingAt = time.Time{}
+	item.ResolvingAt = time.Time{}
+	item.notified = false
+	slog.Info("alert resolved", "rule", rule.Title, "env", item.DockerEnvName, "container", item.Name)
+	if !rule.Setting.NotifyResolve || !notified {
+		return
+	}
+	go func() {
+		_ = notice.Message{}.Success(".alertResolved", "title", rule.Title, "env", item.DockerEnvName, "container", item.Name)
+	}()
+	facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+		Event:   define.NotificationEventAlertResolved,
+		Subject: fmt.Sprintf("[%s] %s", item.DockerEnvName, rule.Title),
+		Content: fmt.Sprintf("%s %s resolved, current %s", item.Name, rule.Setting.Metric, format(rule.Setting.Metric, item.Value)),
+	})
+}
+
+func match(setting *accessor.AlertRuleSettingOption, point *metrics.Point) bool {
+	if !function.IsEmptyArray(setting.ContainerName) && !function.InArray(setting.ContainerName, point.Name) {
+		return false
+	}
+	for _, label := range setting.Label {
+		v, ok := point.Labels[label.Name]
+		if !ok || (label.Value != "" && v != label.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func value(metric string, point *metrics.Point) float64 {
+	switch metric {
+	case accessor.AlertMetricCpu:
+		return point.Cpu
+	case accessor.AlertMetricMemory:
+		return point.MemoryPercent()
+	case accessor.AlertMetricMemoryUsage:
+		return point.Memory
+	case accessor.AlertMetricNetworkRx:
+		return point.NetworkRx
+	case accessor.AlertMetricNetworkTx:
+		return point.NetworkTx
+	case accessor.AlertMetricBlockRead:
+		return point.BlockRead
+	case accessor.AlertMetricBlockWrite:
+		return point.BlockWrite
+	}
+	return 0
+}
+
+func compare(operator string, value, threshold float64) bool {
+	if operator == accessor.AlertOperatorLessThan {
+		return value < threshold
+	}
+	return value > threshold
+}
+
+func format(metric string, value float64) string {
+	switch metric {
+	case accessor.AlertMetricCpu, accessor.AlertMetricMemory:
+		return fmt.Sprintf("%.2f%%", value)
+	case accessor.AlertMetricMemoryUsage:
+		return units.BytesSize(value)
+	default:
+		return units.BytesSize(value) + "/s"
+	}
+}
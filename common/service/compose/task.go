@@ -13,8 +13,9 @@ import (
 )
 
 type Task struct {
-	Name    string
-	Project *types.Project
+	Name      string
+	Project   *types.Project
+	DockerSdk *docker.Client // 部署到指定的环境，为空时使用当前环境
 }
 
 func (self Task) Deploy(removeOrphans bool, pullImage bool) (io.ReadCloser, error) {
@@ -59,7 +60,7 @@ func (self Task) Destroy(deleteImage bool, deleteVolume bool) (io.ReadCloser, er
 			for _, linkItem := range serviceItem.ExternalLinks {
 				links := strings.Split(linkItem, ":")
 				if len(links) == 2 {
-					_ = docker.Sdk.Client.NetworkDisconnect(docker.Sdk.Ctx, item.Name, links[0], true)
+					_ = self.sdk().Client.NetworkDisconnect(self.sdk().Ctx, item.Name, links[0], true)
 				}
 			}
 		}
@@ -126,7 +127,7 @@ func (self Task) runCommand(command []string) (io.ReadCloser, error) {
 		}
 	}
 	cmd = append(cmd, command...)
-	exec, err := docker.Sdk.Compose(cmd...)
+	exec, err := self.sdk().Compose(cmd...)
 	if err != nil {
 		return nil, err
 	}
@@ -153,3 +154,10 @@ func (self Task) GetService(name string) (types.ServiceConfig, ExtService, error
 	}
 	return service, ExtService{}, nil
 }
+
+func (self Task) sdk() *docker.Client {
+	if self.DockerSdk != nil {
+		return self.DockerSdk
+	}
+	return docker.Sdk
+}
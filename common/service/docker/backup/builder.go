@@ -5,8 +5,11 @@ import (
 	"io/fs"
 	"log/slog"
 
+	"filippo.io/age"
 	"github.com/docker/docker/api/types"
 	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker/backup/repository"
 )
 
 func New(opts ...Option) (*Builder, error) {
@@ -31,6 +34,10 @@ type Builder struct {
 	Reader        *reader
 	ctx           context.Context
 	ctxCancel     context.CancelFunc
+	encryption    *ManifestEncryption
+	recipients    []age.Recipient
+	identity      IdentityFunc
+	repository    *repository.Repository
 }
 
 func (self Builder) Context() context.Context {
@@ -69,13 +76,48 @@ type Manifest struct {
 	Volume     []string             `json:"volume"` // Deprecated: instead VolumeList
 	Network    []string             `json:"network"`
 	VolumeList []ManifestVolumeInfo `json:"volumeList"`
+	Encryption *ManifestEncryption  `json:"encryption,omitempty"` // 为空时快照未加密
+	Compose    *ManifestCompose     `json:"compose,omitempty"`    // 编排项目快照中单独的一项，不包含容器配置
+	Dump       *ManifestDump        `json:"dump,omitempty"`       // 备份前在容器中导出的数据库数据
+	Checksum   map[string]string    `json:"checksum,omitempty"`   // 数据块保存到快照中的内容摘要，旧版本快照为空
+}
+
+// BlobList 返回该项引用的全部数据块
+func (self Manifest) BlobList() []string {
+	result := append([]string{self.Config, self.Image}, self.Network...)
+	result = append(result, self.Volume...)
+	for _, item := range self.VolumeList {
+		result = append(result, item.SavePath)
+	}
+	if self.Compose != nil {
+		result = append(result, self.Compose.Config, self.Compose.Files)
+	}
+	if self.Dump != nil {
+		result = append(result, self.Dump.SavePath)
+	}
+	return function.PluckArrayWalk(result, func(i string) (string, bool) {
+		return i, i != ""
+	})
+}
+
+type ManifestDump struct {
+	Profile  string `json:"profile"`
+	FileName string `json:"fileName"`
+	SavePath string `json:"savePath"`
+}
+
+type ManifestCompose struct {
+	Name   string `json:"name"`
+	Config string `json:"config"` // 编排任务的数据库记录
+	Files  string `json:"files"`  // 任务目录下的 yaml 及 .env 等全部文件
 }
 
 type ManifestVolumeInfo struct {
 	Destination string      `json:"destination"`
 	Source      string      `json:"source"`
 	SavePath    string      `json:"savePath"`
-	Mode        fs.FileMode `json:"type"` // file dir
+	Mode        fs.FileMode `json:"type"`              // file dir
+	Chunked     bool        `json:"chunked,omitempty"` // 增量备份，SavePath 为分块索引
 }
 
 type Info struct {
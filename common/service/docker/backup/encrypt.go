@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"errors"
+	"io"
+
+	"filippo.io/age"
+)
+
+const (
+	EncryptionTypePassphrase = "passphrase"
+	EncryptionTypeX25519     = "x25519"
+)
+
+// ManifestEncryption 记录快照加密使用的密钥，不包含任何密钥内容
+type ManifestEncryption struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`                // 面板中保存的密钥名称
+	Recipient string `json:"recipient,omitempty"` // x25519 公钥
+}
+
+// IdentityFunc 根据快照记录的密钥信息返回解密使用的私钥
+type IdentityFunc func(encryption *ManifestEncryption) ([]age.Identity, error)
+
+var ErrEncryptionIdentityRequired = errors.New("the backup is encrypted, a passphrase or identity is required")
+
+func WithEncryption(encryption *ManifestEncryption, recipients ...age.Recipient) Option {
+	return func(self *Builder) error {
+		if encryption == nil || len(recipients) == 0 {
+			return nil
+		}
+		self.encryption = encryption
+		self.recipients = recipients
+		if self.Writer != nil {
+			self.Writer.encryption, self.Writer.recipients = encryption, recipients
+		}
+		return nil
+	}
+}
+
+func WithIdentity(identity IdentityFunc) Option {
+	return func(self *Builder) error {
+		self.identity = identity
+		if self.Reader != nil {
+			self.Reader.identity = identity
+		}
+		return nil
+	}
+}
+
+func encryptWriter(w io.Writer, recipients []age.Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nopWriteCloser{w}, nil
+	}
+	return age.Encrypt(w, recipients...)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
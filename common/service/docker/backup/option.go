@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/donknap/dpanel/common/service/docker/backup/repository"
 )
 
 type Option func(self *Builder) error
@@ -42,6 +44,10 @@ func WithWriter() Option {
 		self.Writer = &writer{
 			tarPathPrefix: self.tarPathPrefix,
 			file:          file,
+			encryption:    self.encryption,
+			recipients:    self.recipients,
+			repository:    self.repository,
+			checksum:      make(map[string]string),
 		}
 		self.Writer.tarWriter = tar.NewWriter(file)
 		return nil
@@ -59,7 +65,9 @@ func WithReader() Option {
 			return err
 		}
 		self.Reader = &reader{
-			file: file,
+			file:       file,
+			identity:   self.identity,
+			repository: self.repository,
 		}
 		if err != nil {
 			return err
@@ -67,3 +75,17 @@ func WithReader() Option {
 		return nil
 	}
 }
+
+// WithRepository 设置增量备份使用的分块仓库
+func WithRepository(repo *repository.Repository) Option {
+	return func(self *Builder) error {
+		self.repository = repo
+		if self.Writer != nil {
+			self.Writer.repository = repo
+		}
+		if self.Reader != nil {
+			self.Reader.repository = repo
+		}
+		return nil
+	}
+}
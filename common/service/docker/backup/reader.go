@@ -11,7 +11,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"filippo.io/age"
 	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker/backup/repository"
 	"github.com/mholt/archives"
 )
 
@@ -21,8 +23,12 @@ type blobItem struct {
 }
 
 type reader struct {
-	file  *os.File
-	blobs []blobItem
+	file       *os.File
+	blobs      []blobItem
+	encryption *ManifestEncryption
+	identity   IdentityFunc
+	identities []age.Identity
+	repository *repository.Repository
 }
 
 func (self *reader) Info() (*Info, error) {
@@ -83,6 +89,12 @@ func (self *reader) Manifest() ([]Manifest, error) {
 	if function.IsEmptyArray(m) {
 		return nil, errors.New("manifest file not found in archive")
 	}
+	for _, item := range m {
+		if item.Encryption != nil {
+			self.encryption = item.Encryption
+			break
+		}
+	}
 	_, err := self.file.Seek(0, io.SeekStart)
 	if err != nil {
 		return nil, err
@@ -91,6 +103,22 @@ func (self *reader) Manifest() ([]Manifest, error) {
 }
 
 func (self *reader) ReadBlobs(fileName string) (io.Reader, error) {
+	out, err := self.readBlobsRaw(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if self.encryption == nil {
+		return out, nil
+	}
+	identities, err := self.getIdentities()
+	if err != nil {
+		return nil, err
+	}
+	return age.Decrypt(out, identities...)
+}
+
+// readBlobsRaw 返回数据块保存在快照中的原始内容，未解密及解压
+func (self *reader) readBlobsRaw(fileName string) (io.Reader, error) {
 	var index int
 	var ok bool
 	if index, ok = function.IndexArrayWalk(self.blobs, func(i blobItem) bool {
@@ -110,6 +138,61 @@ func (self *reader) ReadBlobs(fileName string) (io.Reader, error) {
 	return tarReader, nil
 }
 
+// ReadVolume 返回挂载数据的 tar 流，增量备份从分块仓库中还原
+func (self *reader) ReadVolume(volume ManifestVolumeInfo) (io.ReadCloser, error) {
+	if !volume.Chunked {
+		out, err := self.ReadBlobs(volume.SavePath)
+		if err != nil {
+			return nil, err
+		}
+		return gzip.NewReader(out)
+	}
+	if self.repository == nil {
+		return nil, errors.New("backup repository not set")
+	}
+	content, err := self.ReadBlobsContent(volume.SavePath)
+	if err != nil {
+		return nil, err
+	}
+	index := &repository.Index{}
+	if err = json.Unmarshal(content, index); err != nil {
+		return nil, err
+	}
+	return self.repository.Reader(index), nil
+}
+
+// ReadDump 返回解压后的数据库导出数据
+func (self *reader) ReadDump(dump ManifestDump) (io.ReadCloser, error) {
+	out, err := self.ReadBlobs(dump.SavePath)
+	if err != nil {
+		return nil, err
+	}
+	return gzip.NewReader(out)
+}
+
+// Encryption 返回快照的加密信息，需要先调用 Manifest
+func (self *reader) Encryption() *ManifestEncryption {
+	return self.encryption
+}
+
+func (self *reader) getIdentities() ([]age.Identity, error) {
+	if self.identities != nil {
+		return self.identities, nil
+	}
+	if self.identity == nil {
+		return nil, ErrEncryptionIdentityRequired
+	}
+	identities, err := self.identity(self.encryption)
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, ErrEncryptionIdentityRequired
+	}
+	self.identities = identities
+	return identities, nil
+}
+
 func (self *reader) ReadBlobsContent(fileName string) ([]byte, error) {
 	out, err := self.ReadBlobs(fileName)
 	if err != nil {
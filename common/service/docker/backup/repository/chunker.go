@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"io"
+)
+
+const (
+	chunkMinSize = 512 << 10
+	chunkMaxSize = 8 << 20
+	chunkAvgBits = 20 // 平均 1M 一个分块
+)
+
+var (
+	chunkMask = uint64(1<<chunkAvgBits-1) << (64 - chunkAvgBits)
+	gearTable = func() (table [256]uint64) {
+		// 固定种子生成，保证不同版本的分块边界一致
+		seed := uint64(0x6470616e656c)
+		for i := range table {
+			seed += 0x9e3779b97f4a7c15
+			z := seed
+			z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+			z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+			table[i] = z ^ (z >> 31)
+		}
+		return table
+	}()
+)
+
+// Chunker 使用 gear hash 按内容切分数据，插入或修改数据只影响附近的分块
+type Chunker struct {
+	reader io.Reader
+	buf    []byte
+	eof    bool
+}
+
+func NewChunker(reader io.Reader) *Chunker {
+	return &Chunker{
+		reader: reader,
+		buf:    make([]byte, 0, chunkMaxSize),
+	}
+}
+
+// Next 返回下一个分块，数据读取完成后返回 io.EOF
+func (self *Chunker) Next() ([]byte, error) {
+	if !self.eof && len(self.buf) < chunkMaxSize {
+		n, err := io.ReadFull(self.reader, self.buf[len(self.buf):chunkMaxSize])
+		self.buf = self.buf[:len(self.buf)+n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			self.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(self.buf) == 0 {
+		return nil, io.EOF
+	}
+	n := self.cut(self.buf)
+	chunk := make([]byte, n)
+	copy(chunk, self.buf[:n])
+	self.buf = self.buf[:copy(self.buf, self.buf[n:])]
+	return chunk, nil
+}
+
+func (self *Chunker) cut(data []byte) int {
+	if len(data) <= chunkMinSize {
+		return len(data)
+	}
+	var hash uint64
+	for i := chunkMinSize; i < len(data); i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunkList(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	chunker := NewChunker(bytes.NewReader(data))
+	result := make([][]byte, 0)
+	for {
+		chunk, err := chunker.Next()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, chunk)
+	}
+}
+
+func chunkIds(list [][]byte) []string {
+	result := make([]string, 0, len(list))
+	for _, chunk := range list {
+		sum := sha256.Sum256(chunk)
+		result = append(result, hex.EncodeToString(sum[:]))
+	}
+	return result
+}
+
+func TestChunkerSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		chunks int // 为 0 时不校验数量
+	}{
+		{"empty", []byte{}, 0},
+		{"small", randomData(1, 1024), 1},
+		{"min size", randomData(2, chunkMinSize), 1},
+		{"large", randomData(3, 24<<20), 0},
+		{"zero", make([]byte, 20<<20), 3},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			list := chunkList(t, item.data)
+			if item.chunks > 0 && len(list) != item.chunks {
+				t.Fatalf("expect %d chunks, got %d", item.chunks, len(list))
+			}
+			for i, chunk := range list {
+				if len(chunk) > chunkMaxSize {
+					t.Errorf("chunk %d exceeds max size: %d", i, len(chunk))
+				}
+				if i < len(list)-1 && len(chunk) <= chunkMinSize {
+					t.Errorf("chunk %d below min size: %d", i, len(chunk))
+				}
+			}
+			if !bytes.Equal(bytes.Join(list, nil), item.data) {
+				t.Fatal("joined chunks do not match the input")
+			}
+		})
+	}
+}
+
+func TestChunkerBoundaryStable(t *testing.T) {
+	data := randomData(10, 32<<20)
+	origin := chunkIds(chunkList(t, data))
+	if len(origin) < 8 {
+		t.Fatalf("expect at least 8 chunks, got %d", len(origin))
+	}
+
+	tests := []struct {
+		name   string
+		offset int
+		insert []byte
+	}{
+		{"head", 0, []byte("dpanel")},
+		{"middle", 12 << 20, randomData(11, 4096)},
+		{"tail", len(data) - 100, []byte{0}},
+		{"large", 20 << 20, randomData(12, 2<<20)},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			changed := make([]byte, 0, len(data)+len(item.insert))
+			changed = append(changed, data[:item.offset]...)
+			changed = append(changed, item.insert...)
+			changed = append(changed, data[item.offset:]...)
+
+			exists := make(map[string]bool)
+			for _, id := range origin {
+				exists[id] = true
+			}
+			added := 0
+			for _, id := range chunkIds(chunkList(t, changed)) {
+				if !exists[id] {
+					added++
+				}
+			}
+			// 插入的数据超过最大分块时会额外产生若干分块
+			limit := 2 + len(item.insert)/chunkMinSize
+			if added > limit {
+				t.Fatalf("expect at most %d new chunks, got %d of %d", limit, added, len(origin))
+			}
+		})
+	}
+}
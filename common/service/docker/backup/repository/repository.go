@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 写入快照时持有读锁，清理时持有写锁，避免清理掉正在写入还未记录引用的分块
+var lock sync.RWMutex
+
+var (
+	encoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	decoder, _ = zstd.NewReader(nil)
+)
+
+// Index 一个数据流切分后的分块列表，按顺序拼接即为原始数据
+type Index struct {
+	Chunks []string `json:"chunks"`
+	Size   int64    `json:"size"`
+}
+
+// Ref 记录一个快照引用的全部分块，清理时没有被任何快照引用的分块会被删除
+type Ref struct {
+	Name   string   `json:"name"`
+	Chunks []string `json:"chunks"`
+}
+
+type PruneResult struct {
+	Chunks int   `json:"chunks"`
+	Size   int64 `json:"size"`
+}
+
+type CheckResult struct {
+	Refs         int      `json:"refs"`
+	Chunks       int      `json:"chunks"`
+	Unreferenced int      `json:"unreferenced"`
+	Missing      []string `json:"missing"`
+	Corrupt      []string `json:"corrupt"`
+	Damaged      []string `json:"damaged"` // 分块丢失或损坏的快照
+}
+
+func New(root string) (*Repository, error) {
+	for _, dir := range []string{"chunks", "refs"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	return &Repository{
+		root: root,
+	}, nil
+}
+
+type Repository struct {
+	root string
+}
+
+// Hold 在写入快照期间阻止清理，写入完成并保存引用后调用返回的函数
+func (self *Repository) Hold() func() {
+	lock.RLock()
+	return lock.RUnlock
+}
+
+// Write 切分数据并保存仓库中不存在的分块，返回分块列表及新增的数据大小
+func (self *Repository) Write(ctx context.Context, reader io.Reader) (index *Index, added int64, err error) {
+	index = &Index{
+		Chunks: make([]string, 0),
+	}
+	chunker := NewChunker(reader)
+	for {
+		if err = ctx.Err(); err != nil {
+			return nil, added, err
+		}
+		chunk, err := chunker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, added, err
+		}
+		sum := sha256.Sum256(chunk)
+		id := hex.EncodeToString(sum[:])
+		index.Chunks = append(index.Chunks, id)
+		index.Size += int64(len(chunk))
+
+		chunkPath := self.chunkPath(id)
+		if _, err := os.Stat(chunkPath); err == nil {
+			continue
+		}
+		content := encoder.EncodeAll(chunk, nil)
+		if err = self.writeFile(chunkPath, content); err != nil {
+			// 分块按内容命名，其它快照同时写入了相同的分块时视为成功
+			if _, statErr := os.Stat(chunkPath); statErr == nil {
+				continue
+			}
+			return nil, added, err
+		}
+		added += int64(len(content))
+	}
+	return index, added, nil
+}
+
+// Reader 按分块列表还原数据，读取时校验每个分块的内容
+func (self *Repository) Reader(index *Index) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		for _, id := range index.Chunks {
+			chunk, err := self.readChunk(id)
+			if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+			if _, err = pw.Write(chunk); err != nil {
+				return
+			}
+		}
+		_ = pw.Close()
+	}()
+	return pr
+}
+
+func (self *Repository) SaveRef(name string, list ...*Index) error {
+	ref := Ref{
+		Name:   name,
+		Chunks: make([]string, 0),
+	}
+	exists := make(map[string]bool)
+	for _, index := range list {
+		for _, id := range index.Chunks {
+			if !exists[id] {
+				exists[id] = true
+				ref.Chunks = append(ref.Chunks, id)
+			}
+		}
+	}
+	content, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	return self.writeFile(self.refPath(name), content)
+}
+
+func (self *Repository) DeleteRef(name string) error {
+	err := os.Remove(self.refPath(name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Prune 删除没有被任何快照引用的分块
+func (self *Repository) Prune(ctx context.Context) (result PruneResult, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	refs, err := self.refs()
+	if err != nil {
+		return result, err
+	}
+	used := make(map[string]bool)
+	for _, ref := range refs {
+		for _, id := range ref.Chunks {
+			used[id] = true
+		}
+	}
+	err = self.walkChunks(func(id string, path string, info fs.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if used[id] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		result.Chunks++
+		result.Size += info.Size()
+		return nil
+	})
+	return result, err
+}
+
+// Check 检查快照引用的分块是否存在，readData 时读取并校验分块内容
+func (self *Repository) Check(ctx context.Context, readData bool) (result CheckResult, err error) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	result.Missing = make([]string, 0)
+	result.Corrupt = make([]string, 0)
+	result.Damaged = make([]string, 0)
+
+	refs, err := self.refs()
+	if err != nil {
+		return result, err
+	}
+	result.Refs = len(refs)
+
+	exists := make(map[string]bool)
+	err = self.walkChunks(func(id string, path string, info fs.FileInfo) error {
+		exists[id] = true
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Chunks = len(exists)
+
+	used := make(map[string]bool)
+	// 同一分块只校验一次，结果在引用之间共享
+	broken := make(map[string]bool)
+	for _, ref := range refs {
+		damaged := false
+		for _, id := range ref.Chunks {
+			if err = ctx.Err(); err != nil {
+				return result, err
+			}
+			if used[id] {
+				damaged = damaged || broken[id]
+				continue
+			}
+			used[id] = true
+			if !exists[id] {
+				broken[id] = true
+				result.Missing = append(result.Missing, id)
+			} else if readData {
+				if _, err := self.readChunk(id); err != nil {
+					broken[id] = true
+					result.Corrupt = append(result.Corrupt, id)
+				}
+			}
+			damaged = damaged || broken[id]
+		}
+		if damaged {
+			result.Damaged = append(result.Damaged, ref.Name)
+		}
+	}
+	for id := range exists {
+		if !used[id] {
+			result.Unreferenced++
+		}
+	}
+	return result, nil
+}
+
+func (self *Repository) readChunk(id string) ([]byte, error) {
+	content, err := os.ReadFile(self.chunkPath(id))
+	if err != nil {
+		return nil, err
+	}
+	chunk, err := decoder.DecodeAll(content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s: %w", id, err)
+	}
+	sum := sha256.Sum256(chunk)
+	if hex.EncodeToString(sum[:]) != id {
+		return nil, fmt.Errorf("chunk %s: checksum mismatch", id)
+	}
+	return chunk, nil
+}
+
+func (self *Repository) refs() ([]Ref, error) {
+	list, err := filepath.Glob(filepath.Join(self.root, "refs", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Ref, 0)
+	for _, path := range list {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		ref := Ref{}
+		if err = json.Unmarshal(content, &ref); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		result = append(result, ref)
+	}
+	return result, nil
+}
+
+func (self *Repository) walkChunks(walk func(id string, path string, info fs.FileInfo) error) error {
+	return filepath.Walk(filepath.Join(self.root, "chunks"), func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".temp") {
+			return nil
+		}
+		return walk(filepath.Base(path), path, info)
+	})
+}
+
+// writeFile 先写入同目录下的临时文件再重命名，避免中断时留下不完整的文件
+// 每次写入使用独立的临时文件，同时写入同一文件时不会互相覆盖
+func (self *Repository) writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.temp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+func (self *Repository) chunkPath(id string) string {
+	return filepath.Join(self.root, "chunks", id[:2], id)
+}
+
+func (self *Repository) refPath(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(self.root, "refs", hex.EncodeToString(sum[:])+".json")
+}
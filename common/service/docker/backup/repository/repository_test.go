@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSnapshot(t *testing.T, repo *Repository, name string, data []byte) (*Index, int64) {
+	t.Helper()
+	index, added, err := repo.Write(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.SaveRef(name, index); err != nil {
+		t.Fatal(err)
+	}
+	return index, added
+}
+
+func readSnapshot(t *testing.T, repo *Repository, index *Index) []byte {
+	t.Helper()
+	reader := repo.Reader(index)
+	defer func() {
+		_ = reader.Close()
+	}()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRepositoryDedup(t *testing.T) {
+	base := randomData(20, 12<<20)
+	modified := append([]byte{}, base...)
+	copy(modified[6<<20:], randomData(21, 1024))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		maxAdded int64 // 第二次快照新增的分块大小上限
+	}{
+		{"same", base, 0},
+		{"modified", modified, chunkMaxSize * 2},
+		{"append", append(append([]byte{}, base...), randomData(22, 1<<20)...), chunkMaxSize * 2},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			repo, err := New(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, firstAdded := writeSnapshot(t, repo, "first", base)
+			second, secondAdded := writeSnapshot(t, repo, "second", item.data)
+			if secondAdded > item.maxAdded || secondAdded >= firstAdded {
+				t.Fatalf("second snapshot added %d bytes, first %d", secondAdded, firstAdded)
+			}
+			if second.Size != int64(len(item.data)) {
+				t.Fatalf("expect size %d, got %d", len(item.data), second.Size)
+			}
+			if !bytes.Equal(readSnapshot(t, repo, first), base) {
+				t.Fatal("first snapshot does not match")
+			}
+			if !bytes.Equal(readSnapshot(t, repo, second), item.data) {
+				t.Fatal("second snapshot does not match")
+			}
+		})
+	}
+}
+
+func TestRepositoryPrune(t *testing.T) {
+	repo, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := randomData(30, 4<<20)
+	keepData := append(append([]byte{}, shared...), randomData(31, 4<<20)...)
+	deleteData := append(append([]byte{}, shared...), randomData(32, 4<<20)...)
+	keep, _ := writeSnapshot(t, repo, "keep", keepData)
+	remove, _ := writeSnapshot(t, repo, "delete", deleteData)
+
+	// 没有删除快照时不清理任何分块
+	result, err := repo.Prune(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Chunks != 0 {
+		t.Fatalf("expect no chunks pruned, got %d", result.Chunks)
+	}
+
+	if err = repo.DeleteRef("delete"); err != nil {
+		t.Fatal(err)
+	}
+	used := make(map[string]bool)
+	for _, id := range keep.Chunks {
+		used[id] = true
+	}
+	expect := make(map[string]bool)
+	for _, id := range remove.Chunks {
+		if !used[id] {
+			expect[id] = true
+		}
+	}
+	result, err = repo.Prune(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expect) == 0 || result.Chunks != len(expect) || result.Size == 0 {
+		t.Fatalf("expect %d chunks pruned, got %d (%d bytes)", len(expect), result.Chunks, result.Size)
+	}
+	for id := range expect {
+		if _, err := os.Stat(repo.chunkPath(id)); !os.IsNotExist(err) {
+			t.Errorf("chunk %s should be pruned", id)
+		}
+	}
+	if !bytes.Equal(readSnapshot(t, repo, keep), keepData) {
+		t.Fatal("kept snapshot does not match after prune")
+	}
+	check, err := repo.Check(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Refs != 1 || check.Unreferenced != 0 || len(check.Damaged) != 0 {
+		t.Fatalf("unexpected check result after prune: %+v", check)
+	}
+}
+
+func TestRepositoryCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		readData bool
+		damage   func(repo *Repository, id string) error
+		missing  int
+		corrupt  int
+		damaged  int
+	}{
+		{"healthy", true, nil, 0, 0, 0},
+		{"missing", false, func(repo *Repository, id string) error {
+			return os.Remove(repo.chunkPath(id))
+		}, 1, 0, 2},
+		{"corrupt", true, func(repo *Repository, id string) error {
+			return os.WriteFile(repo.chunkPath(id), encoder.EncodeAll([]byte("dpanel"), nil), 0o644)
+		}, 0, 1, 2},
+		{"corrupt without reading data", false, func(repo *Repository, id string) error {
+			return os.WriteFile(repo.chunkPath(id), []byte("dpanel"), 0o644)
+		}, 0, 0, 0},
+	}
+	for _, item := range tests {
+		t.Run(item.name, func(t *testing.T) {
+			repo, err := New(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := randomData(40, 4<<20)
+			first, _ := writeSnapshot(t, repo, "first", data)
+			writeSnapshot(t, repo, "second", append(append([]byte{}, data...), randomData(41, 2<<20)...))
+			writeSnapshot(t, repo, "other", randomData(42, 2<<20))
+
+			if item.damage != nil {
+				// 损坏两个快照共用的分块
+				if err = item.damage(repo, first.Chunks[0]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			result, err := repo.Check(context.Background(), item.readData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Refs != 3 {
+				t.Fatalf("expect 3 refs, got %d", result.Refs)
+			}
+			if len(result.Missing) != item.missing || len(result.Corrupt) != item.corrupt || len(result.Damaged) != item.damaged {
+				t.Fatalf("unexpected check result: %+v", result)
+			}
+			for _, name := range result.Damaged {
+				if name == "other" {
+					t.Fatal("snapshot without damaged chunks should not be reported")
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryWriteFile(t *testing.T) {
+	repo, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := randomData(50, 2<<20)
+	first, _ := writeSnapshot(t, repo, "first", data)
+	// 重复写入已存在的分块不报错
+	second, added := writeSnapshot(t, repo, "second", data)
+	if added != 0 || strings.Join(first.Chunks, ",") != strings.Join(second.Chunks, ",") {
+		t.Fatalf("expect existing chunks to be reused, added %d", added)
+	}
+	temp, err := filepath.Glob(filepath.Join(repo.root, "chunks", "*", "*.temp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(temp) != 0 {
+		t.Fatalf("temp files left: %v", temp)
+	}
+}
@@ -0,0 +1,208 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type VerifyResult struct {
+	Blobs int   `json:"blobs"`
+	Size  int64 `json:"size"` // 解压后的数据大小
+}
+
+// Verify 完整读取 manifest 中的全部数据并校验
+// 全部数据块先比对写入时记录的内容摘要，再校验配置类数据的内容哈希、镜像及挂载数据的压缩包及 tar 结构，
+// 加密及分块数据在读取时校验
+func (self *reader) Verify(manifest []Manifest) (result VerifyResult, err error) {
+	for _, item := range manifest {
+		if err = self.verifyChecksum(item); err != nil {
+			return result, err
+		}
+		nameList := append([]string{item.Config}, item.Network...)
+		if item.Compose != nil {
+			nameList = append(nameList, item.Compose.Config)
+			size, err := self.verifyFiles(item.Compose.Files)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", item.Compose.Files, err)
+			}
+			result.Blobs++
+			result.Size += size
+		}
+		if item.Dump != nil {
+			size, err := self.verifyDump(*item.Dump)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", item.Dump.SavePath, err)
+			}
+			result.Blobs++
+			result.Size += size
+		}
+		for _, name := range nameList {
+			if name == "" {
+				continue
+			}
+			content, err := self.ReadBlobsContent(name)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", name, err)
+			}
+			sum := sha256.Sum256(content)
+			if hex.EncodeToString(sum[:]) != path.Base(name) {
+				return result, fmt.Errorf("%s: checksum mismatch", name)
+			}
+			if !json.Valid(content) {
+				return result, fmt.Errorf("%s: invalid json", name)
+			}
+			result.Blobs++
+			result.Size += int64(len(content))
+		}
+
+		if item.Image != "" {
+			size, err := self.verifyImage(item.Image)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", item.Image, err)
+			}
+			result.Blobs++
+			result.Size += size
+		}
+
+		volumeList := item.VolumeList
+		if len(volumeList) == 0 {
+			// 兼容旧的数据
+			for _, name := range item.Volume {
+				volumeList = append(volumeList, ManifestVolumeInfo{
+					SavePath: name,
+				})
+			}
+		}
+		for _, volume := range volumeList {
+			size, err := self.verifyVolume(volume)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", volume.SavePath, err)
+			}
+			result.Blobs++
+			result.Size += size
+		}
+	}
+	return result, nil
+}
+
+// verifyChecksum 旧版本快照没有记录摘要时跳过，记录了摘要时每个数据块都必须存在且一致
+func (self *reader) verifyChecksum(item Manifest) error {
+	if item.Checksum == nil {
+		return nil
+	}
+	for _, name := range item.BlobList() {
+		expected, ok := item.Checksum[name]
+		if !ok {
+			return fmt.Errorf("%s: checksum not recorded in manifest", name)
+		}
+		out, err := self.readBlobsRaw(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		hash := sha256.New()
+		if _, err = io.Copy(hash, out); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if "sha256:"+hex.EncodeToString(hash.Sum(nil)) != expected {
+			return fmt.Errorf("%s: checksum mismatch", name)
+		}
+	}
+	return nil
+}
+
+func (self *reader) verifyImage(name string) (int64, error) {
+	out, err := self.ReadBlobs(name)
+	if err != nil {
+		return 0, err
+	}
+	gzReader, err := gzip.NewReader(out)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = gzReader.Close()
+	}()
+	foundManifest := false
+	size, err := walkTar(gzReader, func(header *tar.Header) {
+		if strings.TrimPrefix(header.Name, "./") == "manifest.json" {
+			foundManifest = true
+		}
+	})
+	if err != nil {
+		return size, err
+	}
+	if !foundManifest {
+		return size, errors.New("manifest.json not found in image archive")
+	}
+	return size, nil
+}
+
+func (self *reader) verifyFiles(name string) (int64, error) {
+	out, err := self.ReadBlobs(name)
+	if err != nil {
+		return 0, err
+	}
+	gzReader, err := gzip.NewReader(out)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = gzReader.Close()
+	}()
+	return walkTar(gzReader, nil)
+}
+
+func (self *reader) verifyDump(dump ManifestDump) (int64, error) {
+	out, err := self.ReadDump(dump)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+	return io.Copy(io.Discard, out)
+}
+
+func (self *reader) verifyVolume(volume ManifestVolumeInfo) (int64, error) {
+	out, err := self.ReadVolume(volume)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+	return walkTar(out, nil)
+}
+
+// walkTar 读取 tar 中的全部内容，返回文件总大小
+func walkTar(reader io.Reader, walk func(header *tar.Header)) (size int64, err error) {
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return size, err
+		}
+		if walk != nil {
+			walk(header)
+		}
+		n, err := io.Copy(io.Discard, tarReader)
+		size += n
+		if err != nil {
+			return size, err
+		}
+	}
+	// 读取 tar 结束标记后的剩余数据，使 gzip 及分块校验覆盖整个数据流
+	n, err := io.Copy(io.Discard, reader)
+	return size + n, err
+}
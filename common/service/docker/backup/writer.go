@@ -4,6 +4,8 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,7 +16,9 @@ import (
 	"strings"
 	"time"
 
+	"filippo.io/age"
 	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker/backup/repository"
 	"github.com/donknap/dpanel/common/service/storage"
 	"github.com/mholt/archives"
 )
@@ -23,6 +27,15 @@ type writer struct {
 	file          *os.File
 	tarPathPrefix string
 	tarWriter     *tar.Writer
+	encryption    *ManifestEncryption
+	recipients    []age.Recipient
+	repository    *repository.Repository
+	checksum      map[string]string // 数据块路径及写入快照的内容摘要
+}
+
+// Encryption 返回快照使用的加密信息，需要写入到 manifest 中
+func (self writer) Encryption() *ManifestEncryption {
+	return self.encryption
 }
 
 func (self writer) WriteBlob(content []byte) (path string, err error) {
@@ -45,6 +58,19 @@ func (self writer) WriteBlobStruct(v interface{}) (path string, err error) {
 	return self.WriteBlob(configContent)
 }
 
+// WriteManifest 写入 manifest.json，每一项记录所引用数据块的内容摘要，校验快照时比对
+func (self writer) WriteManifest(manifest []Manifest) error {
+	for i, item := range manifest {
+		manifest[i].Checksum = make(map[string]string)
+		for _, name := range item.BlobList() {
+			if sum, ok := self.checksum[name]; ok {
+				manifest[i].Checksum[name] = sum
+			}
+		}
+	}
+	return self.WriteConfigFile("manifest.json", manifest)
+}
+
 func (self writer) WriteConfigFile(fileName string, v interface{}) error {
 	content, err := json.Marshal(v)
 	if err != nil {
@@ -86,7 +112,11 @@ func (self writer) WriteBlobReader(sha256 string, reader io.ReadCloser) (path st
 		_ = os.Remove(tempFile.Name())
 	}()
 
-	gzWriter, err := archives.Gz{}.OpenWriter(tempFile)
+	encWriter, err := encryptWriter(tempFile, self.recipients)
+	if err != nil {
+		return path, err
+	}
+	gzWriter, err := archives.Gz{}.OpenWriter(encWriter)
 	if err != nil {
 		return path, err
 	}
@@ -98,6 +128,10 @@ func (self writer) WriteBlobReader(sha256 string, reader io.ReadCloser) (path st
 	if err != nil {
 		return path, err
 	}
+	err = encWriter.Close()
+	if err != nil {
+		return path, err
+	}
 	_, _ = tempFile.Seek(io.SeekStart, 0)
 
 	fileInfo, err := tempFile.Stat()
@@ -114,11 +148,7 @@ func (self writer) WriteBlobReader(sha256 string, reader io.ReadCloser) (path st
 	if err != nil {
 		return path, err
 	}
-	_, err = io.Copy(self.tarWriter, tempFile)
-	if err != nil {
-		return path, err
-	}
-	return strings.TrimLeft(path, self.tarPathPrefix), nil
+	return self.copyBlob(path, tempFile)
 }
 
 func (self writer) WriteBlobFiles(sha256 string, files []archives.FileInfo) (path string, err error) {
@@ -142,7 +172,15 @@ func (self writer) WriteBlobFiles(sha256 string, files []archives.FileInfo) (pat
 		Compression: archives.Gz{},
 		Archival:    archives.Tar{},
 	}
-	err = format.Archive(ctx, tempFile, files)
+	encWriter, err := encryptWriter(tempFile, self.recipients)
+	if err != nil {
+		return path, err
+	}
+	err = format.Archive(ctx, encWriter, files)
+	if err != nil {
+		return path, err
+	}
+	err = encWriter.Close()
 	if err != nil {
 		return path, err
 	}
@@ -161,11 +199,41 @@ func (self writer) WriteBlobFiles(sha256 string, files []archives.FileInfo) (pat
 	if err != nil {
 		return path, err
 	}
-	_, err = io.Copy(self.tarWriter, tempFile)
+	return self.copyBlob(path, tempFile)
+}
+
+// WriteBlobChunks 将数据切分后保存到分块仓库，快照中只保存分块索引
+func (self writer) WriteBlobChunks(ctx context.Context, reader io.ReadCloser) (path string, index *repository.Index, added int64, err error) {
+	defer func() {
+		if reader != nil {
+			_ = reader.Close()
+		}
+	}()
+	if self.repository == nil {
+		return "", nil, 0, errors.New("backup repository not set")
+	}
+	index, added, err = self.repository.Write(ctx, reader)
+	if err != nil {
+		return "", nil, added, err
+	}
+	path, err = self.WriteBlobStruct(index)
 	if err != nil {
+		return "", nil, added, err
+	}
+	return path, index, added, nil
+}
+
+// copyBlob 写入快照的同时计算内容摘要，同名数据块读取时使用第一个，摘要也只记录第一次
+func (self writer) copyBlob(path string, reader io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(self.tarWriter, hash), reader); err != nil {
 		return path, err
 	}
-	return strings.TrimLeft(path, self.tarPathPrefix), nil
+	path = strings.TrimLeft(path, self.tarPathPrefix)
+	if _, ok := self.checksum[path]; !ok && self.checksum != nil {
+		self.checksum[path] = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	}
+	return path, nil
 }
 
 func (self writer) getBlobPath(sha256 string) (p string, err error) {
@@ -30,6 +30,7 @@ func New(opts ...Option) (*Builder, error) {
 		networkingConfig: &network.NetworkingConfig{
 			EndpointsConfig: map[string]*network.EndpointSettings{},
 		},
+		dockerSdk: docker.Sdk,
 	}
 	for _, opt := range opts {
 		err = opt(c)
@@ -46,11 +47,12 @@ type Builder struct {
 	networkingConfig *network.NetworkingConfig
 	platform         *v1.Platform
 	containerName    string
+	dockerSdk        *docker.Client
 }
 
 func (self *Builder) Execute() (response container.CreateResponse, err error) {
-	return docker.Sdk.Client.ContainerCreate(
-		docker.Sdk.Ctx,
+	return self.dockerSdk.Client.ContainerCreate(
+		self.dockerSdk.Ctx,
 		self.containerConfig,
 		self.hostConfig,
 		self.networkingConfig,
@@ -21,6 +21,14 @@ import (
 
 type Option func(builder *Builder) error
 
+// WithDockerSdk 在指定的环境中创建容器，默认为当前环境
+func WithDockerSdk(dockerSdk *docker.Client) Option {
+	return func(self *Builder) error {
+		self.dockerSdk = dockerSdk
+		return nil
+	}
+}
+
 func WithContainerInfo(containerInfo container.InspectResponse) Option {
 	return func(self *Builder) error {
 		if containerInfo.Config != nil {
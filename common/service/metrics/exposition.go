@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const ExpositionContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type family struct {
+	help    string
+	kind    string
+	samples []string
+}
+
+// Exposition 按 Prometheus 文本格式输出指标，同名指标会合并在一起输出
+type Exposition struct {
+	families map[string]*family
+}
+
+func NewExposition() *Exposition {
+	return &Exposition{
+		families: make(map[string]*family),
+	}
+}
+
+// Gauge 添加一个 gauge 指标，labels 按 key, value 成对传入
+func (self *Exposition) Gauge(name string, help string, value float64, labels ...string) {
+	self.add("gauge", name, help, value, labels)
+}
+
+func (self *Exposition) Counter(name string, help string, value float64, labels ...string) {
+	self.add("counter", name, help, value, labels)
+}
+
+func (self *Exposition) add(kind string, name string, help string, value float64, labels []string) {
+	f, ok := self.families[name]
+	if !ok {
+		f = &family{
+			help: help,
+			kind: kind,
+		}
+		self.families[name] = f
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], escapeLabelValue(labels[i+1])))
+	}
+	sample := name
+	if len(pairs) > 0 {
+		sample += "{" + strings.Join(pairs, ",") + "}"
+	}
+	f.samples = append(f.samples, sample+" "+formatValue(value))
+}
+
+func (self *Exposition) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(self.families))
+	for name := range self.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	builder := &strings.Builder{}
+	for _, name := range names {
+		f := self.families[name]
+		_, _ = fmt.Fprintf(builder, "# HELP %s %s\n", name, f.help)
+		_, _ = fmt.Fprintf(builder, "# TYPE %s %s\n", name, f.kind)
+		for _, sample := range f.samples {
+			builder.WriteString(sample)
+			builder.WriteByte('\n')
+		}
+	}
+	n, err := io.WriteString(w, builder.String())
+	return int64(n), err
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/donknap/dpanel/common/dao"
+	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/function"
+)
+
+const (
+	ResolutionRaw    = int32(SampleInterval / time.Second)
+	Resolution5m     = int32(300)
+	Resolution1h     = int32(3600)
+	historyPruneTime = 10 * time.Minute
+)
+
+// 各精度数据的保留时长，超出后删除
+var historyRetention = map[int32]time.Duration{
+	ResolutionRaw: 2 * time.Hour,
+	Resolution5m:  48 * time.Hour,
+	Resolution1h:  30 * 24 * time.Hour,
+}
+
+type HistoryRange struct {
+	Duration   time.Duration
+	Resolution int32
+}
+
+// HistoryRanges 查询范围对应使用的数据精度
+var HistoryRanges = map[string]HistoryRange{
+	"1h":  {Duration: time.Hour, Resolution: ResolutionRaw},
+	"24h": {Duration: 24 * time.Hour, Resolution: Resolution5m},
+	"7d":  {Duration: 7 * 24 * time.Hour, Resolution: Resolution1h},
+}
+
+var History = NewHistory()
+
+func NewHistory() *history {
+	return &history{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// bucket 降采样时累加一个时间段内的数据，时间段结束后写入平均值
+type bucket struct {
+	start time.Time
+	count float64
+	row   entity.Metric
+}
+
+func (self *bucket) add(point *Point) {
+	self.count++
+	self.row.Cpu += point.Cpu
+	self.row.Memory += point.Memory
+	self.row.MemoryLimit = point.MemoryLimit
+	self.row.NetworkRx += point.NetworkRx
+	self.row.NetworkTx += point.NetworkTx
+	self.row.BlockRead += point.BlockRead
+	self.row.BlockWrite += point.BlockWrite
+}
+
+func (self *bucket) average() *entity.Metric {
+	row := self.row
+	row.CreatedAt = self.start
+	if self.count > 0 {
+		row.Cpu /= self.count
+		row.Memory /= self.count
+		row.NetworkRx /= self.count
+		row.NetworkTx /= self.count
+		row.BlockRead /= self.count
+		row.BlockWrite /= self.count
+	}
+	return &row
+}
+
+type history struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket // resolution:env:container
+	prunedAt time.Time
+}
+
+// Write 作为采样的处理函数，保存原始数据并按 5 分钟和 1 小时降采样
+func (self *history) Write(sample *Sample) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	rows := make([]*entity.Metric, 0, len(sample.Point))
+	for _, point := range sample.Point {
+		rows = append(rows, &entity.Metric{
+			DockerEnvName: sample.DockerEnvName,
+			ContainerName: point.Name,
+			Resolution:    ResolutionRaw,
+			Cpu:           point.Cpu,
+			Memory:        point.Memory,
+			MemoryLimit:   point.MemoryLimit,
+			NetworkRx:     point.NetworkRx,
+			NetworkTx:     point.NetworkTx,
+			BlockRead:     point.BlockRead,
+			BlockWrite:    point.BlockWrite,
+			CreatedAt:     sample.Time,
+		})
+		for _, resolution := range []int32{Resolution5m, Resolution1h} {
+			key := fmt.Sprintf("%d:%s:%s", resolution, sample.DockerEnvName, point.Name)
+			start := sample.Time.Truncate(time.Duration(resolution) * time.Second)
+			if b, ok := self.buckets[key]; !ok || !b.start.Equal(start) {
+				self.buckets[key] = &bucket{
+					start: start,
+					row: entity.Metric{
+						DockerEnvName: sample.DockerEnvName,
+						ContainerName: point.Name,
+						Resolution:    resolution,
+					},
+				}
+			}
+			self.buckets[key].add(point)
+		}
+	}
+	// 时间段已经结束的数据写入表中，包括已经不存在的容器
+	for key, b := range self.buckets {
+		if b.row.DockerEnvName != sample.DockerEnvName {
+			continue
+		}
+		if b.start.Add(time.Duration(b.row.Resolution) * time.Second).After(sample.Time) {
+			continue
+		}
+		rows = append(rows, b.average())
+		delete(self.buckets, key)
+	}
+	if !function.IsEmptyArray(rows) {
+		if err := dao.Metric.CreateInBatches(rows, 100); err != nil {
+			slog.Warn("metrics history write", "error", err)
+		}
+	}
+
+	if time.Since(self.prunedAt) > historyPruneTime {
+		self.prunedAt = time.Now()
+		for resolution, keep := range historyRetention {
+			_, _ = dao.Metric.Where(
+				dao.Metric.Resolution.Eq(resolution),
+				dao.Metric.CreatedAt.Lt(time.Now().Add(-keep)),
+			).Delete()
+		}
+	}
+}
+
+// Query 按范围查询历史数据，返回以容器名称分组的数据
+func (self *history) Query(dockerEnvName string, rangeName string, containerName ...string) (map[string][]*entity.Metric, error) {
+	historyRange, ok := HistoryRanges[rangeName]
+	if !ok {
+		return nil, errors.New("unsupported history range: " + rangeName)
+	}
+	query := dao.Metric.Where(
+		dao.Metric.DockerEnvName.Eq(dockerEnvName),
+		dao.Metric.Resolution.Eq(historyRange.Resolution),
+		dao.Metric.CreatedAt.Gte(time.Now().Add(-historyRange.Duration)),
+	)
+	if !function.IsEmptyArray(containerName) {
+		query = query.Where(dao.Metric.ContainerName.In(containerName...))
+	}
+	list, err := query.Order(dao.Metric.CreatedAt.Asc()).Find()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]*entity.Metric)
+	for _, item := range list {
+		result[item.ContainerName] = append(result[item.ContainerName], item)
+	}
+	return result, nil
+}
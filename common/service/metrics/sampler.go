@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/donknap/dpanel/common/service/docker"
+	"github.com/donknap/dpanel/common/service/docker/stats"
+	"github.com/donknap/dpanel/common/service/docker/types"
+	"github.com/donknap/dpanel/common/service/notice"
+)
+
+const (
+	SampleInterval = 15 * time.Second
+)
+
+var Sampler = NewSampler()
+
+// Point 单个容器一次采样的数据，IO 类数据为两次采样之间的每秒速率
+type Point struct {
+	Container   string            `json:"container"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"-"`
+	Cpu         float64           `json:"cpu"`
+	Memory      float64           `json:"memory"`
+	MemoryLimit float64           `json:"memoryLimit"`
+	NetworkRx   float64           `json:"networkRx"`
+	NetworkTx   float64           `json:"networkTx"`
+	BlockRead   float64           `json:"blockRead"`
+	BlockWrite  float64           `json:"blockWrite"`
+}
+
+func (self Point) MemoryPercent() float64 {
+	if self.MemoryLimit <= 0 {
+		return 0
+	}
+	return self.Memory / self.MemoryLimit * 100
+}
+
+type Sample struct {
+	DockerEnvName string    `json:"dockerEnvName"`
+	Time          time.Time `json:"time"`
+	Point         []*Point  `json:"point"`
+}
+
+type Handler func(sample *Sample)
+
+// counter 记录容器上一次采样的累计值，用于计算速率
+type counter struct {
+	time       time.Time
+	networkRx  float64
+	networkTx  float64
+	blockRead  float64
+	blockWrite float64
+}
+
+func NewSampler() *sampler {
+	o := &sampler{
+		handlers: make([]Handler, 0),
+		counters: make(map[string]counter),
+	}
+	o.ctx, o.ctxCancel = context.WithCancel(context.Background())
+	return o
+}
+
+type sampler struct {
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	once      sync.Once
+	mu        sync.Mutex
+	handlers  []Handler
+	counters  map[string]counter
+	latest    sync.Map // 每个环境最近一次的采样 *Sample
+	running   sync.Map // 正在采样的环境，避免上一次未结束时重复采样
+}
+
+// Subscribe 注册采样数据的处理函数，每个环境每次采样完成后都会调用
+func (self *sampler) Subscribe(handler Handler) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.handlers = append(self.handlers, handler)
+}
+
+func (self *sampler) Latest(dockerEnvName string) (*Sample, bool) {
+	if v, ok := self.latest.Load(dockerEnvName); ok {
+		return v.(*Sample), true
+	}
+	return nil, false
+}
+
+func (self *sampler) Start() {
+	self.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(SampleInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-self.ctx.Done():
+					return
+				case <-ticker.C:
+					for name, dockerClient := range notice.Monitor.Clients() {
+						if _, loaded := self.running.LoadOrStore(name, true); loaded {
+							continue
+						}
+						go func(name string, dockerClient *docker.Client) {
+							defer self.running.Delete(name)
+							if err := self.collect(name, dockerClient); err != nil {
+								slog.Debug("metrics sampler collect", "name", name, "error", err)
+							}
+						}(name, dockerClient)
+					}
+				}
+			}
+		}()
+	})
+}
+
+func (self *sampler) Close() {
+	self.ctxCancel()
+}
+
+func (self *sampler) collect(name string, dockerClient *docker.Client) error {
+	ctx, cancel := context.WithTimeout(self.ctx, SampleInterval)
+	defer cancel()
+
+	filter := filters.NewArgs()
+	filter.Add("status", "running")
+	containerList, err := dockerClient.Client.ContainerList(ctx, container.ListOptions{
+		Filters: filter,
+	})
+	if err != nil {
+		return err
+	}
+	labels := function.PluckArrayMapWalk(containerList, func(item container.Summary) (string, map[string]string, bool) {
+		return item.ID, item.Labels, true
+	})
+	usageList := make([]*stats.Usage, 0)
+	if !function.IsEmptyArray(containerList) {
+		result, err := dockerClient.ContainerStats(ctx, types.ContainerStatsOption{
+			Stream:  false,
+			Filters: filter,
+		})
+		if err != nil {
+			return err
+		}
+		usageList = <-result
+	}
+
+	now := time.Now()
+	sample := &Sample{
+		DockerEnvName: name,
+		Time:          now,
+		Point:         make([]*Point, 0, len(usageList)),
+	}
+
+	self.mu.Lock()
+	for _, usage := range usageList {
+		point := &Point{
+			Container:   usage.Container,
+			Name:        strings.TrimPrefix(usage.Name, "/"),
+			Labels:      labels[usage.Container],
+			Cpu:         usage.Cpu,
+			Memory:      usage.Memory.In,
+			MemoryLimit: usage.Memory.Out,
+		}
+		current := counter{
+			time: now,
+		}
+		// stats 中 In 为写入（发送），Out 为读取（接收）
+		if usage.PrevNetworkIO != nil {
+			current.networkTx, current.networkRx = usage.PrevNetworkIO.In, usage.PrevNetworkIO.Out
+		}
+		if usage.PrevBlockIO != nil {
+			current.blockWrite, current.blockRead = usage.PrevBlockIO.In, usage.PrevBlockIO.Out
+		}
+		key := fmt.Sprintf("%s:%s", name, usage.Container)
+		if prev, ok := self.counters[key]; ok {
+			if seconds := now.Sub(prev.time).Seconds(); seconds > 0 {
+				point.NetworkRx = rate(current.networkRx, prev.networkRx, seconds)
+				point.NetworkTx = rate(current.networkTx, prev.networkTx, seconds)
+				point.BlockRead = rate(current.blockRead, prev.blockRead, seconds)
+				point.BlockWrite = rate(current.blockWrite, prev.blockWrite, seconds)
+			}
+		}
+		self.counters[key] = current
+		sample.Point = append(sample.Point, point)
+	}
+	// 清理已经不存在的容器的累计值
+	for key, item := range self.counters {
+		if strings.HasPrefix(key, name+":") && item.time != now {
+			delete(self.counters, key)
+		}
+	}
+	handlers := append([]Handler{}, self.handlers...)
+	self.mu.Unlock()
+
+	self.latest.Store(name, sample)
+	for _, handler := range handlers {
+		handler(sample)
+	}
+	return nil
+}
+
+func rate(current, prev, seconds float64) float64 {
+	// 容器重启后累计值会归零
+	if current < prev {
+		return 0
+	}
+	return (current - prev) / seconds
+}
@@ -0,0 +1,35 @@
+package channel
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/smtp"
+	"strconv"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/jordan-wright/email"
+)
+
+type Email struct {
+	Server *accessor.NotificationEmailServer
+	To     string
+}
+
+func (self Email) Send(ctx context.Context, subject string, content string) error {
+	if self.To == "" {
+		return errors.New("email target is required")
+	}
+	e := email.NewEmail()
+	e.From = self.Server.Email
+	e.Subject = subject
+	e.To = []string{self.To}
+	e.HTML = []byte(content)
+	return e.SendWithTLS(
+		self.Server.Host+":"+strconv.Itoa(self.Server.Port),
+		smtp.PlainAuth("", self.Server.Email, self.Server.Code, self.Server.Host),
+		&tls.Config{
+			ServerName: self.Server.Host, // 必须与证书匹配的域名
+		},
+	)
+}
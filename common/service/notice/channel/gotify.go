@@ -0,0 +1,25 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+type Gotify struct {
+	Url   string
+	Token string
+}
+
+func (self Gotify) Send(ctx context.Context, subject string, content string) error {
+	if self.Url == "" || self.Token == "" {
+		return errors.New("gotify server url and app token are required")
+	}
+	return postJson(ctx, strings.TrimRight(self.Url, "/")+"/message", map[string]string{
+		"X-Gotify-Key": self.Token,
+	}, map[string]interface{}{
+		"title":    subject,
+		"message":  content,
+		"priority": 5,
+	})
+}
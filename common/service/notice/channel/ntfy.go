@@ -0,0 +1,35 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+const ntfyDefaultUrl = "https://ntfy.sh"
+
+type Ntfy struct {
+	Url   string // 自建的 ntfy 地址，为空时使用官方地址
+	Token string
+	Topic string
+}
+
+func (self Ntfy) Send(ctx context.Context, subject string, content string) error {
+	if self.Topic == "" {
+		return errors.New("ntfy topic is required")
+	}
+	server := self.Url
+	if server == "" {
+		server = ntfyDefaultUrl
+	}
+	header := map[string]string{
+		"Content-Type": "text/plain; charset=utf-8",
+	}
+	if subject != "" {
+		header["Title"] = subject
+	}
+	if self.Token != "" {
+		header["Authorization"] = "Bearer " + self.Token
+	}
+	return post(ctx, strings.TrimRight(server, "/")+"/"+self.Topic, header, strings.NewReader(content))
+}
@@ -0,0 +1,21 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+)
+
+// Slack 通过 Incoming Webhook 发送消息
+type Slack struct {
+	Url string
+}
+
+func (self Slack) Send(ctx context.Context, subject string, content string) error {
+	text := content
+	if subject != "" {
+		text = fmt.Sprintf("*%s*\n%s", subject, content)
+	}
+	return postJson(ctx, self.Url, nil, map[string]interface{}{
+		"text": text,
+	})
+}
@@ -0,0 +1,34 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const telegramDefaultUrl = "https://api.telegram.org"
+
+type Telegram struct {
+	Url    string // 自建的 Bot API 地址，为空时使用官方地址
+	Token  string
+	ChatId string
+}
+
+func (self Telegram) Send(ctx context.Context, subject string, content string) error {
+	if self.Token == "" || self.ChatId == "" {
+		return errors.New("telegram bot token and chat id are required")
+	}
+	server := self.Url
+	if server == "" {
+		server = telegramDefaultUrl
+	}
+	text := content
+	if subject != "" {
+		text = subject + "\n" + content
+	}
+	return postJson(ctx, fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(server, "/"), self.Token), nil, map[string]interface{}{
+		"chat_id": self.ChatId,
+		"text":    text,
+	})
+}
@@ -0,0 +1,124 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/types/define"
+)
+
+const (
+	sendTimeout      = 10 * time.Second
+	defaultTemplate  = "{{ .Content }}"
+	webhookTemplate  = `{"event":{{ json .Event }},"subject":{{ json .Subject }},"content":{{ json .Content }},"time":{{ json .Time }}}`
+	maxErrorBodySize = 512
+)
+
+type Channel interface {
+	Send(ctx context.Context, subject string, content string) error
+}
+
+// TemplateData 渲染渠道消息模板时可用的变量
+type TemplateData struct {
+	Event   string `json:"event"`
+	Subject string `json:"subject"`
+	Content string `json:"content"`
+	Time    string `json:"time"`
+}
+
+func New(option accessor.NotificationChannel, emailServer *accessor.NotificationEmailServer) (Channel, error) {
+	switch option.Type {
+	case define.NotificationChannelEmail:
+		if emailServer == nil {
+			return nil, errors.New("email server is not configured")
+		}
+		return Email{Server: emailServer, To: option.Target}, nil
+	case define.NotificationChannelWebhook:
+		return Webhook{Url: option.Url, Header: option.Header}, nil
+	case define.NotificationChannelTelegram:
+		return Telegram{Url: option.Url, Token: option.Token, ChatId: option.Target}, nil
+	case define.NotificationChannelSlack:
+		return Slack{Url: option.Url}, nil
+	case define.NotificationChannelGotify:
+		return Gotify{Url: option.Url, Token: option.Token}, nil
+	case define.NotificationChannelNtfy:
+		return Ntfy{Url: option.Url, Token: option.Token, Topic: option.Target}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification channel: %s", option.Type)
+	}
+}
+
+// Render 使用渠道自身的模板渲染消息内容，未配置模板时按渠道类型使用默认模板
+func Render(option accessor.NotificationChannel, data TemplateData) (string, error) {
+	text := option.Template
+	if text == "" {
+		if option.Type == define.NotificationChannelWebhook {
+			text = webhookTemplate
+		} else {
+			text = defaultTemplate
+		}
+	}
+	tmpl, err := template.New(option.Name).Funcs(template.FuncMap{
+		"json": func(v interface{}) string {
+			b, _ := json.Marshal(v)
+			return string(b)
+		},
+	}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buffer := new(bytes.Buffer)
+	if err = tmpl.Execute(buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func post(ctx context.Context, url string, header map[string]string, body io.Reader) error {
+	if url == "" {
+		return errors.New("notification channel url is required")
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+		return fmt.Errorf("notification channel response %s: %s", response.Status, string(message))
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	return nil
+}
+
+func postJson(ctx context.Context, url string, header map[string]string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		header = make(map[string]string)
+	}
+	header["Content-Type"] = "application/json"
+	return post(ctx, url, header, bytes.NewReader(body))
+}
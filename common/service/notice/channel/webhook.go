@@ -0,0 +1,27 @@
+package channel
+
+import (
+	"context"
+	"strings"
+
+	"github.com/donknap/dpanel/common/service/docker/types"
+)
+
+// Webhook 将渲染后的模板内容原样 POST 到指定地址，默认按 json 发送
+type Webhook struct {
+	Url    string
+	Header []types.ValueItem
+}
+
+func (self Webhook) Send(ctx context.Context, subject string, content string) error {
+	header := map[string]string{
+		"Content-Type": "application/json",
+	}
+	for _, item := range self.Header {
+		if item.Name == "" {
+			continue
+		}
+		header[item.Name] = item.Value
+	}
+	return post(ctx, self.Url, header, strings.NewReader(content))
+}
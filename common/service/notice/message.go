@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/donknap/dpanel/common/dao"
 	"github.com/donknap/dpanel/common/entity"
+	"github.com/donknap/dpanel/common/types/define"
+	"github.com/donknap/dpanel/common/types/event"
+	"github.com/we7coreteam/w7-rangine-go/v2/pkg/support/facade"
 )
 
 var (
@@ -18,6 +22,7 @@ const (
 	TypeError   = "error"
 	TypeInfo    = "info"
 	TypeSuccess = "success"
+	TypeWarning = "warning"
 )
 
 type Message struct {
@@ -35,6 +40,10 @@ func (self Message) Success(title string, message ...string) error {
 	return self.push(TypeSuccess, title, message)
 }
 
+func (self Message) Warning(title string, message ...string) error {
+	return self.push(TypeWarning, title, message)
+}
+
 func (self Message) push(level string, title string, message []string) error {
 	jsonMessage, _ := json.Marshal(message)
 	row := &entity.Notice{
@@ -46,5 +55,12 @@ func (self Message) push(level string, title string, message []string) error {
 	err := dao.Notice.Create(row)
 	fmt.Printf("协程数，%v \n", runtime.NumGoroutine())
 	QueueNoticePushMessage <- row
+	if level == TypeError {
+		facade.GetEvent().Publish(event.NotificationEvent, event.NotificationPayload{
+			Event:   define.NotificationEventNoticeError,
+			Subject: title,
+			Content: strings.Join(message, " "),
+		})
+	}
 	return err
 }
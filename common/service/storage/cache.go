@@ -16,6 +16,8 @@ var (
 	CacheKeyLoginFailed            = "login:failed:%s"
 	CacheKeyOauthState             = "oauth:state:%s"
 	CacheKeyOauthCode              = "oauth:code:%s"
+	CacheKeyOauthOidcDiscovery     = "oauth:oidc:discovery:%s"
+	CacheKeyWebauthnSession        = "webauthn:session:%s"
 	CacheKeySetting                = "setting:%s"
 	CacheKeySettingLocale          = fmt.Sprintf(CacheKeySetting, "locale")
 	CacheKeyContainerUpgrade       = "container:upgrade:%s:%s"
@@ -25,6 +27,12 @@ var (
 	CacheKeyDockerContainerRuntime = "docker:container:runtime:%s:%s"
 	CacheKeyConsoleData            = "console:data:%s" // 用于脚本存储一些自定义数据
 	CacheKeyCronTaskStatus         = "cron:task:status:%d"
+	CacheKeyBackupScheduleStatus   = "backup:schedule:status:%d"
+	CacheKeyPanelBackupStatus      = "panel:backup:status"
+	CacheKeyComposeGitSync         = "compose:git:sync:%d"
+	CacheKeyDeployWebhookRun       = "deploy:webhook:run:%s"
+	CacheKeySiteCertRenew          = "site:cert:renew"
+	CacheKeySiteCertNotified       = "site:cert:notified:%s:%d:%d"
 	CacheKeyDockerEventJob         = "docker:event:%s:%s"
 	CacheKeyRsaKey                 = "rsa:key"
 	CacheKeyRsaPub                 = "rsa:pub"
@@ -69,6 +69,11 @@ func (self Local) GetBackupPath() string {
 	return filepath.Join(self.GetStorageLocalPath(), "backup")
 }
 
+// GetBackupRepositoryPath 增量备份的分块仓库
+func (self Local) GetBackupRepositoryPath() string {
+	return filepath.Join(self.GetBackupPath(), "repository")
+}
+
 func (self Local) GetLocalProxySockPath() string {
 	path := filepath.Join(self.GetStorageLocalPath(), "sock")
 	return path
@@ -90,6 +95,14 @@ func (self Local) GetNginxExtraSettingFilePath(fileName string) string {
 	return function.SafePathJoin(self.GetNginxExtraSettingPath(), fileName)
 }
 
+func (self Local) GetNginxHtpasswdPath() string {
+	return fmt.Sprintf("%s/nginx/htpasswd/", self.GetStorageLocalPath())
+}
+
+func (self Local) GetNginxHtpasswdFilePath(serverName string) string {
+	return function.SafePathJoin(self.GetNginxHtpasswdPath(), serverName)
+}
+
 func (self Local) GetComposeProjectPath(dockerEnvName string, projectName string) string {
 	return function.SafePathJoin(self.GetComposePath(dockerEnvName), projectName)
 }
@@ -0,0 +1,94 @@
+package target
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type s3 struct {
+	root   string
+	bucket string
+	client *minio.Client
+}
+
+func newS3(root string, option *accessor.BackupTargetS3) (*s3, error) {
+	secret := option.SecretAccessKey
+	if v, err := function.RSADecode(secret, nil); err == nil {
+		secret = v
+	}
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(option.Endpoint, "https://"), "http://")
+	bucketLookup := minio.BucketLookupAuto
+	if option.PathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(strings.TrimRight(endpoint, "/"), &minio.Options{
+		Creds:        credentials.NewStaticV4(option.AccessKeyId, secret, ""),
+		Secure:       option.UseSsl,
+		Region:       option.Region,
+		BucketLookup: bucketLookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3{
+		root:   root,
+		bucket: option.Bucket,
+		client: client,
+	}, nil
+}
+
+func (self *s3) Put(ctx context.Context, name string, reader io.Reader, size int64) error {
+	_, err := self.client.PutObject(ctx, self.bucket, join(self.root, name), reader, size, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return err
+}
+
+func (self *s3) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	object, err := self.client.GetObject(ctx, self.bucket, join(self.root, name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject 不会立即请求，这里提前检查文件是否存在
+	if _, err = object.Stat(); err != nil {
+		_ = object.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+func (self *s3) List(ctx context.Context, prefix string) ([]FileInfo, error) {
+	result := make([]FileInfo, 0)
+	root := join(self.root, prefix)
+	if root != "" {
+		root += "/"
+	}
+	for item := range self.client.ListObjects(ctx, self.bucket, minio.ListObjectsOptions{
+		Prefix:    root,
+		Recursive: true,
+	}) {
+		if item.Err != nil {
+			return nil, item.Err
+		}
+		result = append(result, FileInfo{
+			Name:    strings.TrimPrefix(item.Key, strings.TrimLeft(self.root+"/", "/")),
+			Size:    item.Size,
+			ModTime: item.LastModified,
+		})
+	}
+	return result, nil
+}
+
+func (self *s3) Delete(ctx context.Context, name string) error {
+	return self.client.RemoveObject(ctx, self.bucket, join(self.root, name), minio.RemoveObjectOptions{})
+}
+
+func (self *s3) Close() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+package target
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/donknap/dpanel/common/service/ssh"
+)
+
+type sftp struct {
+	root   string
+	client *ssh.Client
+}
+
+func newSftp(root string, option *ssh.ServerInfo) (*sftp, error) {
+	// 相对路径基于登录用户的主目录
+	info := *option
+	client, err := ssh.NewClient(append(ssh.WithServerInfo(&info), ssh.WithSftpClient())...)
+	if err != nil {
+		return nil, err
+	}
+	return &sftp{
+		root:   root,
+		client: client,
+	}, nil
+}
+
+func (self *sftp) Put(ctx context.Context, name string, reader io.Reader, size int64) error {
+	p := join(self.root, name)
+	if err := self.client.SftpConn.MkdirAll(path.Dir(p)); err != nil {
+		return err
+	}
+	file, err := self.client.SftpConn.Create(p)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, reader); err != nil {
+		_ = file.Close()
+		_ = self.client.SftpConn.Remove(p)
+		return err
+	}
+	return file.Close()
+}
+
+func (self *sftp) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return self.client.SftpConn.Open(join(self.root, name))
+}
+
+func (self *sftp) List(ctx context.Context, prefix string) ([]FileInfo, error) {
+	result := make([]FileInfo, 0)
+	root := join(self.root, prefix)
+	if root == "" {
+		root = "."
+	}
+	if _, err := self.client.SftpConn.Stat(root); err != nil {
+		return result, nil
+	}
+	walker := self.client.SftpConn.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+		result = append(result, FileInfo{
+			Name:    strings.TrimPrefix(strings.TrimPrefix(walker.Path(), self.root), "/"),
+			Size:    walker.Stat().Size(),
+			ModTime: walker.Stat().ModTime(),
+		})
+	}
+	return result, nil
+}
+
+func (self *sftp) Delete(ctx context.Context, name string) error {
+	return self.client.SftpConn.Remove(join(self.root, name))
+}
+
+func (self *sftp) Close() error {
+	self.client.Close()
+	return nil
+}
@@ -0,0 +1,66 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+)
+
+// Target 远程备份存储，文件名均为相对于配置根目录的路径，使用 / 分隔
+type Target interface {
+	Put(ctx context.Context, name string, reader io.Reader, size int64) error
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]FileInfo, error)
+	Delete(ctx context.Context, name string) error
+	Close() error
+}
+
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+func New(option accessor.BackupTarget) (Target, error) {
+	root := rootPath(option)
+	switch option.Type {
+	case accessor.BackupTargetTypeS3:
+		if option.S3 == nil {
+			break
+		}
+		return newS3(root, option.S3)
+	case accessor.BackupTargetTypeSftp:
+		if option.Sftp == nil {
+			break
+		}
+		return newSftp(root, option.Sftp)
+	case accessor.BackupTargetTypeWebdav:
+		if option.Webdav == nil {
+			break
+		}
+		return newWebdav(root, option.Webdav)
+	}
+	return nil, errors.New("invalid backup target")
+}
+
+// rootPath 配置的根目录，SFTP 保留绝对路径，相对路径基于登录用户的主目录
+func rootPath(option accessor.BackupTarget) string {
+	if option.Type == accessor.BackupTargetTypeSftp && strings.HasPrefix(option.Path, "/") {
+		return path.Clean(option.Path)
+	}
+	return strings.Trim(path.Clean("/"+option.Path), "/")
+}
+
+// join 拼接远程路径，不允许跳出根目录，根目录为绝对路径时结果也为绝对路径
+func join(root string, name string) string {
+	p := path.Join(root, path.Clean("/"+name))
+	if strings.HasPrefix(root, "/") {
+		return p
+	}
+	return strings.TrimLeft(p, "/")
+}
@@ -0,0 +1,34 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/donknap/dpanel/common/accessor"
+)
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		targetType string
+		path       string
+		name       string
+		expect     string
+	}{
+		{accessor.BackupTargetTypeS3, "", "dpanel/a.tar", "dpanel/a.tar"},
+		{accessor.BackupTargetTypeS3, "/backup/", "dpanel/a.tar", "backup/dpanel/a.tar"},
+		{accessor.BackupTargetTypeWebdav, "backup", "../../a.tar", "backup/a.tar"},
+		{accessor.BackupTargetTypeSftp, "/data/backup", "dpanel/a.tar", "/data/backup/dpanel/a.tar"},
+		{accessor.BackupTargetTypeSftp, "/data/backup", "../../etc/passwd", "/data/backup/etc/passwd"},
+		{accessor.BackupTargetTypeSftp, "/", "a.tar", "/a.tar"},
+		{accessor.BackupTargetTypeSftp, "backup", "a.tar", "backup/a.tar"},
+		{accessor.BackupTargetTypeSftp, "", "a.tar", "a.tar"},
+	}
+	for _, item := range tests {
+		root := rootPath(accessor.BackupTarget{
+			Type: item.targetType,
+			Path: item.path,
+		})
+		if result := join(root, item.name); result != item.expect {
+			t.Errorf("%s %s %s: expect %s, got %s", item.targetType, item.path, item.name, item.expect, result)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package target
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/donknap/dpanel/common/accessor"
+	"github.com/donknap/dpanel/common/function"
+	"github.com/studio-b12/gowebdav"
+)
+
+type webdav struct {
+	root   string
+	client *gowebdav.Client
+}
+
+func newWebdav(root string, option *accessor.BackupTargetWebdav) (*webdav, error) {
+	password := option.Password
+	if v, err := function.RSADecode(password, nil); err == nil {
+		password = v
+	}
+	client := gowebdav.NewClient(option.Url, option.Username, password)
+	client.SetTimeout(30 * time.Minute)
+	return &webdav{
+		root:   root,
+		client: client,
+	}, nil
+}
+
+func (self *webdav) Put(ctx context.Context, name string, reader io.Reader, size int64) error {
+	p := "/" + join(self.root, name)
+	if err := self.client.MkdirAll(path.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return self.client.WriteStream(p, reader, 0o644)
+}
+
+func (self *webdav) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return self.client.ReadStream("/" + join(self.root, name))
+}
+
+func (self *webdav) List(ctx context.Context, prefix string) ([]FileInfo, error) {
+	result := make([]FileInfo, 0)
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		list, err := self.client.ReadDir("/" + join(self.root, dir))
+		if err != nil {
+			if os.IsNotExist(err) || gowebdav.IsErrNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		for _, item := range list {
+			name := path.Join(dir, item.Name())
+			if item.IsDir() {
+				if err = walk(name); err != nil {
+					return err
+				}
+				continue
+			}
+			result = append(result, FileInfo{
+				Name:    name,
+				Size:    item.Size(),
+				ModTime: item.ModTime(),
+			})
+		}
+		return nil
+	}
+	if err := walk(path.Clean("/" + prefix)); err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].Name = path.Clean(result[i].Name)[1:]
+	}
+	return result, nil
+}
+
+func (self *webdav) Delete(ctx context.Context, name string) error {
+	return self.client.Remove("/" + join(self.root, name))
+}
+
+func (self *webdav) Close() error {
+	return nil
+}
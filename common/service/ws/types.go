@@ -20,6 +20,7 @@ const (
 	MessageTypeConsoleShell           = "/console/shell"
 	MessageTypeContainerLog           = "container:log:%s"
 	MessageTypeContainerUpgrade       = "container:upgrade:%s"
+	MessageTypeDeployWebhook          = "deploy:webhook:%s"
 	MessageTypeContainerCommandCreate = "container:command:create:%s"
 	MessageTypeContainerAllStat       = "container:stat"
 	MessageTypeContainerStat          = "container:stat:%s"
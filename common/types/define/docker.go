@@ -32,6 +32,7 @@ const (
 	ContainerBackupStepContainer = "container"
 	ContainerBackupStepVolume    = "volume"
 	ContainerBackupStepStart     = "start"
+	ContainerBackupStepCompose   = "compose"
 )
 
 const (
@@ -28,6 +28,7 @@ const (
 	ErrorMessageContainerCronTaskEmpty                      = ".containerCronTaskEmpty"
 	ErrorMessageContainerBackupImportFileFailed             = ".containerBackupImportFileFailed"
 	ErrorMessageContainerBackupImportFileInCorrect          = ".containerBackupImportFileInCorrect"
+	ErrorMessageContainerBackupIncrementalUnsupported       = ".containerBackupIncrementalUnsupported"
 	ErrorMessageContainerBackupRestoreImportImageFailed     = ".containerBackupRestoreImportImageFailed"
 	ErrorMessageSiteDomainExists                            = ".siteDomainExists"
 	ErrorMessageSiteDomainNotFoundDPanel                    = ".siteDomainNotFoundDPanel"
@@ -36,6 +37,7 @@ const (
 	ErrorMessageSiteDomainCertAddTxtFailed                  = ".siteDomainCertAddTxtFailed"
 	ErrorMessageSiteDomainCertHasBindDomain                 = ".siteDomainCertHasBindDomain"
 	ErrorMessageSiteDomainCertHasNotDNSName                 = ".siteDomainCertHasNotDNSName"
+	ErrorMessageSiteDomainUpstreamEmpty                     = ".siteDomainUpstreamEmpty"
 	ErrorMessageImagePullTagNotFound                        = ".imagePullTagNotFound"
 	ErrorMessageImagePullServerHttp                         = ".imagePullServerHttp"
 	ErrorMessageImagePullRegistryBad                        = ".imagePullRegistryBad"
@@ -52,7 +54,9 @@ const (
 	ErrorMessageSystemEnvNameInvalid                        = ".systemEnvNameInvalid"
 	ErrorMessageSystemStoreNotFoundGit                      = ".systemStoreNotFoundGit"
 	ErrorMessageSystemStoreDownloadFailed                   = ".systemStoreDownloadFailed"
+	ErrorMessageSettingBackupTargetInUse                    = ".settingBackupTargetInUse"
 	ErrorMessageSettingBasicEmailInvalid                    = ".settingBasicEmailInvalid"
+	ErrorMessageSettingNotificationChannelInvalid           = ".settingNotificationChannelInvalid"
 	ErrorMessageUserUsernameOrPasswordError                 = ".usernameOrPasswordError"
 	ErrorMessageUserTwoFaEmpty                              = ".userTwoFaEmpty"
 	ErrorMessageUserTwoFaNotCorrect                         = ".userTwoFaNotCorrect"
@@ -64,6 +68,9 @@ const (
 	ErrorMessageUserFailedLock                              = ".userFailedLock"
 	ErrorMessageUserResetTokenExpire                        = ".userResetTokenExpire"
 	ErrorMessageUserNoPermission                            = ".userNoPermission"
+	ErrorMessageUserUsernameExists                          = ".userUsernameExists"
+	ErrorMessageUserExternalNotBound                        = ".userExternalNotBound"
+	ErrorMessageUserRoleEnvNotFound                         = ".userRoleEnvNotFound"
 	ErrorMessageHomeWsHostConsoleSshNotSetting              = ".homeWsHostConsoleSshNotSetting"
 	ErrorMessageTagUrlNotFound                              = ".tagUrlNotFound"
 )
@@ -0,0 +1,23 @@
+package define
+
+const (
+	NotificationChannelEmail    = "email"
+	NotificationChannelWebhook  = "webhook"
+	NotificationChannelTelegram = "telegram"
+	NotificationChannelSlack    = "slack"
+	NotificationChannelGotify   = "gotify"
+	NotificationChannelNtfy     = "ntfy"
+)
+
+const (
+	NotificationEventNoticeError          = "notice/error"
+	NotificationEventContainerUpgrade     = "container/upgrade"
+	NotificationEventContainerUpgradeFail = "container/upgradeFailed"
+	NotificationEventCronFailed           = "cron/failed"
+	NotificationEventAlertFiring          = "alert/firing"
+	NotificationEventAlertResolved        = "alert/resolved"
+	NotificationEventBackupFailed         = "backup/failed"
+	NotificationEventComposeDeployFailed  = "compose/deployFailed"
+	NotificationEventCertExpiring         = "cert/expiring"
+	NotificationEventCertRenewFailed      = "cert/renewFailed"
+)
@@ -0,0 +1,11 @@
+package define
+
+const (
+	UserRoleAdmin    = "admin"
+	UserRoleOperator = "operator"
+	UserRoleReadonly = "readonly"
+)
+
+const (
+	UserTokenPrefix = "dpt_"
+)
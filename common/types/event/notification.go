@@ -0,0 +1,11 @@
+package event
+
+const (
+	NotificationEvent = "notification"
+)
+
+type NotificationPayload struct {
+	Event   string
+	Subject string
+	Content string
+}
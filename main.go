@@ -142,7 +142,7 @@ func main() {
 		if v := (family.Provider{}).Middleware(); v != nil {
 			httpServer.Use(v...)
 		}
-		httpServer.Use(common2.AuthMiddleware{}.Process, common2.CacheMiddleware{}.Process)
+		httpServer.Use(common2.AuditMiddleware{}.Process, common2.AuthMiddleware{}.Process, common2.CacheMiddleware{}.Process)
 		httpServer.RegisterRouters(
 			func(engine *gin.Engine) {
 				subFs, _ := fs.Sub(Asset, "asset/static")
@@ -211,6 +211,11 @@ func initDb() error {
 		&entity.Store{},
 		&entity.Cron{},
 		&entity.CronLog{},
+		&entity.AlertRule{},
+		&entity.Metric{},
+		&entity.UserToken{},
+		&entity.AuditLog{},
+		&entity.BackupSchedule{},
 	)
 	if err != nil {
 		return err
@@ -221,6 +226,7 @@ func initDb() error {
 		&migrate.Upgrade20250113{},
 		&migrate.Upgrade20250401{},
 		&migrate.Upgrade20250521{},
+		&migrate.Upgrade20261018{},
 	}
 	for _, updater := range migrateTableData {
 		slog.Info("main", "migrate", updater.Version())